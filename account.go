@@ -237,3 +237,26 @@ func (app *App) AccountUpdateInfo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 }
+
+// AccountDelete moves the requesting user's account to the trash, it can be restored by an admin
+// until the trash is purged
+func (app *App) AccountDelete(w http.ResponseWriter, r *http.Request) {
+	user, status, err := app.SessionUser(r)
+	if err != nil {
+		WriteResponseError(w, status, err)
+		return
+	}
+
+	if app.isAdmin(user) {
+		WriteResponse(w, http.StatusForbidden, "the admin account can't be deleted")
+		return
+	}
+
+	err = app.Storage.TrashUser(user.ID)
+	if err != nil {
+		WriteResponseError(w, http.StatusInternalServerError, errors.Wrap(err, "failed to delete user"))
+		return
+	}
+
+	WriteResponse(w, http.StatusOK, "account deleted")
+}
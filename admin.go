@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+
+	"github.com/Southclaws/samp-objects-api/storage"
+	"github.com/Southclaws/samp-objects-api/types"
+)
+
+// AdminDedupReport handles the /admin/dedup endpoint, it reports how many bytes are saved by
+// objects sharing identical files
+func (app *App) AdminDedupReport(w http.ResponseWriter, r *http.Request) {
+	report, err := app.Storage.DedupReport()
+	if err != nil {
+		WriteResponseError(w, http.StatusInternalServerError, errors.Wrap(err, "failed to calculate deduplication report"))
+		return
+	}
+
+	payload, err := json.Marshal(report)
+	if err != nil {
+		WriteResponseError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(payload)
+}
+
+// AdminTrash handles the /admin/trash endpoint, it lists every trashed object, comment and user
+func (app *App) AdminTrash(w http.ResponseWriter, r *http.Request) {
+	trash, err := app.Storage.GetTrash()
+	if err != nil {
+		WriteResponseError(w, http.StatusInternalServerError, errors.Wrap(err, "failed to get trash"))
+		return
+	}
+	for i := range trash.Users {
+		trash.Users[i].Password = ""
+	}
+
+	payload, err := json.Marshal(trash)
+	if err != nil {
+		WriteResponseError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(payload)
+}
+
+// AdminUserTrash handles the DELETE /admin/users/{userid} endpoint, it moves a user to the trash
+func (app *App) AdminUserTrash(w http.ResponseWriter, r *http.Request) {
+	app.adminUserTrash(w, r, false)
+}
+
+// AdminUserRestore handles the POST /admin/users/{userid}/restore endpoint, it takes a user back
+// out of the trash
+func (app *App) AdminUserRestore(w http.ResponseWriter, r *http.Request) {
+	app.adminUserTrash(w, r, true)
+}
+
+func (app *App) adminUserTrash(w http.ResponseWriter, r *http.Request, restore bool) {
+	userID := types.UserID(mux.Vars(r)["userid"])
+	if err := userID.Validate(); err != nil {
+		WriteResponseError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if !restore && userID == app.adminID {
+		WriteResponse(w, http.StatusForbidden, "the admin account can't be trashed")
+		return
+	}
+
+	var err error
+	if restore {
+		err = app.Storage.RestoreUser(userID)
+	} else {
+		err = app.Storage.TrashUser(userID)
+	}
+	if err != nil {
+		if err == storage.ErrNotFound {
+			WriteResponse(w, http.StatusNotFound, "user not found or already in that state")
+			return
+		}
+		WriteResponseError(w, http.StatusInternalServerError, errors.Wrap(err, "failed to update user"))
+		return
+	}
+
+	WriteResponse(w, http.StatusOK, "user updated")
+}
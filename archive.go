@@ -0,0 +1,279 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/Southclaws/samp-objects-api/artconfig"
+	"github.com/Southclaws/samp-objects-api/storage"
+	"github.com/Southclaws/samp-objects-api/types"
+)
+
+// maxArchiveEntries is the most files an uploaded archive can hold
+const maxArchiveEntries = 256
+
+// maxArchiveConfigSize is the largest artconfig.txt that is read from an uploaded archive
+const maxArchiveConfigSize = 64 * 1024
+
+// maxArchiveRatio is how many times its compressed size an entry of an uploaded archive can unpack
+// to, anything more is treated as a zip bomb. Entries up to minArchiveRatioSize can have any ratio
+// as a small file of zeros compresses far better than that.
+const (
+	maxArchiveRatio     = 100
+	minArchiveRatioSize = 64 * 1024
+)
+
+// ObjectArchive handles the /object/archive endpoint, it creates an object from one multipart
+// request instead of the prepare, upload and finish steps. The "object" part holds the object's
+// details as JSON and must come before the "archive" part, which is a ZIP of the object's models,
+// textures, images and optionally an artconfig.txt.
+func (app *App) ObjectArchive(w http.ResponseWriter, r *http.Request) {
+	mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || !strings.HasPrefix(mediaType, "multipart/") {
+		WriteResponseError(w, http.StatusBadRequest, errors.New("expected a multipart request"))
+		return
+	}
+	mr := multipart.NewReader(r.Body, params["boundary"])
+
+	p, err := mr.NextPart()
+	if err != nil || p.FormName() != "object" {
+		WriteResponseError(w, http.StatusBadRequest, errors.New("first part of the request must be the object"))
+		return
+	}
+	var object types.Object
+	err = json.NewDecoder(p).Decode(&object)
+	if err != nil {
+		WriteResponseError(w, http.StatusBadRequest, errors.Wrap(err, "failed to decode object"))
+		return
+	}
+	object, ok := app.newObject(w, r, object)
+	if !ok {
+		return
+	}
+
+	p, err = mr.NextPart()
+	if err != nil || p.FormName() != "archive" {
+		WriteResponseError(w, http.StatusBadRequest, errors.New("second part of the request must be the archive"))
+		return
+	}
+
+	// the directory of a ZIP is at the end so the archive is spooled to disk, the entries are then
+	// streamed from there into the store one at a time
+	spool, err := ioutil.TempFile("", "samp-objects-archive-")
+	if err != nil {
+		WriteResponseError(w, http.StatusInternalServerError, errors.Wrap(err, "failed to create temporary file"))
+		return
+	}
+	defer func() {
+		spool.Close()
+		os.Remove(spool.Name())
+	}()
+
+	size, err := io.Copy(spool, &limitedReader{p, "archive", app.archiveLimit, app.archiveLimit})
+	if err != nil {
+		WriteResponseError(w, http.StatusRequestEntityTooLarge, err)
+		return
+	}
+	archive, err := zip.NewReader(spool, size)
+	if err != nil {
+		WriteResponseError(w, http.StatusBadRequest, errors.Wrap(err, "failed to read archive"))
+		return
+	}
+
+	logger.Debug("unpacking object archive",
+		zap.String("objectid", string(object.ID)),
+		zap.Int("entries", len(archive.File)))
+
+	// nothing references the files that were stored until the object is created, so they're
+	// removed again if anything goes wrong before then
+	var created bool
+	version, err := app.unpackArchive(object.ID, archive)
+	defer func() {
+		if !created {
+			app.discardFiles(object.ID, version.Files)
+		}
+	}()
+	if err != nil {
+		WriteResponseError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	object.Images = version.Images
+	object.Models = version.Models
+	object.Textures = version.Textures
+	object.Files = version.Files
+	if err = object.Validate(); err != nil {
+		WriteResponseError(w, http.StatusUnprocessableEntity, errors.Wrap(err, "object not valid"))
+		return
+	}
+
+	now := time.Now()
+	version.Version = 1
+	version.Published = now
+	object.Created = now
+	object.Versions = []types.ObjectVersion{version}
+
+	err = app.Storage.CreateObject(object)
+	if err == storage.ErrObjectNameAlreadyExists {
+		WriteResponseError(w, http.StatusConflict, errors.New("object name already in use by user"))
+		return
+	} else if err != nil {
+		WriteResponseError(w, http.StatusInternalServerError, errors.Wrap(err, "failed to create object"))
+		return
+	}
+	created = true
+
+	payload, err := json.Marshal(object)
+	if err != nil {
+		WriteResponseError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	w.Write(payload)
+}
+
+// discardFiles removes the files stored for an object that won't be created, a failure is only
+// logged as the request has already failed
+func (app *App) discardFiles(objectID types.ObjectID, files []types.FileInfo) {
+	err := app.Storage.DiscardObjectFiles(objectID, files)
+	if err != nil {
+		logger.Error("failed to remove files of failed upload",
+			zap.Error(err),
+			zap.String("objectid", string(objectID)))
+	}
+}
+
+// unpackArchive stores every file in an archive the same way as files uploaded one at a time and
+// returns them as the first version of the object. Folders are flattened, so two entries with the
+// same name in different folders are rejected along with entries that try to leave the archive.
+// Everything that can be checked from the archive's directory and the artconfig.txt is checked
+// before any file is stored, on an error the files that were stored are still returned.
+func (app *App) unpackArchive(objectID types.ObjectID, archive *zip.Reader) (version types.ObjectVersion, err error) {
+	files, err := app.checkArchive(archive)
+	if err != nil {
+		return
+	}
+
+	unpacked := &limitedReader{nil, "unpacked archive", app.archiveUnpackedLimit, app.archiveUnpackedLimit}
+	for _, f := range files {
+		name := path.Base(f.Name)
+
+		var rc io.ReadCloser
+		rc, err = f.Open()
+		if err != nil {
+			return version, errors.Wrapf(err, "failed to open %s", name)
+		}
+		limit := archiveRatioLimit(f)
+		unpacked.r = &limitedReader{rc, name, limit, limit}
+
+		var file types.ObjectFile
+		file, err = app.storeFile(objectID, name, unpacked)
+		rc.Close()
+		if err != nil {
+			return
+		}
+
+		switch file.Type {
+		case "image":
+			version.Images = append(version.Images, types.File(file.Name))
+		case "model":
+			version.Models = append(version.Models, types.File(file.Name))
+		case "texture":
+			version.Textures = append(version.Textures, types.File(file.Name))
+		}
+		version.Files = append(version.Files, file.Info)
+	}
+	return
+}
+
+// checkArchive returns the entries of an archive that are stored, after checking their paths,
+// names and sizes and that the artconfig.txt, if there is one, only uses files in the archive. The
+// sizes in the archive are only used to give up early, the limits are enforced again on the bytes
+// that are actually unpacked.
+func (app *App) checkArchive(archive *zip.Reader) (files []*zip.File, err error) {
+	var (
+		names   = make(map[string]bool)
+		entries []artconfig.Entry
+		total   uint64
+	)
+	for _, f := range archive.File {
+		if f.FileInfo().IsDir() || ignoredArchiveEntry(f.Name) {
+			continue
+		}
+		if len(files) == maxArchiveEntries {
+			return nil, errors.Errorf("archive holds more than %d files", maxArchiveEntries)
+		}
+
+		if strings.Contains(f.Name, "\\") || path.IsAbs(f.Name) ||
+			path.Clean(f.Name) == ".." || strings.HasPrefix(path.Clean(f.Name), "../") {
+			return nil, errors.Errorf("archive entry %s has an invalid path", f.Name)
+		}
+		name := path.Base(f.Name)
+		if names[strings.ToLower(name)] {
+			return nil, errors.Errorf("archive holds more than one file named %s", name)
+		}
+		names[strings.ToLower(name)] = true
+
+		if total += f.UncompressedSize64; total > uint64(app.archiveUnpackedLimit) {
+			return nil, errors.Errorf("%s would unpack past the limit of the archive", name)
+		}
+		if f.UncompressedSize64 > uint64(archiveRatioLimit(f)) {
+			return nil, errors.Errorf("%s is compressed too well to be unpacked", name)
+		}
+
+		if !strings.EqualFold(name, "artconfig.txt") {
+			files = append(files, f)
+			continue
+		}
+
+		var rc io.ReadCloser
+		rc, err = f.Open()
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to open %s", name)
+		}
+		entries, err = artconfig.Parse(&limitedReader{rc, name, maxArchiveConfigSize, maxArchiveConfigSize})
+		rc.Close()
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid artconfig.txt")
+		}
+	}
+
+	// the folders in an artconfig.txt depend on where it was installed so only the file names of
+	// each line have to match the archive
+	for _, entry := range entries {
+		for _, name := range []string{entry.DFF, entry.TXD} {
+			if !names[strings.ToLower(path.Base(strings.Replace(name, "\\", "/", -1)))] {
+				return nil, errors.Errorf("artconfig.txt uses %s which is not in the archive", name)
+			}
+		}
+	}
+	return
+}
+
+// archiveRatioLimit is the most an entry of an archive can unpack to
+func archiveRatioLimit(f *zip.File) int64 {
+	limit := int64(f.CompressedSize64) * maxArchiveRatio
+	if limit < minArchiveRatioSize {
+		limit = minArchiveRatioSize
+	}
+	return limit
+}
+
+// ignoredArchiveEntry reports whether an entry is metadata that archivers add on their own
+func ignoredArchiveEntry(name string) bool {
+	base := path.Base(name)
+	return strings.HasPrefix(name, "__MACOSX/") || base == ".DS_Store" || strings.EqualFold(base, "Thumbs.db")
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Southclaws/samp-objects-api/types"
+)
+
+// archiveEntry is a file written to a test archive
+type archiveEntry struct {
+	name string
+	data []byte
+}
+
+// newArchive writes entries to a ZIP with every entry compressed
+func newArchive(t *testing.T, entries []archiveEntry) *zip.Reader {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, entry := range entries {
+		w, err := zw.Create(entry.name)
+		assert.NoError(t, err)
+		_, err = w.Write(entry.data)
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, zw.Close())
+
+	archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	assert.NoError(t, err)
+	return archive
+}
+
+func testPNG(t *testing.T) []byte {
+	var buf bytes.Buffer
+	assert.NoError(t, png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, 2, 2))))
+	return buf.Bytes()
+}
+
+// testCollision returns a collision file of n bytes, random ones compress poorly and zeros well
+func testCollision(n int, random bool) []byte {
+	data := make([]byte, n)
+	if random {
+		rand.New(rand.NewSource(1)).Read(data)
+	}
+	copy(data, "COLL")
+	return data
+}
+
+func Test_unpackArchive(t *testing.T) {
+	tooMany := make([]archiveEntry, maxArchiveEntries+1)
+	for i := range tooMany {
+		tooMany[i] = archiveEntry{fmt.Sprintf("col/%d.col", i), testCollision(8, false)}
+	}
+	var overLimit []archiveEntry
+	for i := 0; i < 5; i++ {
+		overLimit = append(overLimit, archiveEntry{fmt.Sprintf("%d.col", i), testCollision(1000*1000, true)})
+	}
+
+	tests := []struct {
+		name    string
+		entries []archiveEntry
+		files   []string
+		wantErr string
+	}{
+		{"valid", []archiveEntry{
+			{"images/preview.png", testPNG(t)},
+			{"collision/object.col", testCollision(128, false)},
+			{"__MACOSX/images/._preview.png", []byte("resource fork")},
+		}, []string{"preview.png", "object.col"}, ""},
+		{"parent folder", []archiveEntry{
+			{"../preview.png", testPNG(t)},
+		}, nil, "archive entry ../preview.png has an invalid path"},
+		{"parent folder inside", []archiveEntry{
+			{"images/../../preview.png", testPNG(t)},
+		}, nil, "archive entry images/../../preview.png has an invalid path"},
+		{"absolute path", []archiveEntry{
+			{"/etc/preview.png", testPNG(t)},
+		}, nil, "archive entry /etc/preview.png has an invalid path"},
+		{"windows path", []archiveEntry{
+			{`..\preview.png`, testPNG(t)},
+		}, nil, `archive entry ..\preview.png has an invalid path`},
+		{"duplicate names", []archiveEntry{
+			{"a/preview.png", testPNG(t)},
+			{"b/Preview.png", testPNG(t)},
+		}, nil, "archive holds more than one file named Preview.png"},
+		{"too many entries", tooMany, nil, "archive holds more than 256 files"},
+		{"zip bomb", []archiveEntry{
+			{"bomb.col", testCollision(1000*1000, false)},
+		}, nil, "bomb.col is compressed too well to be unpacked"},
+		{"unpacked limit", overLimit, nil, "4.col would unpack past the limit of the archive"},
+		{"unsupported file", []archiveEntry{
+			{"readme.txt", []byte("hello")},
+		}, nil, "readme.txt is not a supported file type"},
+		{"broken file after a stored one", []archiveEntry{
+			{"preview.png", testPNG(t)},
+			{"broken.png", []byte("\x89PNG\r\n\x1a\nbroken")},
+		}, []string{"preview.png"}, "invalid image broken.png"},
+		{"artconfig without its model", []archiveEntry{
+			{"artconfig.txt", []byte(`AddSimpleModel(-1, 19379, -1000, "door.dff", "door.txd");`)},
+		}, nil, "artconfig.txt uses door.dff which is not in the archive"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApp()
+			version, err := app.unpackArchive("00000000-0000-0000-0000-000000000001", newArchive(t, tt.entries))
+			if tt.wantErr != "" {
+				assert.Error(t, err)
+				if err != nil {
+					assert.Contains(t, err.Error(), tt.wantErr)
+				}
+			} else {
+				assert.NoError(t, err)
+			}
+
+			// files stored before the failure are returned so they can be discarded
+			var files []string
+			for _, info := range version.Files {
+				files = append(files, string(info.Name))
+			}
+			assert.Equal(t, tt.files, files)
+		})
+	}
+}
+
+func Test_unpackArchiveTypes(t *testing.T) {
+	app := newTestApp()
+	version, err := app.unpackArchive("00000000-0000-0000-0000-000000000001", newArchive(t, []archiveEntry{
+		{"preview.png", testPNG(t)},
+		{"object.col", testCollision(128, false)},
+	}))
+	assert.NoError(t, err)
+	assert.Equal(t, []types.File{"preview.png"}, version.Images)
+	assert.Empty(t, version.Models)
+	assert.Len(t, version.Files, 2)
+}
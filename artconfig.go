@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+
+	"github.com/Southclaws/samp-objects-api/artconfig"
+	"github.com/Southclaws/samp-objects-api/storage"
+	"github.com/Southclaws/samp-objects-api/types"
+)
+
+// maxRequestedObjects is the most objects that can be put in one artconfig snippet or download
+const maxRequestedObjects = 100
+
+// ObjectArtConfig handles the /artconfig/{objectid} and /artconfig?objects= endpoints, it returns
+// the AddSimpleModel lines for the models of one object or a comma separated list of objects. The
+// world, baseid and modelid parameters set the virtual world, the base object and the first model
+// ID, format=json returns the entries as JSON instead of artconfig.txt lines.
+func (app *App) ObjectArtConfig(w http.ResponseWriter, r *http.Request) {
+	objects, ok := app.requestedObjects(w, r)
+	if !ok {
+		return
+	}
+
+	options := artconfig.DefaultOptions
+	for name, value := range map[string]*int{"world": &options.World, "baseid": &options.BaseID, "modelid": &options.ModelID} {
+		raw := r.URL.Query().Get(name)
+		if raw == "" {
+			continue
+		}
+		var err error
+		*value, err = strconv.Atoi(raw)
+		if err != nil {
+			WriteResponseError(w, http.StatusBadRequest, errors.Errorf("invalid %s '%s'", name, raw))
+			return
+		}
+	}
+
+	entries, err := artconfig.Generate(objects, options)
+	if err != nil {
+		WriteResponseError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	if r.URL.Query().Get("format") == "json" {
+		payload, err := json.Marshal(entries)
+		if err != nil {
+			WriteResponseError(w, http.StatusInternalServerError, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(payload)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	artconfig.Write(w, objects, entries)
+}
+
+// requestedObjects looks up the object in the objectid route variable or the comma separated list
+// of objects in the objects parameter, writing an error response and returning false if any of them
+// are invalid or don't exist
+func (app *App) requestedObjects(w http.ResponseWriter, r *http.Request) (objects []types.Object, ok bool) {
+	var ids []string
+	if objectID, ok := mux.Vars(r)["objectid"]; ok {
+		ids = []string{objectID}
+	} else if raw := r.URL.Query().Get("objects"); raw != "" {
+		ids = strings.Split(raw, ",")
+	}
+	if len(ids) == 0 || len(ids) > maxRequestedObjects {
+		WriteResponseError(w, http.StatusBadRequest, errors.Errorf("between 1 and %d objects must be given", maxRequestedObjects))
+		return nil, false
+	}
+
+	seen := make(map[types.ObjectID]bool)
+	for _, id := range ids {
+		objectID := types.ObjectID(strings.TrimSpace(id))
+		if err := objectID.Validate(); err != nil {
+			WriteResponseError(w, http.StatusBadRequest, errors.Wrapf(err, "invalid object ID '%s'", objectID))
+			return nil, false
+		}
+		if seen[objectID] {
+			continue
+		}
+		seen[objectID] = true
+
+		object, err := app.Storage.GetObject(objectID)
+		if err != nil || object.Deleted != nil {
+			if err == nil || err == storage.ErrNotFound {
+				WriteResponse(w, http.StatusNotFound, "object "+string(objectID)+" not found")
+				return nil, false
+			}
+			WriteResponseError(w, http.StatusInternalServerError, errors.Wrap(err, "failed to get object"))
+			return nil, false
+		}
+		objects = append(objects, object)
+	}
+	return objects, true
+}
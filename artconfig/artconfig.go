@@ -0,0 +1,291 @@
+// Package artconfig generates the `AddSimpleModel` lines that SA:MP servers read from
+// `models/artconfig.txt` to load custom models. Each object's files live in their own folder under
+// `models/` named after its owner and the object, which is the same layout as object downloads.
+package artconfig
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/Southclaws/samp-objects-api/types"
+)
+
+// the range of model IDs that SA:MP allows for custom objects
+const (
+	FirstModelID = types.FirstModelID
+	LastModelID  = types.LastModelID
+)
+
+// Options are the values shared by every generated line
+type Options struct {
+	World   int // virtual world the models are available in, -1 for every world
+	BaseID  int // the model ID of the object whose collision and properties are used
+	ModelID int // the new ID of the first model, the following models count down from it
+
+	// Collection, when set, holds the model IDs assigned to each model and ModelID is not used
+	Collection *types.Collection
+}
+
+// DefaultOptions makes the models available in every world with the properties of a plain wall
+var DefaultOptions = Options{
+	World:   -1,
+	BaseID:  19379,
+	ModelID: FirstModelID,
+}
+
+// Entry is a single AddSimpleModel line
+type Entry struct {
+	Object  types.ObjectID `json:"object_id"`
+	World   int            `json:"world"`
+	BaseID  int            `json:"base_id"`
+	ModelID int            `json:"model_id"`
+	DFF     string         `json:"dff"`
+	TXD     string         `json:"txd"`
+}
+
+func (e Entry) String() string {
+	return fmt.Sprintf("AddSimpleModel(%d, %d, %d, %q, %q);", e.World, e.BaseID, e.ModelID, e.DFF, e.TXD)
+}
+
+// Folder returns the folder inside `models/` that an object's files are placed in
+func Folder(object types.Object) string {
+	return path.Join(clean(string(object.OwnerName)), clean(string(object.Name)))
+}
+
+// Path returns where one of an object's files is placed inside `models/`
+func Path(object types.Object, file types.File) string {
+	return path.Join(Folder(object), clean(string(file)))
+}
+
+// clean makes a name safe to use as a single path element
+func clean(name string) string {
+	cleaned := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '.' {
+			return r
+		}
+		return '_'
+	}, name)
+	if strings.Trim(cleaned, ".") == "" {
+		return strings.Repeat("_", len(cleaned)+1)
+	}
+	return cleaned
+}
+
+// Generate returns an entry for every model of every object, paired with the texture dictionary
+// it uses. Every model and texture dictionary must be one of the object's stored files.
+func Generate(objects []types.Object, options Options) (entries []Entry, err error) {
+	var (
+		next    = options.ModelID
+		folders = make(map[string]types.ObjectID)
+	)
+	for _, object := range objects {
+		if other, ok := folders[Folder(object)]; ok && other != object.ID {
+			return nil, errors.Errorf("object %s would be placed in the same folder as %s", object.ID, other)
+		}
+		folders[Folder(object)] = object.ID
+
+		if len(object.Models) == 0 {
+			return nil, errors.Errorf("object %s has no models", object.Name)
+		}
+		for _, model := range object.Models {
+			if _, ok := object.FileInfo(model); !ok {
+				return nil, errors.Errorf("model %s of object %s is not stored", model, object.Name)
+			}
+
+			var texture types.File
+			texture, err = Texture(object, model)
+			if err != nil {
+				return nil, errors.Wrapf(err, "object %s", object.Name)
+			}
+			if _, ok := object.FileInfo(texture); !ok {
+				return nil, errors.Errorf("texture dictionary %s of object %s is not stored", texture, object.Name)
+			}
+
+			modelID := next
+			if options.Collection != nil {
+				var ok bool
+				modelID, ok = options.Collection.ModelID(object.ID, model)
+				if !ok {
+					return nil, errors.Errorf("model %s of object %s has no model ID in the collection", model, object.Name)
+				}
+			} else {
+				next--
+			}
+			if modelID > FirstModelID || modelID < LastModelID {
+				return nil, errors.Errorf("model ID %d is outside of %d to %d", modelID, FirstModelID, LastModelID)
+			}
+			entries = append(entries, Entry{
+				Object:  object.ID,
+				World:   options.World,
+				BaseID:  options.BaseID,
+				ModelID: modelID,
+				DFF:     Path(object, model),
+				TXD:     Path(object, texture),
+			})
+		}
+	}
+	return
+}
+
+// Texture picks the texture dictionary of an object that a model uses: the one with the same name
+// as the model, the one holding most of the textures the model's materials use or the only one
+func Texture(object types.Object, model types.File) (types.File, error) {
+	base := strings.TrimSuffix(string(model), path.Ext(string(model)))
+	for _, texture := range object.Textures {
+		if strings.EqualFold(strings.TrimSuffix(string(texture), path.Ext(string(texture))), base) {
+			return texture, nil
+		}
+	}
+
+	var (
+		best      types.File
+		bestScore int
+	)
+	if info, ok := object.FileInfo(model); ok && info.Model != nil {
+		for _, texture := range object.Textures {
+			txd, _ := object.FileInfo(texture)
+			score := 0
+			for _, name := range info.Model.Textures {
+				for _, t := range txd.Textures {
+					if strings.EqualFold(t.Name, name) {
+						score++
+						break
+					}
+				}
+			}
+			if score > bestScore {
+				best, bestScore = texture, score
+			}
+		}
+	}
+	if best != "" {
+		return best, nil
+	}
+
+	if len(object.Textures) == 1 {
+		return object.Textures[0], nil
+	}
+	if len(object.Textures) == 0 {
+		return "", errors.New("object has no texture dictionaries")
+	}
+	return "", errors.Errorf("can't tell which texture dictionary model %s uses", model)
+}
+
+// Write writes the entries as an artconfig.txt snippet with a comment before each object
+func Write(w io.Writer, objects []types.Object, entries []Entry) (err error) {
+	return write(w, objects, entries, Entry.String)
+}
+
+// WritePawn writes a #define for the model ID of each entry so scripts can refer to the models by
+// name, such as `#define MODEL_OWNER_DOORS_DOOR (-1000)`
+func WritePawn(w io.Writer, objects []types.Object, entries []Entry) (err error) {
+	return write(w, objects, entries, func(entry Entry) string {
+		name := strings.TrimSuffix(entry.DFF, path.Ext(entry.DFF))
+		name = strings.ToUpper(strings.Map(func(r rune) rune {
+			if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+				return r
+			}
+			return '_'
+		}, name))
+		return fmt.Sprintf("#define MODEL_%s (%d)", name, entry.ModelID)
+	})
+}
+
+// write writes a line for each entry with a comment before each object
+func write(w io.Writer, objects []types.Object, entries []Entry, format func(Entry) string) (err error) {
+	names := make(map[types.ObjectID]types.Object)
+	for _, object := range objects {
+		names[object.ID] = object
+	}
+
+	var last types.ObjectID
+	for i, entry := range entries {
+		if entry.Object != last {
+			if i > 0 {
+				fmt.Fprintln(w)
+			}
+			// names are free text so they are kept to one line to not break out of the comment
+			object := names[entry.Object]
+			comment := strings.Join(strings.Fields(fmt.Sprintf("%s by %s", object.Name, object.OwnerName)), " ")
+			_, err = fmt.Fprintf(w, "// %s\n", comment)
+			if err != nil {
+				return
+			}
+			last = entry.Object
+		}
+		_, err = fmt.Fprintln(w, format(entry))
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Collision is a model ID that a server already uses for a different model
+type Collision struct {
+	ModelID  int   `json:"model_id"`
+	Entry    Entry `json:"entry"`
+	Existing Entry `json:"existing"`
+}
+
+// Collisions compares entries with the lines of a server's existing artconfig.txt, an existing
+// line with the same model ID is only a collision if it loads a different model since a server
+// that already has the entries installed lists them too
+func Collisions(entries, existing []Entry) (collisions []Collision) {
+	byID := make(map[int][]Entry)
+	for _, e := range existing {
+		byID[e.ModelID] = append(byID[e.ModelID], e)
+	}
+	for _, entry := range entries {
+		for _, e := range byID[entry.ModelID] {
+			if !strings.EqualFold(path.Clean(strings.Replace(e.DFF, "\\", "/", -1)), entry.DFF) {
+				collisions = append(collisions, Collision{entry.ModelID, entry, e})
+			}
+		}
+	}
+	return
+}
+
+// line matches AddSimpleModel and AddSimpleModelTimed lines, the hours a timed model is shown
+// between are not kept
+var line = regexp.MustCompile(`^AddSimpleModel(Timed)?\(\s*(-?\d+)\s*,\s*(-?\d+)\s*,\s*(-?\d+)\s*,\s*"([^"]*)"\s*,\s*"([^"]*)"\s*(,\s*\d+\s*,\s*\d+\s*)?\)\s*;?$`)
+
+// Parse reads the entries of an artconfig.txt, blank lines and comments are skipped and anything
+// else is an error. Entries are not checked against any objects so Object is left empty.
+func Parse(r io.Reader) (entries []Entry, err error) {
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		text := scanner.Text()
+		if i := strings.Index(text, "//"); i != -1 {
+			text = text[:i]
+		}
+		text = strings.TrimSpace(text)
+		if text == "" {
+			continue
+		}
+
+		match := line.FindStringSubmatch(text)
+		if match == nil {
+			return nil, errors.Errorf("line %d is not an AddSimpleModel line", n)
+		}
+		if (match[1] == "") != (match[7] == "") {
+			return nil, errors.Errorf("line %d has the wrong number of arguments", n)
+		}
+		entry := Entry{DFF: match[5], TXD: match[6]}
+		entry.World, _ = strconv.Atoi(match[2])
+		entry.BaseID, _ = strconv.Atoi(match[3])
+		entry.ModelID, _ = strconv.Atoi(match[4])
+		if entry.ModelID > FirstModelID || entry.ModelID < LastModelID {
+			return nil, errors.Errorf("line %d uses model ID %d which is outside of %d to %d", n, entry.ModelID, FirstModelID, LastModelID)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
@@ -0,0 +1,165 @@
+package artconfig
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Southclaws/samp-objects-api/types"
+)
+
+func TestFolder(t *testing.T) {
+	assert.Equal(t, "Southclaws/Big_Door", Folder(types.Object{OwnerName: "Southclaws", Name: "Big Door"}))
+	assert.Equal(t, "owner/___/_", Path(types.Object{OwnerName: "owner", Name: ".."}, "/"))
+	assert.Equal(t, "owner/name/__", Path(types.Object{OwnerName: "owner", Name: "name"}, "."))
+}
+
+func TestGenerate(t *testing.T) {
+	objects := []types.Object{
+		{
+			ID: "00000000-0000-0000-0000-000000000001", OwnerName: "owner", Name: "doors",
+			Models:   []types.File{"door.dff", "frame.dff", "handle.dff"},
+			Textures: []types.File{"door.txd", "metal.txd"},
+			Files: []types.FileInfo{
+				{Name: "door.dff"}, {Name: "door.txd"},
+				{Name: "frame.dff", Model: &types.ModelInfo{Textures: []string{"steel"}}},
+				{Name: "handle.dff", Model: &types.ModelInfo{Textures: []string{"Brass", "Wood"}}},
+				{Name: "metal.txd", Textures: []types.TextureInfo{{Name: "steel"}, {Name: "brass"}}},
+			},
+		},
+		{
+			ID: "00000000-0000-0000-0000-000000000002", OwnerName: "owner", Name: "sign",
+			Models:   []types.File{"sign.dff"},
+			Textures: []types.File{"words.txd"},
+			Files:    []types.FileInfo{{Name: "sign.dff"}, {Name: "words.txd"}},
+		},
+	}
+
+	entries, err := Generate(objects, DefaultOptions)
+	assert.NoError(t, err)
+	assert.Equal(t, []Entry{
+		{objects[0].ID, -1, 19379, -1000, "owner/doors/door.dff", "owner/doors/door.txd"},
+		{objects[0].ID, -1, 19379, -1001, "owner/doors/frame.dff", "owner/doors/metal.txd"},
+		{objects[0].ID, -1, 19379, -1002, "owner/doors/handle.dff", "owner/doors/metal.txd"},
+		{objects[1].ID, -1, 19379, -1003, "owner/sign/sign.dff", "owner/sign/words.txd"},
+	}, entries)
+
+	var buf bytes.Buffer
+	assert.NoError(t, Write(&buf, objects, entries[2:]))
+	assert.Equal(t, `// doors by owner
+AddSimpleModel(-1, 19379, -1002, "owner/doors/handle.dff", "owner/doors/metal.txd");
+
+// sign by owner
+AddSimpleModel(-1, 19379, -1003, "owner/sign/sign.dff", "owner/sign/words.txd");
+`, buf.String())
+
+	_, err = Generate(objects, Options{World: -1, BaseID: 19379, ModelID: -29999})
+	assert.Error(t, err)
+
+	objects[0].Files = objects[0].Files[1:]
+	_, err = Generate(objects, DefaultOptions)
+	assert.Error(t, err)
+
+	objects[0].Models = []types.File{"unknown.dff"}
+	objects[0].Files = []types.FileInfo{{Name: "unknown.dff"}}
+	_, err = Generate(objects, DefaultOptions)
+	assert.Error(t, err)
+}
+
+func TestParse(t *testing.T) {
+	entries, err := Parse(strings.NewReader(`// doors by owner
+AddSimpleModel(-1, 19379, -1000, "owner/doors/door.dff", "owner/doors/door.txd");
+AddSimpleModelTimed(0,19379,-1001,"frame.dff","metal.txd",6,18) // only during the day
+
+`))
+	assert.NoError(t, err)
+	assert.Equal(t, []Entry{
+		{"", -1, 19379, -1000, "owner/doors/door.dff", "owner/doors/door.txd"},
+		{"", 0, 19379, -1001, "frame.dff", "metal.txd"},
+	}, entries)
+
+	_, err = Parse(strings.NewReader(`AddSimpleModel(-1, 19379, -1000, "door.dff", "door.txd", 6, 18);`))
+	assert.Error(t, err)
+
+	_, err = Parse(strings.NewReader(`AddSimpleModel(-1, 19379, 1000, "door.dff", "door.txd");`))
+	assert.Error(t, err)
+
+	_, err = Parse(strings.NewReader(`CreateObject(19379, 0.0, 0.0, 0.0, 0.0, 0.0, 0.0);`))
+	assert.Error(t, err)
+}
+
+func TestGenerateCollection(t *testing.T) {
+	objects := []types.Object{
+		{
+			ID: "00000000-0000-0000-0000-000000000001", OwnerName: "owner", Name: "doors",
+			Models:   []types.File{"door.dff", "frame.dff"},
+			Textures: []types.File{"door.txd"},
+			Files:    []types.FileInfo{{Name: "door.dff"}, {Name: "frame.dff"}, {Name: "door.txd"}},
+		},
+		{
+			ID: "00000000-0000-0000-0000-000000000002", OwnerName: "owner", Name: "sign",
+			Models:   []types.File{"sign.dff"},
+			Textures: []types.File{"sign.txd"},
+			Files:    []types.FileInfo{{Name: "sign.dff"}, {Name: "sign.txd"}},
+		},
+	}
+	collection := types.Collection{
+		ID: "00000000-0000-0000-0000-0000000000c1", OwnerID: "owner", Name: "server",
+		FirstID: -5000, LastID: -5002,
+	}
+
+	assigned, err := collection.Add(objects[0])
+	assert.NoError(t, err)
+	assert.Len(t, assigned, 2)
+	_, err = collection.Add(objects[1])
+	assert.NoError(t, err)
+	assert.NoError(t, collection.Validate())
+
+	// removing an object keeps its IDs reserved and adding it again gives them back
+	assert.True(t, collection.Remove(objects[0].ID))
+	assert.NoError(t, collection.Validate())
+	_, err = Generate(objects, Options{World: -1, BaseID: 19379, Collection: &collection})
+	assert.Error(t, err)
+	_, err = collection.Add(types.Object{ID: "00000000-0000-0000-0000-000000000003", Models: []types.File{"full.dff"}})
+	assert.Error(t, err)
+	assigned, err = collection.Add(objects[0])
+	assert.NoError(t, err)
+	assert.Empty(t, assigned)
+	assert.False(t, collection.Release(objects[0].ID))
+
+	entries, err := Generate(objects, Options{World: -1, BaseID: 19379, Collection: &collection})
+	assert.NoError(t, err)
+	assert.Equal(t, []Entry{
+		{objects[0].ID, -1, 19379, -5000, "owner/doors/door.dff", "owner/doors/door.txd"},
+		{objects[0].ID, -1, 19379, -5001, "owner/doors/frame.dff", "owner/doors/door.txd"},
+		{objects[1].ID, -1, 19379, -5002, "owner/sign/sign.dff", "owner/sign/sign.txd"},
+	}, entries)
+
+	var buf bytes.Buffer
+	assert.NoError(t, WritePawn(&buf, objects, entries))
+	assert.Equal(t, `// doors by owner
+#define MODEL_OWNER_DOORS_DOOR (-5000)
+#define MODEL_OWNER_DOORS_FRAME (-5001)
+
+// sign by owner
+#define MODEL_OWNER_SIGN_SIGN (-5002)
+`, buf.String())
+
+	existing, err := Parse(strings.NewReader(`AddSimpleModel(-1, 19379, -5001, "owner\doors\frame.dff", "owner\doors\door.txd");
+AddSimpleModel(-1, 19379, -5002, "someone/else.dff", "someone/else.txd");`))
+	assert.NoError(t, err)
+	assert.Equal(t, []Collision{{-5002, entries[2], existing[1]}}, Collisions(entries, existing))
+
+	// released IDs are given to objects added later
+	assert.True(t, collection.Remove(objects[0].ID))
+	assert.True(t, collection.Release(objects[0].ID))
+	assert.False(t, collection.Release(objects[0].ID))
+	assigned, err = collection.Add(types.Object{ID: "00000000-0000-0000-0000-000000000003", Models: []types.File{"full.dff"}})
+	assert.NoError(t, err)
+	if assert.Len(t, assigned, 1) {
+		assert.Equal(t, -5000, assigned[0].ModelID)
+	}
+	assert.NoError(t, collection.Validate())
+}
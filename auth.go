@@ -18,6 +18,7 @@ import (
 	"go.uber.org/zap"
 	"golang.org/x/crypto/bcrypt"
 
+	"github.com/Southclaws/samp-objects-api/storage"
 	"github.com/Southclaws/samp-objects-api/types"
 )
 
@@ -33,39 +34,77 @@ type AuthResponse struct {
 	UserID types.UserID `json:"userID"`
 }
 
-// SetupAuth creates a default root user if it does not already exist
-func (app App) SetupAuth() {
-	exists, err := app.Storage.UserExistsByName("root")
+// SetupAuth creates a default root user if it does not already exist and decides which user has
+// admin rights. That's the configured admin ID, or the root account's ID when there isn't one, so
+// the rights stay with the account even if another user later takes the name "root". The admin
+// account can't be trashed, one that was is restored here so the trash sweeper never purges it.
+func (app *App) SetupAuth() {
+	if app.config.AdminID != "" {
+		app.adminID = types.UserID(app.config.AdminID)
+		if err := app.adminID.Validate(); err != nil {
+			logger.Fatal("invalid admin user ID", zap.Error(err))
+		}
+	} else {
+		app.adminID = app.setupRoot()
+	}
+
+	err := app.Storage.RestoreUser(app.adminID)
+	if err == nil {
+		logger.Warn("restored the admin account from the trash",
+			zap.String("userid", string(app.adminID)))
+	} else if err != storage.ErrNotFound {
+		logger.Fatal("failed to check the admin account", zap.Error(err))
+	}
+}
+
+// setupRoot returns the ID of the root account, creating it if it does not already exist
+func (app *App) setupRoot() types.UserID {
+	root, exists, err := app.Storage.GetUserByName("root")
 	if err != nil {
 		logger.Fatal("failed to check for root user account existence")
 	}
 
-	if !exists {
-		// Plaintext passwords are SHA'd on the client, bcrypt'd on the server
-		// since this is the auto-generated root account, we're doing both here.
-		password, err := GenerateRandomString(40)
-		if err != nil {
-			logger.Fatal("failed to create hash from generated bytes")
+	if exists {
+		return root.ID
+	}
+
+	// lookups by name skip trashed users, a root account that was trashed before the admin
+	// account was protected is found in the trash and restored by SetupAuth
+	trash, err := app.Storage.GetTrash()
+	if err != nil {
+		logger.Fatal("failed to check the trash for the root user account", zap.Error(err))
+	}
+	for _, user := range trash.Users {
+		if user.Name == "root" {
+			return user.ID
 		}
+	}
 
-		clientHash := fmt.Sprintf("%x", sha256.Sum256([]byte(password)))
+	// Plaintext passwords are SHA'd on the client, bcrypt'd on the server
+	// since this is the auto-generated root account, we're doing both here.
+	password, err := GenerateRandomString(40)
+	if err != nil {
+		logger.Fatal("failed to create hash from generated bytes")
+	}
 
-		serverHash, err := bcrypt.GenerateFromPassword([]byte(clientHash), bcrypt.DefaultCost)
-		if err != nil {
-			logger.Fatal("failed to generate bcrypt from sha256")
-		}
+	clientHash := fmt.Sprintf("%x", sha256.Sum256([]byte(password)))
 
-		if err = app.Storage.CreateUser(
-			types.User{
-				ID:       types.UserID(uuid.New().String()),
-				Name:     types.UserName("root"),
-				Email:    types.UserEmail("admin@samp-objects.com"),
-				Password: types.UserPass(serverHash),
-			}); err != nil {
-			logger.Fatal("failed to create root user", zap.Error(err))
-		}
-		logger.Info("created new root account", zap.String("password", password))
+	serverHash, err := bcrypt.GenerateFromPassword([]byte(clientHash), bcrypt.DefaultCost)
+	if err != nil {
+		logger.Fatal("failed to generate bcrypt from sha256")
 	}
+
+	root = types.User{
+		ID:       types.UserID(uuid.New().String()),
+		Name:     types.UserName("root"),
+		Email:    types.UserEmail("admin@samp-objects.com"),
+		Password: types.UserPass(serverHash),
+	}
+	if err = app.Storage.CreateUser(root); err != nil {
+		logger.Fatal("failed to create root user", zap.Error(err))
+	}
+	logger.Info("created new root account", zap.String("password", password))
+	return root.ID
 }
 
 // Authenticated is a middleware layer for requests that require authentication
@@ -122,6 +161,58 @@ func (app *App) Authenticated(next http.Handler) http.Handler {
 	})
 }
 
+// Admin is a middleware layer for requests that only the admin user may make, it must be wrapped in
+// Authenticated so the session has already been checked
+func (app *App) Admin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, status, err := app.SessionUser(r)
+		if err != nil {
+			WriteResponseError(w, status, err)
+			return
+		}
+		if !app.isAdmin(user) {
+			WriteResponse(w, http.StatusForbidden, "admin only")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// SessionUser returns the user making an authenticated request, on failure the returned status is
+// the one that should be sent to the client. Trashed users are treated as if they do not exist.
+func (app *App) SessionUser(r *http.Request) (user types.User, status int, err error) {
+	session, err := app.Sessions.Get(r, UserSessionCookie)
+	if err != nil {
+		return user, http.StatusInternalServerError, errors.New("failed to read session cookies")
+	}
+
+	userID, ok := session.Values["UserID"].(types.UserID)
+	if !ok {
+		return user, http.StatusBadRequest, errors.New("failed to read user ID from session")
+	}
+
+	user, exists, err := app.Storage.GetUser(userID)
+	if err != nil {
+		return user, http.StatusInternalServerError, errors.Wrap(err, "failed to get user")
+	}
+	if !exists || user.Deleted != nil {
+		return user, http.StatusUnauthorized, errors.New("user not found")
+	}
+
+	return user, http.StatusOK, nil
+}
+
+// isAdmin reports whether a user has admin rights
+func (app *App) isAdmin(user types.User) bool {
+	return app.adminID != "" && user.ID == app.adminID
+}
+
+// canModerate reports whether a user may trash or restore something owned by ownerID
+func (app *App) canModerate(user types.User, ownerID types.UserID) bool {
+	return user.ID == ownerID || app.isAdmin(user)
+}
+
 // GenerateRandomBytes does what it says on the tin
 // From https://elithrar.github.io/article/generating-secure-random-numbers-crypto-rand/ 2017-06-20
 func GenerateRandomBytes(n int) ([]byte, error) {
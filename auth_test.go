@@ -0,0 +1,53 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Southclaws/samp-objects-api/types"
+)
+
+func TestApp_SetupAuth(t *testing.T) {
+	app := newTestApp()
+	app.SetupAuth()
+
+	root, exists, err := app.Storage.GetUserByName("root")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, root.ID, app.adminID)
+	assert.True(t, app.isAdmin(root))
+	assert.True(t, app.canModerate(root, "00000000-0000-0000-0000-000000000009"))
+
+	// rights follow the account rather than the name
+	impostor := types.User{ID: "00000000-0000-0000-0000-000000000001", Name: "root"}
+	assert.False(t, app.isAdmin(impostor))
+	assert.False(t, app.canModerate(impostor, "00000000-0000-0000-0000-000000000009"))
+	assert.True(t, app.canModerate(impostor, impostor.ID))
+
+	// the admin account can't be trashed and one that was is restored on startup
+	trash := func(userID types.UserID) int {
+		r := mux.SetURLVars(httptest.NewRequest("POST", "/v0/admin/users/"+string(userID)+"/trash", nil),
+			map[string]string{"userid": string(userID)})
+		w := httptest.NewRecorder()
+		app.AdminUserTrash(w, r)
+		return w.Code
+	}
+	assert.Equal(t, http.StatusForbidden, trash(root.ID))
+
+	assert.NoError(t, app.Storage.TrashUser(root.ID))
+	app.SetupAuth()
+	root, _, err = app.Storage.GetUser(root.ID)
+	assert.NoError(t, err)
+	assert.Nil(t, root.Deleted)
+	assert.Equal(t, root.ID, app.adminID)
+
+	// a configured admin replaces root
+	app.config.AdminID = "00000000-0000-0000-0000-000000000002"
+	app.SetupAuth()
+	assert.Equal(t, types.UserID("00000000-0000-0000-0000-000000000002"), app.adminID)
+	assert.False(t, app.isAdmin(root))
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+
+	"github.com/Southclaws/samp-objects-api/storage"
+	"github.com/Southclaws/samp-objects-api/types"
+)
+
+// CategoryList handles the /categories endpoint, it returns the category taxonomy as a tree
+func (app *App) CategoryList(w http.ResponseWriter, r *http.Request) {
+	categories, err := app.Storage.GetCategories()
+	if err != nil {
+		WriteResponseError(w, http.StatusInternalServerError, errors.Wrap(err, "failed to get categories"))
+		return
+	}
+
+	payload, err := json.Marshal(storage.CategoryTree(categories))
+	if err != nil {
+		WriteResponseError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(payload)
+}
+
+// AdminCategoryCreate handles the POST /admin/categories endpoint, the slug is generated from the
+// name when it is not given
+func (app *App) AdminCategoryCreate(w http.ResponseWriter, r *http.Request) {
+	var category types.Category
+	err := readJSON(r, &category)
+	if err != nil {
+		WriteResponseError(w, http.StatusBadRequest, err)
+		return
+	}
+	if category.Slug == "" {
+		category.Slug = types.Slugify(category.Name)
+	}
+	if err = category.Validate(); err != nil {
+		WriteResponseError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	err = app.Storage.CreateCategory(category)
+	if err != nil {
+		writeCategoryError(w, errors.Wrap(err, "failed to create category"))
+		return
+	}
+
+	payload, err := json.Marshal(category)
+	if err != nil {
+		WriteResponseError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	w.Write(payload)
+}
+
+// AdminCategoryUpdate handles the PATCH /admin/categories/{slug} endpoint, it renames a category or
+// moves it to another parent. The slug itself never changes, use a remap to move objects instead.
+func (app *App) AdminCategoryUpdate(w http.ResponseWriter, r *http.Request) {
+	var category types.Category
+	err := readJSON(r, &category)
+	if err != nil {
+		WriteResponseError(w, http.StatusBadRequest, err)
+		return
+	}
+	category.Slug = types.ObjectCategory(mux.Vars(r)["slug"])
+	if err = category.Validate(); err != nil {
+		WriteResponseError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	err = app.Storage.UpdateCategory(category)
+	if err != nil {
+		writeCategoryError(w, errors.Wrap(err, "failed to update category"))
+		return
+	}
+
+	WriteResponse(w, http.StatusOK, "category updated")
+}
+
+// AdminCategoryDelete handles the DELETE /admin/categories/{slug} endpoint, categories that still
+// have objects or subcategories are not deleted
+func (app *App) AdminCategoryDelete(w http.ResponseWriter, r *http.Request) {
+	err := app.Storage.DeleteCategory(types.ObjectCategory(mux.Vars(r)["slug"]))
+	if err != nil {
+		writeCategoryError(w, errors.Wrap(err, "failed to delete category"))
+		return
+	}
+
+	WriteResponse(w, http.StatusOK, "category deleted")
+}
+
+// AdminCategoryUnknown handles the /admin/categories/unknown endpoint, it counts the objects in
+// categories that are not part of the taxonomy
+func (app *App) AdminCategoryUnknown(w http.ResponseWriter, r *http.Request) {
+	unknown, err := storage.UnknownCategories(app.Storage)
+	if err != nil {
+		WriteResponseError(w, http.StatusInternalServerError, errors.Wrap(err, "failed to count unknown categories"))
+		return
+	}
+
+	payload, err := json.Marshal(unknown)
+	if err != nil {
+		WriteResponseError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(payload)
+}
+
+// CategoryRemapRequest maps old free-text categories to categories in the taxonomy
+type CategoryRemapRequest struct {
+	Mapping map[types.ObjectCategory]types.ObjectCategory `json:"mapping"`
+	DryRun  bool                                          `json:"dry_run"`
+}
+
+// CategoryRemapResponse reports what a remap changed and which unknown categories are left
+type CategoryRemapResponse struct {
+	Remapped []storage.CategoryRemap `json:"remapped"`
+	Unknown  []storage.Facet         `json:"unknown"`
+}
+
+// AdminCategoryRemap handles the /admin/categories/remap endpoint, it moves the objects in each
+// category of the mapping to the managed category it maps to
+func (app *App) AdminCategoryRemap(w http.ResponseWriter, r *http.Request) {
+	var request CategoryRemapRequest
+	err := readJSON(r, &request)
+	if err != nil {
+		WriteResponseError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var response CategoryRemapResponse
+	response.Remapped, err = storage.RemapCategories(app.Storage, request.Mapping, request.DryRun)
+	if err != nil {
+		writeCategoryError(w, errors.Wrap(err, "failed to remap categories"))
+		return
+	}
+
+	response.Unknown, err = storage.UnknownCategories(app.Storage)
+	if err != nil {
+		WriteResponseError(w, http.StatusInternalServerError, errors.Wrap(err, "failed to count unknown categories"))
+		return
+	}
+
+	payload, err := json.Marshal(response)
+	if err != nil {
+		WriteResponseError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(payload)
+}
+
+// writeCategoryError responds with the status that matches a category storage error
+func writeCategoryError(w http.ResponseWriter, err error) {
+	switch errors.Cause(err) {
+	case storage.ErrNotFound:
+		WriteResponse(w, http.StatusNotFound, "category not found")
+	case storage.ErrCategoryAlreadyExists, storage.ErrCategoryInUse:
+		WriteResponseError(w, http.StatusConflict, err)
+	case storage.ErrUnknownCategory, storage.ErrCategoryCycle:
+		WriteResponseError(w, http.StatusBadRequest, err)
+	default:
+		WriteResponseError(w, http.StatusInternalServerError, err)
+	}
+}
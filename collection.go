@@ -0,0 +1,341 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+
+	"github.com/Southclaws/samp-objects-api/artconfig"
+	"github.com/Southclaws/samp-objects-api/storage"
+	"github.com/Southclaws/samp-objects-api/types"
+)
+
+// maxCollectionConfigSize is the largest artconfig.txt that can be checked against a collection
+const maxCollectionConfigSize = 1024 * 1024
+
+// collectionUpdateAttempts is how many times a change to a collection is made again to the latest
+// revision when another request changed it first
+const collectionUpdateAttempts = 3
+
+// CollectionRequest holds the details of a new collection, the model IDs of its objects are
+// assigned from the range first_model_id down to last_model_id
+type CollectionRequest struct {
+	Name    string `json:"name"`
+	FirstID int    `json:"first_model_id"`
+	LastID  int    `json:"last_model_id"`
+}
+
+// CollectionList handles the GET /collections endpoint, it returns the collections of the user
+// making the request
+func (app *App) CollectionList(w http.ResponseWriter, r *http.Request) {
+	user, status, err := app.SessionUser(r)
+	if err != nil {
+		WriteResponseError(w, status, err)
+		return
+	}
+
+	collections, err := app.Storage.GetUserCollections(user.ID)
+	if err != nil {
+		WriteResponseError(w, http.StatusInternalServerError, errors.Wrap(err, "failed to get collections"))
+		return
+	}
+	if collections == nil {
+		collections = []types.Collection{}
+	}
+
+	writeJSON(w, http.StatusOK, collections)
+}
+
+// CollectionCreate handles the POST /collections endpoint
+func (app *App) CollectionCreate(w http.ResponseWriter, r *http.Request) {
+	user, status, err := app.SessionUser(r)
+	if err != nil {
+		WriteResponseError(w, status, err)
+		return
+	}
+
+	var request CollectionRequest
+	err = readJSON(r, &request)
+	if err != nil {
+		WriteResponseError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	collection := types.Collection{
+		ID:      types.CollectionID(uuid.New().String()),
+		OwnerID: user.ID,
+		Name:    request.Name,
+		FirstID: request.FirstID,
+		LastID:  request.LastID,
+		Created: time.Now(),
+	}
+	if err = collection.Validate(); err != nil {
+		WriteResponseError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	err = app.Storage.CreateCollection(collection)
+	if err != nil {
+		WriteResponseError(w, http.StatusInternalServerError, errors.Wrap(err, "failed to create collection"))
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, collection)
+}
+
+// CollectionGet handles the GET /collections/{collectionid} endpoint
+func (app *App) CollectionGet(w http.ResponseWriter, r *http.Request) {
+	collection, ok := app.requestedCollection(w, r)
+	if !ok {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, collection)
+}
+
+// CollectionDelete handles the DELETE /collections/{collectionid} endpoint
+func (app *App) CollectionDelete(w http.ResponseWriter, r *http.Request) {
+	collection, ok := app.ownedCollection(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.Storage.DeleteCollection(collection.ID)
+	if err != nil {
+		WriteResponseError(w, http.StatusInternalServerError, errors.Wrap(err, "failed to delete collection"))
+		return
+	}
+
+	WriteResponse(w, http.StatusOK, "collection deleted")
+}
+
+// CollectionAddObject handles the PUT /collections/{collectionid}/objects/{objectid} endpoint, it
+// adds an object and assigns model IDs to its models. Adding an object that is already in the
+// collection assigns IDs to any models it gained in newer versions and keeps the existing ones.
+func (app *App) CollectionAddObject(w http.ResponseWriter, r *http.Request) {
+	objects, ok := app.requestedObjects(w, r)
+	if !ok {
+		return
+	}
+
+	collection, ok := app.updateCollection(w, r, func(collection *types.Collection) bool {
+		_, err := collection.Add(objects[0])
+		if err != nil {
+			WriteResponseError(w, http.StatusConflict, err)
+			return false
+		}
+		return true
+	})
+	if !ok {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, collection)
+}
+
+// CollectionRemoveObject handles the DELETE /collections/{collectionid}/objects/{objectid}
+// endpoint, the model IDs of the object's models stay reserved for it until they are released
+func (app *App) CollectionRemoveObject(w http.ResponseWriter, r *http.Request) {
+	collection, ok := app.updateCollection(w, r, func(collection *types.Collection) bool {
+		if !collection.Remove(types.ObjectID(mux.Vars(r)["objectid"])) {
+			WriteResponse(w, http.StatusNotFound, "object is not in the collection")
+			return false
+		}
+		return true
+	})
+	if !ok {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, collection)
+}
+
+// CollectionReleaseObject handles the POST /collections/{collectionid}/objects/{objectid}/release
+// endpoint, the model IDs reserved for a removed object become free for objects added later
+func (app *App) CollectionReleaseObject(w http.ResponseWriter, r *http.Request) {
+	collection, ok := app.updateCollection(w, r, func(collection *types.Collection) bool {
+		if !collection.Release(types.ObjectID(mux.Vars(r)["objectid"])) {
+			WriteResponse(w, http.StatusNotFound, "no model IDs are reserved for the object")
+			return false
+		}
+		return true
+	})
+	if !ok {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, collection)
+}
+
+// CollectionArtConfig handles the /collections/{collectionid}/artconfig endpoint, it returns the
+// AddSimpleModel lines for every object in the collection using their assigned model IDs. The
+// world and baseid parameters are the same as for /artconfig, format=json returns the entries as
+// JSON and format=pawn returns a #define for each model ID. Objects that are in the trash are left
+// out but keep their model IDs.
+func (app *App) CollectionArtConfig(w http.ResponseWriter, r *http.Request) {
+	collection, objects, ok := app.collectionObjects(w, r)
+	if !ok {
+		return
+	}
+
+	options := artconfig.DefaultOptions
+	options.Collection = &collection
+	for name, value := range map[string]*int{"world": &options.World, "baseid": &options.BaseID} {
+		raw := r.URL.Query().Get(name)
+		if raw == "" {
+			continue
+		}
+		var err error
+		*value, err = strconv.Atoi(raw)
+		if err != nil {
+			WriteResponseError(w, http.StatusBadRequest, errors.Errorf("invalid %s '%s'", name, raw))
+			return
+		}
+	}
+
+	entries, err := artconfig.Generate(objects, options)
+	if err != nil {
+		WriteResponseError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	switch r.URL.Query().Get("format") {
+	case "json":
+		writeJSON(w, http.StatusOK, entries)
+	case "pawn":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		artconfig.WritePawn(w, objects, entries)
+	default:
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		artconfig.Write(w, objects, entries)
+	}
+}
+
+// CollectionCollisions handles the POST /collections/{collectionid}/collisions endpoint, the body
+// is a server's existing artconfig.txt and the response lists every model ID of the collection that
+// the server already uses for a different model
+func (app *App) CollectionCollisions(w http.ResponseWriter, r *http.Request) {
+	collection, objects, ok := app.collectionObjects(w, r)
+	if !ok {
+		return
+	}
+
+	existing, err := artconfig.Parse(http.MaxBytesReader(w, r.Body, maxCollectionConfigSize))
+	if err != nil {
+		WriteResponseError(w, http.StatusBadRequest, errors.Wrap(err, "invalid artconfig.txt"))
+		return
+	}
+
+	options := artconfig.DefaultOptions
+	options.Collection = &collection
+	entries, err := artconfig.Generate(objects, options)
+	if err != nil {
+		WriteResponseError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	collisions := artconfig.Collisions(entries, existing)
+	if collisions == nil {
+		collisions = []artconfig.Collision{}
+	}
+	writeJSON(w, http.StatusOK, collisions)
+}
+
+// requestedCollection looks up the collection in the collectionid route variable, writing an error
+// response and returning false if it is invalid or doesn't exist
+func (app *App) requestedCollection(w http.ResponseWriter, r *http.Request) (collection types.Collection, ok bool) {
+	collectionID := types.CollectionID(mux.Vars(r)["collectionid"])
+	if err := collectionID.Validate(); err != nil {
+		WriteResponseError(w, http.StatusBadRequest, err)
+		return collection, false
+	}
+
+	collection, err := app.Storage.GetCollection(collectionID)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			WriteResponse(w, http.StatusNotFound, "collection not found")
+			return collection, false
+		}
+		WriteResponseError(w, http.StatusInternalServerError, errors.Wrap(err, "failed to get collection"))
+		return collection, false
+	}
+	return collection, true
+}
+
+// ownedCollection is requestedCollection for requests that change a collection, only its owner
+// may do that
+func (app *App) ownedCollection(w http.ResponseWriter, r *http.Request) (collection types.Collection, ok bool) {
+	user, status, err := app.SessionUser(r)
+	if err != nil {
+		WriteResponseError(w, status, err)
+		return collection, false
+	}
+
+	collection, ok = app.requestedCollection(w, r)
+	if !ok {
+		return
+	}
+	if !app.canModerate(user, collection.OwnerID) {
+		WriteResponse(w, http.StatusForbidden, "collection belongs to another user")
+		return collection, false
+	}
+	return collection, true
+}
+
+// updateCollection makes a change to the requested collection and saves it, if another request
+// changed the collection in the meantime the change is made again to the latest revision. change
+// writes the response itself when it can't be made.
+func (app *App) updateCollection(w http.ResponseWriter, r *http.Request, change func(*types.Collection) bool) (collection types.Collection, ok bool) {
+	for attempt := 0; attempt < collectionUpdateAttempts; attempt++ {
+		collection, ok = app.ownedCollection(w, r)
+		if !ok {
+			return
+		}
+
+		if !change(&collection) {
+			return collection, false
+		}
+
+		err := app.Storage.UpdateCollection(collection)
+		if err == storage.ErrCollectionChanged {
+			continue
+		}
+		if err != nil {
+			WriteResponseError(w, http.StatusInternalServerError, errors.Wrap(err, "failed to update collection"))
+			return collection, false
+		}
+		collection.Revision++
+		return collection, true
+	}
+
+	WriteResponseError(w, http.StatusConflict, storage.ErrCollectionChanged)
+	return collection, false
+}
+
+// collectionObjects looks up a collection and the objects in it that are not in the trash
+func (app *App) collectionObjects(w http.ResponseWriter, r *http.Request) (collection types.Collection, objects []types.Object, ok bool) {
+	collection, ok = app.requestedCollection(w, r)
+	if !ok {
+		return
+	}
+
+	for _, objectID := range collection.Objects {
+		object, err := app.Storage.GetObject(objectID)
+		if err == storage.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			WriteResponseError(w, http.StatusInternalServerError, errors.Wrap(err, "failed to get object"))
+			return collection, nil, false
+		}
+		if object.Deleted != nil {
+			continue
+		}
+		objects = append(objects, object)
+	}
+	return collection, objects, true
+}
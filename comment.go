@@ -1,39 +1,31 @@
 package main
 
 import (
-	"encoding/json"
 	"log"
 	"net/http"
 
 	"github.com/gorilla/mux"
 	"github.com/pkg/errors"
+	"gopkg.in/mgo.v2/bson"
 
+	"github.com/Southclaws/samp-objects-api/storage"
 	"github.com/Southclaws/samp-objects-api/types"
 )
 
-// CommentList handles the GET /comments/{objectid} endpoint and returns a list of comments for the
+// CommentList handles the GET /comments/{objectid} endpoint and returns a page of comments for the
 // specified object ID
 func (app *App) CommentList(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	objectID := types.ObjectID(vars["objectid"])
 
-	comments, err := app.Storage.GetComments(objectID)
+	page, err := pageQuery(r)
 	if err != nil {
-		WriteResponseError(w, http.StatusInternalServerError, errors.Wrap(err, "failed to get comments"))
+		WriteResponseError(w, http.StatusBadRequest, err)
 		return
 	}
 
-	payload, err := json.Marshal(comments)
-	if err != nil {
-		WriteResponseError(w, http.StatusInternalServerError, errors.Wrap(err, "failed to encode payload"))
-		return
-	}
-
-	_, err = w.Write(payload)
-	if err != nil {
-		WriteResponseError(w, http.StatusInternalServerError, errors.Wrap(err, "failed to write response"))
-		return
-	}
+	comments, err := app.Storage.GetComments(objectID, page)
+	writePage(w, comments, err)
 }
 
 // CommentCreate handles the POST /comments/{objectid} endpoint and creates a comment on the
@@ -65,7 +57,55 @@ func (app *App) CommentUpdate(w http.ResponseWriter, r *http.Request) {
 	//
 }
 
-// CommentRemove handles the DELETE /comments/{commentid} endpoint
+// CommentRemove handles the DELETE /comments/{objectid}/{commentid} endpoint, it moves a comment to
+// the trash if the requesting user wrote it
 func (app *App) CommentRemove(w http.ResponseWriter, r *http.Request) {
-	//
+	app.commentTrash(w, r, false)
+}
+
+// CommentRestore handles the POST /comments/{objectid}/{commentid}/restore endpoint, it takes a
+// comment back out of the trash
+func (app *App) CommentRestore(w http.ResponseWriter, r *http.Request) {
+	app.commentTrash(w, r, true)
+}
+
+func (app *App) commentTrash(w http.ResponseWriter, r *http.Request, restore bool) {
+	vars := mux.Vars(r)
+	if !bson.IsObjectIdHex(vars["commentid"]) {
+		WriteResponse(w, http.StatusBadRequest, "invalid comment ID")
+		return
+	}
+	commentID := bson.ObjectIdHex(vars["commentid"])
+
+	user, status, err := app.SessionUser(r)
+	if err != nil {
+		WriteResponseError(w, status, err)
+		return
+	}
+
+	comment, err := app.Storage.GetComment(commentID)
+	if err != nil || comment.ObjectID != types.ObjectID(vars["objectid"]) {
+		WriteResponse(w, http.StatusNotFound, "comment not found")
+		return
+	}
+	if !app.canModerate(user, comment.UserID) {
+		WriteResponse(w, http.StatusForbidden, "comment belongs to another user")
+		return
+	}
+
+	if restore {
+		err = app.Storage.RestoreComment(commentID)
+	} else {
+		err = app.Storage.TrashComment(commentID)
+	}
+	if err != nil {
+		if err == storage.ErrNotFound {
+			WriteResponse(w, http.StatusConflict, "comment is already in that state")
+			return
+		}
+		WriteResponseError(w, http.StatusInternalServerError, errors.Wrap(err, "failed to update comment"))
+		return
+	}
+
+	WriteResponse(w, http.StatusOK, "comment updated")
 }
@@ -3,8 +3,10 @@ package main
 import (
 	"context"
 	"net/http"
+	"os"
 	"sync"
 
+	"github.com/dustin/go-humanize"
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
 	"github.com/gorilla/sessions"
@@ -20,17 +22,25 @@ type App struct {
 	cancel         context.CancelFunc
 	config         Config
 	router         *mux.Router
-	Storage        *storage.Database
+	Storage        storage.Storage
 	Sessions       *sessions.CookieStore
 	Uploads        *sync.Map
 	FinishRequests chan types.ObjectID
+	uploadPolicy   UploadPolicy
+	adminID        types.UserID // the only user with admin rights, see SetupAuth
+
+	archiveLimit         int64
+	archiveUnpackedLimit int64
 }
 
 // ActiveUpload represents an object that's currently being uploaded, it contains a channel where
-// new files are added and a types.Object that represents the current state of the object.
+// new files are added and a types.Object that represents the current state of the object. When an
+// existing object is being given a new version, release is set and object only holds the new files.
 type ActiveUpload struct {
-	ch     chan types.ObjectFile
-	object types.Object
+	ch        chan types.ObjectFile
+	object    types.Object
+	release   bool
+	changelog string
 }
 
 const (
@@ -44,30 +54,90 @@ func Initialise(config Config) *App {
 	logger.Debug("initialising samp-servers-api with debug logging", zap.Any("config", config))
 
 	app := App{
-		config: config,
+		config:  config,
+		Uploads: &sync.Map{},
 	}
 	app.ctx, app.cancel = context.WithCancel(context.Background())
 
-	app.Storage, err = storage.New(storage.Config{
-		MongoHost:     config.MongoHost,
-		MongoPort:     config.MongoPort,
-		MongoUser:     config.MongoUser,
-		MongoPass:     config.MongoPass,
-		MongoName:     config.MongoName,
-		StoreHost:     config.StoreHost,
-		StorePort:     config.StorePort,
-		StoreAccess:   config.StoreAccess,
-		StoreSecret:   config.StoreSecret,
-		StoreSecure:   config.StoreSecure,
-		StoreBucket:   config.StoreBucket,
-		StoreLocation: config.StoreLocation,
-	})
+	app.uploadPolicy, err = NewUploadPolicy(config.UploadTypes, config.UploadLimits)
+	if err != nil {
+		logger.Fatal("invalid upload configuration",
+			zap.Error(err))
+	}
+	for _, limit := range []struct {
+		raw   string
+		value *int64
+	}{
+		{config.UploadArchiveLimit, &app.archiveLimit},
+		{config.UploadArchiveUnpackedLimit, &app.archiveUnpackedLimit},
+	} {
+		var size uint64
+		size, err = humanize.ParseBytes(limit.raw)
+		if err != nil {
+			logger.Fatal("invalid archive upload limit",
+				zap.Error(err))
+		}
+		*limit.value = int64(size)
+	}
+
+	switch config.StorageBackend {
+	case "memory":
+		logger.Warn("using in-memory storage backend, nothing will be persisted")
+		app.Storage = storage.NewMemory()
+	case "mongo":
+		var database *storage.Database
+		database, err = storage.New(storage.Config{
+			MongoHost:        config.MongoHost,
+			MongoPort:        config.MongoPort,
+			MongoUser:        config.MongoUser,
+			MongoPass:        config.MongoPass,
+			MongoName:        config.MongoName,
+			MigrationsDryRun: config.MigrateDryRun,
+			StoreType:        config.StoreType,
+			StorePath:        config.StorePath,
+			StoreHost:        config.StoreHost,
+			StorePort:        config.StorePort,
+			StoreAccess:      config.StoreAccess,
+			StoreSecret:      config.StoreSecret,
+			StoreSecure:      config.StoreSecure,
+			StoreBucket:      config.StoreBucket,
+			StoreLocation:    config.StoreLocation,
+		})
+		if err == nil && config.MigrateDryRun {
+			reportMigrations(database)
+		}
+		app.Storage = database
+	case "sql":
+		app.Storage, err = storage.NewSQL(storage.Config{
+			SQLDriver:     config.SQLDriver,
+			SQLSource:     config.SQLSource,
+			StoreType:     config.StoreType,
+			StorePath:     config.StorePath,
+			StoreHost:     config.StoreHost,
+			StorePort:     config.StorePort,
+			StoreAccess:   config.StoreAccess,
+			StoreSecret:   config.StoreSecret,
+			StoreSecure:   config.StoreSecure,
+			StoreBucket:   config.StoreBucket,
+			StoreLocation: config.StoreLocation,
+		})
+	default:
+		logger.Fatal("unknown storage backend",
+			zap.String("backend", config.StorageBackend))
+	}
 	if err != nil {
 		logger.Fatal("failed to interact with database",
 			zap.Error(err))
 	}
 	app.SetupAuth()
 
+	if config.RatingReconcileInterval > 0 {
+		go app.RatingReconciler(config.RatingReconcileInterval)
+	}
+	if config.TrashSweepInterval > 0 {
+		go app.TrashSweeper(config.TrashSweepInterval, config.TrashRetention)
+	}
+
 	// Set up session manager
 	// app.Sessions = sessions.NewCookieStore(securecookie.GenerateRandomKey(64))
 	app.Sessions = sessions.NewCookieStore([]byte(`securecookie.GenerateRandomKey(64)`))
@@ -76,7 +146,13 @@ func Initialise(config Config) *App {
 	app.router = mux.NewRouter().StrictSlash(true)
 
 	for _, route := range app.routes() {
-		if route.Authenticated {
+		if route.Admin {
+			app.router.
+				Methods(route.Methods...).
+				Name(route.Name).
+				Path(route.Path).
+				Handler(app.Authenticated(app.Admin(route.handler)))
+		} else if route.Authenticated {
 			app.router.
 				Methods(route.Methods...).
 				Name(route.Name).
@@ -94,12 +170,31 @@ func Initialise(config Config) *App {
 	return &app
 }
 
+// reportMigrations logs each pending database migration then exits without starting the server
+func reportMigrations(database *storage.Database) {
+	pending, err := database.PendingMigrations()
+	if err != nil {
+		logger.Fatal("failed to check for pending migrations",
+			zap.Error(err))
+	}
+
+	for _, migration := range pending {
+		logger.Info("pending migration",
+			zap.Int("version", migration.Version),
+			zap.String("description", migration.Description))
+	}
+	logger.Info("migration dry run complete",
+		zap.Int("pending", len(pending)))
+
+	os.Exit(0)
+}
+
 // Start begins listening for requests and blocks until fatal error
 func (app *App) Start() {
 	defer app.cancel()
 
 	err := http.ListenAndServe(app.config.Bind, handlers.CORS(
-		handlers.AllowedHeaders([]string{"Cache-Control", "X-File-Name", "X-Requested-With", "X-File-Name", "Content-Type", "Authorization", "Set-Cookie", "Cookie"}),
+		handlers.AllowedHeaders([]string{"Cache-Control", "X-File-Name", "X-Requested-With", "X-File-Name", "Content-Type", "Authorization", "Set-Cookie", "Cookie", "Range", "If-Range", "If-None-Match", "If-Modified-Since"}),
 		handlers.AllowedOrigins([]string{"https://" + app.config.Domain, "http://localhost:3000"}),
 		handlers.AllowedMethods([]string{"OPTIONS", "GET", "HEAD", "POST", "PUT"}),
 		handlers.AllowCredentials(),
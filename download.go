@@ -0,0 +1,183 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/Southclaws/samp-objects-api/artconfig"
+	"github.com/Southclaws/samp-objects-api/types"
+)
+
+// PackManifest is written to manifest.json in every download so the contents can be verified
+type PackManifest struct {
+	Generated time.Time    `json:"generated"`
+	Objects   []PackObject `json:"objects"`
+}
+
+// PackObject lists the files of one object in a download
+type PackObject struct {
+	ID      types.ObjectID   `json:"id"`
+	Name    types.ObjectName `json:"name"`
+	Owner   types.UserName   `json:"owner"`
+	Version int              `json:"version"`
+	Files   []PackFile       `json:"files"`
+}
+
+// PackFile is a file in a download, Path is where it is in the archive
+type PackFile struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	CRC32  string `json:"crc32"`
+	SHA256 string `json:"sha256"`
+}
+
+// ObjectDownload handles the /download/{objectid} and /download?objects= endpoints, it streams a
+// ZIP of the latest files of one object or a comma separated list of objects. Models and textures
+// are placed in models/ the same way artconfig expects them, with a models/artconfig.txt to load
+// them, images are placed in images/ and manifest.json lists the checksums of everything.
+func (app *App) ObjectDownload(w http.ResponseWriter, r *http.Request) {
+	objects, ok := app.requestedObjects(w, r)
+	if !ok {
+		return
+	}
+	if err := checkPackFolders(objects); err != nil {
+		WriteResponseError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	name := "objects.zip"
+	if len(objects) == 1 {
+		name = path.Base(artconfig.Folder(objects[0])) + ".zip"
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+
+	// once the archive has started the status can't be changed, so a failure part way through
+	// leaves the client with a truncated archive that won't open
+	err := app.writePack(w, objects)
+	if err != nil {
+		logger.Error("failed to write object download",
+			zap.Error(err),
+			zap.Int("objects", len(objects)))
+	}
+}
+
+// writePack streams a ZIP of objects to w, each file is copied from the file store straight into
+// the archive
+func (app *App) writePack(w io.Writer, objects []types.Object) (err error) {
+	err = checkPackFolders(objects)
+	if err != nil {
+		return
+	}
+
+	archive := zip.NewWriter(w)
+
+	manifest := PackManifest{Generated: time.Now()}
+	for _, object := range objects {
+		history := object.History()
+		entry := PackObject{
+			ID:      object.ID,
+			Name:    object.Name,
+			Owner:   object.OwnerName,
+			Version: history[len(history)-1].Version,
+		}
+		for _, file := range packFiles(object) {
+			info, _ := object.FileInfo(file.name)
+			entry.Files = append(entry.Files, PackFile{
+				Path:   file.path,
+				Size:   info.Size,
+				CRC32:  info.CRC32,
+				SHA256: info.SHA256,
+			})
+		}
+		manifest.Objects = append(manifest.Objects, entry)
+	}
+
+	f, err := archive.Create("manifest.json")
+	if err != nil {
+		return
+	}
+	encoder := json.NewEncoder(f)
+	encoder.SetIndent("", "  ")
+	err = encoder.Encode(manifest)
+	if err != nil {
+		return errors.Wrap(err, "failed to write manifest")
+	}
+
+	// the artconfig is a convenience, objects that can't be paired up are still downloaded
+	entries, err := artconfig.Generate(objects, artconfig.DefaultOptions)
+	if err == nil {
+		f, err = archive.Create("models/artconfig.txt")
+		if err != nil {
+			return
+		}
+		err = artconfig.Write(f, objects, entries)
+		if err != nil {
+			return errors.Wrap(err, "failed to write artconfig")
+		}
+	}
+
+	for _, object := range objects {
+		for _, file := range packFiles(object) {
+			header := &zip.FileHeader{
+				Name:     file.path,
+				Method:   zip.Deflate,
+				Modified: object.Created,
+			}
+			if file.image {
+				header.Method = zip.Store // already compressed
+			}
+
+			f, err = archive.CreateHeader(header)
+			if err != nil {
+				return
+			}
+			err = app.Storage.GetObjectFile(object.ID, 0, file.name, f)
+			if err != nil {
+				return errors.Wrapf(err, "failed to copy %s", file.path)
+			}
+		}
+	}
+
+	return archive.Close()
+}
+
+// checkPackFolders makes sure no two objects of a download are placed in the same folder, names
+// that only differ by characters artconfig replaces, such as "a b" and "a_b", would otherwise write
+// their files over each other
+func checkPackFolders(objects []types.Object) error {
+	folders := make(map[string]types.ObjectID)
+	for _, object := range objects {
+		folder := artconfig.Folder(object)
+		if other, ok := folders[folder]; ok && other != object.ID {
+			return errors.Errorf("objects %s and %s would both be placed in %s", other, object.ID, folder)
+		}
+		folders[folder] = object.ID
+	}
+	return nil
+}
+
+type packFile struct {
+	name  types.File
+	path  string
+	image bool
+}
+
+// packFiles lists the files of an object and where they go in a download
+func packFiles(object types.Object) (files []packFile) {
+	for _, model := range append(append([]types.File{}, object.Models...), object.Textures...) {
+		files = append(files, packFile{model, path.Join("models", artconfig.Path(object, model)), false})
+	}
+	for _, image := range object.Images {
+		files = append(files, packFile{image, path.Join("images", artconfig.Path(object, image)), true})
+	}
+	return
+}
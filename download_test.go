@@ -0,0 +1,246 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Southclaws/samp-objects-api/artconfig"
+	"github.com/Southclaws/samp-objects-api/types"
+)
+
+// createPackObject stores an object along with the contents of its files
+func createPackObject(t *testing.T, app *App, object types.Object, files map[types.File][]byte) types.Object {
+	for _, name := range append(append(append([]types.File{}, object.Models...), object.Textures...), object.Images...) {
+		info, err := app.Storage.PutObjectFile(object.ID, string(name), "application/octet-stream", bytes.NewReader(files[name]))
+		assert.NoError(t, err)
+		object.Files = append(object.Files, info)
+	}
+	assert.NoError(t, app.Storage.CreateObject(object))
+	return object
+}
+
+// readPack opens the ZIP a download responded with and reads every entry in it
+func readPack(t *testing.T, w *httptest.ResponseRecorder) (names []string, contents map[string][]byte, methods map[string]uint16) {
+	archive, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if !assert.NoError(t, err) {
+		return
+	}
+	contents = make(map[string][]byte)
+	methods = make(map[string]uint16)
+	for _, f := range archive.File {
+		r, err := f.Open()
+		assert.NoError(t, err)
+		data, err := ioutil.ReadAll(r)
+		assert.NoError(t, err)
+		r.Close()
+
+		names = append(names, f.Name)
+		contents[f.Name] = data
+		methods[f.Name] = f.Method
+	}
+	return
+}
+
+func TestObjectDownload(t *testing.T) {
+	app := newTestApp()
+	files := map[types.File][]byte{
+		"door.dff":    testCollision(64, true),
+		"door.txd":    testCollision(48, false),
+		"preview.png": testPNG(t),
+		"lamp.dff":    testCollision(32, false),
+		"lamp.txd":    testCollision(16, true),
+	}
+	door := createPackObject(t, app, types.Object{
+		ID:        "00000000-0000-0000-0000-000000000001",
+		OwnerID:   "00000000-0000-0000-0000-000000000001",
+		OwnerName: "owner",
+		Name:      "Door Pack",
+		Category:  "category",
+		Images:    []types.File{"preview.png"},
+		Models:    []types.File{"door.dff"},
+		Textures:  []types.File{"door.txd"},
+	}, files)
+	lamp := createPackObject(t, app, types.Object{
+		ID:        "00000000-0000-0000-0000-000000000002",
+		OwnerID:   "00000000-0000-0000-0000-000000000002",
+		OwnerName: "other/owner",
+		Name:      "lamp",
+		Category:  "category",
+		Images:    []types.File{"preview.png"},
+		Models:    []types.File{"lamp.dff"},
+		Textures:  []types.File{"lamp.txd"},
+	}, files)
+
+	download := func(objectID string, objects string) *httptest.ResponseRecorder {
+		var r *http.Request
+		if objectID != "" {
+			r = mux.SetURLVars(httptest.NewRequest("GET", "/v0/download/"+objectID, nil),
+				map[string]string{"objectid": objectID})
+		} else {
+			r = httptest.NewRequest("GET", "/v0/download?objects="+url.QueryEscape(objects), nil)
+		}
+		w := httptest.NewRecorder()
+		app.ObjectDownload(w, r)
+		return w
+	}
+
+	t.Run("single object", func(t *testing.T) {
+		w := download(string(door.ID), "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/zip", w.Header().Get("Content-Type"))
+		assert.Equal(t, `attachment; filename="Door_Pack.zip"`, w.Header().Get("Content-Disposition"))
+
+		names, contents, methods := readPack(t, w)
+		assert.Equal(t, []string{
+			"manifest.json",
+			"models/artconfig.txt",
+			"models/owner/Door_Pack/door.dff",
+			"models/owner/Door_Pack/door.txd",
+			"images/owner/Door_Pack/preview.png",
+		}, names)
+		assert.Equal(t, files["door.dff"], contents["models/owner/Door_Pack/door.dff"])
+		assert.Equal(t, files["door.txd"], contents["models/owner/Door_Pack/door.txd"])
+		assert.Equal(t, files["preview.png"], contents["images/owner/Door_Pack/preview.png"])
+		assert.Equal(t, zip.Deflate, methods["models/owner/Door_Pack/door.dff"])
+		assert.Equal(t, zip.Store, methods["images/owner/Door_Pack/preview.png"])
+
+		assert.Contains(t, string(contents["models/artconfig.txt"]), fmt.Sprintf(
+			`AddSimpleModel(-1, 19379, %d, "owner/Door_Pack/door.dff", "owner/Door_Pack/door.txd");`, artconfig.FirstModelID))
+
+		var manifest PackManifest
+		assert.NoError(t, json.Unmarshal(contents["manifest.json"], &manifest))
+		assert.False(t, manifest.Generated.IsZero())
+		if assert.Len(t, manifest.Objects, 1) {
+			object := manifest.Objects[0]
+			assert.Equal(t, door.ID, object.ID)
+			assert.Equal(t, door.Name, object.Name)
+			assert.Equal(t, door.OwnerName, object.Owner)
+			assert.Equal(t, 1, object.Version)
+
+			var paths []string
+			for _, file := range object.Files {
+				paths = append(paths, file.Path)
+				assert.Equal(t, int64(len(contents[file.Path])), file.Size, file.Path)
+
+				// the checksums are the ones recorded when the file was stored
+				name := types.File(file.Path[strings.LastIndex(file.Path, "/")+1:])
+				info, ok := door.FileInfo(name)
+				assert.True(t, ok, file.Path)
+				assert.Equal(t, info.CRC32, file.CRC32, file.Path)
+				assert.Equal(t, info.SHA256, file.SHA256, file.Path)
+			}
+			assert.Equal(t, names[2:], paths)
+		}
+	})
+
+	t.Run("several objects", func(t *testing.T) {
+		w := download("", string(door.ID)+", "+string(lamp.ID)+","+string(door.ID))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, `attachment; filename="objects.zip"`, w.Header().Get("Content-Disposition"))
+
+		names, contents, _ := readPack(t, w)
+		assert.Equal(t, []string{
+			"manifest.json",
+			"models/artconfig.txt",
+			"models/owner/Door_Pack/door.dff",
+			"models/owner/Door_Pack/door.txd",
+			"images/owner/Door_Pack/preview.png",
+			"models/other_owner/lamp/lamp.dff",
+			"models/other_owner/lamp/lamp.txd",
+			"images/other_owner/lamp/preview.png",
+		}, names)
+		assert.Contains(t, string(contents["models/artconfig.txt"]), fmt.Sprintf(
+			`AddSimpleModel(-1, 19379, %d, "other_owner/lamp/lamp.dff", "other_owner/lamp/lamp.txd");`, artconfig.FirstModelID-1))
+
+		var manifest PackManifest
+		assert.NoError(t, json.Unmarshal(contents["manifest.json"], &manifest))
+		if assert.Len(t, manifest.Objects, 2) {
+			assert.Equal(t, door.ID, manifest.Objects[0].ID)
+			assert.Equal(t, lamp.ID, manifest.Objects[1].ID)
+			assert.Len(t, manifest.Objects[1].Files, 3)
+		}
+	})
+
+	t.Run("rejected", func(t *testing.T) {
+		missing := "00000000-0000-0000-0000-000000000009"
+		assert.Equal(t, http.StatusBadRequest, download("", "").Code)
+		assert.Equal(t, http.StatusBadRequest, download("", "not-an-id").Code)
+		assert.Equal(t, http.StatusNotFound, download(missing, "").Code)
+
+		w := download("", string(door.ID)+","+missing)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.NotEqual(t, "application/zip", w.Header().Get("Content-Type"))
+
+		// objects whose names only differ by characters that are replaced would share a folder
+		clash := createPackObject(t, app, types.Object{
+			ID:        "00000000-0000-0000-0000-000000000003",
+			OwnerID:   "00000000-0000-0000-0000-000000000001",
+			OwnerName: "owner",
+			Name:      "Door_Pack",
+			Category:  "category",
+			Images:    []types.File{"preview.png"},
+			Models:    []types.File{"door.dff"},
+			Textures:  []types.File{"door.txd"},
+		}, files)
+		w = download("", string(door.ID)+","+string(clash.ID))
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		assert.NotEqual(t, "application/zip", w.Header().Get("Content-Type"))
+		assert.Error(t, app.writePack(ioutil.Discard, []types.Object{door, clash}))
+
+		assert.NoError(t, app.Storage.TrashObject(lamp.ID))
+		assert.Equal(t, http.StatusNotFound, download(string(lamp.ID), "").Code)
+		w = download("", string(door.ID)+","+string(lamp.ID))
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.NotEqual(t, "application/zip", w.Header().Get("Content-Type"))
+	})
+}
+
+func TestApp_writePack(t *testing.T) {
+	app := newTestApp()
+	object := createPackObject(t, app, types.Object{
+		ID:        "00000000-0000-0000-0000-000000000001",
+		OwnerID:   "00000000-0000-0000-0000-000000000001",
+		OwnerName: "owner",
+		Name:      "unpaired",
+		Category:  "category",
+		Images:    []types.File{"preview.png"},
+		Models:    []types.File{"door.dff"},
+		Textures:  []types.File{"window.txd", "wall.txd"},
+	}, map[types.File][]byte{"preview.png": testPNG(t)})
+
+	// objects that artconfig can't pair up are downloaded without a models/artconfig.txt
+	var buf bytes.Buffer
+	assert.NoError(t, app.writePack(&buf, []types.Object{object}))
+	archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if assert.NoError(t, err) {
+		var names []string
+		for _, f := range archive.File {
+			names = append(names, f.Name)
+		}
+		assert.Equal(t, []string{
+			"manifest.json",
+			"models/owner/unpaired/door.dff",
+			"models/owner/unpaired/window.txd",
+			"models/owner/unpaired/wall.txd",
+			"images/owner/unpaired/preview.png",
+		}, names)
+	}
+
+	// a file that is listed but missing from the file store fails the download
+	object.Images = append(object.Images, "missing.png")
+	err = app.writePack(ioutil.Discard, []types.Object{object})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "failed to copy images/owner/unpaired/missing.png")
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/dustin/go-humanize"
+	"github.com/pkg/errors"
+)
+
+// FileType is a kind of file that can be uploaded, Kind is how it is listed on an object: "image",
+// "model", "texture" or "collision" for files that are only kept in the object's file list
+type FileType struct {
+	Name        string
+	Kind        string
+	ContentType string
+	Extensions  []string
+	match       func(header []byte) bool
+}
+
+// sniffLength is the number of bytes read from the start of an upload to detect its type
+const sniffLength = 12
+
+// fileTypes is every type of file that can be recognised, which ones are accepted is configured by
+// SAMPOBJECTS_UPLOAD_TYPES
+var fileTypes = []FileType{
+	{"dff", "model", "application/octet-stream", []string{".dff"}, func(h []byte) bool { return rwChunk(h, 0x10) || rwChunk(h, 0x2B) }},
+	{"txd", "texture", "application/octet-stream", []string{".txd"}, func(h []byte) bool { return rwChunk(h, 0x16) }},
+	{"col", "collision", "application/octet-stream", []string{".col"}, func(h []byte) bool {
+		return len(h) >= 4 && (string(h[:4]) == "COLL" || (string(h[:3]) == "COL" && h[3] >= '2' && h[3] <= '4'))
+	}},
+	{"png", "image", "image/png", []string{".png"}, func(h []byte) bool { return bytes.HasPrefix(h, []byte("\x89PNG\r\n\x1a\n")) }},
+	{"jpeg", "image", "image/jpeg", []string{".jpg", ".jpeg"}, func(h []byte) bool { return bytes.HasPrefix(h, []byte("\xFF\xD8\xFF")) }},
+	{"gif", "image", "image/gif", []string{".gif"}, func(h []byte) bool {
+		return bytes.HasPrefix(h, []byte("GIF87a")) || bytes.HasPrefix(h, []byte("GIF89a"))
+	}},
+}
+
+// rwChunk reports whether header starts with a RenderWare chunk of the given type, the version
+// stamp is checked too since a bare type is only a few zero bytes
+func rwChunk(header []byte, chunkType uint32) bool {
+	if len(header) < 12 || binary.LittleEndian.Uint32(header) != chunkType {
+		return false
+	}
+	stamp := binary.LittleEndian.Uint32(header[8:])
+	return stamp>>16 != 0 || (stamp >= 0x300 && stamp <= 0x3FF)
+}
+
+// fileTypeByName returns the type of file that a name's extension claims it is
+func fileTypeByName(filename string) (FileType, bool) {
+	ext := strings.ToLower(filepath.Ext(filename))
+	for _, t := range fileTypes {
+		for _, e := range t.Extensions {
+			if e == ext {
+				return t, true
+			}
+		}
+	}
+	return FileType{}, false
+}
+
+// detectFileType returns the type of file that the first bytes of a file belong to
+func detectFileType(header []byte) (FileType, bool) {
+	for _, t := range fileTypes {
+		if t.match(header) {
+			return t, true
+		}
+	}
+	return FileType{}, false
+}
+
+// UploadPolicy is the set of file types that can be uploaded and the largest size of each, in bytes
+type UploadPolicy map[string]int64
+
+// NewUploadPolicy allows the named file types, limits holds a size such as "16MB" for each type
+func NewUploadPolicy(allowed []string, limits map[string]string) (policy UploadPolicy, err error) {
+	policy = make(UploadPolicy)
+	for _, name := range allowed {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !knownFileType(name) {
+			return nil, errors.Errorf("unknown upload type '%s'", name)
+		}
+
+		raw, ok := limits[name]
+		if !ok {
+			return nil, errors.Errorf("no size limit for upload type '%s'", name)
+		}
+		var size uint64
+		size, err = humanize.ParseBytes(raw)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid size limit for upload type '%s'", name)
+		}
+		policy[name] = int64(size)
+	}
+	return
+}
+
+func knownFileType(name string) bool {
+	for _, t := range fileTypes {
+		if t.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Check reads the start of an upload to make sure that its contents match its extension and that
+// its type is allowed, the returned reader yields the whole file and fails once it passes the size
+// limit for its type
+func (policy UploadPolicy) Check(filename string, reader io.Reader) (fileType FileType, r io.Reader, err error) {
+	fileType, ok := fileTypeByName(filename)
+	if !ok {
+		return fileType, nil, errors.Errorf("%s is not a supported file type", filename)
+	}
+	limit, ok := policy[fileType.Name]
+	if !ok {
+		return fileType, nil, errors.Errorf("%s files can not be uploaded", strings.ToUpper(fileType.Name))
+	}
+
+	header := make([]byte, sniffLength)
+	n, err := io.ReadFull(reader, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return fileType, nil, errors.Wrap(err, "failed to read file")
+	}
+	header = header[:n]
+
+	detected, ok := detectFileType(header)
+	if !ok {
+		return fileType, nil, errors.Errorf("%s is not a %s file", filename, strings.ToUpper(fileType.Name))
+	}
+	if detected.Name != fileType.Name {
+		return fileType, nil, errors.Errorf("%s contains a %s file but is named as %s", filename, strings.ToUpper(detected.Name), strings.ToUpper(fileType.Name))
+	}
+
+	return fileType, &limitedReader{io.MultiReader(bytes.NewReader(header), reader), filename, limit, limit}, nil
+}
+
+// limitedReader fails with an error once more than limit bytes have been read
+type limitedReader struct {
+	r         io.Reader
+	name      string
+	limit     int64
+	remaining int64
+}
+
+func (l *limitedReader) Read(p []byte) (n int, err error) {
+	n, err = l.r.Read(p)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		return n, errors.Errorf("%s is larger than the %s limit", l.name, humanize.Bytes(uint64(l.limit)))
+	}
+	return
+}
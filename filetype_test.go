@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// rwHeader returns the start of a RenderWare file of n bytes holding a chunk of the given type
+func rwHeader(chunkType uint32, n int) []byte {
+	data := make([]byte, n)
+	binary.LittleEndian.PutUint32(data, chunkType)
+	binary.LittleEndian.PutUint32(data[4:], uint32(n-12))
+	binary.LittleEndian.PutUint32(data[8:], 0x1803FFFF)
+	return data
+}
+
+// padded returns header followed by zeros up to n bytes
+func padded(header string, n int) []byte {
+	data := make([]byte, n)
+	copy(data, header)
+	return data
+}
+
+func TestNewUploadPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		allowed []string
+		limits  map[string]string
+		want    UploadPolicy
+		wantErr string
+	}{
+		{"per type limits", []string{"dff", " PNG "}, map[string]string{"dff": "16MB", "png": "512KB", "gif": "1MB"},
+			UploadPolicy{"dff": 16000000, "png": 512000}, ""},
+		{"unknown type", []string{"exe"}, map[string]string{"exe": "1MB"}, nil, "unknown upload type 'exe'"},
+		{"missing limit", []string{"txd"}, map[string]string{"dff": "1MB"}, nil, "no size limit for upload type 'txd'"},
+		{"invalid limit", []string{"col"}, map[string]string{"col": "lots"}, nil, "invalid size limit for upload type 'col'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewUploadPolicy(tt.allowed, tt.limits)
+			if tt.wantErr != "" {
+				assert.Error(t, err)
+				if err != nil {
+					assert.Contains(t, err.Error(), tt.wantErr)
+				}
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestUploadPolicy_Check(t *testing.T) {
+	policy := UploadPolicy{"dff": 64, "txd": 64, "col": 16, "png": 32, "jpeg": 32}
+
+	tests := []struct {
+		name     string
+		filename string
+		data     []byte
+		wantType string
+		wantErr  string // from Check
+		readErr  string // from reading the file Check returns
+	}{
+		{"model", "door.dff", rwHeader(0x10, 64), "dff", "", ""},
+		{"texture", "door.TXD", rwHeader(0x16, 40), "txd", "", ""},
+		{"collision", "door.col", padded("COL3", 16), "col", "", ""},
+		{"png", "preview.png", padded("\x89PNG\r\n\x1a\n", 32), "png", "", ""},
+		{"jpeg with either extension", "preview.jpeg", padded("\xFF\xD8\xFF\xE0", 20), "jpeg", "", ""},
+		{"smaller than the sniffed header", "door.col", []byte("COLL"), "col", "", ""},
+		{"unknown extension", "readme.txt", []byte("hello"), "", "readme.txt is not a supported file type", ""},
+		{"type not allowed", "preview.gif", padded("GIF89a", 20), "gif", "GIF files can not be uploaded", ""},
+		{"extension mismatch", "door.dff", padded("\x89PNG\r\n\x1a\n", 20), "dff", "door.dff contains a PNG file but is named as DFF", ""},
+		{"texture named as a model", "door.dff", rwHeader(0x16, 20), "dff", "door.dff contains a TXD file but is named as DFF", ""},
+		{"unrecognised contents", "door.col", []byte("not a collision file"), "col", "door.col is not a COL file", ""},
+		{"empty file", "preview.png", nil, "png", "preview.png is not a PNG file", ""},
+		{"oversized", "preview.png", padded("\x89PNG\r\n\x1a\n", 33), "png", "", "preview.png is larger than the 32 B limit"},
+		{"oversized for its own type", "door.col", padded("COLL", 20), "col", "", "door.col is larger than the 16 B limit"},
+		{"under the limit of its type", "door.dff", rwHeader(0x10, 20), "dff", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fileType, r, err := policy.Check(tt.filename, iotest.OneByteReader(bytes.NewReader(tt.data)))
+			assert.Equal(t, tt.wantType, fileType.Name)
+			if tt.wantErr != "" {
+				assert.Error(t, err)
+				if err != nil {
+					assert.Equal(t, tt.wantErr, err.Error())
+				}
+				return
+			}
+			assert.NoError(t, err)
+
+			data, err := ioutil.ReadAll(r)
+			if tt.readErr != "" {
+				assert.Error(t, err)
+				if err != nil {
+					assert.Equal(t, tt.readErr, err.Error())
+				}
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.data, data)
+		})
+	}
+}
+
+func Test_limitedReader(t *testing.T) {
+	tests := []struct {
+		name    string
+		size    int
+		limit   int64
+		wantErr bool
+	}{
+		{"empty", 0, 10, false},
+		{"under", 9, 10, false},
+		{"exactly", 10, 10, false},
+		{"one over", 11, 10, true},
+		{"far over", 4096, 10, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &limitedReader{bytes.NewReader(make([]byte, tt.size)), "file", tt.limit, tt.limit}
+			data, err := ioutil.ReadAll(r)
+			if tt.wantErr {
+				assert.EqualError(t, err, "file is larger than the 10 B limit")
+				return
+			}
+			assert.NoError(t, err)
+			assert.Len(t, data, tt.size)
+		})
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"os"
 	"strconv"
+	"time"
 
 	// loads environment variables from .env
 	_ "github.com/joho/godotenv/autoload"
@@ -15,22 +16,40 @@ var version = "master"
 
 // Config stores app global configuration
 type Config struct {
-	Version       string
-	Bind          string `split_words:"true" required:"true"`
-	Domain        string `split_words:"true" required:"true"`
-	MongoHost     string `split_words:"true" required:"true"`
-	MongoPort     string `split_words:"true" required:"true"`
-	MongoName     string `split_words:"true" required:"true"`
-	MongoUser     string `split_words:"true" required:"true"`
-	MongoPass     string `split_words:"true" required:"false"`
-	AuthSecret    string `split_words:"true" required:"true"`
-	StoreHost     string `split_words:"true" required:"true"`
-	StorePort     string `split_words:"true" required:"true"`
-	StoreAccess   string `split_words:"true" required:"true"`
-	StoreSecret   string `split_words:"true" required:"true"`
-	StoreSecure   bool   `split_words:"true" required:"true"`
-	StoreBucket   string `split_words:"true" required:"true"`
-	StoreLocation string `split_words:"true" required:"true"`
+	Version        string
+	Bind           string `split_words:"true" required:"true"`
+	Domain         string `split_words:"true" required:"true"`
+	StorageBackend string `split_words:"true" default:"mongo"` // "mongo", "sql" or "memory"
+	MongoHost      string `split_words:"true" required:"false"`
+	MongoPort      string `split_words:"true" required:"false"`
+	MongoName      string `split_words:"true" required:"false"`
+	MongoUser      string `split_words:"true" required:"false"`
+	MongoPass      string `split_words:"true" required:"false"`
+	MigrateDryRun  bool   `split_words:"true" required:"false"` // report pending migrations and exit
+	SQLDriver      string `split_words:"true" required:"false"` // "sqlite3" or "postgres"
+	SQLSource      string `split_words:"true" required:"false"`
+	AuthSecret     string `split_words:"true" required:"true"`
+	StoreType      string `split_words:"true" default:"s3"` // "s3" or "directory"
+	StorePath      string `split_words:"true" required:"false"`
+	StoreHost      string `split_words:"true" required:"false"`
+	StorePort      string `split_words:"true" required:"false"`
+	StoreAccess    string `split_words:"true" required:"false"`
+	StoreSecret    string `split_words:"true" required:"false"`
+	StoreSecure    bool   `split_words:"true" required:"false"`
+	StoreBucket    string `split_words:"true" required:"false"`
+	StoreLocation  string `split_words:"true" required:"false"`
+
+	UploadTypes  []string          `split_words:"true" default:"dff,txd,png,jpeg,gif"`                               // any of these and "col"
+	UploadLimits map[string]string `split_words:"true" default:"dff:16MB,txd:32MB,col:4MB,png:8MB,jpeg:8MB,gif:8MB"` // largest file of each type
+
+	UploadArchiveLimit         string `split_words:"true" default:"64MB"`  // largest archive upload
+	UploadArchiveUnpackedLimit string `split_words:"true" default:"256MB"` // most bytes unpacked from one archive
+
+	RatingReconcileInterval time.Duration `split_words:"true" default:"1h"` // 0 disables the reconciler
+	TrashSweepInterval      time.Duration `split_words:"true" default:"1h"` // 0 disables the sweeper
+	TrashRetention          time.Duration `split_words:"true" default:"720h"`
+
+	AdminID string `split_words:"true" required:"false"` // user with admin rights, the root account when unset
 }
 
 var logger *zap.Logger
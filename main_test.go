@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/sessions"
+	"go.uber.org/zap"
+
+	"github.com/Southclaws/samp-objects-api/storage"
+	"github.com/Southclaws/samp-objects-api/types"
+)
+
+func TestMain(m *testing.M) {
+	logger = zap.NewNop()
+	os.Exit(m.Run())
+}
+
+// newTestApp returns an App backed by the in-memory storage that accepts every type of upload
+func newTestApp() *App {
+	policy, err := NewUploadPolicy(
+		[]string{"dff", "txd", "col", "png", "jpeg", "gif"},
+		map[string]string{"dff": "1MB", "txd": "1MB", "col": "1MB", "png": "1MB", "jpeg": "1MB", "gif": "1MB"})
+	if err != nil {
+		panic(err)
+	}
+	return &App{
+		Storage:              storage.NewMemory(),
+		Sessions:             sessions.NewCookieStore([]byte("test")),
+		Uploads:              &sync.Map{},
+		uploadPolicy:         policy,
+		archiveLimit:         1024 * 1024,
+		archiveUnpackedLimit: 4 * 1024 * 1024,
+	}
+}
+
+// withSession signs a request in as a user, the session is kept for the request so the handler
+// reads the same one
+func withSession(app *App, r *http.Request, userID types.UserID) *http.Request {
+	session, err := app.Sessions.Get(r, UserSessionCookie)
+	if err != nil {
+		panic(err)
+	}
+	session.Values["UserID"] = userID
+	return r
+}
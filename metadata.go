@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+)
+
+// stripMetadata copies an uploaded image from r to w without EXIF and other embedded metadata,
+// which can hold the location a photo was taken at or the camera's serial number. Only the
+// metadata is removed, the image data itself is copied byte for byte as it is read. The EXIF
+// orientation is the one exception, photos taken with the camera turned are stored sideways and
+// need it to be shown the right way up, so it is kept on its own. GIFs can't hold EXIF and are
+// copied as they are.
+func stripMetadata(fileType string, w io.Writer, r io.Reader) error {
+	switch fileType {
+	case "jpeg":
+		return stripJPEG(w, r)
+	case "png":
+		return stripPNG(w, r)
+	}
+	_, err := io.Copy(w, r)
+	return err
+}
+
+// exifHeader starts the APP1 segment that holds a JPEG's EXIF
+const exifHeader = "Exif\x00\x00"
+
+// maxEXIFSize is the largest EXIF that is read to find the orientation in, a JPEG segment can't be
+// any bigger
+const maxEXIFSize = 64 * 1024
+
+// exifOrientation returns the orientation in the first directory of an EXIF block, which is a
+// TIFF header and directories of tags, or 0 if it has none. Only 2 to 8 change how an image is
+// displayed.
+func exifOrientation(exif []byte) uint16 {
+	if len(exif) < 8 {
+		return 0
+	}
+	var order binary.ByteOrder
+	switch string(exif[:4]) {
+	case "II*\x00":
+		order = binary.LittleEndian
+	case "MM\x00*":
+		order = binary.BigEndian
+	default:
+		return 0
+	}
+
+	ifd := uint64(order.Uint32(exif[4:]))
+	if ifd+2 > uint64(len(exif)) {
+		return 0
+	}
+	for i, n := uint64(0), uint64(order.Uint16(exif[ifd:])); i < n; i++ {
+		entry := ifd + 2 + i*12 // tag, type, count and value
+		if entry+12 > uint64(len(exif)) {
+			return 0
+		}
+		if order.Uint16(exif[entry:]) == 0x0112 && order.Uint16(exif[entry+2:]) == 3 {
+			if orientation := order.Uint16(exif[entry+8:]); orientation >= 2 && orientation <= 8 {
+				return orientation
+			}
+			return 0
+		}
+	}
+	return 0
+}
+
+// orientationEXIF returns an EXIF block that only holds an orientation
+func orientationEXIF(orientation uint16) []byte {
+	exif := []byte("MM\x00*\x00\x00\x00\x08" + // big endian, first directory at 8
+		"\x00\x01" + // one tag
+		"\x01\x12\x00\x03\x00\x00\x00\x01\x00\x00\x00\x00" + // orientation, one SHORT
+		"\x00\x00\x00\x00") // no more directories
+	binary.BigEndian.PutUint16(exif[18:], orientation)
+	return exif
+}
+
+// jpegMetadata are the JPEG segments that are dropped, APP13 holds Photoshop's IPTC records. APP1
+// holds EXIF and XMP and is dropped apart from the orientation. APP0 (JFIF) and APP2 (the colour
+// profile) are needed to display the image correctly so they are kept.
+var jpegMetadata = map[byte]bool{
+	0xED: true,
+}
+
+// stripJPEG copies every marker segment up to the start of the scan except the metadata ones, the
+// compressed image data after it is copied as it is
+func stripJPEG(w io.Writer, r io.Reader) (err error) {
+	var (
+		in  = bufio.NewReader(r)
+		out = bufio.NewWriter(w)
+	)
+
+	var soi [2]byte
+	if _, err = io.ReadFull(in, soi[:]); err != nil || soi != [2]byte{0xFF, 0xD8} {
+		return errors.New("not a JPEG file")
+	}
+	out.Write(soi[:])
+
+	for {
+		b, err := in.ReadByte()
+		if err != nil || b != 0xFF {
+			return errors.New("JPEG file is truncated or corrupt")
+		}
+		// markers may be padded with any number of 0xFF bytes
+		for b == 0xFF {
+			if b, err = in.ReadByte(); err != nil {
+				return errors.New("JPEG file is truncated or corrupt")
+			}
+		}
+		marker := b
+
+		// standalone markers have no length
+		if marker == 0x01 || marker >= 0xD0 && marker <= 0xD7 {
+			out.Write([]byte{0xFF, marker})
+			continue
+		}
+		if marker == 0xD9 {
+			out.Write([]byte{0xFF, marker})
+			return out.Flush()
+		}
+
+		var length [2]byte
+		if _, err = io.ReadFull(in, length[:]); err != nil {
+			return errors.New("JPEG file is truncated or corrupt")
+		}
+		n := int64(binary.BigEndian.Uint16(length[:])) - 2
+		if n < 0 {
+			return errors.New("JPEG segment has an invalid length")
+		}
+
+		if marker == 0xE1 {
+			segment := make([]byte, n)
+			if _, err = io.ReadFull(in, segment); err != nil {
+				return errors.New("JPEG segment runs past the end of the file")
+			}
+			if bytes.HasPrefix(segment, []byte(exifHeader)) {
+				if orientation := exifOrientation(segment[len(exifHeader):]); orientation != 0 {
+					exif := append([]byte(exifHeader), orientationEXIF(orientation)...)
+					out.Write([]byte{0xFF, marker, byte((len(exif) + 2) >> 8), byte(len(exif) + 2)})
+					out.Write(exif)
+				}
+			}
+			continue
+		}
+
+		dst := ioutil.Discard
+		if !jpegMetadata[marker] {
+			out.Write([]byte{0xFF, marker})
+			out.Write(length[:])
+			dst = out
+		}
+		if _, err = io.CopyN(dst, in, n); err != nil {
+			return errors.New("JPEG segment runs past the end of the file")
+		}
+
+		if marker == 0xDA {
+			if _, err = io.Copy(out, in); err != nil {
+				return err
+			}
+			return out.Flush()
+		}
+	}
+}
+
+// pngMetadata are the PNG chunks that are dropped, eXIf holds EXIF and the text chunks are where
+// XMP and the EXIF written by older tools end up. The orientation is kept from an eXIf chunk small
+// enough to read.
+var pngMetadata = map[string]bool{
+	"eXIf": true,
+	"tEXt": true,
+	"zTXt": true,
+	"iTXt": true,
+}
+
+// stripPNG copies every chunk except the metadata ones, chunks carry their own CRC so only the
+// orientation chunk written in place of eXIf needs one calculated
+func stripPNG(w io.Writer, r io.Reader) (err error) {
+	const signature = "\x89PNG\r\n\x1a\n"
+
+	var (
+		in  = bufio.NewReader(r)
+		out = bufio.NewWriter(w)
+	)
+
+	var sig [8]byte
+	if _, err = io.ReadFull(in, sig[:]); err != nil || string(sig[:]) != signature {
+		return errors.New("not a PNG file")
+	}
+	out.Write(sig[:])
+
+	for {
+		var header [8]byte // length and type
+		if _, err = io.ReadFull(in, header[:]); err == io.EOF {
+			break
+		} else if err != nil {
+			return errors.New("PNG file is truncated or corrupt")
+		}
+		length := int64(binary.BigEndian.Uint32(header[:]))
+		chunkType := string(header[4:])
+
+		if chunkType == "eXIf" && length <= maxEXIFSize {
+			exif := make([]byte, length+4)
+			if _, err = io.ReadFull(in, exif); err != nil {
+				return errors.New("PNG chunk runs past the end of the file")
+			}
+			if orientation := exifOrientation(exif[:length]); orientation != 0 {
+				writePNGChunk(out, chunkType, orientationEXIF(orientation))
+			}
+			continue
+		}
+
+		dst := ioutil.Discard
+		if !pngMetadata[chunkType] {
+			out.Write(header[:])
+			dst = out
+		}
+		// the chunk's data is followed by its CRC
+		if _, err = io.CopyN(dst, in, length+4); err != nil {
+			return errors.New("PNG chunk runs past the end of the file")
+		}
+
+		if chunkType == "IEND" {
+			break
+		}
+	}
+	return out.Flush()
+}
+
+// writePNGChunk writes a chunk that is made up rather than copied, along with its CRC
+func writePNGChunk(w io.Writer, chunkType string, data []byte) {
+	chunk := make([]byte, len(data)+12)
+	binary.BigEndian.PutUint32(chunk, uint32(len(data)))
+	copy(chunk[4:], chunkType)
+	copy(chunk[8:], data)
+	binary.BigEndian.PutUint32(chunk[len(data)+8:], crc32.ChecksumIEEE(chunk[4:len(data)+8]))
+	w.Write(chunk)
+}
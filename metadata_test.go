@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testEXIF returns a little endian EXIF block with a camera make and, when it isn't 0, an
+// orientation
+func testEXIF(orientation uint16) []byte {
+	var entries [][]byte
+	entry := func(tag, kind uint16, count, value uint32) {
+		e := make([]byte, 12)
+		binary.LittleEndian.PutUint16(e, tag)
+		binary.LittleEndian.PutUint16(e[2:], kind)
+		binary.LittleEndian.PutUint32(e[4:], count)
+		binary.LittleEndian.PutUint32(e[8:], value)
+		entries = append(entries, e)
+	}
+	camera := []byte("Camera SN 123456\x00")
+	entry(0x010F, 2, uint32(len(camera)), 0) // the make's offset is filled in below
+	if orientation != 0 {
+		entry(0x0112, 3, 1, uint32(orientation))
+	}
+
+	exif := []byte("II*\x00\x08\x00\x00\x00")
+	exif = append(exif, byte(len(entries)), 0)
+	for _, e := range entries {
+		exif = append(exif, e...)
+	}
+	exif = append(exif, 0, 0, 0, 0)
+	binary.LittleEndian.PutUint32(exif[18:], uint32(len(exif)))
+	return append(exif, camera...)
+}
+
+// testJPEG returns a JPEG with the given segments inserted after the start of image
+func testJPEG(t *testing.T, segments ...[]byte) []byte {
+	var buf bytes.Buffer
+	assert.NoError(t, jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, 4, 4)), nil))
+	data := buf.Bytes()
+
+	out := append([]byte{}, data[:2]...)
+	for _, segment := range segments {
+		out = append(out, segment...)
+	}
+	return append(out, data[2:]...)
+}
+
+func jpegSegment(marker byte, data []byte) []byte {
+	return append([]byte{0xFF, marker, byte((len(data) + 2) >> 8), byte(len(data) + 2)}, data...)
+}
+
+// testPNGChunks returns a PNG with the given chunks inserted after its header
+func testPNGChunks(t *testing.T, chunks ...[]byte) []byte {
+	data := testPNG(t)
+	ihdrEnd := 8 + 8 + 13 + 4
+
+	out := append([]byte{}, data[:ihdrEnd]...)
+	for _, chunk := range chunks {
+		out = append(out, chunk...)
+	}
+	return append(out, data[ihdrEnd:]...)
+}
+
+func pngChunk(chunkType string, data []byte) []byte {
+	var buf bytes.Buffer
+	writePNGChunk(&buf, chunkType, data)
+	return buf.Bytes()
+}
+
+func Test_stripJPEG(t *testing.T) {
+	exif := jpegSegment(0xE1, append([]byte(exifHeader), testEXIF(6)...))
+	xmp := jpegSegment(0xE1, []byte("http://ns.adobe.com/xap/1.0/\x00<x:xmpmeta>Camera SN 123456</x:xmpmeta>"))
+	iptc := jpegSegment(0xED, []byte("Photoshop 3.0\x00Camera SN 123456"))
+	kept := jpegSegment(0xE1, append([]byte(exifHeader), orientationEXIF(6)...))
+
+	tests := []struct {
+		name    string
+		input   []byte
+		want    []byte
+		wantErr bool
+	}{
+		{"no metadata", testJPEG(t), testJPEG(t), false},
+		{"orientation kept", testJPEG(t, exif, xmp, iptc), testJPEG(t, kept), false},
+		{"no orientation", testJPEG(t, jpegSegment(0xE1, append([]byte(exifHeader), testEXIF(0)...))), testJPEG(t), false},
+		{"default orientation", testJPEG(t, jpegSegment(0xE1, append([]byte(exifHeader), testEXIF(1)...))), testJPEG(t), false},
+		{"corrupt EXIF", testJPEG(t, jpegSegment(0xE1, []byte(exifHeader+"II*\x00\xff\xff\xff\xff"))), testJPEG(t), false},
+		{"not a JPEG", testPNG(t), nil, true},
+		{"truncated", testJPEG(t, exif)[:30], nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := stripJPEG(&out, bytes.NewReader(tt.input))
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, out.Bytes())
+			assert.NotContains(t, out.String(), "Camera SN 123456")
+
+			_, err = jpeg.Decode(&out)
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func Test_stripPNG(t *testing.T) {
+	text := pngChunk("tEXt", []byte("Comment\x00Camera SN 123456"))
+	itxt := pngChunk("iTXt", []byte("XML:com.adobe.xmp\x00\x00\x00\x00\x00<x:xmpmeta>Camera SN 123456</x:xmpmeta>"))
+	ztxt := pngChunk("zTXt", []byte("Comment\x00\x00x\x9c\x00\x00\x00\xff\xff"))
+	exif := pngChunk("eXIf", testEXIF(8))
+	kept := pngChunk("eXIf", orientationEXIF(8))
+
+	tests := []struct {
+		name    string
+		input   []byte
+		want    []byte
+		wantErr bool
+	}{
+		{"no metadata", testPNG(t), testPNG(t), false},
+		{"text chunks", testPNGChunks(t, text, itxt, ztxt), testPNG(t), false},
+		{"orientation kept", testPNGChunks(t, text, exif, itxt), testPNGChunks(t, kept), false},
+		{"no orientation", testPNGChunks(t, pngChunk("eXIf", testEXIF(0))), testPNG(t), false},
+		{"not a PNG", testJPEG(t), nil, true},
+		{"truncated", testPNGChunks(t, exif)[:40], nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := stripPNG(&out, bytes.NewReader(tt.input))
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, out.Bytes())
+			assert.NotContains(t, out.String(), "Camera SN 123456")
+
+			_, err = png.Decode(&out)
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func Test_exifOrientation(t *testing.T) {
+	bigEndian := orientationEXIF(3)
+	assert.Equal(t, uint16(3), exifOrientation(bigEndian))
+	assert.Equal(t, uint16(5), exifOrientation(testEXIF(5)))
+	assert.Equal(t, uint16(0), exifOrientation(testEXIF(0)))
+	assert.Equal(t, uint16(0), exifOrientation(testEXIF(9)))
+	assert.Equal(t, uint16(0), exifOrientation(bigEndian[:20]))
+	assert.Equal(t, uint16(0), exifOrientation([]byte("not exif at all")))
+
+	// the chunk written for the orientation carries a valid CRC
+	chunk := pngChunk("eXIf", bigEndian)
+	assert.Equal(t, crc32.ChecksumIEEE(chunk[4:len(chunk)-4]), binary.BigEndian.Uint32(chunk[len(chunk)-4:]))
+}
@@ -1,7 +1,11 @@
 package main
 
 import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"image"
 	_ "image/gif"
 	"image/jpeg"
@@ -11,7 +15,6 @@ import (
 	"mime"
 	"mime/multipart"
 	"net/http"
-	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
@@ -21,6 +24,7 @@ import (
 	"github.com/pkg/errors"
 	"go.uber.org/zap"
 
+	"github.com/Southclaws/samp-objects-api/storage"
 	"github.com/Southclaws/samp-objects-api/types"
 )
 
@@ -31,38 +35,62 @@ type UploadResponse struct {
 	Error   string `json:"error"`
 }
 
-// ObjectsList handles the /objects endpoint, it returns a query result of objects
+// ObjectsList handles the /objects endpoint, it returns a query result of objects. The q parameter
+// searches names, tags and descriptions allowing for prefixes and typos. The sort parameter is a
+// field name optionally prefixed with - for descending, "score" to rank by the confidence adjusted
+// average rating or "relevance" to rank by how well objects match q, which is the default with q.
+// Results are paginated by limit and cursor, the cursor is the next value of the previous page.
 func (app *App) ObjectsList(w http.ResponseWriter, r *http.Request) {
-	userName := types.UserName(r.URL.Query().Get("userName"))
-
-	category := types.ObjectCategory(r.URL.Query().Get("category"))
-
-	var tags []string
-	tagString := r.URL.Query().Get("tags")
-	if tagString != "" {
-		tags = strings.Split(tagString, ",")
+	page, err := pageQuery(r)
+	if err != nil {
+		WriteResponseError(w, http.StatusBadRequest, err)
+		return
 	}
 
-	sort := r.URL.Query().Get("sort")
-	if sort == "" {
-		sort = "-_id"
-	}
+	objects, err := app.Storage.GetObjects(objectQuery(r), page)
+	writePage(w, objects, err)
+}
 
-	objects, err := app.Storage.GetObjects(userName, category, tags, sort)
+// ObjectFacets handles the /objects/facets endpoint, it counts the objects matching the same
+// filters as /objects by category, tag and owner
+func (app *App) ObjectFacets(w http.ResponseWriter, r *http.Request) {
+	facets, err := app.Storage.GetFacets(objectQuery(r))
 	if err != nil {
-		WriteResponseError(w, http.StatusInternalServerError, err)
+		WriteResponseError(w, http.StatusInternalServerError, errors.Wrap(err, "failed to count facets"))
 		return
 	}
 
-	payload, err := json.Marshal(objects)
+	payload, err := json.Marshal(facets)
 	if err != nil {
 		WriteResponseError(w, http.StatusInternalServerError, err)
 		return
 	}
 
+	w.Header().Set("Content-Type", "application/json")
 	w.Write(payload)
 }
 
+// objectQuery reads the filters and sort shared by the object listing endpoints
+func objectQuery(r *http.Request) (query storage.ObjectQuery) {
+	query.UserName = types.UserName(r.URL.Query().Get("userName"))
+
+	query.Category = types.ObjectCategory(r.URL.Query().Get("category"))
+
+	tagString := r.URL.Query().Get("tags")
+	if tagString != "" {
+		query.Tags = strings.Split(tagString, ",")
+	}
+
+	query.Search = r.URL.Query().Get("q")
+
+	// newest first unless there is a search, then the best matches come first
+	query.Sort = r.URL.Query().Get("sort")
+	if query.Sort == "" && query.Search == "" {
+		query.Sort = "-_id"
+	}
+	return
+}
+
 // ObjectByName handles the /objects/:userName/:objectName endpoint, it returns the metadata for a
 // specific object
 func (app *App) ObjectByName(w http.ResponseWriter, r *http.Request) {
@@ -89,33 +117,245 @@ func (app *App) ObjectByName(w http.ResponseWriter, r *http.Request) {
 	w.Write(payload)
 }
 
-// ObjectThumb handles requests for object image thumbails
+// thumbCacheControl lets browsers and CDNs keep a thumbnail for a week, its ETag changes along
+// with the object's first image so revalidating clients get a 304 until it is replaced
+const thumbCacheControl = "public, max-age=604800"
+
+// ObjectThumb handles requests for object image thumbnails, the size parameter picks one of
+// storage.ThumbnailSizes. Objects without images get a grey placeholder that isn't cached, trashed
+// objects get a 404 like their files do.
 func (app *App) ObjectThumb(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	objectID := types.ObjectID(vars["objectid"])
 
-	err := app.Storage.GetObjectThumb(objectID, w)
-	if err != nil {
-		err = jpeg.Encode(w, image.NewGray(image.Rect(0, 0, 200, 200)), &jpeg.Options{Quality: 50})
-		if err != nil {
-			WriteResponseError(w, http.StatusInternalServerError, errors.Wrap(err, "failed to get object image"))
+	size := uint(storage.DefaultThumbnailSize)
+	if raw := r.URL.Query().Get("size"); raw != "" {
+		parsed, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil || !storage.ThumbnailSizeAllowed(uint(parsed)) {
+			WriteResponseError(w, http.StatusBadRequest, storage.ErrThumbnailSize)
 			return
 		}
+		size = uint(parsed)
+	}
+
+	object, err := app.Storage.GetObject(objectID)
+	if err == nil && object.Deleted != nil {
+		WriteResponse(w, http.StatusNotFound, "object not found")
+		return
+	}
+	if err == nil && len(object.Images) > 0 {
+		if info, ok := object.FileInfo(object.Images[0]); ok && info.SHA256 != "" {
+			etag := fmt.Sprintf(`"%s-%d"`, info.SHA256, size)
+			w.Header().Set("ETag", etag)
+			w.Header().Set("Cache-Control", thumbCacheControl)
+			if strings.Contains(r.Header.Get("If-None-Match"), etag) {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+
+		var buf bytes.Buffer
+		err = app.Storage.GetObjectThumb(objectID, size, &buf)
+		if err == nil {
+			w.Header().Set("Content-Type", "image/jpeg")
+			buf.WriteTo(w)
+			return
+		}
+		logger.Warn("failed to get object thumbnail",
+			zap.String("objectid", string(objectID)),
+			zap.Error(err))
+	}
+
+	w.Header().Del("ETag")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Content-Type", "image/jpeg")
+	err = jpeg.Encode(w, image.NewGray(image.Rect(0, 0, int(size), int(size))), &jpeg.Options{Quality: 50})
+	if err != nil {
+		WriteResponseError(w, http.StatusInternalServerError, errors.Wrap(err, "failed to get object image"))
 		return
 	}
 }
 
-// ObjectFiles handles requests for object files by name
+// fileCacheControl is sent with files requested by version, a version's files never change so they
+// can be kept for a year. Files of the latest version may be replaced by a newer one so caches
+// have to revalidate them, which costs a 304 as long as the ETag still matches.
+const (
+	fileCacheControl       = "public, max-age=31536000, immutable"
+	latestFileCacheControl = "public, no-cache"
+)
+
+// ObjectFiles handles requests for object files by name. Responses carry the file's ETag and the
+// time its blob was stored so conditional requests get a 304, and Range requests get a 206 with
+// one range or a multipart/byteranges body with several so large downloads can be resumed.
 func (app *App) ObjectFiles(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	objectID := types.ObjectID(vars["objectid"])
 	fileName := types.File(vars["fileName"])
 
-	err := app.Storage.GetObjectFile(objectID, fileName, w)
+	version, err := versionQuery(r)
 	if err != nil {
+		WriteResponseError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	object, err := app.Storage.GetObject(objectID)
+	if err != nil || object.Deleted != nil {
+		if err == nil || err == storage.ErrNotFound {
+			WriteResponse(w, http.StatusNotFound, "object not found")
+			return
+		}
+		WriteResponseError(w, http.StatusInternalServerError, errors.Wrap(err, "failed to get object"))
+		return
+	}
+	release, ok := object.AtVersion(version)
+	if !ok {
+		WriteResponse(w, http.StatusNotFound, "version not found")
+		return
+	}
+
+	blob, stat, err := app.Storage.OpenObjectFile(objectID, version, fileName)
+	if err != nil {
+		if errors.Cause(err) == storage.ErrBlobNotFound {
+			WriteResponse(w, http.StatusNotFound, "file not found")
+			return
+		}
+		WriteResponseError(w, http.StatusInternalServerError, errors.Wrap(err, "failed to get object file"))
+		return
+	}
+	defer blob.Close()
+
+	// files from before types were recorded fall back to the store's type and are otherwise
+	// sniffed from their contents by ServeContent
+	contentType := stat.ContentType
+	if info, ok := release.FileInfo(fileName); ok {
+		setChecksumHeaders(w, info)
+		if info.ContentType != "" {
+			contentType = info.ContentType
+		}
+	}
+	if contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+	if version == 0 {
+		w.Header().Set("Cache-Control", latestFileCacheControl)
+	} else {
+		w.Header().Set("Cache-Control", fileCacheControl)
+	}
+
+	http.ServeContent(w, r, string(fileName), stat.Modified, blob)
+}
+
+// ObjectImage handles requests for the web version of one of an object's images, a JPEG scaled
+// down for pages or a PNG if the original has transparency. The original is served by ObjectFiles.
+func (app *App) ObjectImage(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	objectID := types.ObjectID(vars["objectid"])
+	fileName := types.File(vars["fileName"])
+
+	version, err := versionQuery(r)
+	if err != nil {
+		WriteResponseError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	object, err := app.Storage.GetObject(objectID)
+	if err != nil || object.Deleted != nil {
+		WriteResponse(w, http.StatusNotFound, "object not found")
+		return
+	}
+
+	var buf bytes.Buffer
+	err = app.Storage.GetObjectImage(objectID, version, fileName, &buf)
+	if err != nil {
+		if errors.Cause(err) == storage.ErrImageNotFound {
+			WriteResponse(w, http.StatusNotFound, err.Error())
+			return
+		}
 		WriteResponseError(w, http.StatusInternalServerError, errors.Wrap(err, "failed to get object image"))
 		return
 	}
+
+	w.Header().Set("Content-Type", http.DetectContentType(buf.Bytes()))
+	buf.WriteTo(w)
+}
+
+// maxPreviewSize is the largest width or height a texture preview can be scaled to
+const maxPreviewSize = 2048
+
+// ObjectTexturePreview handles requests for a texture from one of an object's texture
+// dictionaries, it is decoded and sent as a PNG. The width and height parameters scale it, if only
+// one of them is set the aspect ratio is kept.
+func (app *App) ObjectTexturePreview(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	objectID := types.ObjectID(vars["objectid"])
+	fileName := types.File(vars["fileName"])
+	texture := vars["texture"]
+
+	version, err := versionQuery(r)
+	if err != nil {
+		WriteResponseError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var size [2]uint
+	for i, param := range []string{"width", "height"} {
+		raw := r.URL.Query().Get(param)
+		if raw == "" {
+			continue
+		}
+		value, err := strconv.Atoi(raw)
+		if err != nil || value < 1 || value > maxPreviewSize {
+			WriteResponseError(w, http.StatusBadRequest, errors.Errorf("%s must be between 1 and %d", param, maxPreviewSize))
+			return
+		}
+		size[i] = uint(value)
+	}
+
+	object, err := app.Storage.GetObject(objectID)
+	if err != nil || object.Deleted != nil {
+		WriteResponse(w, http.StatusNotFound, "object not found")
+		return
+	}
+
+	// decode into a buffer so a failure can still be reported as an error response
+	var buf bytes.Buffer
+	err = app.Storage.GetTexturePreview(objectID, version, fileName, texture, size[0], size[1], &buf)
+	if err != nil {
+		if errors.Cause(err) == storage.ErrTextureNotFound {
+			WriteResponse(w, http.StatusNotFound, err.Error())
+			return
+		}
+		WriteResponseError(w, http.StatusInternalServerError, errors.Wrap(err, "failed to get texture preview"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	buf.WriteTo(w)
+}
+
+// versionQuery reads the version parameter of a file request, the latest version is served unless
+// a specific one is requested with ?version= so 0 is returned when it is not set
+func versionQuery(r *http.Request) (version int, err error) {
+	raw := r.URL.Query().Get("version")
+	if raw == "" {
+		return
+	}
+	version, err = strconv.Atoi(raw)
+	if err != nil || version < 1 {
+		return 0, errors.Errorf("invalid version '%s'", raw)
+	}
+	return
+}
+
+// setChecksumHeaders sets the ETag and Digest (RFC 3230) headers for a file from its stored checksums
+func setChecksumHeaders(w http.ResponseWriter, info types.FileInfo) {
+	sum, err := hex.DecodeString(info.SHA256)
+	if err != nil {
+		return
+	}
+	w.Header().Set("ETag", `"`+info.SHA256+`"`)
+	w.Header().Set("Digest", "SHA-256="+base64.StdEncoding.EncodeToString(sum))
+	w.Header().Set("X-Checksum-CRC32", info.CRC32)
 }
 
 // ObjectPrepare receives a types.Object and caches it while responding with the generated unique ID
@@ -137,30 +377,65 @@ func (app *App) ObjectPrepare(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	object, ok := app.newObject(w, r, object)
+	if !ok {
+		return
+	}
+
+	app.StartUploadWaiter(ActiveUpload{object: object})
+
+	logger.Debug("prepared new object upload waiter",
+		zap.String("objectid", string(object.ID)))
+
+	payload, err = json.Marshal(object)
+	if err != nil {
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	w.Write(payload)
+}
+
+// newObject fills in the owner and ID of a new object from the user sending the request and checks
+// that its category exists and its name isn't taken, it writes an error response and returns false
+// if the object can't be created
+func (app *App) newObject(w http.ResponseWriter, r *http.Request, object types.Object) (types.Object, bool) {
+	object.Tags = types.NormaliseTags(object.Tags)
+
+	_, err := app.Storage.GetCategory(object.Category)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			WriteResponseError(w, http.StatusBadRequest, errors.Wrapf(storage.ErrUnknownCategory, "'%s'", object.Category))
+			return object, false
+		}
+		WriteResponseError(w, http.StatusInternalServerError, errors.Wrap(err, "failed to get category"))
+		return object, false
+	}
+
 	session, err := app.Sessions.Get(r, UserSessionCookie)
 	if err != nil {
 		WriteResponseError(w, http.StatusInternalServerError, errors.New("failed to read session cookies"))
-		return
+		return object, false
 	}
 	userRaw, ok := session.Values["UserID"]
 	if !ok {
 		WriteResponseError(w, http.StatusInternalServerError, errors.New("no UserID field in request"))
-		return
+		return object, false
 	}
 	userID, ok := userRaw.(types.UserID)
 	if !ok {
 		WriteResponseError(w, http.StatusInternalServerError, errors.New("failed to decode user ID from request"))
-		return
+		return object, false
 	}
 
 	user, exists, err := app.Storage.GetUser(userID)
 	if err != nil {
 		WriteResponseError(w, http.StatusInternalServerError, errors.New("failed to get user details by ID"))
-		return
+		return object, false
 	}
 	if !exists {
 		WriteResponse(w, http.StatusNotFound, "user not found")
-		return
+		return object, false
 	}
 
 	object.OwnerID = user.ID
@@ -168,22 +443,72 @@ func (app *App) ObjectPrepare(w http.ResponseWriter, r *http.Request) {
 
 	exists, err = app.Storage.UserObjectExists(object)
 	if err != nil {
-		return
+		WriteResponseError(w, http.StatusInternalServerError, errors.Wrap(err, "failed to check object name"))
+		return object, false
 	}
 	if exists {
 		WriteResponseError(w, http.StatusConflict, errors.New("object name already in use by user"))
-		return
+		return object, false
 	}
 
 	object.ID = types.ObjectID(uuid.New().String())
+	return object, true
+}
 
-	app.StartUploadWaiter(object)
+// VersionPrepare handles the /object/prepare/{objectid} endpoint, it starts an upload of a new
+// version of an existing object owned by the user. The files are then sent to ObjectUpload and the
+// version is published by ObjectFinish, the same as a new object.
+func (app *App) VersionPrepare(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	objectID := types.ObjectID(vars["objectid"])
+	if err := objectID.Validate(); err != nil {
+		WriteResponseError(w, http.StatusBadRequest, err)
+		return
+	}
 
-	logger.Debug("prepared new object upload waiter",
-		zap.String("objectid", string(object.ID)))
+	var release struct {
+		Changelog string `json:"changelog"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&release)
+	if err != nil {
+		WriteResponseError(w, http.StatusBadRequest, errors.Wrap(err, "failed to decode payload"))
+		return
+	}
 
-	payload, err = json.Marshal(object)
+	user, status, err := app.SessionUser(r)
+	if err != nil {
+		WriteResponseError(w, status, err)
+		return
+	}
+
+	object, err := app.Storage.GetObject(objectID)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			WriteResponse(w, http.StatusNotFound, "object not found")
+			return
+		}
+		WriteResponseError(w, http.StatusInternalServerError, errors.Wrap(err, "failed to get object"))
+		return
+	}
+	if object.Deleted != nil {
+		WriteResponse(w, http.StatusNotFound, "object not found")
+		return
+	}
+	if !app.canModerate(user, object.OwnerID) {
+		WriteResponse(w, http.StatusForbidden, "object belongs to another user")
+		return
+	}
+
+	object.Images, object.Models, object.Textures, object.Files = nil, nil, nil, nil
+
+	if !app.StartUploadWaiter(ActiveUpload{object: object, release: true, changelog: release.Changelog}) {
+		WriteResponseError(w, http.StatusConflict, errors.New("an upload is already in progress for this object"))
+		return
+	}
+
+	payload, err := json.Marshal(object)
 	if err != nil {
+		WriteResponseError(w, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -276,19 +601,22 @@ func (app *App) ObjectUpload(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// StartUploadWaiter is called when an upload is prepared and awaits files from ObjectUpload
-func (app *App) StartUploadWaiter(object types.Object) {
-	ch := make(chan types.ObjectFile, 16)
+// StartUploadWaiter is called when an upload is prepared and awaits files from ObjectUpload, it
+// returns false if there is already an upload in progress for the object
+func (app *App) StartUploadWaiter(upload ActiveUpload) bool {
+	upload.ch = make(chan types.ObjectFile, 16)
 
-	app.Uploads.Store(string(object.ID), ActiveUpload{
-		ch:     ch,
-		object: object,
-	})
+	_, loaded := app.Uploads.LoadOrStore(string(upload.object.ID), upload)
+	if loaded {
+		return false
+	}
 
 	logger.Debug("created new object upload waiter",
-		zap.String("objectid", string(object.ID)))
+		zap.String("objectid", string(upload.object.ID)),
+		zap.Bool("release", upload.release))
 
-	go app.UploadWaiter(object.ID, ch)
+	go app.UploadWaiter(upload.object.ID, upload.ch)
+	return true
 }
 
 // UploadWaiter is a goroutine that awaits new files for an upload in progress
@@ -303,8 +631,10 @@ func (app *App) UploadWaiter(objectID types.ObjectID, ch chan types.ObjectFile)
 	for {
 		select {
 		case file, ok := <-ch:
+			// a closed channel means the upload is finished, there is no file to add
 			if !ok {
 				ch = nil
+				break
 			}
 
 			logger.Debug("waiter received file",
@@ -339,6 +669,7 @@ func (app *App) UploadWaiter(objectID types.ObjectID, ch chan types.ObjectFile)
 			case "texture":
 				upload.object.Textures = append(upload.object.Textures, types.File(file.Name))
 			}
+			upload.object.Files = append(upload.object.Files, file.Info)
 
 			app.Uploads.Store(string(objectID), upload)
 
@@ -356,7 +687,12 @@ func (app *App) UploadWaiter(objectID types.ObjectID, ch chan types.ObjectFile)
 		logger.Debug("dropped cache waiter for upload",
 			zap.String("objectid", string(objectID)))
 
-		// todo: purge S3 bucket
+		// nothing references the files the upload stored so far
+		if uploadRaw, ok := app.Uploads.Load(string(objectID)); ok {
+			if upload, ok := uploadRaw.(ActiveUpload); ok {
+				app.discardFiles(objectID, upload.object.Files)
+			}
+		}
 		return
 	}
 
@@ -378,11 +714,54 @@ func (app *App) UploadWaiter(objectID types.ObjectID, ch chan types.ObjectFile)
 	logger.Debug("object upload cache closed successfully, attempting to write to db",
 		zap.String("objectid", string(upload.object.ID)))
 
+	now := time.Now()
+	version := types.ObjectVersion{
+		Changelog: upload.changelog,
+		Published: now,
+		Images:    upload.object.Images,
+		Models:    upload.object.Models,
+		Textures:  upload.object.Textures,
+		Files:     upload.object.Files,
+	}
+
+	if upload.release {
+		object, err := app.Storage.GetObject(upload.object.ID)
+		if err != nil {
+			logger.Error("failed to get object to publish new version",
+				zap.Error(err),
+				zap.String("objectid", string(upload.object.ID)))
+			app.discardFiles(upload.object.ID, upload.object.Files)
+			return
+		}
+		if object.Deleted != nil {
+			logger.Warn("dropped new version of an object that was trashed during the upload",
+				zap.String("objectid", string(upload.object.ID)))
+			app.discardFiles(upload.object.ID, upload.object.Files)
+			return
+		}
+
+		object.PublishVersion(version)
+
+		err = app.Storage.UpdateObject(object)
+		if err != nil {
+			logger.Error("failed to update object metadata in database",
+				zap.Error(err),
+				zap.String("objectid", string(upload.object.ID)))
+			app.discardFiles(upload.object.ID, upload.object.Files)
+		}
+		return
+	}
+
+	version.Version = 1
+	upload.object.Created = now
+	upload.object.Versions = []types.ObjectVersion{version}
+
 	err := app.Storage.CreateObject(upload.object)
 	if err != nil {
 		logger.Error("failed to create object metadata in database",
 			zap.Error(err),
 			zap.String("objectid", string(upload.object.ID)))
+		app.discardFiles(upload.object.ID, upload.object.Files)
 		return
 	}
 }
@@ -398,58 +777,102 @@ func (app *App) AddFile(objectID types.ObjectID, filename string, p io.Reader) (
 		return errors.New("upload cache corrupted")
 	}
 
-	filetype := "image"
-
-	// todo: use mimetypes/first bytes to determine file type
-	switch filepath.Ext(filename) {
-	case ".dff":
-		filetype = "model"
-	case ".txd":
-		filetype = "texture"
+	file, err := app.storeFile(objectID, filename, p)
+	if err != nil {
+		return
 	}
 
-	r, w := io.Pipe()
+	upload.ch <- file
 
-	go func() {
-		if filetype == "image" {
-			img, format, errInner := image.Decode(p)
-			if errInner != nil {
-				w.CloseWithError(errors.Wrapf(errInner, "failed to decode image file of format '%s'", format))
-			}
+	return
+}
 
-			errInner = jpeg.Encode(w, img, &jpeg.Options{Quality: 64})
-			if errInner != nil {
-				w.CloseWithError(errors.Wrap(errInner, "failed to re-encode image as JPEG"))
-			}
+// storeFile checks that an uploaded file is allowed and that its contents match its name, reads the
+// metadata of models and textures and strips it from images, then writes it to the file store
+func (app *App) storeFile(objectID types.ObjectID, filename string, p io.Reader) (file types.ObjectFile, err error) {
+	fileType, p, err := app.uploadPolicy.Check(filename, p)
+	if err != nil {
+		return
+	}
+	filetype := fileType.Kind
+
+	// models and textures are parsed before they are stored so broken files are rejected and the
+	// site can show polygon counts and texture inventories without downloading them, images are
+	// checked the same way and are otherwise stored as they were uploaded
+	var (
+		model    *types.ModelInfo
+		textures []types.TextureInfo
+	)
+	switch filetype {
+	case "model", "texture":
+		// the RenderWare parsers need the whole file, which Check has capped at the limit for
+		// its type, everything else is streamed into the store
+		var data []byte
+		data, err = ioutil.ReadAll(p)
+		if err != nil {
+			return file, errors.Wrapf(err, "failed to read %s", filetype)
+		}
+		if filetype == "model" {
+			model, err = readModel(data)
 		} else {
-			_, errInner := io.Copy(w, p)
-			if errInner != nil {
-				w.CloseWithError(errInner)
-			}
+			textures, err = readTextures(data)
 		}
-		errInner := w.Close()
-		if errInner != nil {
-			logger.Fatal("failed to close upload cache image writer",
-				zap.String("objectid", string(objectID)),
-				zap.String("filename", filename))
+		if err != nil {
+			return file, errors.Wrapf(err, "invalid %s %s", filetype, filename)
 		}
-	}()
+		p = bytes.NewReader(data)
 
-	err = app.Storage.PutObjectFile(objectID, filename, r)
-	if err != nil {
-		return errors.Wrap(err, "failed to write object to store")
+	case "image":
+		var stripped io.ReadCloser
+		stripped, err = checkImage(fileType.Name, p)
+		if err != nil {
+			return file, errors.Wrapf(err, "invalid %s %s", filetype, filename)
+		}
+		defer stripped.Close()
+		p = stripped
 	}
-	err = r.Close()
+
+	info, err := app.Storage.PutObjectFile(objectID, filename, fileType.ContentType, p)
 	if err != nil {
-		return errors.Wrap(err, "failed to close reader")
+		return file, errors.Wrap(err, "failed to write object to store")
 	}
+	info.Model = model
+	info.Textures = textures
 
-	upload.ch <- types.ObjectFile{
+	return types.ObjectFile{
 		Name: filename,
 		Type: filetype,
+		Info: info,
+	}, nil
+}
+
+// the largest image that can be uploaded, thumbnails and web versions decode the whole image so a
+// small file that claims to be huge would otherwise take gigabytes to display
+const (
+	maxImageSize   = 8192     // largest width or height
+	maxImagePixels = 40000000 // most pixels in total
+)
+
+// checkImage reads the header of an uploaded image to make sure it is one and isn't too big to
+// display, then returns the image with its metadata stripped, which is done as it is read. Closing
+// it stops the stripping early.
+func checkImage(fileType string, r io.Reader) (io.ReadCloser, error) {
+	var header bytes.Buffer
+	config, _, err := image.DecodeConfig(io.TeeReader(r, &header))
+	if err != nil {
+		return nil, err
+	}
+	if config.Width < 1 || config.Height < 1 || config.Width > maxImageSize || config.Height > maxImageSize ||
+		config.Width*config.Height > maxImagePixels {
+		return nil, errors.Errorf("image is %dx%d, it must be between 1x1 and %dx%d and at most %d megapixels",
+			config.Width, config.Height, maxImageSize, maxImageSize, maxImagePixels/1000000)
 	}
 
-	return
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(stripMetadata(fileType, pw, io.MultiReader(&header, r)))
+	}()
+	return pr, nil
 }
 
 // ObjectFinish finishes an upload process and closes the channel resulting in the object getting
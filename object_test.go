@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Southclaws/samp-objects-api/storage"
+	"github.com/Southclaws/samp-objects-api/types"
+)
+
+func TestUploadWaiter(t *testing.T) {
+	app := newTestApp()
+	objectID := types.ObjectID("00000000-0000-0000-0000-000000000001")
+
+	ch := make(chan types.ObjectFile, 3)
+	app.Uploads.Store(string(objectID), ActiveUpload{
+		ch: ch,
+		object: types.Object{
+			ID:        objectID,
+			OwnerID:   "00000001-0000-0000-0000-000000000000",
+			OwnerName: "owner",
+			Name:      "uploaded",
+			Category:  "category",
+		},
+	})
+
+	for _, file := range []types.ObjectFile{
+		{Name: "image.png", Type: "image", Info: types.FileInfo{Name: "image.png", Size: 1}},
+		{Name: "model.dff", Type: "model", Info: types.FileInfo{Name: "model.dff", Size: 2}},
+		{Name: "texture.txd", Type: "texture", Info: types.FileInfo{Name: "texture.txd", Size: 3}},
+	} {
+		ch <- file
+	}
+	close(ch)
+	app.UploadWaiter(objectID, ch)
+
+	object, err := app.Storage.GetObject(objectID)
+	assert.NoError(t, err)
+	assert.Len(t, object.Files, 3)
+	for _, info := range object.Files {
+		assert.NotEmpty(t, info.Name)
+	}
+	assert.Len(t, object.Versions, 1)
+	assert.Len(t, object.Versions[0].Files, 3)
+
+	_, ok := app.Uploads.Load(string(objectID))
+	assert.False(t, ok)
+}
+
+func TestUploadWaiterDiscardsFailedUploads(t *testing.T) {
+	app := newTestApp()
+	objectID := types.ObjectID("00000000-0000-0000-0000-000000000001")
+	info, err := app.Storage.PutObjectFile(objectID, "model.dff", "application/octet-stream", strings.NewReader("model"))
+	assert.NoError(t, err)
+
+	// an object without images can't be created so the model that was stored is removed
+	ch := make(chan types.ObjectFile, 1)
+	app.Uploads.Store(string(objectID), ActiveUpload{
+		ch: ch,
+		object: types.Object{
+			ID:        objectID,
+			OwnerID:   "00000001-0000-0000-0000-000000000000",
+			OwnerName: "owner",
+			Name:      "failed",
+			Category:  "category",
+		},
+	})
+	ch <- types.ObjectFile{Name: "model.dff", Type: "model", Info: info}
+	close(ch)
+	app.UploadWaiter(objectID, ch)
+
+	_, err = app.Storage.GetObject(objectID)
+	assert.Equal(t, storage.ErrNotFound, err)
+
+	// an object that claims the discarded file can't open it
+	orphan := types.Object{
+		ID:        objectID,
+		OwnerID:   "00000001-0000-0000-0000-000000000000",
+		OwnerName: "owner",
+		Name:      "orphan",
+		Category:  "category",
+		Images:    []types.File{"image.png"},
+		Models:    []types.File{"model.dff"},
+		Textures:  []types.File{"texture.txd"},
+		Files:     []types.FileInfo{info},
+	}
+	assert.NoError(t, app.Storage.CreateObject(orphan))
+	_, _, err = app.Storage.OpenObjectFile(objectID, 0, "model.dff")
+	assert.Error(t, err)
+}
+
+func TestVersionPrepare(t *testing.T) {
+	app := newTestApp()
+	owner := types.User{ID: "00000000-0000-0000-0000-000000000001", Name: "owner", Email: "owner@example.com", Password: "hash"}
+	other := types.User{ID: "00000000-0000-0000-0000-000000000002", Name: "other", Email: "other@example.com", Password: "hash"}
+	for _, user := range []types.User{owner, other} {
+		assert.NoError(t, app.Storage.CreateUser(user))
+	}
+	object := types.Object{
+		ID:        "00000000-0000-0000-0000-000000000001",
+		OwnerID:   owner.ID,
+		OwnerName: owner.Name,
+		Name:      "released",
+		Category:  "category",
+		Images:    []types.File{"image.png"},
+		Models:    []types.File{"model.dff"},
+		Textures:  []types.File{"texture.txd"},
+	}
+	assert.NoError(t, app.Storage.CreateObject(object))
+
+	prepare := func(userID types.UserID) int {
+		r := mux.SetURLVars(httptest.NewRequest("POST", "/v0/object/"+string(object.ID)+"/versions", strings.NewReader(`{"changelog": "fixes"}`)),
+			map[string]string{"objectid": string(object.ID)})
+		w := httptest.NewRecorder()
+		app.VersionPrepare(w, withSession(app, r, userID))
+		app.Uploads.Delete(string(object.ID))
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusCreated, prepare(owner.ID))
+	assert.Equal(t, http.StatusForbidden, prepare(other.ID))
+
+	assert.NoError(t, app.Storage.TrashUser(owner.ID))
+	assert.Equal(t, http.StatusUnauthorized, prepare(owner.ID))
+	assert.NoError(t, app.Storage.RestoreUser(owner.ID))
+
+	assert.NoError(t, app.Storage.TrashObject(object.ID))
+	assert.Equal(t, http.StatusNotFound, prepare(owner.ID))
+}
+
+func TestObjectThumb(t *testing.T) {
+	app := newTestApp()
+	object := types.Object{
+		ID:        "00000000-0000-0000-0000-000000000001",
+		OwnerID:   "00000000-0000-0000-0000-000000000001",
+		OwnerName: "owner",
+		Name:      "thumbless",
+		Category:  "category",
+		Images:    []types.File{"missing.png"},
+		Models:    []types.File{"model.dff"},
+		Textures:  []types.File{"texture.txd"},
+	}
+	assert.NoError(t, app.Storage.CreateObject(object))
+
+	thumb := func() *httptest.ResponseRecorder {
+		r := mux.SetURLVars(httptest.NewRequest("GET", "/v0/images/"+string(object.ID), nil),
+			map[string]string{"objectid": string(object.ID)})
+		w := httptest.NewRecorder()
+		app.ObjectThumb(w, r)
+		return w
+	}
+
+	// images that can't be read get the placeholder
+	w := thumb()
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "image/jpeg", w.Header().Get("Content-Type"))
+
+	assert.NoError(t, app.Storage.TrashObject(object.ID))
+	w = thumb()
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+// unavailableStorage fails every object lookup the way a database that can't be reached does
+type unavailableStorage struct {
+	storage.Storage
+}
+
+func (unavailableStorage) GetObject(types.ObjectID) (types.Object, error) {
+	return types.Object{}, errors.New("no reachable servers")
+}
+
+func TestObjectFiles(t *testing.T) {
+	app := newTestApp()
+	file := func(objectID string) int {
+		r := mux.SetURLVars(httptest.NewRequest("GET", "/v0/files/"+objectID+"/model.dff", nil),
+			map[string]string{"objectid": objectID, "fileName": "model.dff"})
+		w := httptest.NewRecorder()
+		app.ObjectFiles(w, r)
+		return w.Code
+	}
+
+	// only a missing object is reported as one, a failed lookup is the server's fault
+	assert.Equal(t, http.StatusNotFound, file("00000000-0000-0000-0000-000000000009"))
+	app.Storage = unavailableStorage{app.Storage}
+	assert.Equal(t, http.StatusInternalServerError, file("00000000-0000-0000-0000-000000000009"))
+}
+
+// pngHeader returns the start of a PNG that declares the given size, which is all DecodeConfig reads
+func pngHeader(width, height uint32) []byte {
+	ihdr := make([]byte, 13)
+	binary.BigEndian.PutUint32(ihdr, width)
+	binary.BigEndian.PutUint32(ihdr[4:], height)
+	ihdr[8], ihdr[9] = 8, 6 // 8 bit RGBA
+	return append([]byte("\x89PNG\r\n\x1a\n"), pngChunk("IHDR", ihdr)...)
+}
+
+func Test_checkImage(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr string
+	}{
+		{"small", testPNG(t), ""},
+		{"largest side", pngHeader(maxImageSize, 16), ""},
+		{"too wide", pngHeader(maxImageSize+1, 16), "image is 8193x16"},
+		{"too many pixels", pngHeader(8000, 8000), "image is 8000x8000"},
+		{"huge", pngHeader(30000, 30000), "image is 30000x30000"},
+		{"not an image", []byte("hello"), "unknown format"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := checkImage("png", bytes.NewReader(tt.data))
+			if tt.wantErr != "" {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), tt.wantErr)
+				}
+				return
+			}
+			if assert.NoError(t, err) {
+				r.Close()
+			}
+		})
+	}
+}
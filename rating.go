@@ -4,9 +4,11 @@ import (
 	"encoding/json"
 	"io/ioutil"
 	"net/http"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/pkg/errors"
+	"go.uber.org/zap"
 
 	"github.com/Southclaws/samp-objects-api/types"
 )
@@ -67,7 +69,44 @@ func (app *App) RatingCreate(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// RatingList handles the GET /ratings/{objectid} endpoint and returns a list of ratings
+// RatingList handles the GET /ratings/{objectid} endpoint and returns a page of ratings
 func (app *App) RatingList(w http.ResponseWriter, r *http.Request) {
-	WriteResponseError(w, http.StatusNotImplemented, nil)
+	vars := mux.Vars(r)
+	objectID := types.ObjectID(vars["objectid"])
+
+	page, err := pageQuery(r)
+	if err != nil {
+		WriteResponseError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	ratings, err := app.Storage.GetRatings(objectID, page)
+	writePage(w, ratings, err)
+}
+
+// RatingReconciler periodically recalculates every object's rating counters from the ratings
+// themselves and logs any object that had drifted, it runs until the app context is cancelled.
+func (app *App) RatingReconciler(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-app.ctx.Done():
+			return
+		case <-ticker.C:
+			drift, err := app.Storage.ReconcileRatings(true)
+			if err != nil {
+				logger.Error("failed to reconcile ratings",
+					zap.Error(err))
+				continue
+			}
+			for _, d := range drift {
+				logger.Warn("fixed drifted rating counters",
+					zap.String("object", string(d.ObjectID)),
+					zap.Any("stored", d.Stored),
+					zap.Any("actual", d.Actual))
+			}
+		}
+	}
 }
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"github.com/Southclaws/samp-objects-api/renderware"
+	"github.com/Southclaws/samp-objects-api/types"
+)
+
+// readModel parses an uploaded DFF file into the metadata that is stored with it
+func readModel(data []byte) (info *types.ModelInfo, err error) {
+	model, err := renderware.ReadModel(data)
+	if err != nil {
+		return
+	}
+
+	info = &types.ModelInfo{
+		Version:    model.Version.String(),
+		Geometries: len(model.Geometries),
+		Vertices:   model.Vertices(),
+		Triangles:  model.Triangles(),
+		Textures:   model.Textures(),
+		Collision:  model.Collision,
+	}
+	for _, frame := range model.Frames {
+		info.Frames = append(info.Frames, types.ModelFrame{Name: frame.Name, Parent: frame.Parent})
+	}
+	for _, geometry := range model.Geometries {
+		for _, material := range geometry.Materials {
+			info.Materials = append(info.Materials, types.ModelMaterial{Texture: material.Texture, Mask: material.Mask})
+		}
+	}
+	return
+}
+
+// readTextures parses an uploaded TXD file into the inventory of textures that is stored with it
+func readTextures(data []byte) (textures []types.TextureInfo, err error) {
+	txd, err := renderware.ReadTextureDictionary(data)
+	if err != nil {
+		return
+	}
+
+	textures = []types.TextureInfo{}
+	for _, texture := range txd.Textures {
+		textures = append(textures, types.TextureInfo{
+			Name:    texture.Name,
+			Mask:    texture.Mask,
+			Width:   texture.Width,
+			Height:  texture.Height,
+			Format:  texture.Format,
+			MipMaps: texture.MipMaps,
+			Alpha:   texture.Alpha,
+			Size:    texture.Size,
+		})
+	}
+	return
+}
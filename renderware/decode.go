@@ -0,0 +1,209 @@
+package renderware
+
+import (
+	"encoding/binary"
+	"image"
+	"image/color"
+
+	"github.com/pkg/errors"
+)
+
+// MaxImageSize is the largest width or height of a texture that Image decodes, the game itself
+// never uses more than 2048
+const MaxImageSize = 4096
+
+// Image decodes the largest mipmap level of the texture. The size of the level is checked before
+// the image is allocated so a texture that claims to be larger than its data can't exhaust memory.
+func (t Texture) Image() (img *image.NRGBA, err error) {
+	if len(t.levels) == 0 {
+		return nil, errors.New("texture has no pixel data")
+	}
+	if t.Width < 1 || t.Height < 1 || t.Width > MaxImageSize || t.Height > MaxImageSize {
+		return nil, errors.Errorf("texture is %dx%d, it must be between 1x1 and %dx%d", t.Width, t.Height, MaxImageSize, MaxImageSize)
+	}
+	data := t.levels[0]
+
+	switch t.Format {
+	case "DXT1", "DXT3", "DXT5":
+		blockSize := 16
+		if t.Format == "DXT1" {
+			blockSize = 8
+		}
+		blocksWide, blocksHigh := (t.Width+3)/4, (t.Height+3)/4
+		if len(data) < blocksWide*blocksHigh*blockSize {
+			return nil, errors.Errorf("%s level is %d bytes, expected %d", t.Format, len(data), blocksWide*blocksHigh*blockSize)
+		}
+		img = image.NewNRGBA(image.Rect(0, 0, t.Width, t.Height))
+		for by := 0; by < blocksHigh; by++ {
+			for bx := 0; bx < blocksWide; bx++ {
+				block := data[(by*blocksWide+bx)*blockSize:]
+				var pixels [16]color.NRGBA
+				switch t.Format {
+				case "DXT1":
+					pixels = decodeColourBlock(block, true)
+				case "DXT3":
+					pixels = decodeColourBlock(block[8:], false)
+					decodeExplicitAlpha(block, &pixels)
+				case "DXT5":
+					pixels = decodeColourBlock(block[8:], false)
+					decodeInterpolatedAlpha(block, &pixels)
+				}
+				for i, c := range pixels {
+					x, y := bx*4+i%4, by*4+i/4
+					if x < t.Width && y < t.Height {
+						img.SetNRGBA(x, y, c)
+					}
+				}
+			}
+		}
+		return
+
+	case "PAL8", "PAL4":
+		// 4 bit indices are packed two to a byte unless the level has room for one per byte
+		packed := t.Format == "PAL4" && len(data) < t.Width*t.Height
+		need := t.Width * t.Height
+		if packed {
+			need = (need + 1) / 2
+		}
+		if len(data) < need {
+			return nil, errors.Errorf("%s level is %d bytes, expected %d", t.Format, len(data), need)
+		}
+		img = image.NewNRGBA(image.Rect(0, 0, t.Width, t.Height))
+		entries := len(t.palette) / 4
+		for i := 0; i < t.Width*t.Height; i++ {
+			var index int
+			if packed {
+				index = int(data[i/2] >> (uint(i%2) * 4) & 0x0F)
+			} else {
+				index = int(data[i])
+			}
+			if index >= entries {
+				return nil, errors.Errorf("palette index %d out of range", index)
+			}
+			p := t.palette[index*4:]
+			img.SetNRGBA(i%t.Width, i/t.Width, color.NRGBA{p[0], p[1], p[2], p[3]})
+		}
+		return
+	}
+
+	depth, decode := pixelDecoder(t.Format)
+	if decode == nil {
+		return nil, errors.Errorf("unsupported format %s", t.Format)
+	}
+	if len(data) < t.Width*t.Height*depth {
+		return nil, errors.Errorf("%s level is %d bytes, expected %d", t.Format, len(data), t.Width*t.Height*depth)
+	}
+	img = image.NewNRGBA(image.Rect(0, 0, t.Width, t.Height))
+	for i := 0; i < t.Width*t.Height; i++ {
+		img.SetNRGBA(i%t.Width, i/t.Width, decode(data[i*depth:]))
+	}
+	return
+}
+
+// pixelDecoder returns the bytes per pixel and a function to decode one pixel for uncompressed
+// formats, Direct3D stores the channels of 32 bit formats in BGRA order
+func pixelDecoder(format string) (int, func([]byte) color.NRGBA) {
+	switch format {
+	case "8888":
+		return 4, func(p []byte) color.NRGBA { return color.NRGBA{p[2], p[1], p[0], p[3]} }
+	case "888":
+		return 4, func(p []byte) color.NRGBA { return color.NRGBA{p[2], p[1], p[0], 255} }
+	case "LUM8":
+		return 1, func(p []byte) color.NRGBA { return color.NRGBA{p[0], p[0], p[0], 255} }
+	case "565":
+		return 2, func(p []byte) color.NRGBA { return rgb565(binary.LittleEndian.Uint16(p)) }
+	case "1555":
+		return 2, func(p []byte) color.NRGBA {
+			v := binary.LittleEndian.Uint16(p)
+			c := color.NRGBA{expand(v>>10, 5), expand(v>>5, 5), expand(v, 5), 0}
+			if v&0x8000 != 0 {
+				c.A = 255
+			}
+			return c
+		}
+	case "555":
+		return 2, func(p []byte) color.NRGBA {
+			v := binary.LittleEndian.Uint16(p)
+			return color.NRGBA{expand(v>>10, 5), expand(v>>5, 5), expand(v, 5), 255}
+		}
+	case "4444":
+		return 2, func(p []byte) color.NRGBA {
+			v := binary.LittleEndian.Uint16(p)
+			return color.NRGBA{expand(v>>8, 4), expand(v>>4, 4), expand(v, 4), expand(v>>12, 4)}
+		}
+	}
+	return 0, nil
+}
+
+// expand scales the low bits of a packed channel up to 8 bits
+func expand(v uint16, bits uint) uint8 {
+	max := uint16(1)<<bits - 1
+	return uint8(uint32(v&max) * 255 / uint32(max))
+}
+
+func rgb565(v uint16) color.NRGBA {
+	return color.NRGBA{expand(v>>11, 5), expand(v>>5, 6), expand(v, 5), 255}
+}
+
+// decodeColourBlock decodes the two 565 end points and 2 bit indices of a DXT colour block, DXT1
+// blocks whose first end point is not greater than the second use the last index for transparency
+func decodeColourBlock(block []byte, dxt1 bool) (pixels [16]color.NRGBA) {
+	c0, c1 := binary.LittleEndian.Uint16(block), binary.LittleEndian.Uint16(block[2:])
+	a, b := rgb565(c0), rgb565(c1)
+
+	mix := func(wa, wb, d int) color.NRGBA {
+		return color.NRGBA{
+			uint8((int(a.R)*wa + int(b.R)*wb) / d),
+			uint8((int(a.G)*wa + int(b.G)*wb) / d),
+			uint8((int(a.B)*wa + int(b.B)*wb) / d),
+			255,
+		}
+	}
+
+	palette := [4]color.NRGBA{a, b}
+	if c0 > c1 || !dxt1 {
+		palette[2], palette[3] = mix(2, 1, 3), mix(1, 2, 3)
+	} else {
+		palette[2] = mix(1, 1, 2)
+	}
+
+	indices := binary.LittleEndian.Uint32(block[4:])
+	for i := range pixels {
+		pixels[i] = palette[indices>>(uint(i)*2)&3]
+	}
+	return
+}
+
+// decodeExplicitAlpha applies the 4 bit alpha values of a DXT3 block
+func decodeExplicitAlpha(block []byte, pixels *[16]color.NRGBA) {
+	alpha := binary.LittleEndian.Uint64(block)
+	for i := range pixels {
+		pixels[i].A = uint8(alpha>>(uint(i)*4)&0x0F) * 17
+	}
+}
+
+// decodeInterpolatedAlpha applies the two alpha end points and 3 bit indices of a DXT5 block
+func decodeInterpolatedAlpha(block []byte, pixels *[16]color.NRGBA) {
+	a0, a1 := int(block[0]), int(block[1])
+
+	var palette [8]uint8
+	palette[0], palette[1] = uint8(a0), uint8(a1)
+	if a0 > a1 {
+		for i := 1; i < 7; i++ {
+			palette[i+1] = uint8(((7-i)*a0 + i*a1) / 7)
+		}
+	} else {
+		for i := 1; i < 5; i++ {
+			palette[i+1] = uint8(((5-i)*a0 + i*a1) / 5)
+		}
+		palette[6], palette[7] = 0, 255
+	}
+
+	var indices uint64
+	for i := 7; i >= 2; i-- {
+		indices = indices<<8 | uint64(block[i])
+	}
+	for i := range pixels {
+		pixels[i].A = palette[indices>>(uint(i)*3)&7]
+	}
+}
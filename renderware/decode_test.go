@@ -0,0 +1,80 @@
+package renderware
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func readTestTexture(t *testing.T, texture []byte) Texture {
+	txd, err := ReadTextureDictionary(testDictionary(texture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return txd.Textures[0]
+}
+
+func TestTextureImage(t *testing.T) {
+	red, blue := le(uint16(0xF800)), le(uint16(0x001F))
+
+	// the first row of pixels uses each index of a three colour block with transparency
+	dxt1 := append(append(blue, red...), le(uint32(0xE4))...)
+	img, err := readTestTexture(t, testTexture("dxt1", 0x0200, "DXT1", 4, 4, 0x08, nil, dxt1)).Image()
+	assert.NoError(t, err)
+	assert.Equal(t, color.NRGBA{0, 0, 255, 255}, img.NRGBAAt(0, 0))
+	assert.Equal(t, color.NRGBA{255, 0, 0, 255}, img.NRGBAAt(1, 0))
+	assert.Equal(t, color.NRGBA{127, 0, 127, 255}, img.NRGBAAt(2, 0))
+	assert.Equal(t, color.NRGBA{0, 0, 0, 0}, img.NRGBAAt(3, 0))
+	assert.Equal(t, color.NRGBA{0, 0, 255, 255}, img.NRGBAAt(3, 3))
+
+	dxt3 := append(le(uint64(0xF0)), append(append(blue, red...), le(uint32(0))...)...)
+	img, err = readTestTexture(t, testTexture("dxt3", 0x0300, "DXT3", 2, 2, 0x09, nil, dxt3)).Image()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, img.Bounds().Dx())
+	assert.Equal(t, color.NRGBA{0, 0, 255, 0}, img.NRGBAAt(0, 0))
+	assert.Equal(t, color.NRGBA{0, 0, 255, 255}, img.NRGBAAt(1, 0))
+
+	dxt5 := append([]byte{255, 0, 0x08, 0, 0, 0, 0, 0}, append(append(blue, red...), le(uint32(0))...)...)
+	img, err = readTestTexture(t, testTexture("dxt5", 0x0300, "DXT5", 4, 4, 0x09, nil, dxt5)).Image()
+	assert.NoError(t, err)
+	assert.Equal(t, uint8(255), img.NRGBAAt(0, 0).A)
+	assert.Equal(t, uint8(0), img.NRGBAAt(1, 0).A)
+	assert.Equal(t, uint8(255), img.NRGBAAt(2, 0).A)
+
+	palette := make([]byte, 1024)
+	copy(palette, []byte{10, 20, 30, 255, 1, 2, 3, 128})
+	img, err = readTestTexture(t, testTexture("pal", 0x2500, "", 2, 1, 0, palette, []byte{1, 0})).Image()
+	assert.NoError(t, err)
+	assert.Equal(t, color.NRGBA{1, 2, 3, 128}, img.NRGBAAt(0, 0))
+	assert.Equal(t, color.NRGBA{10, 20, 30, 255}, img.NRGBAAt(1, 0))
+
+	img, err = readTestTexture(t, testTexture("bgra", 0x0500, "", 1, 1, 0x01, nil, []byte{1, 2, 3, 4})).Image()
+	assert.NoError(t, err)
+	assert.Equal(t, color.NRGBA{3, 2, 1, 4}, img.NRGBAAt(0, 0))
+
+	img, err = readTestTexture(t, testTexture("argb", 0x0300, "", 1, 1, 0x01, nil, le(uint16(0x8F00)))).Image()
+	assert.NoError(t, err)
+	assert.Equal(t, color.NRGBA{255, 0, 0, 136}, img.NRGBAAt(0, 0))
+
+	_, err = readTestTexture(t, testTexture("short", 0x0500, "", 4, 4, 0, nil, []byte{1, 2, 3, 4})).Image()
+	assert.Error(t, err)
+}
+
+func TestTextureImageTruncated(t *testing.T) {
+	// levels of one byte that claim to be as large as a texture can be are rejected before the
+	// image is allocated
+	for _, texture := range [][]byte{
+		testTexture("bgra", 0x0500, "", 4096, 4096, 0, nil, []byte{1}),
+		testTexture("dxt1", 0x0200, "DXT1", 4096, 4096, 0x08, nil, []byte{1}),
+		testTexture("pal", 0x2500, "", 4096, 4096, 0, make([]byte, 1024), []byte{1}),
+	} {
+		_, err := readTestTexture(t, texture).Image()
+		assert.Error(t, err)
+	}
+
+	for _, size := range [][2]uint16{{65535, 65535}, {4097, 1}, {1, 4097}} {
+		_, err := readTestTexture(t, testTexture("huge", 0x0500, "", size[0], size[1], 0, nil, []byte{1})).Image()
+		assert.Error(t, err, "%dx%d", size[0], size[1])
+	}
+}
@@ -0,0 +1,401 @@
+package renderware
+
+import (
+	"github.com/pkg/errors"
+)
+
+// chunkUVAnimDictionary holds UV animations and is written before the clump in some models
+const chunkUVAnimDictionary = 0x2B
+
+// geometry flags
+const (
+	geometryTextured  = 0x04
+	geometryPrelit    = 0x08
+	geometryTextured2 = 0x80
+	geometryNative    = 0x01000000
+)
+
+// Model is the metadata of a DFF clump
+type Model struct {
+	Version    Version
+	Frames     []Frame
+	Geometries []Geometry
+	Atomics    int
+	Collision  bool // the clump has a COL model embedded in it
+}
+
+// Frame is a node in the model's hierarchy, Parent is the index of the parent frame or -1 for the
+// root
+type Frame struct {
+	Name   string
+	Parent int
+}
+
+// Geometry is a single mesh of a model
+type Geometry struct {
+	Vertices  int
+	Triangles int
+	Materials []Material
+}
+
+// Material is a surface of a geometry, Texture is empty for untextured materials
+type Material struct {
+	Texture string
+	Mask    string
+}
+
+// Vertices returns the total number of vertices in every geometry
+func (m Model) Vertices() (n int) {
+	for _, g := range m.Geometries {
+		n += g.Vertices
+	}
+	return
+}
+
+// Triangles returns the total number of triangles in every geometry
+func (m Model) Triangles() (n int) {
+	for _, g := range m.Geometries {
+		n += g.Triangles
+	}
+	return
+}
+
+// Textures returns the names of the textures used by the model's materials in the order they are
+// first used, without duplicates
+func (m Model) Textures() (names []string) {
+	seen := make(map[string]bool)
+	for _, g := range m.Geometries {
+		for _, mat := range g.Materials {
+			if mat.Texture == "" || seen[mat.Texture] {
+				continue
+			}
+			seen[mat.Texture] = true
+			names = append(names, mat.Texture)
+		}
+	}
+	return
+}
+
+// ReadModel parses a DFF file, ErrNotRenderWare is returned if data does not start with a clump
+func ReadModel(data []byte) (model Model, err error) {
+	c, rest, err := readChunk(data)
+	if err != nil {
+		return model, ErrNotRenderWare
+	}
+	if c.Type == chunkUVAnimDictionary {
+		c, _, err = readChunk(rest)
+		if err != nil {
+			return model, errors.Wrap(err, "failed to read clump")
+		}
+	}
+	if c.Type != chunkClump {
+		return model, ErrNotRenderWare
+	}
+	model.Version = c.Version
+
+	err = model.readClump(c)
+	return
+}
+
+func (m *Model) readClump(clump chunk) (err error) {
+	header, rest, err := expect(clump.Data, chunkStruct)
+	if err != nil {
+		return errors.Wrap(err, "clump")
+	}
+	r := reader{data: header.Data}
+	atomics := r.count(0)
+	if r.err != nil {
+		return errors.Wrap(r.err, "clump")
+	}
+
+	chunks, err := children(rest)
+	if err != nil {
+		return errors.Wrap(err, "clump")
+	}
+
+	frames, ok := find(chunks, chunkFrameList)
+	if !ok {
+		return errors.New("clump has no frame list")
+	}
+	err = m.readFrameList(frames)
+	if err != nil {
+		return errors.Wrap(err, "frame list")
+	}
+
+	geometries, ok := find(chunks, chunkGeometryList)
+	if !ok {
+		return errors.New("clump has no geometry list")
+	}
+	err = m.readGeometryList(geometries)
+	if err != nil {
+		return errors.Wrap(err, "geometry list")
+	}
+
+	for _, c := range chunks {
+		if c.Type != chunkAtomic {
+			continue
+		}
+		err = m.readAtomic(c)
+		if err != nil {
+			return errors.Wrapf(err, "atomic %d", m.Atomics)
+		}
+		m.Atomics++
+	}
+	if m.Atomics != atomics {
+		return errors.Errorf("clump declares %d atomics but has %d", atomics, m.Atomics)
+	}
+
+	if extension, ok := find(chunks, chunkExtension); ok {
+		plugins, err := children(extension.Data)
+		if err != nil {
+			return errors.Wrap(err, "clump extension")
+		}
+		_, m.Collision = find(plugins, chunkCollision)
+	}
+	return
+}
+
+func (m *Model) readFrameList(list chunk) (err error) {
+	header, rest, err := expect(list.Data, chunkStruct)
+	if err != nil {
+		return
+	}
+
+	// each frame is a rotation matrix, a position, a parent index and flags
+	r := reader{data: header.Data}
+	m.Frames = make([]Frame, r.count(56))
+	for i := range m.Frames {
+		r.skip(48)
+		m.Frames[i].Parent = int(r.int32())
+		r.skip(4)
+
+		if m.Frames[i].Parent < -1 || m.Frames[i].Parent >= len(m.Frames) {
+			return errors.Errorf("frame %d has invalid parent %d", i, m.Frames[i].Parent)
+		}
+	}
+	if r.err != nil {
+		return r.err
+	}
+
+	// followed by an extension for each frame that holds its name
+	extensions, err := children(rest)
+	if err != nil {
+		return
+	}
+	for i, extension := range extensions {
+		if i >= len(m.Frames) || extension.Type != chunkExtension {
+			break
+		}
+		plugins, err := children(extension.Data)
+		if err != nil {
+			return errors.Wrapf(err, "frame %d extension", i)
+		}
+		if name, ok := find(plugins, chunkFrame); ok {
+			m.Frames[i].Name = cString(name.Data)
+		}
+	}
+	return
+}
+
+func (m *Model) readGeometryList(list chunk) (err error) {
+	header, rest, err := expect(list.Data, chunkStruct)
+	if err != nil {
+		return
+	}
+	r := reader{data: header.Data}
+	count := r.count(0)
+	if r.err != nil {
+		return r.err
+	}
+
+	chunks, err := children(rest)
+	if err != nil {
+		return
+	}
+	for _, c := range chunks {
+		if c.Type != chunkGeometry {
+			continue
+		}
+		var g Geometry
+		g, err = readGeometry(c)
+		if err != nil {
+			return errors.Wrapf(err, "geometry %d", len(m.Geometries))
+		}
+		m.Geometries = append(m.Geometries, g)
+	}
+	if len(m.Geometries) != count {
+		return errors.Errorf("declares %d geometries but has %d", count, len(m.Geometries))
+	}
+	return
+}
+
+func readGeometry(geometry chunk) (g Geometry, err error) {
+	header, rest, err := expect(geometry.Data, chunkStruct)
+	if err != nil {
+		return
+	}
+
+	r := reader{data: header.Data}
+	flags := r.uint32()
+	// native geometry keeps its triangles and vertices in a plugin, otherwise every triangle takes
+	// at least 8 bytes of the struct and every vertex at least 12
+	if flags&geometryNative == 0 {
+		g.Triangles = r.count(8)
+		g.Vertices = r.count(12)
+	} else {
+		g.Triangles = r.count(0)
+		g.Vertices = r.count(0)
+	}
+	morphTargets := r.count(0)
+	if geometry.Version < 0x34000 {
+		r.skip(12) // ambient, specular and diffuse lighting
+	}
+
+	if flags&geometryNative == 0 {
+		uvSets := int(flags >> 16 & 0xFF)
+		if uvSets == 0 {
+			if flags&geometryTextured2 != 0 {
+				uvSets = 2
+			} else if flags&geometryTextured != 0 {
+				uvSets = 1
+			}
+		}
+		if flags&geometryPrelit != 0 {
+			r.skip(g.Vertices * 4)
+		}
+		r.skip(g.Vertices * 8 * uvSets)
+		r.skip(g.Triangles * 8)
+	}
+
+	for i := 0; i < morphTargets && r.err == nil; i++ {
+		r.skip(16) // bounding sphere
+		hasVertices := r.int32() != 0
+		hasNormals := r.int32() != 0
+		if hasVertices {
+			r.skip(g.Vertices * 12)
+		}
+		if hasNormals {
+			r.skip(g.Vertices * 12)
+		}
+	}
+	if r.err != nil {
+		return g, r.err
+	}
+
+	materials, _, err := expect(rest, chunkMaterialList)
+	if err != nil {
+		return
+	}
+	g.Materials, err = readMaterialList(materials)
+	if err != nil {
+		return g, errors.Wrap(err, "material list")
+	}
+	return
+}
+
+func readMaterialList(list chunk) (materials []Material, err error) {
+	header, rest, err := expect(list.Data, chunkStruct)
+	if err != nil {
+		return
+	}
+
+	// every slot is either -1 for the next material chunk or the index of an earlier slot
+	r := reader{data: header.Data}
+	slots := make([]int32, r.count(4))
+	for i := range slots {
+		slots[i] = r.int32()
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	chunks, err := children(rest)
+	if err != nil {
+		return
+	}
+
+	materials = make([]Material, len(slots))
+	next := 0
+	for i, slot := range slots {
+		if slot >= 0 {
+			if int(slot) >= i {
+				return nil, errors.Errorf("material %d refers to later material %d", i, slot)
+			}
+			materials[i] = materials[slot]
+			continue
+		}
+
+		for next < len(chunks) && chunks[next].Type != chunkMaterial {
+			next++
+		}
+		if next == len(chunks) {
+			return nil, errors.Errorf("material %d is missing", i)
+		}
+		materials[i], err = readMaterial(chunks[next])
+		if err != nil {
+			return nil, errors.Wrapf(err, "material %d", i)
+		}
+		next++
+	}
+	return
+}
+
+func readMaterial(material chunk) (m Material, err error) {
+	header, rest, err := expect(material.Data, chunkStruct)
+	if err != nil {
+		return
+	}
+
+	r := reader{data: header.Data}
+	r.skip(12) // flags, colour and an unused value
+	textured := r.int32() != 0
+	if r.err != nil {
+		return m, r.err
+	}
+	if !textured {
+		return
+	}
+
+	texture, _, err := expect(rest, chunkTexture)
+	if err != nil {
+		return
+	}
+	_, rest, err = expect(texture.Data, chunkStruct)
+	if err != nil {
+		return m, errors.Wrap(err, "texture")
+	}
+	name, rest, err := expect(rest, chunkString)
+	if err != nil {
+		return m, errors.Wrap(err, "texture name")
+	}
+	mask, _, err := expect(rest, chunkString)
+	if err != nil {
+		return m, errors.Wrap(err, "texture mask name")
+	}
+
+	m.Texture = cString(name.Data)
+	m.Mask = cString(mask.Data)
+	return
+}
+
+func (m *Model) readAtomic(atomic chunk) (err error) {
+	header, _, err := expect(atomic.Data, chunkStruct)
+	if err != nil {
+		return
+	}
+
+	r := reader{data: header.Data}
+	frame := int(r.int32())
+	geometry := int(r.int32())
+	if r.err != nil {
+		return r.err
+	}
+
+	if frame < 0 || frame >= len(m.Frames) {
+		return errors.Errorf("invalid frame index %d", frame)
+	}
+	if geometry < 0 || geometry >= len(m.Geometries) {
+		return errors.Errorf("invalid geometry index %d", geometry)
+	}
+	return
+}
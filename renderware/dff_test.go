@@ -0,0 +1,120 @@
+package renderware
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testFrame(parent int32) []byte {
+	return le([12]float32{}, parent, uint32(0))
+}
+
+func testMaterial(texture string) []byte {
+	if texture == "" {
+		return build(chunkMaterial, build(chunkStruct, le(int32(0), [4]uint8{255, 255, 255, 255}, int32(0), int32(0), [3]float32{})))
+	}
+	return build(chunkMaterial,
+		build(chunkStruct, le(int32(0), [4]uint8{255, 255, 255, 255}, int32(0), int32(1), [3]float32{})),
+		build(chunkTexture,
+			build(chunkStruct, le(uint32(0x1106))),
+			build(chunkString, str(texture, 16)),
+			build(chunkString, str("", 4)),
+			build(chunkExtension)),
+		build(chunkExtension))
+}
+
+func testGeometry(vertices, triangles int32, textures ...string) []byte {
+	geometry := le(uint32(0x02|0x04|0x10|1<<16), triangles, vertices, int32(1))
+	geometry = append(geometry, make([]byte, vertices*8)...)  // uvs
+	geometry = append(geometry, make([]byte, triangles*8)...) // triangles
+	geometry = append(geometry, le([4]float32{}, int32(1), int32(1))...)
+	geometry = append(geometry, make([]byte, vertices*24)...) // positions and normals
+
+	slots := le(int32(len(textures) + 1))
+	var materials []byte
+	for _, texture := range textures {
+		slots = append(slots, le(int32(-1))...)
+		materials = append(materials, testMaterial(texture)...)
+	}
+	slots = append(slots, le(int32(0))...) // a second use of the first material
+
+	return build(chunkGeometry,
+		build(chunkStruct, geometry),
+		build(chunkMaterialList, build(chunkStruct, slots), materials),
+		build(chunkExtension))
+}
+
+func testModel(collision bool) []byte {
+	var extension []byte
+	if collision {
+		extension = build(chunkCollision, []byte("COL3"))
+	}
+	return build(chunkClump,
+		build(chunkStruct, le(int32(2), int32(0), int32(0))),
+		build(chunkFrameList,
+			build(chunkStruct, le(int32(2)), testFrame(-1), testFrame(0)),
+			build(chunkExtension, build(chunkFrame, []byte("root"))),
+			build(chunkExtension, build(chunkFrame, []byte("door_l")))),
+		build(chunkGeometryList,
+			build(chunkStruct, le(int32(2))),
+			testGeometry(4, 2, "wall", ""),
+			testGeometry(3, 1, "door", "wall")),
+		build(chunkAtomic, build(chunkStruct, le(int32(0), int32(0), int32(5), int32(0))), build(chunkExtension)),
+		build(chunkAtomic, build(chunkStruct, le(int32(1), int32(1), int32(5), int32(0))), build(chunkExtension)),
+		build(chunkExtension, extension))
+}
+
+func TestReadModel(t *testing.T) {
+	model, err := ReadModel(testModel(true))
+	assert.NoError(t, err)
+	assert.Equal(t, "3.6.0.3", model.Version.String())
+	assert.Equal(t, []Frame{{"root", -1}, {"door_l", 0}}, model.Frames)
+	assert.Equal(t, 2, len(model.Geometries))
+	assert.Equal(t, 2, model.Atomics)
+	assert.Equal(t, 7, model.Vertices())
+	assert.Equal(t, 3, model.Triangles())
+	assert.Equal(t, []Material{{"wall", ""}, {}, {"wall", ""}}, model.Geometries[0].Materials)
+	assert.Equal(t, []string{"wall", "door"}, model.Textures())
+	assert.True(t, model.Collision)
+
+	model, err = ReadModel(testModel(false))
+	assert.NoError(t, err)
+	assert.False(t, model.Collision)
+}
+
+func TestReadModelInvalid(t *testing.T) {
+	_, err := ReadModel([]byte("\x89PNG\r\n\x1a\n0000000000000000"))
+	assert.Equal(t, ErrNotRenderWare, err)
+
+	_, err = ReadModel(nil)
+	assert.Equal(t, ErrNotRenderWare, err)
+
+	data := testModel(false)
+	for _, n := range []int{20, len(data) / 2, len(data) - 1} {
+		truncated := append([]byte{}, data[:n]...)
+		copy(truncated[4:], le(uint32(n-chunkHeaderSize)))
+		_, err = ReadModel(truncated)
+		assert.Error(t, err, "truncated to %d bytes", n)
+	}
+}
+
+func TestReadModelHugeCounts(t *testing.T) {
+	// a geometry that claims 0x7FFFFFFF vertices with 255 UV sets and then ends, reading it must
+	// fail instead of allocating the terabytes it would need
+	data := build(chunkClump,
+		build(chunkStruct, le(int32(0))),
+		build(chunkFrameList, build(chunkStruct, le(int32(0)))),
+		build(chunkGeometryList,
+			build(chunkStruct, le(int32(1))),
+			build(chunkGeometry, build(chunkStruct, le(uint32(0x00FF0000), int32(0), int32(0x7FFFFFFF))))))
+
+	_, err := ReadModel(data)
+	assert.Error(t, err)
+
+	r := reader{data: []byte{1, 2}}
+	assert.Nil(t, r.take(0x7FFFFFFF))
+	assert.Nil(t, r.take(1))
+	assert.Equal(t, uint32(0), r.uint32())
+	assert.Error(t, r.err)
+}
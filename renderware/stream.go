@@ -0,0 +1,184 @@
+// Package renderware reads the RenderWare binary stream files used by GTA: San Andreas for models
+// (DFF) and texture dictionaries (TXD). A stream is a tree of chunks, each one a 12 byte header of
+// type, size and library version followed by its data, which for most chunks is more chunks.
+package renderware
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+// chunk types
+const (
+	chunkStruct            = 0x01
+	chunkString            = 0x02
+	chunkExtension         = 0x03
+	chunkTexture           = 0x06
+	chunkMaterial          = 0x07
+	chunkMaterialList      = 0x08
+	chunkFrameList         = 0x0E
+	chunkGeometry          = 0x0F
+	chunkClump             = 0x10
+	chunkAtomic            = 0x14
+	chunkTextureNative     = 0x15
+	chunkTextureDictionary = 0x16
+	chunkGeometryList      = 0x1A
+	chunkFrame             = 0x0253F2FE // node name plugin
+	chunkCollision         = 0x0253F2FA // embedded collision model plugin
+)
+
+// chunkHeaderSize is the size of a chunk's type, size and library version
+const chunkHeaderSize = 12
+
+// ErrNotRenderWare is returned when a file does not start with the chunk that is expected for its
+// type, usually because it is some other kind of file with a RenderWare extension
+var ErrNotRenderWare = errors.New("not a RenderWare file")
+
+// Version is a RenderWare library version such as 0x36003 for 3.6.0.3, the version used by GTA: San
+// Andreas
+type Version uint32
+
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d.%d.%d", v>>16&0xF, v>>12&0xF, v>>8&0xF, v&0xFF)
+}
+
+// chunk is a section of a binary stream
+type chunk struct {
+	Type    uint32
+	Version Version
+	Data    []byte
+}
+
+// decodeVersion unpacks the library version stamp from a chunk header, streams written before 3.1
+// store the version directly rather than packed with a build number
+func decodeVersion(stamp uint32) Version {
+	if stamp&0xFFFF0000 == 0 {
+		return Version(stamp << 8)
+	}
+	return Version((stamp>>14&0x3FF00)+0x30000) | Version(stamp>>16&0x3F)
+}
+
+// readChunk reads the chunk at the start of data and returns the data after it
+func readChunk(data []byte) (c chunk, rest []byte, err error) {
+	if len(data) < chunkHeaderSize {
+		return c, nil, errors.New("truncated chunk header")
+	}
+	c.Type = binary.LittleEndian.Uint32(data[0:])
+	size := binary.LittleEndian.Uint32(data[4:])
+	c.Version = decodeVersion(binary.LittleEndian.Uint32(data[8:]))
+
+	data = data[chunkHeaderSize:]
+	if uint64(size) > uint64(len(data)) {
+		return c, nil, errors.Errorf("chunk 0x%X claims %d bytes but only %d remain", c.Type, size, len(data))
+	}
+	c.Data = data[:size]
+	return c, data[size:], nil
+}
+
+// children splits the data of a chunk into the chunks inside it
+func children(data []byte) (chunks []chunk, err error) {
+	for len(data) > 0 {
+		var c chunk
+		c, data, err = readChunk(data)
+		if err != nil {
+			return
+		}
+		chunks = append(chunks, c)
+	}
+	return
+}
+
+// expect reads the next child chunk and checks its type
+func expect(data []byte, chunkType uint32) (c chunk, rest []byte, err error) {
+	c, rest, err = readChunk(data)
+	if err != nil {
+		return
+	}
+	if c.Type != chunkType {
+		return c, rest, errors.Errorf("expected chunk 0x%X but found 0x%X", chunkType, c.Type)
+	}
+	return
+}
+
+// find returns the first chunk of a type in a list
+func find(chunks []chunk, chunkType uint32) (chunk, bool) {
+	for _, c := range chunks {
+		if c.Type == chunkType {
+			return c, true
+		}
+	}
+	return chunk{}, false
+}
+
+// reader decodes little endian values from a struct chunk and remembers the first overrun so a
+// sequence of reads only needs one check at the end
+type reader struct {
+	data []byte
+	err  error
+}
+
+// take returns the next n bytes, once a read has overrun it returns nil without allocating so a
+// corrupt size can't exhaust memory
+func (r *reader) take(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(r.data) {
+		r.err = errors.New("truncated struct")
+		return nil
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *reader) uint8() uint8 {
+	if b := r.take(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *reader) uint16() uint16 {
+	if b := r.take(2); b != nil {
+		return binary.LittleEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (r *reader) uint32() uint32 {
+	if b := r.take(4); b != nil {
+		return binary.LittleEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (r *reader) int32() int32     { return int32(r.uint32()) }
+func (r *reader) float32() float32 { return math.Float32frombits(r.uint32()) }
+func (r *reader) skip(n int)       { r.take(n) }
+
+// count reads a 32 bit element count and checks that at least size bytes per element remain, so a
+// corrupt count can't cause a huge allocation
+func (r *reader) count(size int) int {
+	n := r.int32()
+	if r.err == nil && (n < 0 || (size > 0 && int64(n)*int64(size) > int64(len(r.data)))) {
+		r.err = errors.Errorf("invalid count %d", n)
+	}
+	if r.err != nil {
+		return 0
+	}
+	return int(n)
+}
+
+// cString decodes a fixed size or chunk string, which is padded with zeros
+func cString(b []byte) string {
+	for i, c := range b {
+		if c == 0 {
+			return string(b[:i])
+		}
+	}
+	return string(b)
+}
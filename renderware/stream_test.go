@@ -0,0 +1,55 @@
+package renderware
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// sa is the version stamp written by GTA: San Andreas, 3.6.0.3
+const sa = 0x1803FFFF
+
+// build writes a chunk with the concatenated parts as its data
+func build(chunkType uint32, parts ...[]byte) []byte {
+	data := bytes.Join(parts, nil)
+	return append(le(chunkType, uint32(len(data)), uint32(sa)), data...)
+}
+
+// le encodes fixed size values as little endian
+func le(values ...interface{}) []byte {
+	var buf bytes.Buffer
+	for _, v := range values {
+		err := binary.Write(&buf, binary.LittleEndian, v)
+		if err != nil {
+			panic(err)
+		}
+	}
+	return buf.Bytes()
+}
+
+// str encodes a zero padded string for a string chunk
+func str(s string, size int) []byte {
+	b := make([]byte, size)
+	copy(b, s)
+	return b
+}
+
+func Test_decodeVersion(t *testing.T) {
+	assert.Equal(t, "3.6.0.3", decodeVersion(sa).String())
+	assert.Equal(t, "3.1.0.0", decodeVersion(0x310).String())
+}
+
+func Test_readChunk(t *testing.T) {
+	c, rest, err := readChunk(append(build(chunkString, []byte("abc\x00")), 1, 2))
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(chunkString), c.Type)
+	assert.Equal(t, "abc", cString(c.Data))
+	assert.Equal(t, []byte{1, 2}, rest)
+
+	_, _, err = readChunk(build(chunkString, []byte("abc"))[:14])
+	assert.Error(t, err)
+	_, _, err = readChunk([]byte{1, 2, 3})
+	assert.Error(t, err)
+}
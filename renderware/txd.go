@@ -0,0 +1,185 @@
+package renderware
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// texture native platforms
+const (
+	platformD3D8 = 8
+	platformD3D9 = 9
+)
+
+// raster format flags, the low bits of the masked value are the pixel format
+const (
+	rasterFormatMask = 0x0F00
+	rasterPal8       = 0x2000
+	rasterPal4       = 0x4000
+)
+
+// rasterFormats names the pixel formats of uncompressed rasters
+var rasterFormats = map[uint32]string{
+	0x0100: "1555",
+	0x0200: "565",
+	0x0300: "4444",
+	0x0400: "LUM8",
+	0x0500: "8888",
+	0x0600: "888",
+	0x0A00: "555",
+}
+
+// TextureDictionary is the contents of a TXD file
+type TextureDictionary struct {
+	Version  Version
+	Textures []Texture
+}
+
+// Texture is a single raster in a texture dictionary, Format is DXT1, DXT3 or DXT5 for compressed
+// textures, PAL4 or PAL8 for paletted textures or the bit layout of the pixels such as 8888
+type Texture struct {
+	Name    string
+	Mask    string
+	Width   int
+	Height  int
+	Depth   int
+	Format  string
+	MipMaps int
+	Alpha   bool
+	Size    int // bytes of pixel data in every mipmap level, which is roughly what it costs in memory
+
+	palette []byte
+	levels  [][]byte
+}
+
+// Texture returns the texture with the given name, RenderWare texture names are case insensitive
+func (d TextureDictionary) Texture(name string) (Texture, bool) {
+	for _, t := range d.Textures {
+		if strings.EqualFold(t.Name, name) {
+			return t, true
+		}
+	}
+	return Texture{}, false
+}
+
+// ReadTextureDictionary parses a TXD file, ErrNotRenderWare is returned if data does not start
+// with a texture dictionary
+func ReadTextureDictionary(data []byte) (txd TextureDictionary, err error) {
+	c, _, err := readChunk(data)
+	if err != nil || c.Type != chunkTextureDictionary {
+		return txd, ErrNotRenderWare
+	}
+	txd.Version = c.Version
+
+	header, rest, err := expect(c.Data, chunkStruct)
+	if err != nil {
+		return txd, errors.Wrap(err, "texture dictionary")
+	}
+	r := reader{data: header.Data}
+	count := int(r.uint16()) // followed by a device ID since 3.6
+	if r.err != nil {
+		return txd, errors.Wrap(r.err, "texture dictionary")
+	}
+
+	chunks, err := children(rest)
+	if err != nil {
+		return txd, errors.Wrap(err, "texture dictionary")
+	}
+	for _, c := range chunks {
+		if c.Type != chunkTextureNative {
+			continue
+		}
+		var t Texture
+		t, err = readTextureNative(c)
+		if err != nil {
+			return txd, errors.Wrapf(err, "texture %d", len(txd.Textures))
+		}
+		txd.Textures = append(txd.Textures, t)
+	}
+	if len(txd.Textures) != count {
+		return txd, errors.Errorf("texture dictionary declares %d textures but has %d", count, len(txd.Textures))
+	}
+	return
+}
+
+func readTextureNative(native chunk) (t Texture, err error) {
+	header, _, err := expect(native.Data, chunkStruct)
+	if err != nil {
+		return
+	}
+
+	r := reader{data: header.Data}
+	platform := r.uint32()
+	if r.err == nil && platform != platformD3D8 && platform != platformD3D9 {
+		return t, errors.Errorf("unsupported platform %d, only PC textures can be read", platform)
+	}
+	r.skip(4) // filtering and addressing
+	t.Name = cString(r.take(32))
+	t.Mask = cString(r.take(32))
+	raster := r.uint32()
+
+	// Direct3D 9 textures store the D3DFORMAT and a set of flags, Direct3D 8 textures store whether
+	// there is an alpha channel and the DXT compression type in their place
+	var compression string
+	if platform == platformD3D9 {
+		format := r.take(4)
+		t.Width = int(r.uint16())
+		t.Height = int(r.uint16())
+		t.Depth = int(r.uint8())
+		t.MipMaps = int(r.uint8())
+		r.skip(1) // raster type
+		flags := r.uint8()
+		t.Alpha = flags&0x01 != 0
+		if flags&0x08 != 0 {
+			compression = string(format)
+		}
+	} else {
+		t.Alpha = r.uint32() != 0
+		t.Width = int(r.uint16())
+		t.Height = int(r.uint16())
+		t.Depth = int(r.uint8())
+		t.MipMaps = int(r.uint8())
+		r.skip(1) // raster type
+		if dxt := r.uint8(); dxt != 0 {
+			compression = fmt.Sprintf("DXT%d", dxt)
+		}
+	}
+	if r.err != nil {
+		return t, r.err
+	}
+
+	switch {
+	case compression != "":
+		if compression != "DXT1" && compression != "DXT3" && compression != "DXT5" {
+			return t, errors.Errorf("unsupported compression %q", compression)
+		}
+		t.Format = compression
+	case raster&rasterPal8 != 0:
+		t.Format = "PAL8"
+		t.palette = r.take(256 * 4)
+	case raster&rasterPal4 != 0:
+		t.Format = "PAL4"
+		t.palette = r.take(32 * 4)
+	default:
+		var ok bool
+		t.Format, ok = rasterFormats[raster&rasterFormatMask]
+		if !ok {
+			return t, errors.Errorf("unknown raster format 0x%X", raster)
+		}
+	}
+
+	for i := 0; i < t.MipMaps; i++ {
+		level := r.take(r.count(1))
+		t.levels = append(t.levels, level)
+		t.Size += len(level)
+	}
+	if r.err != nil {
+		return t, r.err
+	}
+	if t.Width == 0 || t.Height == 0 || t.MipMaps == 0 {
+		return t, errors.Errorf("texture %s has no pixels", t.Name)
+	}
+	return
+}
@@ -0,0 +1,85 @@
+package renderware
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testTexture builds a Direct3D 9 texture native, format is the D3DFORMAT and flags are the alpha
+// and compression flags
+func testTexture(name string, raster uint32, format string, width, height uint16, flags uint8, palette []byte, levels ...[]byte) []byte {
+	data := le(uint32(platformD3D9), uint32(0x1102))
+	data = append(data, str(name, 32)...)
+	data = append(data, str("", 32)...)
+	data = append(data, le(raster)...)
+	data = append(data, str(format, 4)...)
+	data = append(data, le(width, height, uint8(32), uint8(len(levels)), uint8(4), flags)...)
+	data = append(data, palette...)
+	for _, level := range levels {
+		data = append(data, le(uint32(len(level)))...)
+		data = append(data, level...)
+	}
+	return build(chunkTextureNative, build(chunkStruct, data), build(chunkExtension))
+}
+
+func testDictionary(textures ...[]byte) []byte {
+	var natives []byte
+	for _, t := range textures {
+		natives = append(natives, t...)
+	}
+	return build(chunkTextureDictionary,
+		build(chunkStruct, le(uint16(len(textures)), uint16(2))),
+		natives,
+		build(chunkExtension))
+}
+
+func TestReadTextureDictionary(t *testing.T) {
+	d3d8 := le(uint32(platformD3D8), uint32(0x1102))
+	d3d8 = append(d3d8, str("Sign", 32)...)
+	d3d8 = append(d3d8, str("SignA", 32)...)
+	d3d8 = append(d3d8, le(uint32(0x0300), uint32(1), uint16(8), uint16(8), uint8(16), uint8(1), uint8(4), uint8(3))...)
+	d3d8 = append(d3d8, le(uint32(16))...)
+	d3d8 = append(d3d8, make([]byte, 16)...)
+
+	txd, err := ReadTextureDictionary(testDictionary(
+		testTexture("brick", 0x8200, "DXT1", 8, 8, 0x08, nil, make([]byte, 32), make([]byte, 8)),
+		testTexture("glass", 0x0500, "\x15\x00\x00\x00", 2, 2, 0x01, nil, make([]byte, 16)),
+		testTexture("palette", 0x2500, "\x29\x00\x00\x00", 4, 2, 0x00, make([]byte, 1024), make([]byte, 8)),
+		build(chunkTextureNative, build(chunkStruct, d3d8), build(chunkExtension)),
+	))
+	assert.NoError(t, err)
+	assert.Equal(t, "3.6.0.3", txd.Version.String())
+	for i := range txd.Textures {
+		txd.Textures[i].palette, txd.Textures[i].levels = nil, nil
+	}
+	assert.Equal(t, []Texture{
+		{Name: "brick", Width: 8, Height: 8, Depth: 32, Format: "DXT1", MipMaps: 2, Size: 40},
+		{Name: "glass", Width: 2, Height: 2, Depth: 32, Format: "8888", MipMaps: 1, Alpha: true, Size: 16},
+		{Name: "palette", Width: 4, Height: 2, Depth: 32, Format: "PAL8", MipMaps: 1, Size: 8},
+		{Name: "Sign", Mask: "SignA", Width: 8, Height: 8, Depth: 16, Format: "DXT3", MipMaps: 1, Alpha: true, Size: 16},
+	}, txd.Textures)
+
+	texture, ok := txd.Texture("SIGN")
+	assert.True(t, ok)
+	assert.Equal(t, "Sign", texture.Name)
+	_, ok = txd.Texture("missing")
+	assert.False(t, ok)
+}
+
+func TestReadTextureDictionaryInvalid(t *testing.T) {
+	_, err := ReadTextureDictionary(testModel(false))
+	assert.Equal(t, ErrNotRenderWare, err)
+
+	_, err = ReadTextureDictionary(testDictionary(testTexture("bad", 0x0500, "", 2, 2, 0, nil)))
+	assert.Error(t, err)
+
+	_, err = ReadTextureDictionary(testDictionary(testTexture("bad", 0x0500, "DXT2", 4, 4, 0x08, nil, make([]byte, 16))))
+	assert.Error(t, err)
+
+	data := testDictionary(testTexture("brick", 0x8200, "DXT1", 8, 8, 0x08, nil, make([]byte, 32)))
+	truncated := append([]byte{}, data[:len(data)-20]...)
+	copy(truncated[4:], le(uint32(len(truncated)-chunkHeaderSize)))
+	_, err = ReadTextureDictionary(truncated)
+	assert.Error(t, err)
+}
@@ -13,6 +13,7 @@ type Route struct {
 	Methods       []string `json:"method"`
 	Path          string   `json:"path"`
 	Authenticated bool     `json:"authenticated"`
+	Admin         bool     `json:"admin"`
 	handler       http.HandlerFunc
 }
 
@@ -61,6 +62,13 @@ func (app App) routes() (routes []Route) {
 			Authenticated: true,
 			handler:       app.AccountUpdateInfo,
 		},
+		{
+			Name:          "delete user account",
+			Methods:       []string{"DELETE"},
+			Path:          "/v0/accounts/info",
+			Authenticated: true,
+			handler:       app.AccountDelete,
+		},
 		// /objects/
 		{
 			Name:          "list objects",
@@ -69,6 +77,13 @@ func (app App) routes() (routes []Route) {
 			Authenticated: false,
 			handler:       app.ObjectsList,
 		},
+		{
+			Name:          "count object facets",
+			Methods:       []string{"GET"},
+			Path:          "/v0/objects/facets",
+			Authenticated: false,
+			handler:       app.ObjectFacets,
+		},
 		{
 			Name:          "get object info",
 			Methods:       []string{"GET"},
@@ -76,6 +91,22 @@ func (app App) routes() (routes []Route) {
 			Authenticated: false,
 			handler:       app.ObjectByName,
 		},
+		// /categories/
+		{
+			Name:          "list categories",
+			Methods:       []string{"GET"},
+			Path:          "/v0/categories",
+			Authenticated: false,
+			handler:       app.CategoryList,
+		},
+		// /tags/
+		{
+			Name:          "autocomplete tags",
+			Methods:       []string{"GET"},
+			Path:          "/v0/tags",
+			Authenticated: false,
+			handler:       app.TagList,
+		},
 		// /images/
 		{
 			Name:          "get object image",
@@ -89,16 +120,117 @@ func (app App) routes() (routes []Route) {
 			Methods:       []string{"GET"},
 			Path:          "/v0/images/{objectid}/{fileName}",
 			Authenticated: false,
-			handler:       app.ObjectFiles,
+			handler:       app.ObjectImage,
 		},
 		// /files/
 		{
 			Name:          "get object file by name",
-			Methods:       []string{"GET"},
+			Methods:       []string{"GET", "HEAD"},
 			Path:          "/v0/files/{objectid}/{fileName}",
 			Authenticated: false,
 			handler:       app.ObjectFiles,
 		},
+		{
+			Name:          "get texture preview",
+			Methods:       []string{"GET"},
+			Path:          "/v0/files/{objectid}/{fileName}/textures/{texture}",
+			Authenticated: false,
+			handler:       app.ObjectTexturePreview,
+		},
+		// /artconfig/
+		{
+			Name:          "generate artconfig for objects",
+			Methods:       []string{"GET"},
+			Path:          "/v0/artconfig",
+			Authenticated: false,
+			handler:       app.ObjectArtConfig,
+		},
+		{
+			Name:          "generate artconfig for object",
+			Methods:       []string{"GET"},
+			Path:          "/v0/artconfig/{objectid}",
+			Authenticated: false,
+			handler:       app.ObjectArtConfig,
+		},
+		// /download/
+		{
+			Name:          "download objects",
+			Methods:       []string{"GET"},
+			Path:          "/v0/download",
+			Authenticated: false,
+			handler:       app.ObjectDownload,
+		},
+		{
+			Name:          "download object",
+			Methods:       []string{"GET"},
+			Path:          "/v0/download/{objectid}",
+			Authenticated: false,
+			handler:       app.ObjectDownload,
+		},
+		// /collections/
+		{
+			Name:          "list user collections",
+			Methods:       []string{"GET"},
+			Path:          "/v0/collections",
+			Authenticated: true,
+			handler:       app.CollectionList,
+		},
+		{
+			Name:          "create collection",
+			Methods:       []string{"POST"},
+			Path:          "/v0/collections",
+			Authenticated: true,
+			handler:       app.CollectionCreate,
+		},
+		{
+			Name:          "get collection",
+			Methods:       []string{"GET"},
+			Path:          "/v0/collections/{collectionid}",
+			Authenticated: false,
+			handler:       app.CollectionGet,
+		},
+		{
+			Name:          "delete collection",
+			Methods:       []string{"DELETE"},
+			Path:          "/v0/collections/{collectionid}",
+			Authenticated: true,
+			handler:       app.CollectionDelete,
+		},
+		{
+			Name:          "add object to collection",
+			Methods:       []string{"PUT"},
+			Path:          "/v0/collections/{collectionid}/objects/{objectid}",
+			Authenticated: true,
+			handler:       app.CollectionAddObject,
+		},
+		{
+			Name:          "remove object from collection",
+			Methods:       []string{"DELETE"},
+			Path:          "/v0/collections/{collectionid}/objects/{objectid}",
+			Authenticated: true,
+			handler:       app.CollectionRemoveObject,
+		},
+		{
+			Name:          "release model IDs of object removed from collection",
+			Methods:       []string{"POST"},
+			Path:          "/v0/collections/{collectionid}/objects/{objectid}/release",
+			Authenticated: true,
+			handler:       app.CollectionReleaseObject,
+		},
+		{
+			Name:          "generate artconfig for collection",
+			Methods:       []string{"GET"},
+			Path:          "/v0/collections/{collectionid}/artconfig",
+			Authenticated: false,
+			handler:       app.CollectionArtConfig,
+		},
+		{
+			Name:          "check collection for model ID collisions",
+			Methods:       []string{"POST"},
+			Path:          "/v0/collections/{collectionid}/collisions",
+			Authenticated: false,
+			handler:       app.CollectionCollisions,
+		},
 		// /object/
 		{
 			Name:          "prepare object upload",
@@ -107,6 +239,13 @@ func (app App) routes() (routes []Route) {
 			Authenticated: true,
 			handler:       app.ObjectPrepare,
 		},
+		{
+			Name:          "prepare object version upload",
+			Methods:       []string{"POST"},
+			Path:          "/v0/object/prepare/{objectid}",
+			Authenticated: true,
+			handler:       app.VersionPrepare,
+		},
 		{
 			Name:          "upload object files",
 			Methods:       []string{"POST"},
@@ -121,6 +260,27 @@ func (app App) routes() (routes []Route) {
 			Authenticated: true,
 			handler:       app.ObjectFinish,
 		},
+		{
+			Name:          "upload object archive",
+			Methods:       []string{"POST"},
+			Path:          "/v0/object/archive",
+			Authenticated: true,
+			handler:       app.ObjectArchive,
+		},
+		{
+			Name:          "trash object",
+			Methods:       []string{"DELETE"},
+			Path:          "/v0/object/{objectid}",
+			Authenticated: true,
+			handler:       app.ObjectTrash,
+		},
+		{
+			Name:          "restore object",
+			Methods:       []string{"POST"},
+			Path:          "/v0/object/{objectid}/restore",
+			Authenticated: true,
+			handler:       app.ObjectRestore,
+		},
 		// /users/
 		{
 			Name:          "get user public profile",
@@ -129,6 +289,13 @@ func (app App) routes() (routes []Route) {
 			Authenticated: false,
 			handler:       app.UserProfile,
 		},
+		{
+			Name:          "list user objects",
+			Methods:       []string{"GET"},
+			Path:          "/v0/users/{username}/objects",
+			Authenticated: false,
+			handler:       app.UserObjects,
+		},
 		// /ratings/
 		{
 			Name:          "post rating to object",
@@ -139,7 +306,7 @@ func (app App) routes() (routes []Route) {
 		},
 		{
 			Name:          "list object ratings",
-			Methods:       []string{"DELETE"},
+			Methods:       []string{"GET"},
 			Path:          "/v0/ratings/{objectid}",
 			Authenticated: true,
 			handler:       app.RatingList,
@@ -168,11 +335,115 @@ func (app App) routes() (routes []Route) {
 		},
 		{
 			Name:          "remove comment",
-			Methods:       []string{"PATCH"},
+			Methods:       []string{"DELETE"},
 			Path:          "/v0/comments/{objectid}/{commentid}",
 			Authenticated: true,
 			handler:       app.CommentRemove,
 		},
+		{
+			Name:          "restore comment",
+			Methods:       []string{"POST"},
+			Path:          "/v0/comments/{objectid}/{commentid}/restore",
+			Authenticated: true,
+			handler:       app.CommentRestore,
+		},
+		// /admin/
+		{
+			Name:          "file deduplication report",
+			Methods:       []string{"GET"},
+			Path:          "/v0/admin/dedup",
+			Authenticated: true,
+			Admin:         true,
+			handler:       app.AdminDedupReport,
+		},
+		{
+			Name:          "list trash",
+			Methods:       []string{"GET"},
+			Path:          "/v0/admin/trash",
+			Authenticated: true,
+			Admin:         true,
+			handler:       app.AdminTrash,
+		},
+		{
+			Name:          "trash user",
+			Methods:       []string{"DELETE"},
+			Path:          "/v0/admin/users/{userid}",
+			Authenticated: true,
+			Admin:         true,
+			handler:       app.AdminUserTrash,
+		},
+		{
+			Name:          "restore user",
+			Methods:       []string{"POST"},
+			Path:          "/v0/admin/users/{userid}/restore",
+			Authenticated: true,
+			Admin:         true,
+			handler:       app.AdminUserRestore,
+		},
+		{
+			Name:          "create category",
+			Methods:       []string{"POST"},
+			Path:          "/v0/admin/categories",
+			Authenticated: true,
+			Admin:         true,
+			handler:       app.AdminCategoryCreate,
+		},
+		{
+			Name:          "list unknown categories",
+			Methods:       []string{"GET"},
+			Path:          "/v0/admin/categories/unknown",
+			Authenticated: true,
+			Admin:         true,
+			handler:       app.AdminCategoryUnknown,
+		},
+		{
+			Name:          "remap categories",
+			Methods:       []string{"POST"},
+			Path:          "/v0/admin/categories/remap",
+			Authenticated: true,
+			Admin:         true,
+			handler:       app.AdminCategoryRemap,
+		},
+		{
+			Name:          "update category",
+			Methods:       []string{"PATCH"},
+			Path:          "/v0/admin/categories/{slug}",
+			Authenticated: true,
+			Admin:         true,
+			handler:       app.AdminCategoryUpdate,
+		},
+		{
+			Name:          "delete category",
+			Methods:       []string{"DELETE"},
+			Path:          "/v0/admin/categories/{slug}",
+			Authenticated: true,
+			Admin:         true,
+			handler:       app.AdminCategoryDelete,
+		},
+		{
+			Name:          "list tag aliases",
+			Methods:       []string{"GET"},
+			Path:          "/v0/admin/tags/aliases",
+			Authenticated: true,
+			Admin:         true,
+			handler:       app.AdminTagAliasList,
+		},
+		{
+			Name:          "create tag alias",
+			Methods:       []string{"POST"},
+			Path:          "/v0/admin/tags/aliases",
+			Authenticated: true,
+			Admin:         true,
+			handler:       app.AdminTagAliasCreate,
+		},
+		{
+			Name:          "delete tag alias",
+			Methods:       []string{"DELETE"},
+			Path:          "/v0/admin/tags/aliases/{alias}",
+			Authenticated: true,
+			Admin:         true,
+			handler:       app.AdminTagAliasDelete,
+		},
 	}
 	return
 }
@@ -0,0 +1,156 @@
+package storage
+
+import (
+	"math"
+	"sort"
+
+	"github.com/pkg/errors"
+
+	"github.com/Southclaws/samp-objects-api/types"
+)
+
+const (
+	// ratingConfidence is the number of virtual ratings at the prior mean that are added to every
+	// object when ranking by score, objects with only a few ratings are pulled towards the mean so
+	// a single 5 star rating doesn't outrank fifty 4.8 star ratings.
+	ratingConfidence = 5
+
+	// defaultRatingPrior is used as the prior mean before anything has been rated
+	defaultRatingPrior = 2.5
+
+	// ratingTolerance absorbs floating point error in summed rating totals
+	ratingTolerance = 1e-6
+
+	// ratingFixAttempts is how many times an object's counters are recalculated when ratings keep
+	// changing them while they are being fixed
+	ratingFixAttempts = 3
+)
+
+// RatingAggregate holds the denormalised rating counters that are stored on each object
+type RatingAggregate struct {
+	Count     types.ObjectRateCount     `json:"count"`
+	Total     types.ObjectRateTotal     `json:"total"`
+	Histogram types.ObjectRateHistogram `json:"histogram"`
+}
+
+// RatingDrift describes an object whose stored rating counters disagree with its ratings
+type RatingDrift struct {
+	ObjectID types.ObjectID  `json:"object_id"`
+	Stored   RatingAggregate `json:"stored"`
+	Actual   RatingAggregate `json:"actual"`
+}
+
+func (a *RatingAggregate) add(value float64) {
+	a.Count++
+	a.Total += types.ObjectRateTotal(value)
+	a.Histogram[types.RatingStar(value)]++
+}
+
+func (a RatingAggregate) equal(b RatingAggregate) bool {
+	return a.Count == b.Count &&
+		a.Histogram == b.Histogram &&
+		math.Abs(float64(a.Total-b.Total)) < ratingTolerance
+}
+
+func aggregateOf(object types.Object) RatingAggregate {
+	return RatingAggregate{
+		Count:     object.RateCount,
+		Total:     object.RateTotal,
+		Histogram: object.RateHistogram,
+	}
+}
+
+// aggregateRatings sums a set of ratings per object
+func aggregateRatings(ratings []types.Rating) map[types.ObjectID]RatingAggregate {
+	actual := make(map[types.ObjectID]RatingAggregate)
+	for _, rating := range ratings {
+		aggregate := actual[rating.ObjectID]
+		aggregate.add(rating.Value)
+		actual[rating.ObjectID] = aggregate
+	}
+	return actual
+}
+
+// ratingDrift compares the stored counters of every object with the aggregates calculated from the
+// ratings themselves and returns the objects that differ, ordered by object ID.
+func ratingDrift(stored map[types.ObjectID]RatingAggregate, actual map[types.ObjectID]RatingAggregate) (drift []RatingDrift) {
+	for objectID, aggregate := range stored {
+		if !aggregate.equal(actual[objectID]) {
+			drift = append(drift, RatingDrift{
+				ObjectID: objectID,
+				Stored:   aggregate,
+				Actual:   actual[objectID],
+			})
+		}
+	}
+	sort.Slice(drift, func(i, j int) bool { return drift[i].ObjectID < drift[j].ObjectID })
+	return
+}
+
+// ratingCounters reads and saves the rating counters of one object, every backend implements it
+// so the counters are fixed the same way everywhere
+type ratingCounters interface {
+	// readRatingCounters returns the counters stored on an object followed by the ones calculated
+	// from its ratings, the stored ones are read first so a rating that lands in between has
+	// already changed them by the time they are saved
+	readRatingCounters(objectID types.ObjectID) (stored, actual RatingAggregate, err error)
+
+	// saveRatingCounters replaces an object's counters with actual only if they are still stored,
+	// otherwise it returns ErrNotFound
+	saveRatingCounters(objectID types.ObjectID, stored, actual RatingAggregate) error
+}
+
+// fixRatingDrift fixes the counters of each drifted object and returns the ones that were changed.
+// Counters are only saved if nothing changed them since they were read so the increment of a
+// rating added or removed meanwhile is never overwritten, the object is recalculated again instead.
+func fixRatingDrift(store ratingCounters, drift []RatingDrift) (fixed []RatingDrift, err error) {
+	for _, d := range drift {
+		var changed bool
+		changed, err = fixRatingCounters(store, &d)
+		if err != nil {
+			return
+		}
+		if changed {
+			fixed = append(fixed, d)
+		}
+	}
+	return
+}
+
+func fixRatingCounters(store ratingCounters, d *RatingDrift) (changed bool, err error) {
+	for attempt := 0; attempt < ratingFixAttempts; attempt++ {
+		var stored, actual RatingAggregate
+		stored, actual, err = store.readRatingCounters(d.ObjectID)
+		if errors.Cause(err) == ErrNotFound {
+			// deleted since the drift was found
+			return false, nil
+		} else if err != nil {
+			return
+		}
+		if stored.equal(actual) {
+			return false, nil
+		}
+
+		err = store.saveRatingCounters(d.ObjectID, stored, actual)
+		if err == nil {
+			d.Stored, d.Actual = stored, actual
+			return true, nil
+		} else if errors.Cause(err) != ErrNotFound {
+			return false, errors.Wrapf(err, "failed to fix rating counters for %s", d.ObjectID)
+		}
+	}
+	return false, errors.Errorf("rating counters for %s kept changing while being fixed", d.ObjectID)
+}
+
+// ratingPrior returns the mean of every rating on the site, used as the prior for bayesianScore
+func ratingPrior(total types.ObjectRateTotal, count types.ObjectRateCount) float64 {
+	if count <= 0 {
+		return defaultRatingPrior
+	}
+	return float64(total) / float64(count)
+}
+
+// bayesianScore is the confidence adjusted average rating used by the "score" sort option
+func bayesianScore(total types.ObjectRateTotal, count types.ObjectRateCount, prior float64) float64 {
+	return (ratingConfidence*prior + float64(total)) / (ratingConfidence + float64(count))
+}
@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Southclaws/samp-objects-api/types"
+)
+
+func Test_ratingDrift(t *testing.T) {
+	actual := aggregateRatings([]types.Rating{
+		{ObjectID: "a", Value: 5},
+		{ObjectID: "a", Value: 3.2},
+		{ObjectID: "b", Value: 1},
+	})
+	stored := map[types.ObjectID]RatingAggregate{
+		"a": {Count: 2, Total: 8.2, Histogram: types.ObjectRateHistogram{0, 0, 0, 1, 0, 1}},
+		"b": {Count: 2, Total: 2, Histogram: types.ObjectRateHistogram{0, 2, 0, 0, 0, 0}},
+		"c": {Count: 1, Total: 4, Histogram: types.ObjectRateHistogram{0, 0, 0, 0, 1, 0}},
+		"d": {},
+	}
+
+	drift := ratingDrift(stored, actual)
+	assert.Equal(t, []RatingDrift{
+		{
+			ObjectID: "b",
+			Stored:   stored["b"],
+			Actual:   RatingAggregate{Count: 1, Total: 1, Histogram: types.ObjectRateHistogram{0, 1, 0, 0, 0, 0}},
+		},
+		{
+			ObjectID: "c",
+			Stored:   stored["c"],
+		},
+	}, drift)
+}
+
+func Test_bayesianScore(t *testing.T) {
+	prior := ratingPrior(0, 0)
+	assert.Equal(t, defaultRatingPrior, prior)
+
+	// a single perfect rating should not outrank many near perfect ratings
+	assert.True(t, bayesianScore(5, 1, prior) < bayesianScore(240, 50, prior))
+	assert.Equal(t, prior, bayesianScore(0, 0, prior))
+}
@@ -1,17 +1,70 @@
 package storage
 
 import (
+	"io/ioutil"
 	"os"
 	"testing"
 
 	"gopkg.in/mgo.v2/bson"
 )
 
-var db *Database
+// db is the backend under test, set TEST_BACKEND to "mongo" to run the suite against a local
+// MongoDB and Minio instead of the in-memory backend. "sqlite" and "postgres" run it against the
+// SQL backend and need the matching build tag, SQLite also needs sqlite_fts5 for search so the
+// suite runs with -tags "sqlite sqlite_fts5". Postgres reads its DSN from TEST_POSTGRES.
+var db Storage
 
 func TestMain(m *testing.M) {
-	var err error
-	db, err = New(Config{
+	switch os.Getenv("TEST_BACKEND") {
+	case "", "memory":
+		db = NewMemory()
+	case "mongo":
+		db = newMongoTestDatabase()
+	case "sqlite":
+		db = newSQLTestDatabase("sqlite3", ":memory:")
+	case "postgres":
+		db = newSQLTestDatabase("postgres", os.Getenv("TEST_POSTGRES"))
+	default:
+		panic("unknown TEST_BACKEND " + os.Getenv("TEST_BACKEND"))
+	}
+
+	os.Exit(m.Run())
+}
+
+func newSQLTestDatabase(driver, source string) *SQL {
+	dir, err := ioutil.TempDir("", "samp-objects-test")
+	if err != nil {
+		panic(err)
+	}
+
+	database, err := NewSQL(Config{
+		SQLDriver: driver,
+		SQLSource: source,
+		StoreType: "directory",
+		StorePath: dir,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	if os.Getenv("NO_CLEAN") == "" {
+		tables := []string{"users", "objects", "object_tags", "object_files", "ratings", "comments", "categories", "tag_aliases", "collections"}
+		if database.dialect.search == searchFTS5 {
+			tables = append(tables, "object_text")
+		}
+		for _, table := range tables {
+			_, err = database.db.Exec("DELETE FROM " + table)
+			if err != nil {
+				panic(err)
+			}
+		}
+	}
+
+	return database
+}
+
+func newMongoTestDatabase() *Database {
+	mongo, err := New(Config{
 		MongoHost:   "localhost",
 		MongoPort:   "27017",
 		MongoUser:   "sampobjects",
@@ -30,29 +83,43 @@ func TestMain(m *testing.M) {
 
 	if os.Getenv("NO_CLEAN") == "" {
 		// clean db before tests
-		_, err = db.users.RemoveAll(bson.M{})
+		_, err = mongo.users.RemoveAll(bson.M{})
+		if err != nil {
+			panic(err)
+		}
+		_, err = mongo.objects.RemoveAll(bson.M{})
+		if err != nil {
+			panic(err)
+		}
+		_, err = mongo.ratings.RemoveAll(bson.M{})
+		if err != nil {
+			panic(err)
+		}
+		_, err = mongo.categories.RemoveAll(bson.M{})
 		if err != nil {
 			panic(err)
 		}
-		_, err = db.objects.RemoveAll(bson.M{})
+		_, err = mongo.tagAliases.RemoveAll(bson.M{})
 		if err != nil {
 			panic(err)
 		}
-		_, err = db.ratings.RemoveAll(bson.M{})
+		_, err = mongo.collections.RemoveAll(bson.M{})
 		if err != nil {
 			panic(err)
 		}
 
-		// clean S3 bucket
-		doneCh := make(chan struct{})
-		infoCh := db.store.ListObjects(db.StoreBucket, "", true, doneCh)
-		for info := range infoCh {
-			err = db.store.RemoveObject(db.StoreBucket, info.Key)
+		// clean file store
+		keys, err := mongo.blobs.List("")
+		if err != nil {
+			panic(err)
+		}
+		for _, key := range keys {
+			err = mongo.blobs.Remove(key)
 			if err != nil {
 				panic(err)
 			}
 		}
 	}
 
-	os.Exit(m.Run())
+	return mongo
 }
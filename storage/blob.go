@@ -0,0 +1,284 @@
+package storage
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/Southclaws/samp-objects-api/types"
+)
+
+// BlobStore is the file storage layer underneath the metadata backends. Blobs are addressed by
+// slash-separated keys, object files are stored once per unique content under `sha256/<hash>` and
+// referenced by name from each object's metadata, files from before deduplication may still be
+// found under `<objectID>/<filename>`. Decoded texture previews are cached under
+// `previews/<key of the texture dictionary>/`, image thumbnails under `thumbs/<key of the image>/`
+// and web versions of images under `web/<key of the image>/`. The layout is the same for every implementation so
+// data can be moved between them without renaming anything.
+type BlobStore interface {
+	// Put writes the contents of reader to key, replacing any existing blob
+	Put(key string, reader io.Reader, contentType string) error
+
+	// Get opens the blob at key for reading, the caller must close it
+	Get(key string) (Blob, error)
+
+	// Stat returns the size, modification time and content type of the blob at key without
+	// opening it, ErrBlobNotFound is returned if there is no blob at key
+	Stat(key string) (BlobInfo, error)
+
+	// Exists reports whether there is a blob at key
+	Exists(key string) (bool, error)
+
+	// Remove deletes the blob at key
+	Remove(key string) error
+
+	// List returns the keys of all blobs that begin with prefix
+	List(prefix string) ([]string, error)
+}
+
+// Blob is an open blob, it can be seeked so a range of a large file can be read without reading
+// everything before it
+type Blob interface {
+	io.ReadSeeker
+	io.Closer
+}
+
+// BlobInfo describes a blob as it is in the store, ContentType is empty for stores that don't
+// record it
+type BlobInfo struct {
+	Size        int64
+	Modified    time.Time
+	ContentType string
+}
+
+// ErrBlobNotFound is returned by Stat when there is no blob at a key
+var ErrBlobNotFound = errors.New("blob not found")
+
+var (
+	_ BlobStore = &S3Store{}
+	_ BlobStore = &DirectoryStore{}
+	_ BlobStore = &MemoryStore{}
+)
+
+// NewBlobStore creates the blob store selected by config.StoreType, "s3" is the default
+func NewBlobStore(config Config) (BlobStore, error) {
+	switch config.StoreType {
+	case "", "s3":
+		return NewS3Store(config)
+	case "directory":
+		return NewDirectoryStore(config.StorePath)
+	}
+	return nil, errors.Errorf("unknown store type '%s'", config.StoreType)
+}
+
+// objectFileKey returns the legacy per-object blob key for a file that belongs to an object
+func objectFileKey(objectID types.ObjectID, filename string) string {
+	return path.Join(string(objectID), filename)
+}
+
+// contentKey returns the blob key for a file's contents from its SHA-256
+func contentKey(sha256 string) string {
+	return path.Join("sha256", sha256)
+}
+
+// fileKey resolves a file name on an object to the blob that holds its contents, falling back to
+// the legacy per-object key for files that were stored before checksums were recorded
+func fileKey(object types.Object, fileName types.File) string {
+	info, ok := object.FileInfo(fileName)
+	if !ok || info.SHA256 == "" {
+		return objectFileKey(object.ID, string(fileName))
+	}
+	return contentKey(info.SHA256)
+}
+
+// blobLeaseExpiry is how long a lease lasts if the upload that took it is never finished or
+// discarded, such as when the server restarts part way through
+const blobLeaseExpiry = 24 * time.Hour
+
+// blobLeases are the blobs that uploads still in progress have stored or found already stored.
+// Nothing references them until the upload's object is created so without a lease a blob shared
+// with an object that is deleted in the meantime would be removed from under the upload. Leases
+// are held by the object being uploaded and released once it has been written or discarded.
+type blobLeases struct {
+	mu     sync.Mutex
+	leases map[string]map[types.ObjectID]time.Time
+}
+
+func newBlobLeases() *blobLeases {
+	return &blobLeases{leases: make(map[string]map[types.ObjectID]time.Time)}
+}
+
+// hold leases a blob to an object until it is released
+func (l *blobLeases) hold(sha256 string, objectID types.ObjectID) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.leases[sha256] == nil {
+		l.leases[sha256] = make(map[types.ObjectID]time.Time)
+	}
+	l.leases[sha256][objectID] = time.Now()
+}
+
+// release drops every lease an object holds
+func (l *blobLeases) release(objectID types.ObjectID) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for sha256, holders := range l.leases {
+		delete(holders, objectID)
+		if len(holders) == 0 {
+			delete(l.leases, sha256)
+		}
+	}
+}
+
+// removeUnleased calls remove for a blob unless an upload holds a lease on it, remove is called
+// with the leases locked so no upload can take one on the blob until it is gone
+func (l *blobLeases) removeUnleased(sha256 string, remove func() error) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for objectID, held := range l.leases[sha256] {
+		if time.Since(held) < blobLeaseExpiry {
+			return nil
+		}
+		delete(l.leases[sha256], objectID)
+	}
+	delete(l.leases, sha256)
+	return remove()
+}
+
+// putObjectFile writes a file to the blob store under its content hash, the upload is spooled to a
+// temporary file while the checksums are calculated and is only sent to the store if no blob with
+// the same contents exists yet. The blob is leased to the object until it is created or discarded.
+func putObjectFile(blobs BlobStore, leases *blobLeases, objectID types.ObjectID, filename, contentType string, reader io.Reader) (info types.FileInfo, err error) {
+	if err = objectID.Validate(); err != nil {
+		return
+	}
+
+	tmp, err := ioutil.TempFile("", "samp-objects-upload-")
+	if err != nil {
+		return info, errors.Wrap(err, "failed to create temporary file")
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	checksum := newChecksum()
+	_, err = io.Copy(io.MultiWriter(tmp, checksum), reader)
+	if err != nil {
+		return info, errors.Wrap(err, "failed to read upload")
+	}
+	info = checksum.info(types.File(filename))
+	info.ContentType = contentType
+
+	// the lease is taken before checking for the blob so it can't be removed after it was found
+	leases.hold(info.SHA256, objectID)
+
+	key := contentKey(info.SHA256)
+	exists, err := blobs.Exists(key)
+	if err != nil {
+		return info, errors.Wrap(err, "failed to check for existing blob")
+	}
+	if exists {
+		return
+	}
+
+	_, err = tmp.Seek(0, io.SeekStart)
+	if err != nil {
+		return
+	}
+	err = blobs.Put(key, tmp, contentType)
+	return
+}
+
+// getObjectFile copies a file from a version of object from the blob store to writer, version 0 is
+// the latest
+func getObjectFile(blobs BlobStore, object types.Object, version int, fileName types.File, writer io.Writer) (err error) {
+	object, ok := object.AtVersion(version)
+	if !ok {
+		return errors.Errorf("object has no version %d", version)
+	}
+
+	blob, err := blobs.Get(fileKey(object, fileName))
+	if err != nil {
+		err = errors.Wrap(err, "failed to get file from object store")
+		return
+	}
+	defer blob.Close()
+
+	_, err = io.Copy(writer, blob)
+	return
+}
+
+// openObjectFile opens a file from a version of object in the blob store along with its stat info,
+// version 0 is the latest. The caller must close the blob.
+func openObjectFile(blobs BlobStore, object types.Object, version int, fileName types.File) (blob Blob, info BlobInfo, err error) {
+	object, ok := object.AtVersion(version)
+	if !ok {
+		return nil, info, errors.Errorf("object has no version %d", version)
+	}
+
+	key := fileKey(object, fileName)
+	info, err = blobs.Stat(key)
+	if err != nil {
+		return nil, info, errors.Wrap(err, "failed to stat file in object store")
+	}
+	blob, err = blobs.Get(key)
+	if err != nil {
+		return nil, info, errors.Wrap(err, "failed to get file from object store")
+	}
+	return
+}
+
+// removeObjectFiles deletes the blobs of every version of a deleted object that are no longer
+// referenced by any other object or leased by an upload in progress, along with anything left in
+// the object's legacy folder and any texture previews, thumbnails or web images made from them
+func removeObjectFiles(blobs BlobStore, leases *blobLeases, object types.Object, referenced func(sha256 string) (bool, error)) (err error) {
+	removed := make(map[string]bool)
+	for _, info := range historyFiles(object) {
+		if info.SHA256 == "" || removed[info.SHA256] {
+			continue
+		}
+		removed[info.SHA256] = true
+
+		info := info
+		err = leases.removeUnleased(info.SHA256, func() error {
+			// the references are checked while the blob can't be leased, an upload that
+			// finished before then has already written its object
+			inUse, err := referenced(info.SHA256)
+			if err != nil {
+				return errors.Wrap(err, "failed to check blob references")
+			}
+			if inUse {
+				return nil
+			}
+
+			err = blobs.Remove(contentKey(info.SHA256))
+			if err != nil {
+				return errors.Wrapf(err, "failed to remove %s", info.Name)
+			}
+			return removeDerived(blobs, contentKey(info.SHA256))
+		})
+		if err != nil {
+			return
+		}
+	}
+
+	keys, err := blobs.List(string(object.ID) + "/")
+	if err != nil {
+		return errors.Wrap(err, "failed to list object files")
+	}
+
+	for _, key := range keys {
+		err = blobs.Remove(key)
+		if err != nil {
+			return errors.Wrapf(err, "failed to remove %s", key)
+		}
+	}
+	return removeDerived(blobs, string(object.ID))
+}
@@ -0,0 +1,177 @@
+package storage
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// DirectoryStore is a BlobStore that keeps blobs as plain files under a root directory, each key
+// maps directly to a path relative to the root so `<objectID>/<filename>` becomes a folder per
+// object, the same layout as the S3 bucket.
+type DirectoryStore struct {
+	Root string
+}
+
+// tempPrefix is used for partially written files, these are never listed
+const tempPrefix = ".upload-"
+
+// NewDirectoryStore creates the root directory if necessary and returns a store that uses it
+func NewDirectoryStore(root string) (*DirectoryStore, error) {
+	if root == "" {
+		return nil, errors.New("store path is empty")
+	}
+
+	err := os.MkdirAll(root, 0755)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create store directory")
+	}
+
+	return &DirectoryStore{Root: root}, nil
+}
+
+// Put writes the contents of reader to a temporary file and moves it into place once complete so
+// readers never see partially written files.
+func (d *DirectoryStore) Put(key string, reader io.Reader, contentType string) (err error) {
+	target, err := d.path(key)
+	if err != nil {
+		return
+	}
+
+	err = os.MkdirAll(filepath.Dir(target), 0755)
+	if err != nil {
+		return errors.Wrap(err, "failed to create blob directory")
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(target), tempPrefix)
+	if err != nil {
+		return errors.Wrap(err, "failed to create temporary file")
+	}
+	defer func() {
+		if err != nil {
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	_, err = io.Copy(tmp, reader)
+	if err != nil {
+		tmp.Close()
+		return errors.Wrap(err, "failed to write blob")
+	}
+	err = tmp.Close()
+	if err != nil {
+		return errors.Wrap(err, "failed to close blob")
+	}
+
+	err = os.Rename(tmp.Name(), target)
+	if err != nil {
+		return errors.Wrap(err, "failed to move blob into place")
+	}
+	return
+}
+
+// Get opens the file for key
+func (d *DirectoryStore) Get(key string) (Blob, error) {
+	target, err := d.path(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(target)
+}
+
+// Stat returns the size and modification time of the file for key, directories don't record
+// content types so it's always empty
+func (d *DirectoryStore) Stat(key string) (info BlobInfo, err error) {
+	target, err := d.path(key)
+	if err != nil {
+		return
+	}
+
+	fi, err := os.Stat(target)
+	if os.IsNotExist(err) {
+		return info, ErrBlobNotFound
+	}
+	if err != nil {
+		return
+	}
+	return BlobInfo{
+		Size:     fi.Size(),
+		Modified: fi.ModTime(),
+	}, nil
+}
+
+// Exists reports whether there is a file for key
+func (d *DirectoryStore) Exists(key string) (bool, error) {
+	target, err := d.path(key)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = os.Stat(target)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Remove deletes the file for key and its parent directory if that leaves it empty
+func (d *DirectoryStore) Remove(key string) (err error) {
+	target, err := d.path(key)
+	if err != nil {
+		return
+	}
+
+	err = os.Remove(target)
+	if err != nil {
+		return
+	}
+
+	// only succeeds when the directory is empty, which is exactly what we want
+	dir := filepath.Dir(target)
+	if dir != filepath.Clean(d.Root) {
+		os.Remove(dir)
+	}
+	return
+}
+
+// List walks the directory that contains prefix and returns every key that begins with it
+func (d *DirectoryStore) List(prefix string) (keys []string, err error) {
+	base := filepath.Join(d.Root, filepath.FromSlash(path.Dir(prefix+"_")))
+
+	err = filepath.Walk(base, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() || strings.HasPrefix(info.Name(), tempPrefix) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(d.Root, p)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	return
+}
+
+// path converts a key to a file path under the root and refuses anything that would escape it
+func (d *DirectoryStore) path(key string) (string, error) {
+	clean := path.Clean("/" + key)
+	if clean == "/" || clean != "/"+key {
+		return "", errors.Errorf("invalid blob key '%s'", key)
+	}
+	return filepath.Join(d.Root, filepath.FromSlash(clean)), nil
+}
@@ -0,0 +1,118 @@
+package storage
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// MemoryStore is a BlobStore that holds every blob in memory, it's used by the Memory backend
+type MemoryStore struct {
+	mu    sync.RWMutex
+	blobs map[string]memoryBlob
+}
+
+// memoryBlob is the contents of a blob along with the details Stat returns
+type memoryBlob struct {
+	contents    []byte
+	contentType string
+	modified    time.Time
+}
+
+// NewMemoryStore returns an empty in-memory blob store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		blobs: make(map[string]memoryBlob),
+	}
+}
+
+// Put reads the whole of reader into memory
+func (s *MemoryStore) Put(key string, reader io.Reader, contentType string) (err error) {
+	contents, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return errors.Wrap(err, "failed to read blob contents")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.blobs[key] = memoryBlob{contents, contentType, time.Now()}
+	return
+}
+
+// Get returns a reader over the blob at key
+func (s *MemoryStore) Get(key string) (Blob, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	blob, ok := s.blobs[key]
+	if !ok {
+		return nil, errors.Errorf("blob %s does not exist", key)
+	}
+	return nopCloser{bytes.NewReader(blob.contents)}, nil
+}
+
+// Stat returns the size of the blob at key, the content type it was put with and when it was put
+func (s *MemoryStore) Stat(key string) (info BlobInfo, err error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	blob, ok := s.blobs[key]
+	if !ok {
+		return info, ErrBlobNotFound
+	}
+	return BlobInfo{
+		Size:        int64(len(blob.contents)),
+		Modified:    blob.modified,
+		ContentType: blob.contentType,
+	}, nil
+}
+
+// nopCloser turns a bytes.Reader into a Blob
+type nopCloser struct {
+	*bytes.Reader
+}
+
+// Close does nothing
+func (nopCloser) Close() error { return nil }
+
+// Exists reports whether there is a blob at key
+func (s *MemoryStore) Exists(key string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, ok := s.blobs[key]
+	return ok, nil
+}
+
+// Remove deletes the blob at key
+func (s *MemoryStore) Remove(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.blobs[key]; !ok {
+		return errors.Errorf("blob %s does not exist", key)
+	}
+	delete(s.blobs, key)
+	return nil
+}
+
+// List returns the sorted keys of every blob that begins with prefix
+func (s *MemoryStore) List(prefix string) (keys []string, err error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for key := range s.blobs {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return
+}
@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/minio/minio-go"
+	"github.com/pkg/errors"
+)
+
+// S3Store is a BlobStore backed by an S3 compatible bucket, it uses the Minio client
+type S3Store struct {
+	client *minio.Client
+
+	Bucket   string
+	Location string
+}
+
+// NewS3Store connects to the S3 server described by config and ensures the bucket exists
+func NewS3Store(config Config) (*S3Store, error) {
+	var (
+		err   error
+		store S3Store
+	)
+
+	store.client, err = minio.New(
+		fmt.Sprintf("%s:%s", config.StoreHost, config.StorePort),
+		config.StoreAccess,
+		config.StoreSecret,
+		config.StoreSecure)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to connect to object store")
+	}
+
+	exists, err := store.client.BucketExists(config.StoreBucket)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to check if bucket exists")
+	}
+
+	if !exists {
+		err = store.client.MakeBucket(config.StoreBucket, config.StoreLocation)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create bucket")
+		}
+	}
+
+	exists, err = store.client.BucketExists(config.StoreBucket)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to check it bucket exists after creation")
+	}
+	if !exists {
+		return nil, errors.New("bucket was not created")
+	}
+
+	store.Bucket = config.StoreBucket
+	store.Location = config.StoreLocation
+
+	return &store, nil
+}
+
+// Put uploads the contents of reader to the bucket
+func (s *S3Store) Put(key string, reader io.Reader, contentType string) (err error) {
+	_, err = s.client.PutObject(s.Bucket, key, reader, contentType)
+	return
+}
+
+// Get opens an object in the bucket for reading
+func (s *S3Store) Get(key string) (Blob, error) {
+	return s.client.GetObject(s.Bucket, key)
+}
+
+// Stat returns the size, modification time and content type the bucket has for an object
+func (s *S3Store) Stat(key string) (info BlobInfo, err error) {
+	stat, err := s.client.StatObject(s.Bucket, key)
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return info, ErrBlobNotFound
+		}
+		return
+	}
+	return BlobInfo{
+		Size:        stat.Size,
+		Modified:    stat.LastModified,
+		ContentType: stat.ContentType,
+	}, nil
+}
+
+// Exists checks for an object in the bucket
+func (s *S3Store) Exists(key string) (bool, error) {
+	_, err := s.client.StatObject(s.Bucket, key)
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// Remove deletes an object from the bucket
+func (s *S3Store) Remove(key string) error {
+	return s.client.RemoveObject(s.Bucket, key)
+}
+
+// List returns the keys of every object in the bucket that begins with prefix
+func (s *S3Store) List(prefix string) (keys []string, err error) {
+	doneCh := make(chan struct{})
+	defer close(doneCh)
+
+	for info := range s.client.ListObjects(s.Bucket, prefix, true, doneCh) {
+		if info.Err != nil {
+			return nil, info.Err
+		}
+		keys = append(keys, info.Key)
+	}
+	return
+}
@@ -0,0 +1,173 @@
+package storage
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Southclaws/samp-objects-api/types"
+)
+
+func TestBlobStores(t *testing.T) {
+	dir, err := ioutil.TempDir("", "samp-objects-blobs")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+
+	directory, err := NewDirectoryStore(dir)
+	if err != nil {
+		panic(err)
+	}
+
+	stores := []struct {
+		name  string
+		store BlobStore
+	}{
+		{"directory", directory},
+		{"memory", NewMemoryStore()},
+	}
+	for _, tt := range stores {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.NoError(t, tt.store.Put("00000000-0000-0000-0000-100000000000/model.dff", strings.NewReader("model"), "application/octet-stream"))
+			assert.NoError(t, tt.store.Put("00000000-0000-0000-0000-100000000000/texture.txd", strings.NewReader("texture"), "application/octet-stream"))
+			assert.NoError(t, tt.store.Put("00000000-0000-0000-0000-200000000000/model.dff", strings.NewReader("other"), "application/octet-stream"))
+
+			blob, err := tt.store.Get("00000000-0000-0000-0000-100000000000/model.dff")
+			assert.NoError(t, err)
+			contents, err := ioutil.ReadAll(blob)
+			assert.NoError(t, err)
+			assert.NoError(t, blob.Close())
+			assert.Equal(t, "model", string(contents))
+
+			info, err := tt.store.Stat("00000000-0000-0000-0000-100000000000/texture.txd")
+			assert.NoError(t, err)
+			assert.Equal(t, int64(len("texture")), info.Size)
+			assert.False(t, info.Modified.IsZero())
+			_, err = tt.store.Stat("00000000-0000-0000-0000-100000000000/missing.dff")
+			assert.Equal(t, ErrBlobNotFound, err)
+
+			blob, err = tt.store.Get("00000000-0000-0000-0000-100000000000/texture.txd")
+			assert.NoError(t, err)
+			_, err = blob.Seek(3, io.SeekStart)
+			assert.NoError(t, err)
+			contents, err = ioutil.ReadAll(blob)
+			assert.NoError(t, err)
+			assert.NoError(t, blob.Close())
+			assert.Equal(t, "ture", string(contents))
+
+			keys, err := tt.store.List("00000000-0000-0000-0000-100000000000/")
+			assert.NoError(t, err)
+			assert.Equal(t, []string{
+				"00000000-0000-0000-0000-100000000000/model.dff",
+				"00000000-0000-0000-0000-100000000000/texture.txd",
+			}, keys)
+
+			var buf bytes.Buffer
+			assert.NoError(t, removeObjectFiles(tt.store, newBlobLeases(), types.Object{ID: "00000000-0000-0000-0000-100000000000"}, nil))
+			assert.Error(t, getObjectFile(tt.store, types.Object{ID: "00000000-0000-0000-0000-100000000000"}, 0, "model.dff", &buf))
+			assert.NoError(t, getObjectFile(tt.store, types.Object{ID: "00000000-0000-0000-0000-200000000000"}, 0, "model.dff", &buf))
+			assert.Equal(t, "other", buf.String())
+
+			keys, err = tt.store.List("")
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"00000000-0000-0000-0000-200000000000/model.dff"}, keys)
+		})
+	}
+
+	// the directory layout must match the bucket layout so data can be moved between stores
+	_, err = os.Stat(filepath.Join(dir, "00000000-0000-0000-0000-200000000000", "model.dff"))
+	assert.NoError(t, err)
+
+	assert.Error(t, directory.Put("../escape", strings.NewReader("x"), ""))
+	assert.Error(t, directory.Put("a/../../escape", strings.NewReader("x"), ""))
+}
+
+func TestPutObjectFileChecksums(t *testing.T) {
+	store := NewMemoryStore()
+
+	info, err := putObjectFile(store, newBlobLeases(), "00000000-0000-0000-0000-100000000000", "model.dff", "application/octet-stream", strings.NewReader("hello world"))
+	assert.NoError(t, err)
+	assert.Equal(t, types.FileInfo{
+		Name:        "model.dff",
+		Size:        11,
+		CRC32:       "0d4a1185",
+		SHA256:      "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9",
+		ContentType: "application/octet-stream",
+	}, info)
+
+	// legacy files are checksummed in place then moved to their content key
+	assert.NoError(t, store.Put("00000000-0000-0000-0000-200000000000/model.dff", strings.NewReader("hello world"), ""))
+	stored, err := fileInfo(store, "00000000-0000-0000-0000-200000000000", "model.dff")
+	assert.NoError(t, err)
+	legacy := info
+	legacy.ContentType = "" // not known for files stored before it was recorded
+	assert.Equal(t, legacy, stored)
+
+	assert.NoError(t, moveLegacyFiles(store, types.Object{ID: "00000000-0000-0000-0000-200000000000", Files: []types.FileInfo{stored}}))
+	keys, err := store.List("")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{contentKey(info.SHA256)}, keys)
+
+	// a legacy file that isn't stored yet is given the content type its contents have
+	assert.NoError(t, store.Put("00000000-0000-0000-0000-300000000000/image.png", strings.NewReader("\x89PNG\r\n\x1a\nimage"), ""))
+	image, err := fileInfo(store, "00000000-0000-0000-0000-300000000000", "image.png")
+	assert.NoError(t, err)
+	assert.NoError(t, moveLegacyFiles(store, types.Object{ID: "00000000-0000-0000-0000-300000000000", Files: []types.FileInfo{image}}))
+	moved, err := store.Stat(contentKey(image.SHA256))
+	assert.NoError(t, err)
+	assert.Equal(t, "image/png", moved.ContentType)
+}
+
+func TestContentDeduplication(t *testing.T) {
+	store := NewMemoryStore()
+	leases := newBlobLeases()
+
+	first := types.Object{ID: "00000000-0000-0000-0000-100000000000"}
+	second := types.Object{ID: "00000000-0000-0000-0000-200000000000"}
+	for _, object := range []*types.Object{&first, &second} {
+		info, err := putObjectFile(store, leases, object.ID, "shared.txd", "application/octet-stream", strings.NewReader("texture"))
+		assert.NoError(t, err)
+		object.Files = append(object.Files, info)
+	}
+	info, err := putObjectFile(store, leases, first.ID, "model.dff", "application/octet-stream", strings.NewReader("model"))
+	assert.NoError(t, err)
+	first.Files = append(first.Files, info)
+
+	keys, err := store.List("")
+	assert.NoError(t, err)
+	assert.Len(t, keys, 2)
+
+	assert.Equal(t, DedupReport{
+		Files:        3,
+		Blobs:        2,
+		LogicalBytes: 19,
+		StoredBytes:  12,
+		SavedBytes:   7,
+	}, dedupReport(append(first.Files, second.Files...)))
+
+	// nothing is removed while the uploads that stored the files hold them
+	assert.NoError(t, removeObjectFiles(store, leases, first, func(sha256 string) (bool, error) { return false, nil }))
+	keys, err = store.List("")
+	assert.NoError(t, err)
+	assert.Len(t, keys, 2)
+
+	// the shared texture is still referenced by the second object so only the model is removed
+	leases.release(first.ID)
+	leases.release(second.ID)
+	shared := second.Files[0].SHA256
+	assert.NoError(t, removeObjectFiles(store, leases, first, func(sha256 string) (bool, error) {
+		return sha256 == shared, nil
+	}))
+
+	var buf bytes.Buffer
+	assert.NoError(t, getObjectFile(store, second, 0, "shared.txd", &buf))
+	assert.Equal(t, "texture", buf.String())
+	assert.Error(t, getObjectFile(store, first, 0, "model.dff", &buf))
+}
@@ -0,0 +1,237 @@
+package storage
+
+import (
+	"sort"
+
+	"github.com/pkg/errors"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+
+	"github.com/Southclaws/samp-objects-api/types"
+)
+
+var (
+	// ErrCategoryAlreadyExists indicates that a category with the same slug already exists
+	ErrCategoryAlreadyExists = errors.New("category already exists")
+
+	// ErrCategoryInUse indicates that a category can't be deleted because objects or other
+	// categories still refer to it
+	ErrCategoryInUse = errors.New("category is in use")
+
+	// ErrUnknownCategory indicates that an object or category refers to a category that does not
+	// exist
+	ErrUnknownCategory = errors.New("unknown category")
+
+	// ErrCategoryCycle indicates that a category would be placed below itself or one of its children
+	ErrCategoryCycle = errors.New("category can not be placed below itself")
+)
+
+// CategoryNode is a category and the categories below it
+type CategoryNode struct {
+	types.Category
+	Children []CategoryNode `json:"children"`
+}
+
+// CategoryTree arranges a flat list of categories into a tree sorted by name, categories whose
+// parent is missing are placed at the top level
+func CategoryTree(categories []types.Category) []CategoryNode {
+	known := make(map[types.ObjectCategory]bool)
+	for _, category := range categories {
+		known[category.Slug] = true
+	}
+
+	children := make(map[types.ObjectCategory][]types.Category)
+	for _, category := range categories {
+		parent := category.Parent
+		if !known[parent] {
+			parent = ""
+		}
+		children[parent] = append(children[parent], category)
+	}
+
+	var build func(parent types.ObjectCategory) []CategoryNode
+	build = func(parent types.ObjectCategory) (nodes []CategoryNode) {
+		nodes = []CategoryNode{}
+		for _, category := range children[parent] {
+			nodes = append(nodes, CategoryNode{category, build(category.Slug)})
+		}
+		sort.Slice(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+		return
+	}
+	return build("")
+}
+
+// checkCategoryParent ensures a category's parent exists and that it isn't placed below one of its
+// own descendants
+func checkCategoryParent(categories []types.Category, category types.Category) error {
+	if category.Parent == "" {
+		return nil
+	}
+
+	parents := make(map[types.ObjectCategory]types.ObjectCategory)
+	for _, existing := range categories {
+		parents[existing.Slug] = existing.Parent
+	}
+
+	for slug, depth := category.Parent, 0; slug != ""; slug, depth = parents[slug], depth+1 {
+		if slug == category.Slug || depth > len(categories) {
+			return ErrCategoryCycle
+		}
+		if _, ok := parents[slug]; !ok {
+			return errors.Wrapf(ErrUnknownCategory, "parent '%s'", slug)
+		}
+	}
+	return nil
+}
+
+// CategoryRemap is the result of moving the objects in one category to another
+type CategoryRemap struct {
+	From    types.ObjectCategory `json:"from"`
+	To      types.ObjectCategory `json:"to"`
+	Objects int                  `json:"objects"`
+}
+
+// RemapCategories moves every object, including trashed ones, from each category in mapping to the
+// managed category it maps to. The targets are checked before anything is changed and with dryRun
+// only the number of objects that would be moved is reported.
+func RemapCategories(store Storage, mapping map[types.ObjectCategory]types.ObjectCategory, dryRun bool) (result []CategoryRemap, err error) {
+	var from []types.ObjectCategory
+	for source, target := range mapping {
+		_, err = store.GetCategory(target)
+		if err != nil {
+			if err == ErrNotFound {
+				err = errors.Wrapf(ErrUnknownCategory, "'%s'", target)
+			}
+			return
+		}
+		from = append(from, source)
+	}
+	sort.Slice(from, func(i, j int) bool { return from[i] < from[j] })
+
+	for _, source := range from {
+		var n int
+		n, err = store.RemapCategory(source, mapping[source], dryRun)
+		if err != nil {
+			return result, errors.Wrapf(err, "failed to remap '%s'", source)
+		}
+		result = append(result, CategoryRemap{source, mapping[source], n})
+	}
+	return
+}
+
+// CreateCategory adds a category to the taxonomy
+func (db Database) CreateCategory(category types.Category) (err error) {
+	if err = category.Validate(); err != nil {
+		return
+	}
+
+	categories, err := db.GetCategories()
+	if err != nil {
+		return
+	}
+	if err = checkCategoryParent(categories, category); err != nil {
+		return
+	}
+
+	err = db.categories.Insert(category)
+	if mgo.IsDup(err) {
+		return ErrCategoryAlreadyExists
+	}
+	return
+}
+
+// UpdateCategory changes the name or parent of a category
+func (db Database) UpdateCategory(category types.Category) (err error) {
+	if err = category.Validate(); err != nil {
+		return
+	}
+
+	categories, err := db.GetCategories()
+	if err != nil {
+		return
+	}
+	if err = checkCategoryParent(categories, category); err != nil {
+		return
+	}
+
+	return db.categories.Update(bson.M{"slug": category.Slug}, category)
+}
+
+// DeleteCategory removes a category that no object or other category refers to
+func (db Database) DeleteCategory(slug types.ObjectCategory) (err error) {
+	children, err := db.categories.Find(bson.M{"parent": slug}).Count()
+	if err != nil {
+		return
+	}
+	objects, err := db.objects.Find(bson.M{"category": slug}).Count()
+	if err != nil {
+		return
+	}
+	if children > 0 || objects > 0 {
+		return ErrCategoryInUse
+	}
+
+	return db.categories.Remove(bson.M{"slug": slug})
+}
+
+// GetCategory returns a category by its slug
+func (db Database) GetCategory(slug types.ObjectCategory) (category types.Category, err error) {
+	err = db.categories.Find(bson.M{"slug": slug}).One(&category)
+	return
+}
+
+// GetCategories returns every category sorted by slug
+func (db Database) GetCategories() (categories []types.Category, err error) {
+	err = db.categories.Find(nil).Sort("slug").All(&categories)
+	return
+}
+
+// RemapCategory moves every object in one category to another and returns how many were moved, or
+// with dryRun how many would be
+func (db Database) RemapCategory(from, to types.ObjectCategory, dryRun bool) (n int, err error) {
+	if dryRun {
+		return db.objects.Find(bson.M{"category": from}).Count()
+	}
+
+	info, err := db.objects.UpdateAll(bson.M{"category": from}, bson.M{"$set": bson.M{"category": to}})
+	if err != nil {
+		return
+	}
+	return info.Updated, nil
+}
+
+// UnknownCategories counts the listed objects whose category is not in the taxonomy, these are the
+// categories that still need to be remapped
+func UnknownCategories(store Storage) (unknown []Facet, err error) {
+	categories, err := store.GetCategories()
+	if err != nil {
+		return
+	}
+	known := make(map[types.ObjectCategory]bool)
+	for _, category := range categories {
+		known[category.Slug] = true
+	}
+
+	page, err := store.GetObjects(ObjectQuery{}, PageQuery{})
+	if err != nil {
+		return
+	}
+	counts := make(map[string]int)
+	for _, object := range page.Objects {
+		if !known[object.Category] {
+			counts[string(object.Category)]++
+		}
+	}
+
+	unknown = []Facet{}
+	for category, count := range counts {
+		unknown = append(unknown, Facet{category, count})
+	}
+	sort.Slice(unknown, func(i, j int) bool {
+		if unknown[i].Count != unknown[j].Count {
+			return unknown[i].Count > unknown[j].Count
+		}
+		return unknown[i].Value < unknown[j].Value
+	})
+	return
+}
@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Southclaws/samp-objects-api/types"
+)
+
+func TestDatabase_Categories(t *testing.T) {
+	assert.NoError(t, db.CreateCategory(types.Category{Slug: "buildings", Name: "Buildings"}))
+	assert.NoError(t, db.CreateCategory(types.Category{Slug: "interiors", Name: "Interiors", Parent: "buildings"}))
+	assert.NoError(t, db.CreateCategory(types.Category{Slug: "vehicles", Name: "Vehicles"}))
+
+	assert.Equal(t, ErrCategoryAlreadyExists, db.CreateCategory(types.Category{Slug: "vehicles", Name: "Cars"}))
+	assert.Equal(t, ErrUnknownCategory, errors.Cause(db.CreateCategory(types.Category{Slug: "shops", Name: "Shops", Parent: "missing"})))
+	assert.Equal(t, ErrCategoryCycle, db.UpdateCategory(types.Category{Slug: "buildings", Name: "Buildings", Parent: "interiors"}))
+	assert.Equal(t, ErrNotFound, db.UpdateCategory(types.Category{Slug: "missing", Name: "Missing"}))
+
+	assert.NoError(t, db.UpdateCategory(types.Category{Slug: "vehicles", Name: "Vehicles", Parent: "buildings"}))
+	category, err := db.GetCategory("vehicles")
+	assert.NoError(t, err)
+	assert.Equal(t, types.Category{Slug: "vehicles", Name: "Vehicles", Parent: "buildings"}, category)
+	assert.NoError(t, db.UpdateCategory(types.Category{Slug: "vehicles", Name: "Vehicles"}))
+
+	categories, err := db.GetCategories()
+	assert.NoError(t, err)
+	assert.Equal(t, []CategoryNode{
+		{types.Category{Slug: "buildings", Name: "Buildings"}, []CategoryNode{
+			{types.Category{Slug: "interiors", Name: "Interiors", Parent: "buildings"}, []CategoryNode{}},
+		}},
+		{types.Category{Slug: "vehicles", Name: "Vehicles"}, []CategoryNode{}},
+	}, CategoryTree(categories))
+
+	objects := []types.Object{
+		{ID: "00000000-0000-0000-0000-900000000000", OwnerID: "00000003-0000-0000-0000-000000000000", OwnerName: "owner3", Name: "cara", Category: "Cars"},
+		{ID: "00000000-0000-0000-0000-910000000000", OwnerID: "00000003-0000-0000-0000-000000000000", OwnerName: "owner3", Name: "carb", Category: "Cars"},
+		{ID: "00000000-0000-0000-0000-920000000000", OwnerID: "00000003-0000-0000-0000-000000000000", OwnerName: "owner3", Name: "housea", Category: "interiors"},
+	}
+	for _, object := range objects {
+		object.Description = "categorised"
+		object.Images = []types.File{"image.jpg"}
+		object.Models = []types.File{"model.dff"}
+		object.Textures = []types.File{"texture.txd"}
+		assert.NoError(t, db.CreateObject(object))
+	}
+
+	assert.Equal(t, ErrCategoryInUse, db.DeleteCategory("buildings"))
+	assert.Equal(t, ErrCategoryInUse, db.DeleteCategory("interiors"))
+
+	unknown, err := UnknownCategories(db)
+	assert.NoError(t, err)
+	assert.Contains(t, unknown, Facet{"Cars", 2})
+
+	_, err = RemapCategories(db, map[types.ObjectCategory]types.ObjectCategory{"Cars": "trains"}, false)
+	assert.Equal(t, ErrUnknownCategory, errors.Cause(err))
+
+	result, err := RemapCategories(db, map[types.ObjectCategory]types.ObjectCategory{"Cars": "vehicles"}, true)
+	assert.NoError(t, err)
+	assert.Equal(t, []CategoryRemap{{"Cars", "vehicles", 2}}, result)
+	object, err := db.GetObject(objects[0].ID)
+	assert.NoError(t, err)
+	assert.Equal(t, types.ObjectCategory("Cars"), object.Category)
+
+	result, err = RemapCategories(db, map[types.ObjectCategory]types.ObjectCategory{"Cars": "vehicles"}, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []CategoryRemap{{"Cars", "vehicles", 2}}, result)
+	object, err = db.GetObject(objects[0].ID)
+	assert.NoError(t, err)
+	assert.Equal(t, types.ObjectCategory("vehicles"), object.Category)
+
+	unknown, err = UnknownCategories(db)
+	assert.NoError(t, err)
+	assert.NotContains(t, unknown, Facet{"Cars", 2})
+
+	for _, object := range objects {
+		assert.NoError(t, db.DeleteObject(object.ID))
+	}
+	assert.NoError(t, db.DeleteCategory("interiors"))
+	assert.NoError(t, db.DeleteCategory("buildings"))
+	assert.NoError(t, db.DeleteCategory("vehicles"))
+	assert.Equal(t, ErrNotFound, db.DeleteCategory("vehicles"))
+}
+
+func TestSlugify(t *testing.T) {
+	assert.Equal(t, types.ObjectCategory("buildings-interiors"), types.Slugify("Buildings > Interiors"))
+	assert.Equal(t, types.ObjectCategory("street-props-2"), types.Slugify("  Street Props 2!"))
+}
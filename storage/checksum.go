@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+
+	"github.com/pkg/errors"
+
+	"github.com/Southclaws/samp-objects-api/types"
+)
+
+// checksum is an io.Writer that calculates the size, CRC32 and SHA-256 of everything written to it
+type checksum struct {
+	size   int64
+	crc32  hash.Hash32
+	sha256 hash.Hash
+}
+
+func newChecksum() *checksum {
+	return &checksum{
+		crc32:  crc32.NewIEEE(),
+		sha256: sha256.New(),
+	}
+}
+
+func (c *checksum) Write(p []byte) (n int, err error) {
+	c.size += int64(len(p))
+	c.crc32.Write(p)
+	c.sha256.Write(p)
+	return len(p), nil
+}
+
+func (c *checksum) info(name types.File) types.FileInfo {
+	return types.FileInfo{
+		Name:   name,
+		Size:   c.size,
+		CRC32:  fmt.Sprintf("%08x", c.crc32.Sum32()),
+		SHA256: hex.EncodeToString(c.sha256.Sum(nil)),
+	}
+}
+
+// fileInfo reads a blob to calculate its size and checksums
+func fileInfo(blobs BlobStore, objectID types.ObjectID, fileName types.File) (info types.FileInfo, err error) {
+	blob, err := blobs.Get(objectFileKey(objectID, string(fileName)))
+	if err != nil {
+		return info, errors.Wrapf(err, "failed to get %s from object store", fileName)
+	}
+	defer blob.Close()
+
+	checksum := newChecksum()
+	_, err = io.Copy(checksum, blob)
+	if err != nil {
+		return
+	}
+
+	return checksum.info(fileName), nil
+}
+
+// objectFileNames returns the names of every image, model and texture in an object
+func objectFileNames(object types.Object) (names []types.File) {
+	names = append(names, object.Images...)
+	names = append(names, object.Models...)
+	names = append(names, object.Textures...)
+	return
+}
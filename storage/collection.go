@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"sort"
+
+	"github.com/pkg/errors"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+
+	"github.com/Southclaws/samp-objects-api/types"
+)
+
+// ErrCollectionAlreadyExists indicates that a collection with the same ID already exists
+var ErrCollectionAlreadyExists = errors.New("collection already exists")
+
+// ErrCollectionChanged indicates that a collection was updated since the revision being saved was
+// read, the update has to be made again to the latest revision
+var ErrCollectionChanged = errors.New("collection was changed by another request")
+
+// sortCollections orders a user's collections by name
+func sortCollections(collections []types.Collection) {
+	sort.Slice(collections, func(i, j int) bool { return collections[i].Name < collections[j].Name })
+}
+
+// CreateCollection creates a new collection
+func (db Database) CreateCollection(collection types.Collection) (err error) {
+	if err = collection.Validate(); err != nil {
+		return
+	}
+
+	err = db.collections.Insert(collection)
+	if mgo.IsDup(err) {
+		return ErrCollectionAlreadyExists
+	}
+	return
+}
+
+// UpdateCollection replaces a collection, including its objects and model IDs, if it is still at
+// the revision collection was read at. The stored revision is incremented.
+func (db Database) UpdateCollection(collection types.Collection) (err error) {
+	if err = collection.Validate(); err != nil {
+		return
+	}
+
+	// collections stored before revisions were added have no revision field
+	revision := interface{}(collection.Revision)
+	if collection.Revision == 0 {
+		revision = bson.M{"$in": []interface{}{0, nil}}
+	}
+
+	collection.Revision++
+	err = db.collections.Update(bson.M{"id": collection.ID, "revision": revision}, collection)
+	if err == mgo.ErrNotFound {
+		var n int
+		n, err = db.collections.Find(bson.M{"id": collection.ID}).Count()
+		if err != nil {
+			return
+		}
+		if n > 0 {
+			return ErrCollectionChanged
+		}
+		return ErrNotFound
+	}
+	return
+}
+
+// DeleteCollection removes a collection, the objects in it are not affected
+func (db Database) DeleteCollection(collectionID types.CollectionID) (err error) {
+	return db.collections.Remove(bson.M{"id": collectionID})
+}
+
+// GetCollection returns a collection by its ID
+func (db Database) GetCollection(collectionID types.CollectionID) (collection types.Collection, err error) {
+	err = db.collections.Find(bson.M{"id": collectionID}).One(&collection)
+	return
+}
+
+// GetUserCollections returns every collection a user owns sorted by name
+func (db Database) GetUserCollections(userID types.UserID) (collections []types.Collection, err error) {
+	err = db.collections.Find(bson.M{"ownerid": userID}).All(&collections)
+	sortCollections(collections)
+	return
+}
@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Southclaws/samp-objects-api/types"
+)
+
+func TestDatabase_Collections(t *testing.T) {
+	owner := types.UserID("00000005-0000-0000-0000-000000000000")
+	collection := types.Collection{
+		ID:      "00000000-0000-0000-0000-c00000000000",
+		OwnerID: owner,
+		Name:    "roleplay server",
+		FirstID: -2000,
+		LastID:  -2999,
+	}
+	assert.NoError(t, db.CreateCollection(collection))
+	assert.Equal(t, ErrCollectionAlreadyExists, db.CreateCollection(collection))
+	assert.NoError(t, db.CreateCollection(types.Collection{
+		ID: "00000000-0000-0000-0000-c10000000000", OwnerID: owner, Name: "drift server", FirstID: -1000, LastID: -1999,
+	}))
+	assert.Error(t, db.CreateCollection(types.Collection{
+		ID: "00000000-0000-0000-0000-c20000000000", OwnerID: owner, Name: "bad range", FirstID: -999, LastID: -1999,
+	}))
+
+	_, err := collection.Add(types.Object{ID: "00000000-0000-0000-0000-c00000000001", Models: []types.File{"a.dff", "b.dff"}})
+	assert.NoError(t, err)
+	assert.NoError(t, db.UpdateCollection(collection))
+
+	got, err := db.GetCollection(collection.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, []types.ModelAssignment{
+		{ModelID: -2000, Object: "00000000-0000-0000-0000-c00000000001", Model: "a.dff"},
+		{ModelID: -2001, Object: "00000000-0000-0000-0000-c00000000001", Model: "b.dff"},
+	}, got.Models)
+
+	// two models with the same ID are never stored
+	got.Models[1].ModelID = -2000
+	assert.Error(t, db.UpdateCollection(got))
+
+	// an update made to a revision that has since been replaced is rejected
+	got, err = db.GetCollection(collection.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, got.Revision)
+	stale := got
+	got.Name = "renamed"
+	assert.NoError(t, db.UpdateCollection(got))
+	stale.Name = "lost"
+	assert.Equal(t, ErrCollectionChanged, db.UpdateCollection(stale))
+	got, err = db.GetCollection(collection.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "renamed", got.Name)
+	assert.Equal(t, 2, got.Revision)
+	assert.Equal(t, ErrNotFound, db.UpdateCollection(types.Collection{
+		ID: "00000000-0000-0000-0000-c30000000000", OwnerID: owner, Name: "missing", FirstID: -1000, LastID: -1999,
+	}))
+
+	collections, err := db.GetUserCollections(owner)
+	assert.NoError(t, err)
+	assert.Len(t, collections, 2)
+	assert.Equal(t, "drift server", collections[0].Name)
+	assert.Equal(t, "renamed", collections[1].Name)
+
+	assert.NoError(t, db.DeleteCollection(collection.ID))
+	_, err = db.GetCollection(collection.ID)
+	assert.Equal(t, ErrNotFound, err)
+	assert.Equal(t, ErrNotFound, db.DeleteCollection(collection.ID))
+}
@@ -9,13 +9,32 @@ import (
 	"github.com/Southclaws/samp-objects-api/types"
 )
 
-// GetComments returns a slice of types.Comment for a given object ID
-func (db *Database) GetComments(objectID types.ObjectID) (comments []types.Comment, err error) {
+// GetComments returns a page of comments for a given object ID
+func (db *Database) GetComments(objectID types.ObjectID, page PageQuery) (result CommentPage, err error) {
 	if err = objectID.Validate(); err != nil {
 		return
 	}
 
-	err = db.comments.Find(bson.M{"objectid": objectID}).All(&comments)
+	filter := bson.M{"objectid": objectID, "deleted": notTrashed}
+	result.Total, err = db.comments.Find(filter).Count()
+	if err != nil {
+		return
+	}
+
+	if page.Cursor != "" {
+		var after string
+		after, err = commentAfter(page.Cursor)
+		if err != nil {
+			return
+		}
+		filter["_id"] = bson.M{"$gt": bson.ObjectIdHex(after)}
+	}
+
+	err = db.comments.Find(filter).Sort("_id").Limit(page.fetchLimit()).All(&result.Comments)
+	if err != nil {
+		return
+	}
+	result.Comments, result.Next = nextComments(result.Comments, page)
 	return
 }
 
@@ -49,3 +68,28 @@ func (db *Database) RemoveComment(commentID bson.ObjectId) (err error) {
 	err = db.comments.Remove(bson.M{"_id": commentID})
 	return
 }
+
+// PurgeComment removes a trashed comment if it was trashed at or before the given time
+func (db *Database) PurgeComment(commentID bson.ObjectId, before time.Time) (err error) {
+	return db.comments.Remove(bson.M{"_id": commentID, "deleted": bson.M{"$lte": before}})
+}
+
+// GetComment returns a single comment by ID, including trashed comments
+func (db *Database) GetComment(commentID bson.ObjectId) (comment types.Comment, err error) {
+	err = db.comments.FindId(commentID).One(&comment)
+	return
+}
+
+// TrashComment soft deletes a comment
+func (db *Database) TrashComment(commentID bson.ObjectId) (err error) {
+	return db.comments.Update(
+		bson.M{"_id": commentID, "deleted": notTrashed},
+		bson.M{"$set": bson.M{"deleted": time.Now()}})
+}
+
+// RestoreComment takes a comment back out of the trash
+func (db *Database) RestoreComment(commentID bson.ObjectId) (err error) {
+	return db.comments.Update(
+		bson.M{"_id": commentID, "deleted": trashed},
+		bson.M{"$unset": bson.M{"deleted": ""}})
+}
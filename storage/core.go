@@ -3,22 +3,23 @@ package storage
 import (
 	"fmt"
 
-	"github.com/minio/minio-go"
 	"github.com/pkg/errors"
 	"gopkg.in/mgo.v2"
 )
 
 // Database represents the storage backend state
 type Database struct {
-	session  *mgo.Session
-	users    *mgo.Collection
-	objects  *mgo.Collection
-	ratings  *mgo.Collection
-	comments *mgo.Collection
-	store    *minio.Client
-
-	StoreBucket   string
-	StoreLocation string
+	session     *mgo.Session
+	users       *mgo.Collection
+	objects     *mgo.Collection
+	ratings     *mgo.Collection
+	comments    *mgo.Collection
+	categories  *mgo.Collection
+	tagAliases  *mgo.Collection
+	collections *mgo.Collection
+	migrations  *mgo.Collection
+	blobs       BlobStore
+	leases      *blobLeases
 }
 
 // Config represents the configuration required to interact with the database
@@ -29,6 +30,11 @@ type Config struct {
 	MongoPass           string
 	MongoName           string
 	MongoCollectionInfo mgo.CollectionInfo
+	MigrationsDryRun    bool   // when set, New does not apply pending migrations
+	SQLDriver           string // "sqlite3" or "postgres", used by NewSQL
+	SQLSource           string // driver specific data source name, used by NewSQL
+	StoreType           string // "s3" (default) or "directory"
+	StorePath           string // root directory for the "directory" store type
 	StoreHost           string
 	StorePort           string
 	StoreAccess         string
@@ -39,7 +45,9 @@ type Config struct {
 }
 
 // New simply provides a function to set up a MongoDB connection and perform some checks
-// against the selected database/collection to ensure it's ready for use.
+// against the selected database/collection to ensure it's ready for use. Any pending migrations
+// are applied unless config.MigrationsDryRun is set. Files are stored in the blob store selected
+// by config.StoreType.
 func New(config Config) (*Database, error) {
 	var (
 		err      error
@@ -78,38 +86,35 @@ func New(config Config) (*Database, error) {
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to ensure ratings collection")
 	}
-
-	database.store, err = minio.New(
-		fmt.Sprintf("%s:%s", config.StoreHost, config.StorePort),
-		config.StoreAccess,
-		config.StoreSecret,
-		config.StoreSecure)
+	err = database.ensureCategoryCollection(config)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to connect to object store")
+		return nil, errors.Wrap(err, "failed to ensure categories collection")
 	}
-
-	exists, err := database.store.BucketExists(config.StoreBucket)
+	err = database.ensureTagAliasCollection(config)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to check if bucket exists")
+		return nil, errors.Wrap(err, "failed to ensure tag aliases collection")
 	}
-
-	if !exists {
-		err = database.store.MakeBucket(config.StoreBucket, config.StoreLocation)
-		if err != nil {
-			return nil, errors.Wrap(err, "failed to create bucket")
-		}
+	err = database.ensureCollectionCollection(config)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to ensure collections collection")
 	}
-
-	exists, err = database.store.BucketExists(config.StoreBucket)
+	err = database.ensureMigrationCollection(config)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to check it bucket exists after creation")
+		return nil, errors.Wrap(err, "failed to ensure migrations collection")
 	}
-	if !exists {
-		return nil, errors.New("bucket was not created")
+
+	database.blobs, err = NewBlobStore(config)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to set up file store")
 	}
+	database.leases = newBlobLeases()
 
-	database.StoreBucket = config.StoreBucket
-	database.StoreLocation = config.StoreLocation
+	if !config.MigrationsDryRun {
+		err = database.Migrate()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to migrate database")
+		}
+	}
 
 	return &database, nil
 }
@@ -208,6 +213,13 @@ func (database *Database) ensureRatingCollection(config Config) (err error) {
 		Key:    []string{"userid", "objectid"},
 		Unique: true,
 	})
+	if err != nil {
+		return err
+	}
+	err = database.ratings.EnsureIndex(mgo.Index{
+		Name: "OBJECT_RATINGS",
+		Key:  []string{"objectid", "date", "userid"},
+	})
 
 	return
 }
@@ -225,5 +237,83 @@ func (database *Database) ensureCommentCollection(config Config) (err error) {
 	}
 	database.comments = database.session.DB(config.MongoName).C("comments")
 
+	err = database.comments.EnsureIndex(mgo.Index{
+		Name: "OBJECT_COMMENTS",
+		Key:  []string{"objectid", "_id"},
+	})
+
+	return
+}
+
+func (database *Database) ensureCategoryCollection(config Config) (err error) {
+	exists, err := database.CollectionExists(config.MongoName, "categories")
+	if err != nil {
+		return err
+	}
+	if !exists {
+		err = database.session.DB(config.MongoName).C("categories").Create(&config.MongoCollectionInfo)
+		if err != nil {
+			return err
+		}
+	}
+	database.categories = database.session.DB(config.MongoName).C("categories")
+
+	err = database.categories.EnsureIndex(mgo.Index{
+		Name:   "UNIQUE_SLUG",
+		Key:    []string{"slug"},
+		Unique: true,
+	})
+
+	return
+}
+
+func (database *Database) ensureTagAliasCollection(config Config) (err error) {
+	exists, err := database.CollectionExists(config.MongoName, "tagaliases")
+	if err != nil {
+		return err
+	}
+	if !exists {
+		err = database.session.DB(config.MongoName).C("tagaliases").Create(&config.MongoCollectionInfo)
+		if err != nil {
+			return err
+		}
+	}
+	database.tagAliases = database.session.DB(config.MongoName).C("tagaliases")
+
+	err = database.tagAliases.EnsureIndex(mgo.Index{
+		Name:   "UNIQUE_ALIAS",
+		Key:    []string{"alias"},
+		Unique: true,
+	})
+
+	return
+}
+
+func (database *Database) ensureCollectionCollection(config Config) (err error) {
+	exists, err := database.CollectionExists(config.MongoName, "collections")
+	if err != nil {
+		return err
+	}
+	if !exists {
+		err = database.session.DB(config.MongoName).C("collections").Create(&config.MongoCollectionInfo)
+		if err != nil {
+			return err
+		}
+	}
+	database.collections = database.session.DB(config.MongoName).C("collections")
+
+	err = database.collections.EnsureIndex(mgo.Index{
+		Name:   "UNIQUE_COLLECTION_ID",
+		Key:    []string{"id"},
+		Unique: true,
+	})
+	if err != nil {
+		return err
+	}
+	err = database.collections.EnsureIndex(mgo.Index{
+		Name: "COLLECTION_OWNER",
+		Key:  []string{"ownerid"},
+	})
+
 	return
 }
@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/pkg/errors"
+
+	"github.com/Southclaws/samp-objects-api/types"
+)
+
+// DedupReport summarises how much space content-addressed storage saves, Files and LogicalBytes
+// count every file of every object while Blobs and StoredBytes count each unique content once.
+type DedupReport struct {
+	Files        int   `json:"files"`
+	Blobs        int   `json:"blobs"`
+	LogicalBytes int64 `json:"logical_bytes"`
+	StoredBytes  int64 `json:"stored_bytes"`
+	SavedBytes   int64 `json:"saved_bytes"`
+}
+
+// historyFiles returns the files of every version of an object
+func historyFiles(object types.Object) (files []types.FileInfo) {
+	for _, version := range object.History() {
+		files = append(files, version.Files...)
+	}
+	return
+}
+
+// dedupReport calculates a DedupReport from the files of every object, files without a checksum
+// are stored per object so they are always counted as their own blob
+func dedupReport(files []types.FileInfo) (report DedupReport) {
+	seen := make(map[string]bool)
+	for _, info := range files {
+		report.Files++
+		report.LogicalBytes += info.Size
+
+		if info.SHA256 != "" {
+			if seen[info.SHA256] {
+				continue
+			}
+			seen[info.SHA256] = true
+		}
+		report.Blobs++
+		report.StoredBytes += info.Size
+	}
+	report.SavedBytes = report.LogicalBytes - report.StoredBytes
+	return
+}
+
+// moveLegacyFiles moves the files of an object stored under `<objectID>/<filename>` to their content
+// key, if the content is already stored the legacy copy is simply removed
+func moveLegacyFiles(blobs BlobStore, object types.Object) (err error) {
+	var exists, stored bool
+	for _, info := range object.Files {
+		legacy := objectFileKey(object.ID, string(info.Name))
+
+		exists, err = blobs.Exists(legacy)
+		if err != nil {
+			return
+		}
+		if !exists {
+			continue
+		}
+
+		stored, err = blobs.Exists(contentKey(info.SHA256))
+		if err != nil {
+			return
+		}
+		if !stored {
+			var blob Blob
+			blob, err = blobs.Get(legacy)
+			if err != nil {
+				return errors.Wrapf(err, "failed to read %s", legacy)
+			}
+			contentType := info.ContentType
+			if contentType == "" {
+				contentType, err = sniffContentType(blob)
+				if err != nil {
+					blob.Close()
+					return errors.Wrapf(err, "failed to read %s", legacy)
+				}
+			}
+			err = blobs.Put(contentKey(info.SHA256), blob, contentType)
+			blob.Close()
+			if err != nil {
+				return errors.Wrapf(err, "failed to move %s", legacy)
+			}
+		}
+
+		err = blobs.Remove(legacy)
+		if err != nil {
+			return errors.Wrapf(err, "failed to remove %s", legacy)
+		}
+	}
+	return
+}
+
+// sniffContentType works out the content type of a blob from its first bytes, for files stored
+// before the type of uploads was recorded, and rewinds it. RenderWare files aren't recognised and
+// are application/octet-stream like when they are uploaded.
+func sniffContentType(blob Blob) (contentType string, err error) {
+	head := make([]byte, 512)
+	n, err := io.ReadFull(blob, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return
+	}
+	_, err = blob.Seek(0, io.SeekStart)
+	return http.DetectContentType(head[:n]), err
+}
@@ -0,0 +1,172 @@
+package storage
+
+import (
+	"sort"
+
+	"gopkg.in/mgo.v2/bson"
+
+	"github.com/Southclaws/samp-objects-api/types"
+)
+
+// facetLimit is the most values returned for each facet
+const facetLimit = 50
+
+// Facet is the number of objects that have a value of a field
+type Facet struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// Facets counts the objects matching a query by category, tag and owner. Each facet is counted
+// with every filter except its own so the other values of a selected facet are still listed, and
+// selecting a value narrows down the counts of the remaining facets.
+type Facets struct {
+	Total      int     `json:"total"`
+	Categories []Facet `json:"categories"`
+	Tags       []Facet `json:"tags"`
+	Owners     []Facet `json:"owners"`
+}
+
+// the fields objects are counted by
+const (
+	facetCategory = "category"
+	facetTag      = "tag"
+	facetOwner    = "owner"
+)
+
+// countFacets counts the objects matching a query by each field with group, which is given the
+// query without that field's filter. The backends group the objects in the database.
+func countFacets(query ObjectQuery, group func(field string, query ObjectQuery) ([]Facet, error)) (facets Facets, err error) {
+	withoutCategory := query
+	withoutCategory.Category = ""
+	facets.Categories, err = group(facetCategory, withoutCategory)
+	if err != nil {
+		return
+	}
+
+	withoutTags := query
+	withoutTags.Tags = nil
+	facets.Tags, err = group(facetTag, withoutTags)
+	if err != nil {
+		return
+	}
+
+	withoutOwner := query
+	withoutOwner.UserName = ""
+	facets.Owners, err = group(facetOwner, withoutOwner)
+	return
+}
+
+// GetFacets counts the objects matching a query by category, tag and owner
+func (db Database) GetFacets(query ObjectQuery) (facets Facets, err error) {
+	facets, err = countFacets(query, db.groupFacet)
+	if err != nil {
+		return
+	}
+
+	filter, err := db.objectFilter(query)
+	if err != nil {
+		return
+	}
+	facets.Total, err = db.objects.Find(filter).Count()
+	return
+}
+
+// mongoFacetFields are the document fields facets are grouped by
+var mongoFacetFields = map[string]string{
+	facetCategory: "$category",
+	facetTag:      "$tags",
+	facetOwner:    "$ownername",
+}
+
+// groupFacet counts the objects matching a query by the values of a field, an object is counted
+// once for each distinct tag it has
+func (db Database) groupFacet(field string, query ObjectQuery) (facet []Facet, err error) {
+	filter, err := db.objectFilter(query)
+	if err != nil {
+		return
+	}
+
+	value := interface{}(mongoFacetFields[field])
+	if field == facetTag {
+		value = bson.M{"$setUnion": []interface{}{value, []interface{}{}}}
+	}
+
+	var groups []struct {
+		Value string `bson:"_id"`
+		Count int    `bson:"count"`
+	}
+	err = db.objects.Pipe([]bson.M{
+		{"$match": filter},
+		{"$project": bson.M{"value": value}},
+		{"$unwind": "$value"},
+		{"$group": bson.M{"_id": "$value", "count": bson.M{"$sum": 1}}},
+		{"$sort": bson.D{{Name: "count", Value: -1}, {Name: "_id", Value: 1}}},
+		{"$limit": facetLimit},
+	}).All(&groups)
+	if err != nil {
+		return
+	}
+
+	facet = []Facet{}
+	for _, group := range groups {
+		facet = append(facet, Facet{group.Value, group.Count})
+	}
+	return
+}
+
+// listFacets counts the objects matching a query by listing them, the Memory backend uses it
+func listFacets(store Storage, query ObjectQuery) (facets Facets, err error) {
+	query.Sort = ""
+
+	facets, err = countFacets(query, func(field string, query ObjectQuery) ([]Facet, error) {
+		page, err := store.GetObjects(query, PageQuery{})
+		if err != nil {
+			return nil, err
+		}
+		return countFacet(page.Objects, field), nil
+	})
+	if err != nil {
+		return
+	}
+
+	page, err := store.GetObjects(query, PageQuery{})
+	facets.Total = len(page.Objects)
+	return
+}
+
+// countFacet counts objects by the values of a field, the most common values come first
+func countFacet(objects []types.Object, field string) (facet []Facet) {
+	counts := make(map[string]int)
+	for _, object := range objects {
+		switch field {
+		case facetCategory:
+			counts[string(object.Category)]++
+		case facetTag:
+			seen := make(map[types.ObjectTag]bool)
+			for _, tag := range object.Tags {
+				if !seen[tag] {
+					seen[tag] = true
+					counts[string(tag)]++
+				}
+			}
+		case facetOwner:
+			counts[string(object.OwnerName)]++
+		}
+	}
+
+	facet = []Facet{}
+	for value, count := range counts {
+		facet = append(facet, Facet{value, count})
+	}
+	sort.Slice(facet, func(i, j int) bool {
+		if facet[i].Count != facet[j].Count {
+			return facet[i].Count > facet[j].Count
+		}
+		return facet[i].Value < facet[j].Value
+	})
+	if len(facet) > facetLimit {
+		facet = facet[:facetLimit]
+	}
+	return
+}
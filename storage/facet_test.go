@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Southclaws/samp-objects-api/types"
+)
+
+func TestGetFacets(t *testing.T) {
+	objects := []types.Object{
+		{ID: "00000000-0000-0000-0000-800000000000", OwnerID: "00000003-0000-0000-0000-000000000000", OwnerName: "owner3", Name: "faceta", Category: "facetA", Tags: []types.ObjectTag{"red", "blue"}},
+		{ID: "00000000-0000-0000-0000-810000000000", OwnerID: "00000002-0000-0000-0000-000000000000", OwnerName: "owner2", Name: "facetb", Category: "facetA", Tags: []types.ObjectTag{"red"}},
+		{ID: "00000000-0000-0000-0000-820000000000", OwnerID: "00000003-0000-0000-0000-000000000000", OwnerName: "owner3", Name: "facetc", Category: "facetB", Tags: []types.ObjectTag{"green"}},
+	}
+	for _, object := range objects {
+		object.Description = "facetable"
+		object.Images = []types.File{"image.jpg"}
+		object.Models = []types.File{"model.dff"}
+		object.Textures = []types.File{"texture.txd"}
+		assert.NoError(t, db.CreateObject(object))
+	}
+
+	facets, err := db.GetFacets(ObjectQuery{Search: "facetable"})
+	assert.NoError(t, err)
+	assert.Equal(t, Facets{
+		Total:      3,
+		Categories: []Facet{{"facetA", 2}, {"facetB", 1}},
+		Tags:       []Facet{{"red", 2}, {"blue", 1}, {"green", 1}},
+		Owners:     []Facet{{"owner3", 2}, {"owner2", 1}},
+	}, facets)
+
+	facets, err = db.GetFacets(ObjectQuery{Search: "facetable", Category: "facetA"})
+	assert.NoError(t, err)
+	assert.Equal(t, Facets{
+		Total:      2,
+		Categories: []Facet{{"facetA", 2}, {"facetB", 1}},
+		Tags:       []Facet{{"red", 2}, {"blue", 1}},
+		Owners:     []Facet{{"owner2", 1}, {"owner3", 1}},
+	}, facets)
+
+	for _, object := range objects {
+		assert.NoError(t, db.DeleteObject(object.ID))
+	}
+}
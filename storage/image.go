@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"path"
+
+	"github.com/nfnt/resize"
+	"github.com/pkg/errors"
+
+	"github.com/Southclaws/samp-objects-api/types"
+)
+
+// ErrImageNotFound is returned when a web image is requested for a file that isn't one of the
+// object's images
+var ErrImageNotFound = errors.New("image not found")
+
+// webImageSize is the longest side of the web version of an image, larger images are scaled down
+const webImageSize = 1600
+
+// webImageKey returns the blob key the web version of an image is cached under, next to the
+// thumbnails and previews made from the same file
+func webImageKey(key string) string {
+	return path.Join("web", key, "image")
+}
+
+// getWebImage writes a version of one of the images in a version of object that is suited to
+// showing on a web page. The original is kept as it was uploaded, this is a JPEG no larger than
+// webImageSize or a PNG if the original has transparency. GIFs are written as they are so
+// animations keep working. The web version is cached the first time it is made.
+func getWebImage(blobs BlobStore, object types.Object, version int, fileName types.File, writer io.Writer) (err error) {
+	object, ok := object.AtVersion(version)
+	if !ok {
+		return errors.Errorf("object has no version %d", version)
+	}
+	if !hasFile(object.Images, fileName) {
+		return ErrImageNotFound
+	}
+	key := fileKey(object, fileName)
+
+	if cached, err := blobs.Get(webImageKey(key)); err == nil {
+		defer cached.Close()
+		_, err = io.Copy(writer, cached)
+		return err
+	}
+
+	blob, err := blobs.Get(key)
+	if err != nil {
+		return errors.Wrap(err, "failed to get file from object store")
+	}
+	defer blob.Close()
+
+	var original bytes.Buffer
+	_, err = io.Copy(&original, blob)
+	if err != nil {
+		return errors.Wrap(err, "failed to read image")
+	}
+
+	img, format, err := image.Decode(bytes.NewReader(original.Bytes()))
+	if err != nil {
+		return errors.Wrap(err, "failed to decode stored image")
+	}
+	if format == "gif" {
+		_, err = original.WriteTo(writer)
+		return
+	}
+
+	// images without an Opaque method are treated as transparent so nothing is lost
+	opaque := false
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		opaque = o.Opaque()
+	}
+	img = resize.Thumbnail(webImageSize, webImageSize, img, resize.Bilinear)
+
+	var (
+		buf         bytes.Buffer
+		contentType string
+	)
+	if opaque {
+		contentType = "image/jpeg"
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 80})
+	} else {
+		contentType = "image/png"
+		err = png.Encode(&buf, img)
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to encode web image")
+	}
+
+	err = blobs.Put(webImageKey(key), bytes.NewReader(buf.Bytes()), contentType)
+	if err != nil {
+		return errors.Wrap(err, "failed to cache web image")
+	}
+
+	_, err = buf.WriteTo(writer)
+	return
+}
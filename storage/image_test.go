@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Southclaws/samp-objects-api/types"
+)
+
+func TestDatabase_WebImages(t *testing.T) {
+	objectID := types.ObjectID("00000000-0000-0000-0000-e00000000000")
+
+	transparent := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	transparent.SetNRGBA(0, 0, color.NRGBA{255, 0, 0, 128})
+	var source bytes.Buffer
+	assert.NoError(t, png.Encode(&source, transparent))
+	overlay, err := db.PutObjectFile(objectID, "overlay.png", "image/png", bytes.NewReader(source.Bytes()))
+	assert.NoError(t, err)
+
+	source.Reset()
+	assert.NoError(t, jpeg.Encode(&source, image.NewGray(image.Rect(0, 0, 3200, 1600)), nil))
+	photo, err := db.PutObjectFile(objectID, "photo.jpg", "image/jpeg", bytes.NewReader(source.Bytes()))
+	assert.NoError(t, err)
+
+	object := types.Object{
+		ID:        objectID,
+		OwnerID:   "00000003-0000-0000-0000-000000000000",
+		OwnerName: "owner3",
+		Name:      "photographed",
+		Category:  "category1",
+		Images:    []types.File{"overlay.png", "photo.jpg"},
+		Models:    []types.File{"model.dff"},
+		Textures:  []types.File{"texture.txd"},
+		Files:     []types.FileInfo{overlay, photo},
+	}
+	assert.NoError(t, db.CreateObject(object))
+
+	for i := 0; i < 2; i++ { // the second request is served from the cache
+		var buf bytes.Buffer
+		assert.NoError(t, db.GetObjectImage(objectID, 0, "overlay.png", &buf))
+		img, err := png.Decode(&buf)
+		assert.NoError(t, err)
+		assert.Equal(t, color.NRGBAModel.Convert(color.NRGBA{255, 0, 0, 128}), color.NRGBAModel.Convert(img.At(0, 0)))
+
+		buf.Reset()
+		assert.NoError(t, db.GetObjectImage(objectID, 0, "photo.jpg", &buf))
+		img, err = jpeg.Decode(&buf)
+		assert.NoError(t, err)
+		assert.Equal(t, image.Rect(0, 0, 1600, 800), img.Bounds())
+	}
+
+	// the original is kept as it was uploaded
+	var buf bytes.Buffer
+	assert.NoError(t, db.GetObjectFile(objectID, 0, "photo.jpg", &buf))
+	assert.Equal(t, source.Bytes(), buf.Bytes())
+
+	assert.Equal(t, ErrImageNotFound, db.GetObjectImage(objectID, 0, "model.dff", ioutil.Discard))
+
+	assert.NoError(t, db.DeleteObject(objectID))
+}
@@ -0,0 +1,1255 @@
+package storage
+
+import (
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/mgo.v2/bson"
+
+	"github.com/Southclaws/samp-objects-api/types"
+)
+
+// Memory is an in-process implementation of Storage, it holds all metadata and file contents in
+// memory and follows the same uniqueness and validation rules as Database. It is intended for
+// tests and local demos, nothing is persisted once the process exits.
+type Memory struct {
+	mu          sync.RWMutex
+	users       []types.User
+	objects     []types.Object
+	ratings     []types.Rating
+	comments    []types.Comment
+	categories  []types.Category
+	tagAliases  []types.TagAlias
+	collections []types.Collection
+	blobs       BlobStore
+	leases      *blobLeases
+
+	// objectSeq numbers objects in the order they were created, it's what "_id" sorts by
+	objectSeq map[types.ObjectID]int64
+	lastSeq   int64
+}
+
+// NewMemory returns an empty in-memory storage backend, files are kept in a MemoryStore
+func NewMemory() *Memory {
+	return &Memory{
+		blobs:     NewMemoryStore(),
+		leases:    newBlobLeases(),
+		objectSeq: make(map[types.ObjectID]int64),
+	}
+}
+
+// -
+// Users
+// -
+
+// CreateUser creates a new user
+func (m *Memory) CreateUser(user types.User) (err error) {
+	if err = user.Validate(); err != nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, existing := range m.users {
+		if existing.ID == user.ID {
+			return errors.New("user ID already exists")
+		}
+		if existing.Name == user.Name {
+			return ErrUserNameAlreadyExists
+		}
+		if existing.Email == user.Email {
+			return ErrUserEmailAlreadyExists
+		}
+	}
+
+	m.users = append(m.users, user)
+	return
+}
+
+// UpdateUser updates a user's account information
+func (m *Memory) UpdateUser(user types.User) (err error) {
+	if err = user.Validate(); err != nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	idx := m.userIndex(func(u types.User) bool { return u.ID == user.ID })
+	if idx == -1 {
+		return ErrNotFound
+	}
+	m.users[idx] = user
+	return
+}
+
+// DeleteUser deletes a user's account
+func (m *Memory) DeleteUser(userID types.UserID) (err error) {
+	return m.deleteUser(userID, func(u types.User) bool { return true })
+}
+
+// PurgeUser deletes a trashed user's account if it was trashed at or before the given time
+func (m *Memory) PurgeUser(userID types.UserID, before time.Time) (err error) {
+	return m.deleteUser(userID, func(u types.User) bool { return trashedBefore(u.Deleted, before) })
+}
+
+func (m *Memory) deleteUser(userID types.UserID, match func(types.User) bool) (err error) {
+	if err = userID.Validate(); err != nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	idx := m.userIndex(func(u types.User) bool { return u.ID == userID && match(u) })
+	if idx == -1 {
+		return ErrNotFound
+	}
+	m.users = append(m.users[:idx], m.users[idx+1:]...)
+	return
+}
+
+// GetUser returns a types.User by their unique ID
+func (m *Memory) GetUser(userID types.UserID) (user types.User, exists bool, err error) {
+	if err = userID.Validate(); err != nil {
+		err = errors.Wrap(err, "invalid user ID")
+		return
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	idx := m.userIndex(func(u types.User) bool { return u.ID == userID })
+	if idx == -1 {
+		return
+	}
+	return m.users[idx], true, nil
+}
+
+// GetUserByName returns a types.User by their name
+func (m *Memory) GetUserByName(userName types.UserName) (user types.User, exists bool, err error) {
+	if err = userName.Validate(); err != nil {
+		err = errors.Wrap(err, "invalid user name")
+		return
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	idx := m.userIndex(func(u types.User) bool { return u.Name == userName && u.Deleted == nil })
+	if idx == -1 {
+		return
+	}
+	return m.users[idx], true, nil
+}
+
+// UserExists checks if a user exists by their unique ID
+func (m *Memory) UserExists(userID types.UserID) (exists bool, err error) {
+	_, exists, err = m.GetUser(userID)
+	return
+}
+
+// UserExistsByName checks if a user exists by their name
+func (m *Memory) UserExistsByName(userName types.UserName) (exists bool, err error) {
+	_, exists, err = m.GetUserByName(userName)
+	return
+}
+
+// TrashUser soft deletes a user's account
+func (m *Memory) TrashUser(userID types.UserID) (err error) {
+	if err = userID.Validate(); err != nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	idx := m.userIndex(func(u types.User) bool { return u.ID == userID && u.Deleted == nil })
+	if idx == -1 {
+		return ErrNotFound
+	}
+	now := time.Now()
+	m.users[idx].Deleted = &now
+	return
+}
+
+// RestoreUser takes a user's account back out of the trash
+func (m *Memory) RestoreUser(userID types.UserID) (err error) {
+	if err = userID.Validate(); err != nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	idx := m.userIndex(func(u types.User) bool { return u.ID == userID && u.Deleted != nil })
+	if idx == -1 {
+		return ErrNotFound
+	}
+	m.users[idx].Deleted = nil
+	return
+}
+
+func (m *Memory) userIndex(match func(types.User) bool) int {
+	for i, user := range m.users {
+		if match(user) {
+			return i
+		}
+	}
+	return -1
+}
+
+// -
+// Objects
+// -
+
+// CreateObject creates a new object
+func (m *Memory) CreateObject(object types.Object) (err error) {
+	object.Tags = types.NormaliseTags(object.Tags)
+	if err = object.Validate(); err != nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, existing := range m.objects {
+		if existing.ID == object.ID {
+			return errors.New("object ID already exists")
+		}
+		if existing.OwnerID == object.OwnerID && existing.Name == object.Name {
+			return ErrObjectNameAlreadyExists
+		}
+	}
+
+	m.objects = append(m.objects, copyObject(object))
+	m.lastSeq++
+	m.objectSeq[object.ID] = m.lastSeq
+	m.leases.release(object.ID)
+	return
+}
+
+// UpdateObject updates a object's information
+func (m *Memory) UpdateObject(object types.Object) (err error) {
+	object.Tags = types.NormaliseTags(object.Tags)
+	if err = object.Validate(); err != nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	idx := m.objectIndex(func(o types.Object) bool { return o.ID == object.ID })
+	if idx == -1 {
+		return ErrNotFound
+	}
+	// the rating counters are only changed by ratings, the caller's copy may be out of date
+	existing := m.objects[idx]
+	object.RateCount, object.RateTotal, object.RateHistogram = existing.RateCount, existing.RateTotal, existing.RateHistogram
+	m.objects[idx] = copyObject(object)
+	m.leases.release(object.ID)
+	return
+}
+
+// DeleteObject deletes an object and all of its files
+func (m *Memory) DeleteObject(objectID types.ObjectID) (err error) {
+	return m.deleteObject(objectID, func(o types.Object) bool { return true })
+}
+
+// PurgeObject deletes a trashed object and its files if it was trashed at or before the given time
+func (m *Memory) PurgeObject(objectID types.ObjectID, before time.Time) (err error) {
+	return m.deleteObject(objectID, func(o types.Object) bool { return trashedBefore(o.Deleted, before) })
+}
+
+func (m *Memory) deleteObject(objectID types.ObjectID, match func(types.Object) bool) (err error) {
+	if err = objectID.Validate(); err != nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	idx := m.objectIndex(func(o types.Object) bool { return o.ID == objectID && match(o) })
+	if idx == -1 {
+		return ErrNotFound
+	}
+	object := m.objects[idx]
+	m.objects = append(m.objects[:idx], m.objects[idx+1:]...)
+	delete(m.objectSeq, objectID)
+
+	return removeObjectFiles(m.blobs, m.leases, object, m.blobReferenced)
+}
+
+// DiscardObjectFiles removes the files stored for an object that was never created, such as an
+// upload that failed part way through, unless another object shares them
+func (m *Memory) DiscardObjectFiles(objectID types.ObjectID, files []types.FileInfo) (err error) {
+	if err = objectID.Validate(); err != nil {
+		return
+	}
+
+	m.leases.release(objectID)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return removeObjectFiles(m.blobs, m.leases, types.Object{ID: objectID, Files: files}, m.blobReferenced)
+}
+
+// blobReferenced reports whether any object has a file with the given contents, the caller must
+// hold the lock
+func (m *Memory) blobReferenced(sha256 string) (bool, error) {
+	for _, object := range m.objects {
+		for _, info := range historyFiles(object) {
+			if info.SHA256 == sha256 {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// GetObject returns a types.Object by their unique ID
+func (m *Memory) GetObject(objectID types.ObjectID) (object types.Object, err error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	idx := m.objectIndex(func(o types.Object) bool { return o.ID == objectID })
+	if idx == -1 {
+		err = ErrNotFound
+		return
+	}
+	return copyObject(m.objects[idx]), nil
+}
+
+// GetObjects returns a list of objects based on query parameters, sort accepts the same field
+// names as the MongoDB backend with an optional "-" prefix for descending order.
+func (m *Memory) GetObjects(query ObjectQuery, page PageQuery) (result ObjectPage, err error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	tags := expandTags(m.tagAliases, query.Tags)
+
+	var objects []types.Object
+	for _, object := range m.objects {
+		if object.Deleted != nil {
+			continue
+		}
+		if query.UserName != "" && object.OwnerName != query.UserName {
+			continue
+		}
+		if query.Category != "" && object.Category != query.Category {
+			continue
+		}
+		if len(tags) > 0 && !hasAnyTag(object.Tags, tags) {
+			continue
+		}
+		objects = append(objects, copyObject(object))
+	}
+
+	prior := m.ratingPrior()
+	if query.objectSort().field == "score" {
+		prior, err = scorePrior(page.Cursor, func() (float64, error) { return prior, nil })
+		if err != nil {
+			return
+		}
+	}
+	sortObjects(objects, query.storedSort(), prior)
+	words := parseSearch(query.Search)
+	if len(words) > 0 {
+		objects = rankObjects(objects, words, query.byRelevance())
+	}
+	return pageObjects(objects, query, page, objectKeys{
+		seq:   func(id types.ObjectID) interface{} { return float64(m.objectSeq[id]) },
+		prior: prior,
+		words: words,
+	})
+}
+
+// GetFacets counts the objects matching a query by category, tag and owner
+func (m *Memory) GetFacets(query ObjectQuery) (Facets, error) {
+	return listFacets(m, query)
+}
+
+func (m *Memory) ratingPrior() float64 {
+	var (
+		total types.ObjectRateTotal
+		count types.ObjectRateCount
+	)
+	for _, object := range m.objects {
+		total += object.RateTotal
+		count += object.RateCount
+	}
+	return ratingPrior(total, count)
+}
+
+// GetUserObjects returns an array of types.Object from a specific owner
+func (m *Memory) GetUserObjects(userName types.UserName) (objects []types.Object, err error) {
+	if err = userName.Validate(); err != nil {
+		return
+	}
+	page, err := m.GetObjects(ObjectQuery{UserName: userName}, PageQuery{})
+	return page.Objects, err
+}
+
+// GetUserObject returns a types.Object from a specific owner and an object name
+func (m *Memory) GetUserObject(userName types.UserName, objectName types.ObjectName) (object types.Object, err error) {
+	if err = userName.Validate(); err != nil {
+		return
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	idx := m.objectIndex(func(o types.Object) bool {
+		return o.OwnerName == userName && o.Name == objectName && o.Deleted == nil
+	})
+	if idx == -1 {
+		err = ErrNotFound
+		return
+	}
+	return copyObject(m.objects[idx]), nil
+}
+
+// ObjectExists checks if an object exists by their unique ID
+func (m *Memory) ObjectExists(objectID types.ObjectID) (exists bool, err error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	exists = m.objectIndex(func(o types.Object) bool { return o.ID == objectID }) != -1
+	return
+}
+
+// UserObjectExists checks if an object exists by their name in a user's account
+func (m *Memory) UserObjectExists(object types.Object) (exists bool, err error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	exists = m.objectIndex(func(o types.Object) bool { return o.OwnerID == object.OwnerID && o.Name == object.Name }) != -1
+	return
+}
+
+// TrashObject soft deletes an object, it keeps its name and files until it is purged
+func (m *Memory) TrashObject(objectID types.ObjectID) (err error) {
+	if err = objectID.Validate(); err != nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	idx := m.objectIndex(func(o types.Object) bool { return o.ID == objectID && o.Deleted == nil })
+	if idx == -1 {
+		return ErrNotFound
+	}
+	now := time.Now()
+	m.objects[idx].Deleted = &now
+	return
+}
+
+// RestoreObject takes an object back out of the trash
+func (m *Memory) RestoreObject(objectID types.ObjectID) (err error) {
+	if err = objectID.Validate(); err != nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	idx := m.objectIndex(func(o types.Object) bool { return o.ID == objectID && o.Deleted != nil })
+	if idx == -1 {
+		return ErrNotFound
+	}
+	m.objects[idx].Deleted = nil
+	return
+}
+
+func (m *Memory) objectIndex(match func(types.Object) bool) int {
+	for i, object := range m.objects {
+		if match(object) {
+			return i
+		}
+	}
+	return -1
+}
+
+// copyObject ensures stored objects never share slices with callers, it also fills in the
+// calculated rating average as every read goes through here
+func copyObject(object types.Object) types.Object {
+	object.UpdateRateAverage()
+	if object.Tags != nil {
+		object.Tags = append([]types.ObjectTag{}, object.Tags...)
+	}
+	if object.Images != nil {
+		object.Images = append([]types.File{}, object.Images...)
+	}
+	if object.Models != nil {
+		object.Models = append([]types.File{}, object.Models...)
+	}
+	if object.Textures != nil {
+		object.Textures = append([]types.File{}, object.Textures...)
+	}
+	object.Files = copyFiles(object.Files)
+	if object.Versions != nil {
+		versions := make([]types.ObjectVersion, len(object.Versions))
+		for i, version := range object.Versions {
+			if version.Images != nil {
+				version.Images = append([]types.File{}, version.Images...)
+			}
+			if version.Models != nil {
+				version.Models = append([]types.File{}, version.Models...)
+			}
+			if version.Textures != nil {
+				version.Textures = append([]types.File{}, version.Textures...)
+			}
+			version.Files = copyFiles(version.Files)
+			versions[i] = version
+		}
+		object.Versions = versions
+	}
+	return object
+}
+
+// copyFiles copies the details of an object's files along with the model and textures in them
+func copyFiles(files []types.FileInfo) []types.FileInfo {
+	if files == nil {
+		return nil
+	}
+	copied := make([]types.FileInfo, len(files))
+	for i, file := range files {
+		if file.Model != nil {
+			model := *file.Model
+			if model.Frames != nil {
+				model.Frames = append([]types.ModelFrame{}, model.Frames...)
+			}
+			if model.Materials != nil {
+				model.Materials = append([]types.ModelMaterial{}, model.Materials...)
+			}
+			if model.Textures != nil {
+				model.Textures = append([]string{}, model.Textures...)
+			}
+			file.Model = &model
+		}
+		if file.Textures != nil {
+			file.Textures = append([]types.TextureInfo{}, file.Textures...)
+		}
+		copied[i] = file
+	}
+	return copied
+}
+
+func hasAnyTag(have []types.ObjectTag, want []string) bool {
+	for _, h := range have {
+		for _, w := range want {
+			if string(h) == w {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sortObjects sorts a slice of objects by a MongoDB style sort field or "score", unknown fields and
+// "_id" retain insertion order which matches sorting by MongoDB's ObjectId.
+func sortObjects(objects []types.Object, sortBy string, prior float64) {
+	desc := strings.HasPrefix(sortBy, "-")
+	field := strings.TrimPrefix(strings.TrimPrefix(sortBy, "-"), "+")
+
+	var less func(a, b types.Object) bool
+	switch field {
+	case "id":
+		less = func(a, b types.Object) bool { return a.ID < b.ID }
+	case "name":
+		less = func(a, b types.Object) bool { return a.Name < b.Name }
+	case "ownername":
+		less = func(a, b types.Object) bool { return a.OwnerName < b.OwnerName }
+	case "category":
+		less = func(a, b types.Object) bool { return a.Category < b.Category }
+	case "ratecount":
+		less = func(a, b types.Object) bool { return a.RateCount < b.RateCount }
+	case "ratetotal":
+		less = func(a, b types.Object) bool { return a.RateTotal < b.RateTotal }
+	case "score":
+		// objects with the same score are listed newest first
+		for i, j := 0, len(objects)-1; i < j; i, j = i+1, j-1 {
+			objects[i], objects[j] = objects[j], objects[i]
+		}
+		less = func(a, b types.Object) bool {
+			return bayesianScore(a.RateTotal, a.RateCount, prior) < bayesianScore(b.RateTotal, b.RateCount, prior)
+		}
+	default:
+		if desc {
+			for i, j := 0, len(objects)-1; i < j; i, j = i+1, j-1 {
+				objects[i], objects[j] = objects[j], objects[i]
+			}
+		}
+		return
+	}
+
+	sort.SliceStable(objects, func(i, j int) bool {
+		if desc {
+			return less(objects[j], objects[i])
+		}
+		return less(objects[i], objects[j])
+	})
+}
+
+// -
+// Files
+// -
+
+// PutObjectFile stores a file in an object's folder from an io.Reader and returns its size and
+// checksums
+func (m *Memory) PutObjectFile(objectID types.ObjectID, filename, contentType string, reader io.Reader) (info types.FileInfo, err error) {
+	return putObjectFile(m.blobs, m.leases, objectID, filename, contentType, reader)
+}
+
+// GetObjectThumb writes a thumbnail of the first image from an object to the given writer
+func (m *Memory) GetObjectThumb(objectID types.ObjectID, size uint, writer io.Writer) (err error) {
+	if err = objectID.Validate(); err != nil {
+		err = errors.Wrap(err, "invalid object ID format")
+		return
+	}
+
+	object, err := m.GetObject(objectID)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to lookup object %s", string(objectID))
+		return
+	}
+
+	return getObjectThumb(m.blobs, object, size, writer)
+}
+
+// GetObjectFile writes the specified file from a version of an object to the given writer
+func (m *Memory) GetObjectFile(objectID types.ObjectID, version int, fileName types.File, writer io.Writer) (err error) {
+	object, err := m.GetObject(objectID)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to lookup object %s", string(objectID))
+		return
+	}
+
+	return getObjectFile(m.blobs, object, version, fileName, writer)
+}
+
+// OpenObjectFile opens the specified file from a version of an object along with its stat info
+// from the blob store, the caller must close it
+func (m *Memory) OpenObjectFile(objectID types.ObjectID, version int, fileName types.File) (blob Blob, info BlobInfo, err error) {
+	object, err := m.GetObject(objectID)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to lookup object %s", string(objectID))
+		return
+	}
+
+	return openObjectFile(m.blobs, object, version, fileName)
+}
+
+// GetTexturePreview writes a texture from a texture dictionary of an object to the given writer
+// as a PNG
+func (m *Memory) GetTexturePreview(objectID types.ObjectID, version int, fileName types.File, texture string, width, height uint, writer io.Writer) (err error) {
+	if err = objectID.Validate(); err != nil {
+		err = errors.Wrap(err, "invalid object ID format")
+		return
+	}
+
+	object, err := m.GetObject(objectID)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to lookup object %s", string(objectID))
+		return
+	}
+
+	return getTexturePreview(m.blobs, object, version, fileName, texture, width, height, writer)
+}
+
+// GetObjectImage writes the web version of one of the images from a version of an object to the
+// given writer
+func (m *Memory) GetObjectImage(objectID types.ObjectID, version int, fileName types.File, writer io.Writer) (err error) {
+	if err = objectID.Validate(); err != nil {
+		err = errors.Wrap(err, "invalid object ID format")
+		return
+	}
+
+	object, err := m.GetObject(objectID)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to lookup object %s", string(objectID))
+		return
+	}
+
+	return getWebImage(m.blobs, object, version, fileName, writer)
+}
+
+// DedupReport calculates how much space is saved by objects sharing files
+func (m *Memory) DedupReport() (report DedupReport, err error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var files []types.FileInfo
+	for _, object := range m.objects {
+		files = append(files, historyFiles(object)...)
+	}
+	return dedupReport(files), nil
+}
+
+// -
+// Ratings
+// -
+
+// AddRating adds a rating to an object from a user
+func (m *Memory) AddRating(userID types.UserID, objectID types.ObjectID, value float64) (exists bool, err error) {
+	if err = userID.Validate(); err != nil {
+		return
+	}
+	if err = objectID.Validate(); err != nil {
+		return
+	}
+	if value < 0.0 || value > 5.0 {
+		return false, errors.New("invalid rating value")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.ratingIndex(userID, objectID) != -1 {
+		return true, nil
+	}
+
+	idx := m.objectIndex(func(o types.Object) bool { return o.ID == objectID })
+	if idx == -1 {
+		return false, ErrNotFound
+	}
+
+	m.ratings = append(m.ratings, types.Rating{
+		UserID:   userID,
+		ObjectID: objectID,
+		Value:    value,
+		Date:     time.Now(),
+	})
+	m.objects[idx].RateCount++
+	m.objects[idx].RateTotal += types.ObjectRateTotal(value)
+	m.objects[idx].RateHistogram[types.RatingStar(value)]++
+	return
+}
+
+// RemoveRating removes a user's rating from an object
+func (m *Memory) RemoveRating(userID types.UserID, objectID types.ObjectID) (err error) {
+	if err = userID.Validate(); err != nil {
+		return
+	}
+	if err = objectID.Validate(); err != nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ratingIdx := m.ratingIndex(userID, objectID)
+	if ratingIdx == -1 {
+		return errors.Wrap(ErrNotFound, "failed to find rating")
+	}
+	rating := m.ratings[ratingIdx]
+	m.ratings = append(m.ratings[:ratingIdx], m.ratings[ratingIdx+1:]...)
+
+	idx := m.objectIndex(func(o types.Object) bool { return o.ID == objectID })
+	if idx == -1 {
+		return ErrNotFound
+	}
+	m.objects[idx].RateCount--
+	m.objects[idx].RateTotal -= types.ObjectRateTotal(rating.Value)
+	m.objects[idx].RateHistogram[types.RatingStar(rating.Value)]--
+	return
+}
+
+// GetRatings returns a page of the ratings on an object
+func (m *Memory) GetRatings(objectID types.ObjectID, page PageQuery) (result RatingPage, err error) {
+	if err = objectID.Validate(); err != nil {
+		return
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var ratings []types.Rating
+	for _, rating := range m.ratings {
+		if rating.ObjectID == objectID {
+			ratings = append(ratings, rating)
+		}
+	}
+	return pageRatings(ratings, page)
+}
+
+// ReconcileRatings recalculates the rating counters of every object from the stored ratings
+func (m *Memory) ReconcileRatings(fix bool) (drift []RatingDrift, err error) {
+	m.mu.RLock()
+	stored := make(map[types.ObjectID]RatingAggregate)
+	for _, object := range m.objects {
+		stored[object.ID] = aggregateOf(object)
+	}
+	drift = ratingDrift(stored, aggregateRatings(m.ratings))
+	m.mu.RUnlock()
+
+	if !fix {
+		return
+	}
+	return fixRatingDrift(m, drift)
+}
+
+func (m *Memory) readRatingCounters(objectID types.ObjectID) (stored, actual RatingAggregate, err error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	idx := m.objectIndex(func(o types.Object) bool { return o.ID == objectID })
+	if idx == -1 {
+		return stored, actual, ErrNotFound
+	}
+	var ratings []types.Rating
+	for _, rating := range m.ratings {
+		if rating.ObjectID == objectID {
+			ratings = append(ratings, rating)
+		}
+	}
+	return aggregateOf(m.objects[idx]), aggregateRatings(ratings)[objectID], nil
+}
+
+func (m *Memory) saveRatingCounters(objectID types.ObjectID, stored, actual RatingAggregate) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	idx := m.objectIndex(func(o types.Object) bool { return o.ID == objectID })
+	if idx == -1 || aggregateOf(m.objects[idx]) != stored {
+		return ErrNotFound
+	}
+	m.objects[idx].RateCount = actual.Count
+	m.objects[idx].RateTotal = actual.Total
+	m.objects[idx].RateHistogram = actual.Histogram
+	return nil
+}
+
+func (m *Memory) ratingIndex(userID types.UserID, objectID types.ObjectID) int {
+	for i, rating := range m.ratings {
+		if rating.UserID == userID && rating.ObjectID == objectID {
+			return i
+		}
+	}
+	return -1
+}
+
+// -
+// Comments
+// -
+
+// GetComments returns a page of comments for a given object ID
+func (m *Memory) GetComments(objectID types.ObjectID, page PageQuery) (result CommentPage, err error) {
+	if err = objectID.Validate(); err != nil {
+		return
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var comments []types.Comment
+	for _, comment := range m.comments {
+		if comment.ObjectID == objectID && comment.Deleted == nil {
+			comments = append(comments, comment)
+		}
+	}
+	return pageComments(comments, page)
+}
+
+// AddComment creates a comment from a user on an object
+func (m *Memory) AddComment(userID types.UserID, objectID types.ObjectID, content string) (err error) {
+	if err = userID.Validate(); err != nil {
+		return
+	}
+	if err = objectID.Validate(); err != nil {
+		return
+	}
+	if len(content) > 1024 {
+		return errors.New("content too large")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.comments = append(m.comments, types.Comment{
+		ID:       bson.NewObjectId(),
+		UserID:   userID,
+		ObjectID: objectID,
+		Content:  content,
+		Date:     time.Now(),
+	})
+	return
+}
+
+// RemoveComment removes a comment by ID
+func (m *Memory) RemoveComment(commentID bson.ObjectId) (err error) {
+	return m.removeComment(commentID, func(c types.Comment) bool { return true })
+}
+
+// PurgeComment removes a trashed comment if it was trashed at or before the given time
+func (m *Memory) PurgeComment(commentID bson.ObjectId, before time.Time) (err error) {
+	return m.removeComment(commentID, func(c types.Comment) bool { return trashedBefore(c.Deleted, before) })
+}
+
+func (m *Memory) removeComment(commentID bson.ObjectId, match func(types.Comment) bool) (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, comment := range m.comments {
+		if comment.ID == commentID && match(comment) {
+			m.comments = append(m.comments[:i], m.comments[i+1:]...)
+			return
+		}
+	}
+	return ErrNotFound
+}
+
+// GetComment returns a single comment by ID, including trashed comments
+func (m *Memory) GetComment(commentID bson.ObjectId) (comment types.Comment, err error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, comment = range m.comments {
+		if comment.ID == commentID {
+			return
+		}
+	}
+	return types.Comment{}, ErrNotFound
+}
+
+// TrashComment soft deletes a comment
+func (m *Memory) TrashComment(commentID bson.ObjectId) (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, comment := range m.comments {
+		if comment.ID == commentID && comment.Deleted == nil {
+			now := time.Now()
+			m.comments[i].Deleted = &now
+			return
+		}
+	}
+	return ErrNotFound
+}
+
+// RestoreComment takes a comment back out of the trash
+func (m *Memory) RestoreComment(commentID bson.ObjectId) (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, comment := range m.comments {
+		if comment.ID == commentID && comment.Deleted != nil {
+			m.comments[i].Deleted = nil
+			return
+		}
+	}
+	return ErrNotFound
+}
+
+// -
+// Trash
+// -
+
+// GetTrash returns every trashed object, comment and user
+func (m *Memory) GetTrash() (trash Trash, err error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, object := range m.objects {
+		if object.Deleted != nil {
+			trash.Objects = append(trash.Objects, copyObject(object))
+		}
+	}
+	for _, comment := range m.comments {
+		if comment.Deleted != nil {
+			trash.Comments = append(trash.Comments, comment)
+		}
+	}
+	for _, user := range m.users {
+		if user.Deleted != nil {
+			trash.Users = append(trash.Users, user)
+		}
+	}
+	return
+}
+
+// -
+// Collections
+// -
+
+// CreateCollection creates a new collection
+func (m *Memory) CreateCollection(collection types.Collection) (err error) {
+	if err = collection.Validate(); err != nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.collectionIndex(collection.ID) != -1 {
+		return ErrCollectionAlreadyExists
+	}
+	m.collections = append(m.collections, copyCollection(collection))
+	return
+}
+
+// UpdateCollection replaces a collection, including its objects and model IDs, if it is still at
+// the revision collection was read at. The stored revision is incremented.
+func (m *Memory) UpdateCollection(collection types.Collection) (err error) {
+	if err = collection.Validate(); err != nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	idx := m.collectionIndex(collection.ID)
+	if idx == -1 {
+		return ErrNotFound
+	}
+	if m.collections[idx].Revision != collection.Revision {
+		return ErrCollectionChanged
+	}
+	collection.Revision++
+	m.collections[idx] = copyCollection(collection)
+	return
+}
+
+// DeleteCollection removes a collection, the objects in it are not affected
+func (m *Memory) DeleteCollection(collectionID types.CollectionID) (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	idx := m.collectionIndex(collectionID)
+	if idx == -1 {
+		return ErrNotFound
+	}
+	m.collections = append(m.collections[:idx], m.collections[idx+1:]...)
+	return
+}
+
+// GetCollection returns a collection by its ID
+func (m *Memory) GetCollection(collectionID types.CollectionID) (collection types.Collection, err error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	idx := m.collectionIndex(collectionID)
+	if idx == -1 {
+		return collection, ErrNotFound
+	}
+	return copyCollection(m.collections[idx]), nil
+}
+
+// GetUserCollections returns every collection a user owns sorted by name
+func (m *Memory) GetUserCollections(userID types.UserID) (collections []types.Collection, err error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, collection := range m.collections {
+		if collection.OwnerID == userID {
+			collections = append(collections, copyCollection(collection))
+		}
+	}
+	sortCollections(collections)
+	return
+}
+
+func (m *Memory) collectionIndex(collectionID types.CollectionID) int {
+	for i, collection := range m.collections {
+		if collection.ID == collectionID {
+			return i
+		}
+	}
+	return -1
+}
+
+func copyCollection(collection types.Collection) types.Collection {
+	if collection.Objects != nil {
+		collection.Objects = append([]types.ObjectID{}, collection.Objects...)
+	}
+	if collection.Models != nil {
+		collection.Models = append([]types.ModelAssignment{}, collection.Models...)
+	}
+	return collection
+}
+
+// -
+// Categories
+// -
+
+// CreateCategory adds a category to the taxonomy
+func (m *Memory) CreateCategory(category types.Category) (err error) {
+	if err = category.Validate(); err != nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.categoryIndex(category.Slug) != -1 {
+		return ErrCategoryAlreadyExists
+	}
+	if err = checkCategoryParent(m.categories, category); err != nil {
+		return
+	}
+
+	m.categories = append(m.categories, category)
+	return
+}
+
+// UpdateCategory changes the name or parent of a category
+func (m *Memory) UpdateCategory(category types.Category) (err error) {
+	if err = category.Validate(); err != nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	idx := m.categoryIndex(category.Slug)
+	if idx == -1 {
+		return ErrNotFound
+	}
+	if err = checkCategoryParent(m.categories, category); err != nil {
+		return
+	}
+
+	m.categories[idx] = category
+	return
+}
+
+// DeleteCategory removes a category that no object or other category refers to
+func (m *Memory) DeleteCategory(slug types.ObjectCategory) (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	idx := m.categoryIndex(slug)
+	if idx == -1 {
+		return ErrNotFound
+	}
+	for _, category := range m.categories {
+		if category.Parent == slug {
+			return ErrCategoryInUse
+		}
+	}
+	for _, object := range m.objects {
+		if object.Category == slug {
+			return ErrCategoryInUse
+		}
+	}
+
+	m.categories = append(m.categories[:idx], m.categories[idx+1:]...)
+	return
+}
+
+// GetCategory returns a category by its slug
+func (m *Memory) GetCategory(slug types.ObjectCategory) (category types.Category, err error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	idx := m.categoryIndex(slug)
+	if idx == -1 {
+		return category, ErrNotFound
+	}
+	return m.categories[idx], nil
+}
+
+// GetCategories returns every category sorted by slug
+func (m *Memory) GetCategories() (categories []types.Category, err error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	categories = append(categories, m.categories...)
+	sort.Slice(categories, func(i, j int) bool { return categories[i].Slug < categories[j].Slug })
+	return
+}
+
+// RemapCategory moves every object in one category to another and returns how many were moved, or
+// with dryRun how many would be
+func (m *Memory) RemapCategory(from, to types.ObjectCategory, dryRun bool) (n int, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := range m.objects {
+		if m.objects[i].Category == from {
+			if !dryRun {
+				m.objects[i].Category = to
+			}
+			n++
+		}
+	}
+	return
+}
+
+func (m *Memory) categoryIndex(slug types.ObjectCategory) int {
+	for i, category := range m.categories {
+		if category.Slug == slug {
+			return i
+		}
+	}
+	return -1
+}
+
+// -
+// Tags
+// -
+
+// CreateTagAlias makes a tag an alias of another
+func (m *Memory) CreateTagAlias(alias types.TagAlias) (err error) {
+	if err = alias.Validate(); err != nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err = checkTagAlias(m.tagAliases, alias); err != nil {
+		return
+	}
+
+	m.tagAliases = append(m.tagAliases, alias)
+	return
+}
+
+// DeleteTagAlias removes an alias so the tag stands on its own again
+func (m *Memory) DeleteTagAlias(alias types.ObjectTag) (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, existing := range m.tagAliases {
+		if existing.Alias == alias {
+			m.tagAliases = append(m.tagAliases[:i], m.tagAliases[i+1:]...)
+			return
+		}
+	}
+	return ErrNotFound
+}
+
+// GetTagAliases returns every tag alias sorted by alias
+func (m *Memory) GetTagAliases() (aliases []types.TagAlias, err error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	aliases = append(aliases, m.tagAliases...)
+	sort.Slice(aliases, func(i, j int) bool { return aliases[i].Alias < aliases[j].Alias })
+	return
+}
+
+// GetTagCounts returns how many listed objects use each tag
+func (m *Memory) GetTagCounts() (counts map[types.ObjectTag]int, err error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	counts = make(map[types.ObjectTag]int)
+	for _, object := range m.objects {
+		if object.Deleted != nil {
+			continue
+		}
+		for _, tag := range object.Tags {
+			counts[tag]++
+		}
+	}
+	return
+}
@@ -0,0 +1,303 @@
+package storage
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+
+	"github.com/Southclaws/samp-objects-api/types"
+)
+
+// Migration is a single ordered change to the MongoDB collections. Up must be idempotent, if a
+// process dies between Up finishing and the migration being recorded it will run again.
+type Migration struct {
+	Version     int
+	Description string
+	Up          func(db *Database) error
+}
+
+// MigrationRecord is stored in the migrations collection for every migration that has been applied
+type MigrationRecord struct {
+	Version     int       `bson:"version"`
+	Description string    `bson:"description"`
+	Applied     time.Time `bson:"applied"`
+}
+
+// migrations is the ordered list of schema changes applied by New, never edit or reorder an
+// existing entry, append a new one with the next version number instead.
+var migrations = []Migration{
+	{
+		Version:     1,
+		Description: "add unique index on object owner and name",
+		Up: func(db *Database) error {
+			return db.objects.EnsureIndex(mgo.Index{
+				Name:   "UNIQUE_OBJECT_NAME",
+				Key:    []string{"ownerid", "name"},
+				Unique: true,
+			})
+		},
+	},
+	{
+		Version:     2,
+		Description: "backfill object creation time from document ObjectId",
+		Up: func(db *Database) (err error) {
+			var doc struct {
+				ID bson.ObjectId `bson:"_id"`
+			}
+			iter := db.objects.Find(bson.M{"created": bson.M{"$exists": false}}).Select(bson.M{"_id": 1}).Iter()
+			for iter.Next(&doc) {
+				err = db.objects.UpdateId(doc.ID, bson.M{"$set": bson.M{"created": doc.ID.Time()}})
+				if err != nil {
+					iter.Close()
+					return
+				}
+			}
+			return iter.Close()
+		},
+	},
+	{
+		Version:     3,
+		Description: "rebuild object rating counters and histograms from ratings",
+		Up: func(db *Database) (err error) {
+			// $inc on an index of a missing array creates a sub-document, so start every
+			// object with an empty histogram before recalculating them
+			_, err = db.objects.UpdateAll(
+				bson.M{"ratehistogram": bson.M{"$exists": false}},
+				bson.M{"$set": bson.M{"ratehistogram": types.ObjectRateHistogram{}}})
+			if err != nil {
+				return
+			}
+			_, err = db.ReconcileRatings(true)
+			return
+		},
+	},
+	{
+		Version:     4,
+		Description: "backfill file sizes and checksums from the file store",
+		Up: func(db *Database) (err error) {
+			var object types.Object
+			iter := db.objects.Find(bson.M{"files": bson.M{"$exists": false}}).Iter()
+			for iter.Next(&object) {
+				var files []types.FileInfo
+				for _, name := range objectFileNames(object) {
+					info, errInner := fileInfo(db.blobs, object.ID, name)
+					if errInner != nil {
+						// files that have gone missing from the store are left without checksums
+						continue
+					}
+					files = append(files, info)
+				}
+				err = db.objects.Update(bson.M{"id": object.ID}, bson.M{"$set": bson.M{"files": files}})
+				if err != nil {
+					iter.Close()
+					return
+				}
+			}
+			return iter.Close()
+		},
+	},
+	{
+		Version:     5,
+		Description: "move object files to content-addressed storage",
+		Up: func(db *Database) (err error) {
+			err = db.objects.EnsureIndex(mgo.Index{
+				Name: "FILE_SHA256",
+				Key:  []string{"files.sha256"},
+			})
+			if err != nil {
+				return
+			}
+
+			var object types.Object
+			iter := db.objects.Find(nil).Iter()
+			for iter.Next(&object) {
+				err = moveLegacyFiles(db.blobs, object)
+				if err != nil {
+					iter.Close()
+					return
+				}
+			}
+			return iter.Close()
+		},
+	},
+	{
+		Version:     6,
+		Description: "index file hashes of object versions",
+		Up: func(db *Database) error {
+			return db.objects.EnsureIndex(mgo.Index{
+				Name: "VERSION_FILE_SHA256",
+				Key:  []string{"versions.files.sha256"},
+			})
+		},
+	},
+	{
+		Version:     7,
+		Description: "index search terms of objects",
+		Up: func(db *Database) (err error) {
+			var object types.Object
+			iter := db.objects.Find(nil).Iter()
+			for iter.Next(&object) {
+				err = db.objects.Update(bson.M{"id": object.ID}, bson.M{"$set": bson.M{"searchterms": searchTerms(object)}})
+				if err != nil {
+					iter.Close()
+					return
+				}
+				object = types.Object{}
+			}
+			err = iter.Close()
+			if err != nil {
+				return
+			}
+
+			return db.objects.EnsureIndex(mgo.Index{
+				Name: "SEARCH_TERMS",
+				Key:  []string{"searchterms"},
+			})
+		},
+	},
+	{
+		Version:     8,
+		Description: "normalise object tags",
+		Up: func(db *Database) (err error) {
+			var object types.Object
+			iter := db.objects.Find(nil).Iter()
+			for iter.Next(&object) {
+				tags := types.NormaliseTags(object.Tags)
+				if !reflect.DeepEqual(tags, object.Tags) {
+					object.Tags = tags
+					err = db.objects.Update(bson.M{"id": object.ID}, bson.M{"$set": bson.M{
+						"tags":        tags,
+						"searchterms": searchTerms(object),
+					}})
+					if err != nil {
+						iter.Close()
+						return
+					}
+				}
+				object = types.Object{}
+			}
+			return iter.Close()
+		},
+	},
+	{
+		Version:     9,
+		Description: "replace search term arrays with the text index",
+		Up: func(db *Database) (err error) {
+			// migrations 7 and 8 stored an array of terms on every object to search them with
+			_, err = db.objects.UpdateAll(
+				bson.M{"searchterms": bson.M{"$exists": true}},
+				bson.M{"$unset": bson.M{"searchterms": ""}})
+			if err != nil {
+				return
+			}
+			err = db.objects.DropIndexName("SEARCH_TERMS")
+			if err != nil && !strings.Contains(err.Error(), "index not found") {
+				return
+			}
+			return db.objects.EnsureIndex(objectTextIndex)
+		},
+	},
+}
+
+// searchTerms is how migrations 7 and 8 indexed an object for search before the text index
+// replaced it: every word, its prefixes and, for words of four or more letters, every way of
+// deleting one letter from it. It's kept so those migrations still do what they did when they
+// were written.
+func searchTerms(object types.Object) (terms []string) {
+	seen := make(map[string]bool)
+	add := func(term string) {
+		if !seen[term] {
+			seen[term] = true
+			terms = append(terms, term)
+		}
+	}
+
+	name, tags, description := searchText(object)
+	for _, word := range searchWords(name + " " + tags + " " + description) {
+		r := []rune(word)
+		for i := searchMinPrefix; i < len(r); i++ {
+			add(string(r[:i]))
+		}
+		add(word)
+		if len(r) >= 4 {
+			for i := range r {
+				add(string(r[:i]) + string(r[i+1:]))
+			}
+		}
+	}
+	sort.Strings(terms)
+	return
+}
+
+func (database *Database) ensureMigrationCollection(config Config) (err error) {
+	exists, err := database.CollectionExists(config.MongoName, "migrations")
+	if err != nil {
+		return err
+	}
+	if !exists {
+		err = database.session.DB(config.MongoName).C("migrations").Create(&config.MongoCollectionInfo)
+		if err != nil {
+			return err
+		}
+	}
+	database.migrations = database.session.DB(config.MongoName).C("migrations")
+
+	err = database.migrations.EnsureIndex(mgo.Index{
+		Name:   "UNIQUE_MIGRATION_VERSION",
+		Key:    []string{"version"},
+		Unique: true,
+	})
+
+	return
+}
+
+// PendingMigrations returns every migration that has not yet been applied, in order
+func (database *Database) PendingMigrations() (pending []Migration, err error) {
+	var applied []MigrationRecord
+	err = database.migrations.Find(nil).All(&applied)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read applied migrations")
+	}
+
+	done := make(map[int]bool)
+	for _, record := range applied {
+		done[record.Version] = true
+	}
+
+	for _, migration := range migrations {
+		if !done[migration.Version] {
+			pending = append(pending, migration)
+		}
+	}
+	return
+}
+
+// Migrate applies every pending migration in order and records each one as it completes
+func (database *Database) Migrate() (err error) {
+	pending, err := database.PendingMigrations()
+	if err != nil {
+		return
+	}
+
+	for _, migration := range pending {
+		err = migration.Up(database)
+		if err != nil {
+			return errors.Wrapf(err, "failed to apply migration %d (%s)", migration.Version, migration.Description)
+		}
+
+		err = database.migrations.Insert(MigrationRecord{
+			Version:     migration.Version,
+			Description: migration.Description,
+			Applied:     time.Now(),
+		})
+		if err != nil && !mgo.IsDup(err) {
+			return errors.Wrapf(err, "failed to record migration %d", migration.Version)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Southclaws/samp-objects-api/types"
+)
+
+func TestMigrations_Ordered(t *testing.T) {
+	assert.True(t, sort.SliceIsSorted(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	}))
+	for i, migration := range migrations {
+		assert.Equal(t, i+1, migration.Version, "migration versions must be sequential")
+		assert.NotEmpty(t, migration.Description)
+	}
+}
+
+func TestDatabase_PendingMigrations(t *testing.T) {
+	mongo, ok := db.(*Database)
+	if !ok {
+		t.Skip("migrations only apply to the MongoDB backend")
+	}
+
+	// New applies everything so nothing should be pending
+	pending, err := mongo.PendingMigrations()
+	assert.NoError(t, err)
+	assert.Empty(t, pending)
+
+	// migrations must be idempotent
+	for _, migration := range migrations {
+		assert.NoError(t, migration.Up(mongo))
+	}
+}
+
+func Test_searchTerms(t *testing.T) {
+	terms := searchTerms(types.Object{Name: "Stadium", Tags: []types.ObjectTag{"sports-arena"}})
+	assert.True(t, sort.StringsAreSorted(terms))
+	for _, term := range []string{"st", "stad", "stadium", "sadium", "stadiu", "sports", "arena", "arna"} {
+		assert.Contains(t, terms, term)
+	}
+	assert.NotContains(t, terms, "s")
+	assert.NotContains(t, terms, "sportsarena")
+}
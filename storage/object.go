@@ -1,13 +1,12 @@
 package storage
 
 import (
-	"image/jpeg"
 	"io"
-	"path/filepath"
 	"strings"
+	"time"
 
-	"github.com/nfnt/resize"
 	"github.com/pkg/errors"
+	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
 
 	"github.com/Southclaws/samp-objects-api/types"
@@ -21,6 +20,7 @@ var (
 
 // CreateObject creates a new object in the database
 func (db Database) CreateObject(object types.Object) (err error) {
+	object.Tags = types.NormaliseTags(object.Tags)
 	if err = object.Validate(); err != nil {
 		return
 	}
@@ -30,38 +30,78 @@ func (db Database) CreateObject(object types.Object) (err error) {
 		if strings.Contains(err.Error(), "UNIQUE_OBJECT_NAME") {
 			return ErrObjectNameAlreadyExists
 		}
+		return
 	}
 
+	db.leases.release(object.ID)
 	return
 }
 
 // UpdateObject updates a object's information
 func (db Database) UpdateObject(object types.Object) (err error) {
+	object.Tags = types.NormaliseTags(object.Tags)
 	if err = object.Validate(); err != nil {
 		return
 	}
 
-	err = db.objects.Update(bson.M{"id": object.ID}, object)
+	update, err := objectUpdate(object)
+	if err != nil {
+		return
+	}
+	err = db.objects.Update(bson.M{"id": object.ID}, update)
+	if err != nil {
+		return
+	}
+
+	db.leases.release(object.ID)
 	return
 }
 
-// PutObjectFile uploads a file to an object's folder in S3 from an io.Reader
-func (db Database) PutObjectFile(objectID types.ObjectID, filename string, reader io.Reader) (err error) {
-	if err = objectID.Validate(); err != nil {
-		return
+// ratingFields are the counters that AddRating and RemoveRating increment in place, an update
+// carries whatever the caller read earlier so they are left out of it
+var ratingFields = []string{"ratecount", "ratetotal", "ratehistogram"}
+
+// optionalFields are the object fields that are omitted when empty and so have to be unset
+// explicitly, otherwise an update could never clear them
+var optionalFields = []string{"files", "versions", "deleted"}
+
+// objectUpdate turns a document into a $set of every field except the rating counters
+func objectUpdate(document interface{}) (update bson.M, err error) {
+	raw, err := bson.Marshal(document)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encode object")
+	}
+	fields := bson.M{}
+	err = bson.Unmarshal(raw, &fields)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encode object")
 	}
 
-	_, err = db.store.PutObject(
-		db.StoreBucket,
-		filepath.Join(string(objectID), filename),
-		reader,
-		"application/octet-stream")
+	for _, key := range ratingFields {
+		delete(fields, key)
+	}
+	update = bson.M{"$set": fields}
 
+	unset := bson.M{}
+	for _, key := range optionalFields {
+		if _, ok := fields[key]; !ok {
+			unset[key] = ""
+		}
+	}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
 	return
 }
 
-// GetObjectThumb writes the first image from an object to the given writer
-func (db Database) GetObjectThumb(objectID types.ObjectID, writer io.Writer) (err error) {
+// PutObjectFile uploads a file to an object's folder in the file store from an io.Reader and
+// returns its size and checksums
+func (db Database) PutObjectFile(objectID types.ObjectID, filename, contentType string, reader io.Reader) (info types.FileInfo, err error) {
+	return putObjectFile(db.blobs, db.leases, objectID, filename, contentType, reader)
+}
+
+// GetObjectThumb writes a thumbnail of the first image from an object to the given writer
+func (db Database) GetObjectThumb(objectID types.ObjectID, size uint, writer io.Writer) (err error) {
 	if err = objectID.Validate(); err != nil {
 		err = errors.Wrap(err, "invalid object ID format")
 		return
@@ -74,93 +114,327 @@ func (db Database) GetObjectThumb(objectID types.ObjectID, writer io.Writer) (er
 		return
 	}
 
-	storeObject, err := db.store.GetObject(
-		db.StoreBucket,
-		filepath.Join(string(objectID), string(tmpObject.Images[0])))
+	return getObjectThumb(db.blobs, tmpObject, size, writer)
+}
+
+// GetObjectFile writes the specified file from a version of an object to the given writer
+func (db Database) GetObjectFile(objectID types.ObjectID, version int, fileName types.File, writer io.Writer) (err error) {
+	if err = objectID.Validate(); err != nil {
+		err = errors.Wrap(err, "invalid object ID format")
+		return
+	}
+
+	tmpObject := types.Object{}
+	err = db.objects.Find(bson.M{"id": objectID}).One(&tmpObject)
 	if err != nil {
-		err = errors.Wrap(err, "failed to get file from object store")
+		err = errors.Wrapf(err, "failed to lookup object %s", string(objectID))
+		return
+	}
+
+	return getObjectFile(db.blobs, tmpObject, version, fileName, writer)
+}
+
+// OpenObjectFile opens the specified file from a version of an object along with its stat info
+// from the blob store, the caller must close it
+func (db Database) OpenObjectFile(objectID types.ObjectID, version int, fileName types.File) (blob Blob, info BlobInfo, err error) {
+	if err = objectID.Validate(); err != nil {
+		err = errors.Wrap(err, "invalid object ID format")
 		return
 	}
 
-	img, err := jpeg.Decode(storeObject)
+	tmpObject := types.Object{}
+	err = db.objects.Find(bson.M{"id": objectID}).One(&tmpObject)
 	if err != nil {
-		return errors.Wrap(err, "failed to decode stored image")
+		err = errors.Wrapf(err, "failed to lookup object %s", string(objectID))
+		return
 	}
-	err = jpeg.Encode(writer, resize.Thumbnail(200, 200, img, resize.Bilinear), &jpeg.Options{Quality: 64})
 
-	return
+	return openObjectFile(db.blobs, tmpObject, version, fileName)
 }
 
-// GetObjectFile writes the specified image file from an object to the given writer
-func (db Database) GetObjectFile(objectID types.ObjectID, fileName types.File, writer io.Writer) (err error) {
+// GetTexturePreview writes a texture from a texture dictionary of an object to the given writer
+// as a PNG
+func (db Database) GetTexturePreview(objectID types.ObjectID, version int, fileName types.File, texture string, width, height uint, writer io.Writer) (err error) {
 	if err = objectID.Validate(); err != nil {
 		err = errors.Wrap(err, "invalid object ID format")
 		return
 	}
 
-	storeObject, err := db.store.GetObject(
-		db.StoreBucket,
-		filepath.Join(string(objectID), string(fileName)))
+	object := types.Object{}
+	err = db.objects.Find(bson.M{"id": objectID}).One(&object)
 	if err != nil {
-		err = errors.Wrap(err, "failed to get file from object store")
+		err = errors.Wrapf(err, "failed to lookup object %s", string(objectID))
 		return
 	}
 
-	_, err = io.Copy(writer, storeObject)
+	return getTexturePreview(db.blobs, object, version, fileName, texture, width, height, writer)
+}
 
-	return
+// GetObjectImage writes the web version of one of the images from a version of an object to the
+// given writer
+func (db Database) GetObjectImage(objectID types.ObjectID, version int, fileName types.File, writer io.Writer) (err error) {
+	if err = objectID.Validate(); err != nil {
+		err = errors.Wrap(err, "invalid object ID format")
+		return
+	}
+
+	object := types.Object{}
+	err = db.objects.Find(bson.M{"id": objectID}).One(&object)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to lookup object %s", string(objectID))
+		return
+	}
+
+	return getWebImage(db.blobs, object, version, fileName, writer)
 }
 
-// DeleteObject deletes a object
+// DeleteObject deletes a object and any of its files that no other object shares
 func (db Database) DeleteObject(objectID types.ObjectID) (err error) {
+	return db.deleteObject(objectID, bson.M{"id": objectID})
+}
+
+// PurgeObject deletes a trashed object and its files if it was trashed at or before the given time
+func (db Database) PurgeObject(objectID types.ObjectID, before time.Time) (err error) {
+	return db.deleteObject(objectID, bson.M{"id": objectID, "deleted": bson.M{"$lte": before}})
+}
+
+func (db Database) deleteObject(objectID types.ObjectID, selector bson.M) (err error) {
 	if err = objectID.Validate(); err != nil {
 		return
 	}
 
-	doneCh := make(chan struct{})
-	infoCh := db.store.ListObjects(db.StoreBucket, string(objectID), true, doneCh)
-	for object := range infoCh {
-		err = db.store.RemoveObject(db.StoreBucket, object.Key)
+	tmpObject := types.Object{}
+	err = db.objects.Find(selector).One(&tmpObject)
+	if err != nil {
+		return
+	}
+
+	err = db.objects.Remove(selector)
+	if err != nil {
+		return
+	}
+
+	return removeObjectFiles(db.blobs, db.leases, tmpObject, db.blobReferenced)
+}
+
+// DiscardObjectFiles removes the files stored for an object that was never created, such as an
+// upload that failed part way through, unless another object shares them
+func (db Database) DiscardObjectFiles(objectID types.ObjectID, files []types.FileInfo) (err error) {
+	if err = objectID.Validate(); err != nil {
+		return
+	}
+	db.leases.release(objectID)
+	return removeObjectFiles(db.blobs, db.leases, types.Object{ID: objectID, Files: files}, db.blobReferenced)
+}
+
+// blobReferenced reports whether any object has a file with the given contents
+func (db Database) blobReferenced(sha256 string) (bool, error) {
+	count, err := db.objects.Find(bson.M{"$or": []bson.M{
+		{"files.sha256": sha256},
+		{"versions.files.sha256": sha256},
+	}}).Count()
+	return count > 0, err
+}
+
+// DedupReport calculates how much space is saved by objects sharing files
+func (db Database) DedupReport() (report DedupReport, err error) {
+	var (
+		files  []types.FileInfo
+		object types.Object
+	)
+	iter := db.objects.Find(nil).Select(bson.M{"files": 1, "versions": 1}).Iter()
+	for iter.Next(&object) {
+		files = append(files, historyFiles(object)...)
+		object.Files, object.Versions = nil, nil
+	}
+	err = iter.Close()
+	if err != nil {
+		return
+	}
+
+	return dedupReport(files), nil
+}
+
+// GetObjects returns a list of objects based on query parameters, sort is a MongoDB field name
+// with an optional "-" prefix, "score" to rank by confidence adjusted average rating or "relevance"
+// to rank search results by their text score
+func (db Database) GetObjects(query ObjectQuery, page PageQuery) (result ObjectPage, err error) {
+	filter, err := db.objectFilter(query)
+	if err != nil {
+		return
+	}
+
+	result.Total, err = db.objects.Find(filter).Count()
+	if err != nil {
+		return
+	}
+
+	sort := query.objectSort()
+	pipeline := []bson.M{{"$match": filter}}
+	var prior float64
+	switch sort.field {
+	case "score":
+		prior, err = scorePrior(page.Cursor, db.ratingPrior)
 		if err != nil {
 			return
 		}
+		pipeline = append(pipeline, bson.M{"$addFields": bson.M{"score": mongoScore(prior)}})
+	case SortRelevance:
+		pipeline = append(pipeline, bson.M{"$addFields": bson.M{SortRelevance: bson.M{"$meta": "textScore"}}})
+	}
+	if page.Cursor != "" {
+		var after bson.M
+		after, err = mongoAfter(sort, page.Cursor)
+		if err != nil {
+			return
+		}
+		pipeline = append(pipeline, bson.M{"$match": after})
+	}
+	pipeline = append(pipeline, bson.M{"$sort": mongoSort(sort)})
+	if limit := page.fetchLimit(); limit > 0 {
+		pipeline = append(pipeline, bson.M{"$limit": limit})
 	}
 
-	err = db.objects.Remove(bson.M{"id": objectID})
+	var stored []storedObject
+	err = db.objects.Pipe(pipeline).All(&stored)
 	if err != nil {
 		return
 	}
 
+	if page.Limit > 0 && len(stored) > page.Limit {
+		stored = stored[:page.Limit]
+		last := stored[len(stored)-1]
+		next := keyset{ID: last.DocumentID.Hex()}
+		if sort.field == "score" {
+			next.Key = last.Score
+			next.Prior = &prior
+		} else if sort.field == SortRelevance {
+			next.Key = last.Relevance
+		} else if sort.field != "_id" {
+			next.Key = objectSortKey(last.Object, sort.field, objectKeys{})
+		}
+		result.Next = next.encode()
+	}
+
+	result.Objects = make([]types.Object, len(stored))
+	for i := range stored {
+		result.Objects[i] = stored[i].Object
+		result.Objects[i].UpdateRateAverage()
+	}
 	return
 }
 
-// GetObjects returns a list of objects based on query parameters
-func (db Database) GetObjects(
-	userName types.UserName,
-	category types.ObjectCategory,
-	tags []string,
-	sort string,
-) (objects []types.Object, err error) {
-	query := bson.M{}
+// objectFilter returns the selector for the objects matching a query
+func (db Database) objectFilter(query ObjectQuery) (filter bson.M, err error) {
+	filter = bson.M{"deleted": notTrashed}
 
-	if userName != "" {
-		query["ownername"] = userName
+	if query.UserName != "" {
+		filter["ownername"] = query.UserName
 	}
 
-	if category != "" {
-		query["category"] = category
+	if query.Category != "" {
+		filter["category"] = query.Category
 	}
 
-	if len(tags) > 0 {
-		query["tags"] = bson.M{"$in": tags}
+	if len(query.Tags) > 0 {
+		var aliases []types.TagAlias
+		aliases, err = db.GetTagAliases()
+		if err != nil {
+			return
+		}
+		if tags := expandTags(aliases, query.Tags); len(tags) > 0 {
+			filter["tags"] = bson.M{"$in": tags}
+		}
 	}
 
-	err = db.objects.Find(query).Sort(sort).All(&objects)
+	if words := parseSearch(query.Search); len(words) > 0 {
+		filter["$text"] = bson.M{"$search": mongoTextSearch(words)}
+	}
+	return
+}
+
+// mongoTextSearch returns the $text search for the words of a query, each word is quoted as a
+// phrase so every one of them has to match rather than any
+func mongoTextSearch(words []string) string {
+	phrases := make([]string, len(words))
+	for i, word := range words {
+		phrases[i] = `"` + word + `"`
+	}
+	return strings.Join(phrases, " ")
+}
+
+// objectTextIndex is the text index searches use, names count for more than tags, which count for
+// more than descriptions. Words are matched as they are without stemming or stop words so searches
+// for object names work the same in any language.
+var objectTextIndex = mgo.Index{
+	Name:            "OBJECT_TEXT",
+	Key:             []string{"$text:name", "$text:tags", "$text:description"},
+	Weights:         map[string]int{"name": 3, "tags": 2, "description": 1},
+	DefaultLanguage: "none",
+}
+
+// storedObject is an object as it is read from the objects collection along with the document ID,
+// which orders objects by when they were created, and the score or relevance when sorting by it
+type storedObject struct {
+	types.Object `bson:",inline"`
+	DocumentID   bson.ObjectId `bson:"_id"`
+	Score        float64       `bson:"score"`
+	Relevance    float64       `bson:"relevance"`
+}
+
+// mongoScore is the expression for the confidence adjusted average rating of an object
+func mongoScore(prior float64) bson.M {
+	return bson.M{"$divide": []interface{}{
+		bson.M{"$add": []interface{}{ratingConfidence * prior, "$ratetotal"}},
+		bson.M{"$add": []interface{}{ratingConfidence, "$ratecount"}},
+	}}
+}
+
+// mongoSort returns the $sort stage for a sort, objects with equal keys are ordered by _id
+func mongoSort(sort objectSort) bson.D {
+	direction := func(descending bool) int {
+		if descending {
+			return -1
+		}
+		return 1
+	}
+	if sort.field == "_id" {
+		return bson.D{{Name: "_id", Value: direction(sort.descending)}}
+	}
+	return bson.D{
+		{Name: sort.field, Value: direction(sort.descending)},
+		{Name: "_id", Value: direction(sort.tieDescending())},
+	}
+}
+
+// mongoAfter returns the filter for the objects that come after a cursor
+func mongoAfter(sort objectSort, cursor string) (filter bson.M, err error) {
+	k, err := decodeKeyset(cursor)
 	if err != nil {
 		return
 	}
+	hex, ok := k.ID.(string)
+	if !ok || !bson.IsObjectIdHex(hex) {
+		return nil, ErrInvalidCursor
+	}
+	id := bson.ObjectIdHex(hex)
+	if err = sort.checkKey(k); err != nil {
+		return
+	}
 
-	return
+	op := func(descending bool) string {
+		if descending {
+			return "$lt"
+		}
+		return "$gt"
+	}
+	if sort.field == "_id" {
+		return bson.M{"_id": bson.M{op(sort.descending): id}}, nil
+	}
+	return bson.M{"$or": []bson.M{
+		{sort.field: bson.M{op(sort.descending): k.Key}},
+		{sort.field: k.Key, "_id": bson.M{op(sort.tieDescending()): id}},
+	}}, nil
 }
 
 // GetObject returns a types.Object by their unique ID
@@ -169,6 +443,7 @@ func (db Database) GetObject(objectID types.ObjectID) (object types.Object, err
 	if err != nil {
 		return
 	}
+	object.UpdateRateAverage()
 
 	return
 }
@@ -179,10 +454,13 @@ func (db Database) GetUserObjects(userName types.UserName) (objects []types.Obje
 		return
 	}
 
-	err = db.objects.Find(bson.M{"ownername": userName}).All(&objects)
+	err = db.objects.Find(bson.M{"ownername": userName, "deleted": notTrashed}).All(&objects)
 	if err != nil {
 		return
 	}
+	for i := range objects {
+		objects[i].UpdateRateAverage()
+	}
 
 	return
 }
@@ -196,14 +474,37 @@ func (db Database) GetUserObject(userName types.UserName, objectName types.Objec
 	// 	return
 	// }
 
-	err = db.objects.Find(bson.M{"name": objectName, "ownername": userName}).One(&object)
+	err = db.objects.Find(bson.M{"name": objectName, "ownername": userName, "deleted": notTrashed}).One(&object)
 	if err != nil {
 		return
 	}
+	object.UpdateRateAverage()
 
 	return
 }
 
+// TrashObject soft deletes an object, it keeps its name and files until it is purged
+func (db Database) TrashObject(objectID types.ObjectID) (err error) {
+	if err = objectID.Validate(); err != nil {
+		return
+	}
+
+	return db.objects.Update(
+		bson.M{"id": objectID, "deleted": notTrashed},
+		bson.M{"$set": bson.M{"deleted": time.Now()}})
+}
+
+// RestoreObject takes an object back out of the trash
+func (db Database) RestoreObject(objectID types.ObjectID) (err error) {
+	if err = objectID.Validate(); err != nil {
+		return
+	}
+
+	return db.objects.Update(
+		bson.M{"id": objectID, "deleted": trashed},
+		bson.M{"$unset": bson.M{"deleted": ""}})
+}
+
 // ObjectExists checks if an object exists by their unique ID
 func (db Database) ObjectExists(objectID types.ObjectID) (exists bool, err error) {
 	count, err := db.objects.Find(bson.M{"id": objectID}).Count()
@@ -1,10 +1,15 @@
 package storage
 
 import (
-	"path/filepath"
+	"bytes"
+	"io/ioutil"
 	"reflect"
+	"strings"
 	"testing"
 
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+
 	"github.com/Southclaws/samp-objects-api/types"
 )
 
@@ -16,9 +21,9 @@ func must(err error, rest ...interface{}) {
 
 // Create some dummy users to own objects
 func TestDatabase_ObjectOwners(t *testing.T) {
-	must(db.CreateUser(types.User{"00000001-0000-0000-0000-000000000000", "owner1", "ownermail1", "pass1"}))
-	must(db.CreateUser(types.User{"00000002-0000-0000-0000-000000000000", "owner2", "ownermail2", "pass2"}))
-	must(db.CreateUser(types.User{"00000003-0000-0000-0000-000000000000", "owner3", "ownermail3", "pass3"}))
+	must(db.CreateUser(types.User{ID: "00000001-0000-0000-0000-000000000000", Name: "owner1", Email: "ownermail1", Password: "pass1"}))
+	must(db.CreateUser(types.User{ID: "00000002-0000-0000-0000-000000000000", Name: "owner2", Email: "ownermail2", Password: "pass2"}))
+	must(db.CreateUser(types.User{ID: "00000003-0000-0000-0000-000000000000", Name: "owner3", Email: "ownermail3", Password: "pass3"}))
 }
 
 func TestDatabase_CreateObject(t *testing.T) {
@@ -253,15 +258,15 @@ func TestDatabase_DeleteObject(t *testing.T) {
 			}
 
 			if !tt.wantErr {
-				// ensure files are present in S3
-				_, err := db.store.StatObject(db.StoreBucket, filepath.Join("/", string(tt.args.objectID), "test_model.dff"))
+				// ensure files are no longer present in the file store
+				err := db.GetObjectFile(tt.args.objectID, 0, "test_model.dff", ioutil.Discard)
 				if err == nil {
-					panic(err)
+					t.Errorf("Database.DeleteObject() left test_model.dff behind")
 				}
 
-				_, err = db.store.StatObject(db.StoreBucket, filepath.Join("/", string(tt.args.objectID), "test_texture.txd"))
+				err = db.GetObjectFile(tt.args.objectID, 0, "test_texture.txd", ioutil.Discard)
 				if err == nil {
-					panic(err)
+					t.Errorf("Database.DeleteObject() left test_texture.txd behind")
 				}
 			}
 		})
@@ -383,3 +388,237 @@ func TestDatabase_ObjectExists(t *testing.T) {
 		})
 	}
 }
+
+func TestDatabase_ObjectVersions(t *testing.T) {
+	objectID := types.ObjectID("00000000-0000-0000-0000-400000000000")
+	upload := func(version string) types.ObjectVersion {
+		image, err := db.PutObjectFile(objectID, "image.jpg", "image/jpeg", strings.NewReader("image"))
+		assert.NoError(t, err)
+		model, err := db.PutObjectFile(objectID, "model.dff", "application/octet-stream", strings.NewReader("model "+version))
+		assert.NoError(t, err)
+		texture, err := db.PutObjectFile(objectID, "texture.txd", "application/octet-stream", strings.NewReader("texture"))
+		assert.NoError(t, err)
+		return types.ObjectVersion{
+			Changelog: version,
+			Images:    []types.File{"image.jpg"},
+			Models:    []types.File{"model.dff"},
+			Textures:  []types.File{"texture.txd"},
+			Files:     []types.FileInfo{image, model, texture},
+		}
+	}
+
+	object := types.Object{
+		ID:        objectID,
+		OwnerID:   "00000003-0000-0000-0000-000000000000",
+		OwnerName: "owner3",
+		Name:      "versioned",
+		Category:  "category1",
+	}
+	object.PublishVersion(upload("first"))
+	assert.NoError(t, db.CreateObject(object))
+
+	object, err := db.GetObject(objectID)
+	assert.NoError(t, err)
+	object.PublishVersion(upload("second"))
+	assert.NoError(t, db.UpdateObject(object))
+
+	object, err = db.GetObject(objectID)
+	assert.NoError(t, err)
+	assert.Len(t, object.Versions, 2)
+	assert.Equal(t, 2, object.Versions[1].Version)
+	assert.Equal(t, "second", object.Versions[1].Changelog)
+
+	for version, want := range map[int]string{0: "model second", 1: "model first", 2: "model second"} {
+		var buf bytes.Buffer
+		assert.NoError(t, db.GetObjectFile(objectID, version, "model.dff", &buf))
+		assert.Equal(t, want, buf.String())
+	}
+	assert.Error(t, db.GetObjectFile(objectID, 3, "model.dff", ioutil.Discard))
+
+	blob, info, err := db.OpenObjectFile(objectID, 1, "model.dff")
+	assert.NoError(t, err)
+	contents, err := ioutil.ReadAll(blob)
+	assert.NoError(t, err)
+	assert.NoError(t, blob.Close())
+	assert.Equal(t, "model first", string(contents))
+	assert.Equal(t, int64(len("model first")), info.Size)
+	_, _, err = db.OpenObjectFile(objectID, 0, "missing.dff")
+	assert.Equal(t, ErrBlobNotFound, errors.Cause(err))
+
+	report, err := db.DedupReport()
+	assert.NoError(t, err)
+	assert.Equal(t, 6, report.Files)
+	assert.Equal(t, 4, report.Blobs)
+
+	assert.NoError(t, db.DeleteObject(objectID))
+}
+
+func TestDatabase_DiscardObjectFiles(t *testing.T) {
+	kept := types.Object{
+		ID:        "00000000-0000-0000-0000-410000000000",
+		OwnerID:   "00000003-0000-0000-0000-000000000000",
+		OwnerName: "owner3",
+		Name:      "kept",
+		Category:  "category1",
+		Images:    []types.File{"shared.jpg"},
+		Models:    []types.File{"model.dff"},
+		Textures:  []types.File{"texture.txd"},
+	}
+	shared, err := db.PutObjectFile(kept.ID, "shared.jpg", "image/jpeg", strings.NewReader("shared"))
+	assert.NoError(t, err)
+	kept.Files = []types.FileInfo{shared}
+	assert.NoError(t, db.CreateObject(kept))
+
+	// an upload that failed stored a file it shares with an existing object and one of its own
+	failed := types.ObjectID("00000000-0000-0000-0000-420000000000")
+	sharedAgain, err := db.PutObjectFile(failed, "shared.jpg", "image/jpeg", strings.NewReader("shared"))
+	assert.NoError(t, err)
+	own, err := db.PutObjectFile(failed, "own.jpg", "image/jpeg", strings.NewReader("own"))
+	assert.NoError(t, err)
+	assert.NoError(t, db.DiscardObjectFiles(failed, []types.FileInfo{sharedAgain, own}))
+
+	var buf bytes.Buffer
+	assert.NoError(t, db.GetObjectFile(kept.ID, 0, "shared.jpg", &buf))
+	assert.Equal(t, "shared", buf.String())
+
+	// an object that claims the discarded file can't open it
+	orphan := types.Object{
+		ID:        failed,
+		OwnerID:   "00000003-0000-0000-0000-000000000000",
+		OwnerName: "owner3",
+		Name:      "orphan",
+		Category:  "category1",
+		Images:    []types.File{"own.jpg"},
+		Models:    []types.File{"model.dff"},
+		Textures:  []types.File{"texture.txd"},
+		Files:     []types.FileInfo{own},
+	}
+	assert.NoError(t, db.CreateObject(orphan))
+	_, _, err = db.OpenObjectFile(failed, 0, "own.jpg")
+	assert.Equal(t, ErrBlobNotFound, errors.Cause(err))
+
+	_, err = db.PutObjectFile(failed, "own.jpg", "image/jpeg", strings.NewReader("own"))
+	assert.NoError(t, err)
+	assert.NoError(t, db.DeleteObject(orphan.ID))
+	assert.NoError(t, db.DeleteObject(kept.ID))
+}
+
+func TestDatabase_DeleteObjectKeepsPendingUploads(t *testing.T) {
+	deleted := types.Object{
+		ID:        "00000000-0000-0000-0000-430000000000",
+		OwnerID:   "00000003-0000-0000-0000-000000000000",
+		OwnerName: "owner3",
+		Name:      "deleted",
+		Category:  "category1",
+		Images:    []types.File{"shared.jpg"},
+		Models:    []types.File{"model.dff"},
+		Textures:  []types.File{"texture.txd"},
+	}
+	shared, err := db.PutObjectFile(deleted.ID, "shared.jpg", "image/jpeg", strings.NewReader("pending"))
+	assert.NoError(t, err)
+	deleted.Files = []types.FileInfo{shared}
+	assert.NoError(t, db.CreateObject(deleted))
+
+	// an upload in progress finds the blob already stored, the only object using it is then
+	// deleted before the upload's object is created
+	uploaded := deleted
+	uploaded.ID = "00000000-0000-0000-0000-440000000000"
+	uploaded.Name = "uploaded"
+	info, err := db.PutObjectFile(uploaded.ID, "shared.jpg", "image/jpeg", strings.NewReader("pending"))
+	assert.NoError(t, err)
+	uploaded.Files = []types.FileInfo{info}
+	assert.NoError(t, db.DeleteObject(deleted.ID))
+	assert.NoError(t, db.CreateObject(uploaded))
+
+	var buf bytes.Buffer
+	assert.NoError(t, db.GetObjectFile(uploaded.ID, 0, "shared.jpg", &buf))
+	assert.Equal(t, "pending", buf.String())
+
+	assert.NoError(t, db.DeleteObject(uploaded.ID))
+}
+
+func TestDatabase_SearchObjects(t *testing.T) {
+	objects := []types.Object{
+		{ID: "00000000-0000-0000-0000-600000000000", Name: "pier", Description: "wooden walkway", Category: "category1"},
+		{ID: "00000000-0000-0000-0000-610000000000", Name: "searchdock", Description: "a pier and a crane", Category: "category2", Tags: []types.ObjectTag{"harbour"}},
+	}
+	for _, object := range objects {
+		object.OwnerID = "00000003-0000-0000-0000-000000000000"
+		object.OwnerName = "owner3"
+		object.Images = []types.File{"image.jpg"}
+		object.Models = []types.File{"model.dff"}
+		object.Textures = []types.File{"texture.txd"}
+		assert.NoError(t, db.CreateObject(object))
+	}
+
+	search := func(query ObjectQuery) (ids []types.ObjectID) {
+		got, err := db.GetObjects(query, PageQuery{})
+		assert.NoError(t, err)
+		for _, object := range got.Objects {
+			ids = append(ids, object.ID)
+		}
+		return
+	}
+
+	assert.Equal(t, []types.ObjectID{objects[0].ID, objects[1].ID}, search(ObjectQuery{Search: "pier"}))
+	assert.Equal(t, []types.ObjectID{objects[1].ID, objects[0].ID}, search(ObjectQuery{Search: "pier", Sort: "-_id"}))
+	assert.Equal(t, []types.ObjectID{objects[1].ID}, search(ObjectQuery{Search: "harbour pier"}))
+	assert.Empty(t, search(ObjectQuery{Search: "harbor pier"}))
+	if _, ok := db.(*Database); !ok {
+		// MongoDB's text index only matches whole words
+		assert.Equal(t, []types.ObjectID{objects[1].ID}, search(ObjectQuery{Search: "harb pie"}))
+	}
+
+	// ranked results are paged by relevance
+	first, err := db.GetObjects(ObjectQuery{Search: "pier"}, PageQuery{Limit: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, first.Total)
+	if assert.Len(t, first.Objects, 1) {
+		assert.Equal(t, objects[0].ID, first.Objects[0].ID)
+	}
+	second, err := db.GetObjects(ObjectQuery{Search: "pier"}, PageQuery{Limit: 1, Cursor: first.Next})
+	assert.NoError(t, err)
+	if assert.Len(t, second.Objects, 1) {
+		assert.Equal(t, objects[1].ID, second.Objects[0].ID)
+	}
+	assert.Empty(t, second.Next)
+	assert.Equal(t, []types.ObjectID{objects[1].ID}, search(ObjectQuery{Search: "pier", Category: "category2"}))
+	assert.Empty(t, search(ObjectQuery{Search: "pier", UserName: "owner1"}))
+
+	objects[0].Description = "stone jetty"
+	object, err := db.GetObject(objects[0].ID)
+	assert.NoError(t, err)
+	object.Description = objects[0].Description
+	assert.NoError(t, db.UpdateObject(object))
+	assert.Equal(t, []types.ObjectID{objects[0].ID}, search(ObjectQuery{Search: "jetty"}))
+
+	for _, object := range objects {
+		assert.NoError(t, db.DeleteObject(object.ID))
+	}
+	assert.Empty(t, search(ObjectQuery{Search: "pier"}))
+}
+
+func Test_copyObject(t *testing.T) {
+	files := func() []types.FileInfo {
+		return []types.FileInfo{
+			{Name: "model.dff", Model: &types.ModelInfo{Frames: []types.ModelFrame{{Name: "root", Parent: -1}}, Textures: []string{"wood"}}},
+			{Name: "texture.txd", Textures: []types.TextureInfo{{Name: "wood"}}},
+		}
+	}
+	object := types.Object{
+		Files:    files(),
+		Versions: []types.ObjectVersion{{Version: 1, Models: []types.File{"model.dff"}, Files: files()}},
+	}
+
+	// changing a copy leaves the stored object as it was
+	copied := copyObject(object)
+	copied.Files[0].Model.Textures[0] = "stone"
+	copied.Files[1].Textures[0].Name = "stone"
+	copied.Versions[0].Models[0] = "other.dff"
+	copied.Versions[0].Files[0].Model.Frames[0].Name = "other"
+	copied.Versions[0].Version = 2
+	assert.Equal(t, types.Object{
+		Files:    files(),
+		Versions: []types.ObjectVersion{{Version: 1, Models: []types.File{"model.dff"}, Files: files()}},
+	}, object)
+}
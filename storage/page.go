@@ -0,0 +1,372 @@
+package storage
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/mgo.v2/bson"
+
+	"github.com/Southclaws/samp-objects-api/types"
+)
+
+// ErrInvalidCursor is returned when a page cursor was not created by a previous listing
+var ErrInvalidCursor = errors.New("invalid page cursor")
+
+// PageQuery selects one page of a listing, Cursor is the Next value from the previous page and a
+// Limit of zero returns every remaining item
+type PageQuery struct {
+	Limit  int
+	Cursor string
+}
+
+// Page describes where a page sits in a listing
+type Page struct {
+	Total int    `json:"total"`          // number of items on every page together
+	Next  string `json:"next,omitempty"` // cursor for the following page, empty on the last page
+}
+
+// ObjectPage is one page of a list of objects
+type ObjectPage struct {
+	Page
+	Objects []types.Object `json:"objects"`
+}
+
+// CommentPage is one page of a list of comments
+type CommentPage struct {
+	Page
+	Comments []types.Comment `json:"comments"`
+}
+
+// RatingPage is one page of a list of ratings
+type RatingPage struct {
+	Page
+	Ratings []types.Rating `json:"ratings"`
+}
+
+// keyset is the last item of a page of a listing, the next page is every item that sorts after its
+// key and, between items with the same key, after its ID. IDs are the order items were stored in so
+// they are unique and a page never shifts when items are added or removed anywhere in the listing.
+type keyset struct {
+	Key   interface{} `json:"k,omitempty"`
+	ID    interface{} `json:"i"`
+	Prior *float64    `json:"p,omitempty"` // the rating prior of a "score" listing, see scorePrior
+}
+
+// encode turns a keyset into the opaque cursor string handed to clients
+func (k keyset) encode() string {
+	raw, _ := json.Marshal(k)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// decodeKeyset reads a cursor created by keyset.encode
+func decodeKeyset(s string) (k keyset, err error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return k, ErrInvalidCursor
+	}
+	if json.Unmarshal(raw, &k) != nil || k.ID == nil {
+		return k, ErrInvalidCursor
+	}
+	return
+}
+
+// fetchLimit is the number of items a backend asks the database for, one more than the page holds
+// so it can tell if there is a page after it. Zero means no limit.
+func (query PageQuery) fetchLimit() int {
+	if query.Limit <= 0 {
+		return 0
+	}
+	return query.Limit + 1
+}
+
+// objectSort is the order of an object listing: a stored field, "_id" for the order objects were
+// created in, "score" for the confidence adjusted average rating or "relevance" for how well
+// objects match a search. Objects with the same value are ordered by creation, oldest first except
+// for "score" and "relevance" where the newest come first.
+type objectSort struct {
+	field      string
+	descending bool
+}
+
+// objectSortFields are the fields objects can be sorted by and whether their keys are numbers
+var objectSortFields = map[string]bool{
+	"_id":       true,
+	"id":        false,
+	"name":      false,
+	"ownername": false,
+	"category":  false,
+	"ratecount": true,
+	"ratetotal": true,
+	"score":     true,
+}
+
+// parseObjectSort reads a sort such as "-name", unknown fields sort by creation
+func parseObjectSort(sort string) objectSort {
+	field := strings.TrimLeft(sort, "+-")
+	if _, ok := objectSortFields[field]; !ok {
+		field = "_id"
+	}
+	return objectSort{field, strings.HasPrefix(sort, "-")}
+}
+
+// tieDescending reports whether objects with equal keys are ordered newest first
+func (sort objectSort) tieDescending() bool {
+	if sort.field == "_id" {
+		return sort.descending
+	}
+	return sort.field == "score" || sort.field == SortRelevance
+}
+
+// numeric reports whether the keys of a sort are numbers
+func (sort objectSort) numeric() bool {
+	return objectSortFields[sort.field] || sort.field == SortRelevance
+}
+
+// checkKey makes sure the key of a cursor is of the type the sort field needs, cursors are given
+// back by clients so they can't be trusted. Keys for "_id" are checked by the backends.
+func (sort objectSort) checkKey(k keyset) error {
+	if sort.field == "_id" {
+		return nil
+	}
+	var ok bool
+	if sort.numeric() {
+		_, ok = k.Key.(float64)
+	} else {
+		_, ok = k.Key.(string)
+	}
+	if !ok {
+		return ErrInvalidCursor
+	}
+	return nil
+}
+
+// after reports whether an item with keyset k comes after the cursor c in a listing in this order,
+// the same comparison the database backends make in their queries
+func (sort objectSort) after(k, c keyset) bool {
+	if sort.field == "_id" {
+		return follows(compareKeys(k.ID, c.ID), sort.descending)
+	}
+	if order := compareKeys(k.Key, c.Key); order != 0 {
+		return follows(order, sort.descending)
+	}
+	return follows(compareKeys(k.ID, c.ID), sort.tieDescending())
+}
+
+// follows reports whether a comparison puts an item after another in ascending or descending order
+func follows(order int, descending bool) bool {
+	if descending {
+		return order < 0
+	}
+	return order > 0
+}
+
+// compareKeys compares two sort keys, numbers come back from a cursor as float64
+func compareKeys(a, b interface{}) int {
+	switch a := a.(type) {
+	case string:
+		b, _ := b.(string)
+		return strings.Compare(a, b)
+	case float64:
+		b, _ := b.(float64)
+		switch {
+		case a < b:
+			return -1
+		case a > b:
+			return 1
+		}
+	}
+	return 0
+}
+
+// scorePrior returns the rating prior a "score" listing is ranked with. The pages after the first
+// use the prior frozen in the cursor, a rating made in between changes the current prior and with
+// it the score of every object, which would make pages overlap or skip objects.
+func scorePrior(cursor string, current func() (float64, error)) (float64, error) {
+	if cursor == "" {
+		return current()
+	}
+	k, err := decodeKeyset(cursor)
+	if err != nil {
+		return 0, err
+	}
+	if k.Prior == nil {
+		return 0, ErrInvalidCursor
+	}
+	return *k.Prior, nil
+}
+
+// pageObjects cuts a page out of a list of objects in the order given by query for the memory
+// backend, the page starts after the cursor the same way it does in the database backends
+func pageObjects(objects []types.Object, query ObjectQuery, pageQuery PageQuery, keys objectKeys) (page ObjectPage, err error) {
+	sort := query.objectSort()
+	keysetOf := func(object types.Object) keyset {
+		k := keyset{ID: keys.seq(object.ID)}
+		if sort.field != "_id" {
+			k.Key = objectSortKey(object, sort.field, keys)
+		}
+		if sort.field == "score" {
+			k.Prior = &keys.prior
+		}
+		return k
+	}
+
+	page.Total = len(objects)
+	if pageQuery.Cursor != "" {
+		var after keyset
+		after, err = decodeKeyset(pageQuery.Cursor)
+		if err != nil {
+			return
+		}
+		if _, ok := after.ID.(float64); !ok {
+			return page, ErrInvalidCursor
+		}
+		if err = sort.checkKey(after); err != nil {
+			return
+		}
+		for len(objects) > 0 && !sort.after(keysetOf(objects[0]), after) {
+			objects = objects[1:]
+		}
+	}
+
+	if pageQuery.Limit > 0 && len(objects) > pageQuery.Limit {
+		objects = objects[:pageQuery.Limit]
+		page.Next = keysetOf(objects[len(objects)-1]).encode()
+	}
+	page.Objects = append([]types.Object{}, objects...)
+	return
+}
+
+// objectKeys holds what a backend knows about objects beyond their fields that they can be sorted by
+type objectKeys struct {
+	seq   func(types.ObjectID) interface{} // the order objects were created in, sorted by "_id"
+	prior float64                          // the rating prior "score" is calculated with
+	words []string                         // the search "relevance" is calculated for
+}
+
+// objectSortKey returns the value of the field an object is sorted by
+func objectSortKey(object types.Object, field string, keys objectKeys) interface{} {
+	switch field {
+	case "_id":
+		if keys.seq != nil {
+			return keys.seq(object.ID)
+		}
+	case "score":
+		return bayesianScore(object.RateTotal, object.RateCount, keys.prior)
+	case SortRelevance:
+		score, _ := searchScore(object, keys.words)
+		return score
+	case "id":
+		return string(object.ID)
+	case "name":
+		return string(object.Name)
+	case "ownername":
+		return string(object.OwnerName)
+	case "category":
+		return string(object.Category)
+	case "ratecount":
+		return float64(object.RateCount)
+	case "ratetotal":
+		return float64(object.RateTotal)
+	}
+	return nil
+}
+
+// pageComments cuts a page out of a list of comments in the order they were posted, comment IDs
+// begin with their creation time so they are sorted by ID
+func pageComments(comments []types.Comment, pageQuery PageQuery) (page CommentPage, err error) {
+	sort.SliceStable(comments, func(i, j int) bool { return comments[i].ID.Hex() < comments[j].ID.Hex() })
+
+	page.Total = len(comments)
+	if pageQuery.Cursor != "" {
+		var after string
+		after, err = commentAfter(pageQuery.Cursor)
+		if err != nil {
+			return
+		}
+		for len(comments) > 0 && comments[0].ID.Hex() <= after {
+			comments = comments[1:]
+		}
+	}
+	page.Comments, page.Next = nextComments(append([]types.Comment{}, comments...), pageQuery)
+	return
+}
+
+// pageRatings cuts a page out of a list of ratings in the order they were made
+func pageRatings(ratings []types.Rating, pageQuery PageQuery) (page RatingPage, err error) {
+	sort.SliceStable(ratings, func(i, j int) bool { return ratingSortKey(ratings[i]) < ratingSortKey(ratings[j]) })
+
+	page.Total = len(ratings)
+	if pageQuery.Cursor != "" {
+		var (
+			date   time.Time
+			userID types.UserID
+		)
+		date, userID, err = ratingAfter(pageQuery.Cursor)
+		if err != nil {
+			return
+		}
+		for len(ratings) > 0 && (ratings[0].Date.Before(date) || ratings[0].Date.Equal(date) && ratings[0].UserID <= userID) {
+			ratings = ratings[1:]
+		}
+	}
+	page.Ratings, page.Next = nextRatings(append([]types.Rating{}, ratings...), pageQuery)
+	return
+}
+
+// commentAfter returns the ID of the last comment of the previous page from its cursor
+func commentAfter(cursor string) (id string, err error) {
+	k, err := decodeKeyset(cursor)
+	if err != nil {
+		return
+	}
+	id, ok := k.ID.(string)
+	if !ok || !bson.IsObjectIdHex(id) {
+		return "", ErrInvalidCursor
+	}
+	return
+}
+
+// nextComments trims the extra comment fetched past the end of a page and returns the cursor for
+// the page after it
+func nextComments(comments []types.Comment, query PageQuery) ([]types.Comment, string) {
+	if query.Limit <= 0 || len(comments) <= query.Limit {
+		return comments, ""
+	}
+	comments = comments[:query.Limit]
+	return comments, keyset{ID: comments[len(comments)-1].ID.Hex()}.encode()
+}
+
+// ratingAfter returns the date and user of the last rating of the previous page from its cursor
+func ratingAfter(cursor string) (date time.Time, userID types.UserID, err error) {
+	k, err := decodeKeyset(cursor)
+	if err != nil {
+		return
+	}
+	id, ok := k.ID.(string)
+	key, _ := k.Key.(string)
+	date, errDate := time.Parse(time.RFC3339Nano, key)
+	if !ok || errDate != nil {
+		return date, userID, ErrInvalidCursor
+	}
+	return date, types.UserID(id), nil
+}
+
+// nextRatings trims the extra rating fetched past the end of a page and returns the cursor for the
+// page after it
+func nextRatings(ratings []types.Rating, query PageQuery) ([]types.Rating, string) {
+	if query.Limit <= 0 || len(ratings) <= query.Limit {
+		return ratings, ""
+	}
+	ratings = ratings[:query.Limit]
+	last := ratings[len(ratings)-1]
+	return ratings, keyset{Key: last.Date.Format(time.RFC3339Nano), ID: string(last.UserID)}.encode()
+}
+
+// ratingSortKey orders ratings by date then by user
+func ratingSortKey(rating types.Rating) string {
+	return rating.Date.UTC().Format("2006-01-02T15:04:05.000000000") + "/" + string(rating.UserID)
+}
@@ -0,0 +1,185 @@
+package storage
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Southclaws/samp-objects-api/types"
+)
+
+func Test_pageObjects(t *testing.T) {
+	objects := []types.Object{{ID: "a", Name: "x"}, {ID: "b", Name: "y"}, {ID: "c", Name: "y"}, {ID: "d", Name: "z"}}
+	seq := map[types.ObjectID]float64{"0": 0, "a": 1, "b": 2, "c": 3, "d": 4}
+	keys := objectKeys{seq: func(id types.ObjectID) interface{} { return seq[id] }}
+	query := ObjectQuery{Sort: "name"}
+
+	page, err := pageObjects(objects, query, PageQuery{Limit: 2}, keys)
+	assert.NoError(t, err)
+	assert.Equal(t, []types.ObjectID{"a", "b"}, objectIDs(page.Objects))
+	assert.Equal(t, 4, page.Total)
+
+	// an object added before the cursor doesn't shift the next page
+	objects = append([]types.Object{{ID: "0", Name: "w"}}, objects...)
+	next, err := pageObjects(objects, query, PageQuery{Limit: 2, Cursor: page.Next}, keys)
+	assert.NoError(t, err)
+	assert.Equal(t, []types.ObjectID{"c", "d"}, objectIDs(next.Objects))
+	assert.Empty(t, next.Next)
+
+	// the last object of the page was removed, the next page starts after its key
+	objects = []types.Object{objects[0], objects[1], objects[4]}
+	next, err = pageObjects(objects, query, PageQuery{Limit: 2, Cursor: page.Next}, keys)
+	assert.NoError(t, err)
+	assert.Equal(t, []types.ObjectID{"d"}, objectIDs(next.Objects))
+
+	_, err = pageObjects(objects, query, PageQuery{Cursor: "nope"}, keys)
+	assert.Equal(t, ErrInvalidCursor, err)
+	_, err = pageObjects(objects, query, PageQuery{Cursor: keyset{Key: 1.0, ID: 1.0}.encode()}, keys)
+	assert.Equal(t, ErrInvalidCursor, err)
+
+	// the rating prior of a score listing is frozen in its cursor
+	prior, err := scorePrior("", func() (float64, error) { return 3, nil })
+	assert.NoError(t, err)
+	assert.Equal(t, 3.0, prior)
+	score := ObjectQuery{Sort: "-score"}
+	page, err = pageObjects(objects, score, PageQuery{Limit: 1}, objectKeys{seq: keys.seq, prior: prior})
+	assert.NoError(t, err)
+	prior, err = scorePrior(page.Next, func() (float64, error) { return 1, nil })
+	assert.NoError(t, err)
+	assert.Equal(t, 3.0, prior)
+	_, err = scorePrior(keyset{Key: 1.0, ID: 1.0}.encode(), func() (float64, error) { return 1, nil })
+	assert.Equal(t, ErrInvalidCursor, err)
+}
+
+func TestDatabase_Pagination(t *testing.T) {
+	var ids []types.ObjectID
+	for i := 0; i < 5; i++ {
+		object := types.Object{
+			ID:        types.ObjectID(fmt.Sprintf("00000000-0000-0000-0000-70000000000%d", i)),
+			OwnerID:   "00000003-0000-0000-0000-000000000000",
+			OwnerName: "owner3",
+			Name:      types.ObjectName(fmt.Sprintf("paged%d", 4-i)),
+			Category:  "paged",
+			Images:    []types.File{"image.jpg"},
+			Models:    []types.File{"model.dff"},
+			Textures:  []types.File{"texture.txd"},
+		}
+		assert.NoError(t, db.CreateObject(object))
+		ids = append(ids, object.ID)
+		assert.NoError(t, db.AddComment("00000003-0000-0000-0000-000000000000", ids[0], fmt.Sprint(i)))
+	}
+
+	var (
+		got    []types.ObjectID
+		cursor string
+	)
+	for pages := 0; pages < 3; pages++ {
+		page, err := db.GetObjects(ObjectQuery{Category: "paged", Sort: "name"}, PageQuery{Limit: 2, Cursor: cursor})
+		assert.NoError(t, err)
+		assert.Equal(t, 5-pages, page.Total)
+		for _, object := range page.Objects {
+			got = append(got, object.ID)
+		}
+		if cursor = page.Next; cursor == "" {
+			break
+		}
+		// removing an object that was already listed doesn't skip the next one
+		assert.NoError(t, db.DeleteObject(ids[4-pages]))
+	}
+	assert.Equal(t, []types.ObjectID{ids[4], ids[3], ids[2], ids[1], ids[0]}, got)
+	ids = ids[:3]
+
+	comments, err := db.GetComments(ids[0], PageQuery{Limit: 3})
+	assert.NoError(t, err)
+	assert.Equal(t, 5, comments.Total)
+	assert.Len(t, comments.Comments, 3)
+	assert.Equal(t, "0", comments.Comments[0].Content)
+	comments, err = db.GetComments(ids[0], PageQuery{Limit: 3, Cursor: comments.Next})
+	assert.NoError(t, err)
+	assert.Len(t, comments.Comments, 2)
+	assert.Equal(t, "3", comments.Comments[0].Content)
+	assert.Empty(t, comments.Next)
+
+	// newest first, an object created after the first page was read doesn't shift the next one
+	newest, err := db.GetObjects(ObjectQuery{Category: "paged", Sort: "-_id"}, PageQuery{Limit: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, []types.ObjectID{ids[2], ids[1]}, objectIDs(newest.Objects))
+	assert.NoError(t, db.CreateObject(types.Object{
+		ID:        "00000000-0000-0000-0000-700000000005",
+		OwnerID:   "00000003-0000-0000-0000-000000000000",
+		OwnerName: "owner3",
+		Name:      "paged5",
+		Category:  "paged",
+		Images:    []types.File{"image.jpg"},
+		Models:    []types.File{"model.dff"},
+		Textures:  []types.File{"texture.txd"},
+	}))
+	newest, err = db.GetObjects(ObjectQuery{Category: "paged", Sort: "-_id"}, PageQuery{Limit: 2, Cursor: newest.Next})
+	assert.NoError(t, err)
+	assert.Equal(t, 4, newest.Total)
+	assert.Equal(t, []types.ObjectID{ids[0]}, objectIDs(newest.Objects))
+	assert.Empty(t, newest.Next)
+	assert.NoError(t, db.DeleteObject("00000000-0000-0000-0000-700000000005"))
+
+	// objects with the same score are listed newest first
+	got = nil
+	cursor = ""
+	for pages := 0; pages < 3; pages++ {
+		page, err := db.GetObjects(ObjectQuery{Category: "paged", Sort: "-score"}, PageQuery{Limit: 1, Cursor: cursor})
+		assert.NoError(t, err)
+		got = append(got, objectIDs(page.Objects)...)
+		if cursor = page.Next; cursor == "" {
+			break
+		}
+	}
+	assert.Equal(t, []types.ObjectID{ids[2], ids[1], ids[0]}, got)
+
+	// a rating made between pages changes every score but not the ones the next page is ranked by
+	page, err := db.GetObjects(ObjectQuery{Category: "paged", Sort: "-score"}, PageQuery{Limit: 1})
+	assert.NoError(t, err)
+	got = objectIDs(page.Objects)
+	_, err = db.AddRating("00000003-0000-0000-0000-000000000000", ids[0], 1)
+	assert.NoError(t, err)
+	for cursor = page.Next; cursor != ""; cursor = page.Next {
+		page, err = db.GetObjects(ObjectQuery{Category: "paged", Sort: "-score"}, PageQuery{Limit: 1, Cursor: cursor})
+		if !assert.NoError(t, err) {
+			break
+		}
+		got = append(got, objectIDs(page.Objects)...)
+	}
+	assert.Equal(t, []types.ObjectID{ids[2], ids[1], ids[0]}, got)
+	assert.NoError(t, db.RemoveRating("00000003-0000-0000-0000-000000000000", ids[0]))
+
+	for _, userID := range []types.UserID{"00000003-0000-0000-0000-000000000000", "00000004-0000-0000-0000-000000000000"} {
+		_, err = db.AddRating(userID, ids[1], 3)
+		assert.NoError(t, err)
+	}
+	ratings, err := db.GetRatings(ids[1], PageQuery{Limit: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, ratings.Total)
+	assert.Len(t, ratings.Ratings, 1)
+	first := ratings.Ratings[0].UserID
+	ratings, err = db.GetRatings(ids[1], PageQuery{Limit: 1, Cursor: ratings.Next})
+	assert.NoError(t, err)
+	assert.Len(t, ratings.Ratings, 1)
+	assert.NotEqual(t, first, ratings.Ratings[0].UserID)
+	assert.Empty(t, ratings.Next)
+	for _, userID := range []types.UserID{"00000003-0000-0000-0000-000000000000", "00000004-0000-0000-0000-000000000000"} {
+		assert.NoError(t, db.RemoveRating(userID, ids[1]))
+	}
+
+	_, err = db.GetObjects(ObjectQuery{}, PageQuery{Cursor: "!"})
+	assert.Equal(t, ErrInvalidCursor, err)
+
+	for _, id := range ids {
+		assert.NoError(t, db.DeleteObject(id))
+	}
+}
+
+func objectIDs(objects []types.Object) (ids []types.ObjectID) {
+	for _, object := range objects {
+		ids = append(ids, object.ID)
+	}
+	return
+}
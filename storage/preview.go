@@ -0,0 +1,131 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"path"
+	"strings"
+
+	"github.com/nfnt/resize"
+	"github.com/pkg/errors"
+
+	"github.com/Southclaws/samp-objects-api/renderware"
+	"github.com/Southclaws/samp-objects-api/types"
+)
+
+// ErrTextureNotFound is returned when a preview is requested for a texture that isn't in the
+// texture dictionary, or for a file that isn't one of the object's texture dictionaries
+var ErrTextureNotFound = errors.New("texture not found")
+
+// previewKey returns the blob key a decoded texture is cached under, previews are stored next to
+// the texture dictionary they came from so they can be removed along with it. Textures are
+// identified by their position in the dictionary as their names are not safe to use in a key.
+func previewKey(key string, index int, width, height uint) string {
+	return path.Join("previews", key, fmt.Sprintf("%d-%dx%d.png", index, width, height))
+}
+
+// getTexturePreview writes a texture from one of the texture dictionaries in a version of object
+// to writer as a PNG, scaled to width and height if either is set with 0 keeping the aspect ratio.
+// Previews are cached in the blob store the first time they are decoded.
+func getTexturePreview(blobs BlobStore, object types.Object, version int, fileName types.File, texture string, width, height uint, writer io.Writer) (err error) {
+	object, ok := object.AtVersion(version)
+	if !ok {
+		return errors.Errorf("object has no version %d", version)
+	}
+	if !hasFile(object.Textures, fileName) {
+		return ErrTextureNotFound
+	}
+	key := fileKey(object, fileName)
+
+	// the inventory stored at upload saves reading the dictionary to find the cached preview
+	info, _ := object.FileInfo(fileName)
+	for index, t := range info.Textures {
+		if strings.EqualFold(t.Name, texture) {
+			if cached, err := blobs.Get(previewKey(key, index, width, height)); err == nil {
+				defer cached.Close()
+				_, err = io.Copy(writer, cached)
+				return err
+			}
+			break
+		}
+	}
+
+	blob, err := blobs.Get(key)
+	if err != nil {
+		return errors.Wrap(err, "failed to get file from object store")
+	}
+	data, err := ioutil.ReadAll(blob)
+	blob.Close()
+	if err != nil {
+		return errors.Wrap(err, "failed to read texture dictionary")
+	}
+
+	txd, err := renderware.ReadTextureDictionary(data)
+	if err != nil {
+		return errors.Wrap(err, "failed to read texture dictionary")
+	}
+	index := -1
+	for i, t := range txd.Textures {
+		if strings.EqualFold(t.Name, texture) {
+			index = i
+			break
+		}
+	}
+	if index == -1 {
+		return ErrTextureNotFound
+	}
+
+	var img image.Image
+	img, err = txd.Textures[index].Image()
+	if err != nil {
+		return errors.Wrapf(err, "failed to decode texture %s", texture)
+	}
+	if width > 0 || height > 0 {
+		img = resize.Resize(width, height, img, resize.Bilinear)
+	}
+
+	var buf bytes.Buffer
+	err = png.Encode(&buf, img)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode preview")
+	}
+
+	err = blobs.Put(previewKey(key, index, width, height), bytes.NewReader(buf.Bytes()), "image/png")
+	if err != nil {
+		return errors.Wrap(err, "failed to cache preview")
+	}
+
+	_, err = buf.WriteTo(writer)
+	return
+}
+
+// removeDerived deletes every cached preview, thumbnail or web image made from the file at key
+func removeDerived(blobs BlobStore, key string) (err error) {
+	for _, prefix := range []string{"previews", "thumbs", "web"} {
+		var keys []string
+		keys, err = blobs.List(path.Join(prefix, key) + "/")
+		if err != nil {
+			return errors.Wrapf(err, "failed to list %s", prefix)
+		}
+		for _, derived := range keys {
+			err = blobs.Remove(derived)
+			if err != nil {
+				return errors.Wrapf(err, "failed to remove %s", derived)
+			}
+		}
+	}
+	return
+}
+
+func hasFile(files []types.File, name types.File) bool {
+	for _, file := range files {
+		if file == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Southclaws/samp-objects-api/types"
+)
+
+// testTXD builds a texture dictionary holding a single 2x2 texture of one BGRA colour
+func testTXD(name string, bgra [4]byte) []byte {
+	chunk := func(chunkType uint32, data []byte) []byte {
+		var buf bytes.Buffer
+		binary.Write(&buf, binary.LittleEndian, []uint32{chunkType, uint32(len(data)), 0x1803FFFF})
+		return append(buf.Bytes(), data...)
+	}
+
+	var native bytes.Buffer
+	binary.Write(&native, binary.LittleEndian, []uint32{9, 0x1102})
+	native.Write(append([]byte(name), make([]byte, 64-len(name))...))
+	binary.Write(&native, binary.LittleEndian, []uint32{0x0500, 0x15})
+	binary.Write(&native, binary.LittleEndian, []uint16{2, 2})
+	native.Write([]byte{32, 1, 4, 1})
+	binary.Write(&native, binary.LittleEndian, uint32(16))
+	native.Write(bytes.Repeat(bgra[:], 4))
+
+	dictionary := chunk(0x01, []byte{1, 0, 2, 0})
+	dictionary = append(dictionary, chunk(0x15, append(chunk(0x01, native.Bytes()), chunk(0x03, nil)...))...)
+	return chunk(0x16, append(dictionary, chunk(0x03, nil)...))
+}
+
+func TestDatabase_TexturePreview(t *testing.T) {
+	objectID := types.ObjectID("00000000-0000-0000-0000-b00000000000")
+
+	texture, err := db.PutObjectFile(objectID, "texture.txd", "application/octet-stream", bytes.NewReader(testTXD("Brick", [4]byte{0, 0, 255, 128})))
+	assert.NoError(t, err)
+	texture.Textures = []types.TextureInfo{{Name: "Brick", Width: 2, Height: 2, Format: "8888", MipMaps: 1, Alpha: true, Size: 16}}
+	image, err := db.PutObjectFile(objectID, "image.jpg", "image/jpeg", bytes.NewReader([]byte("image")))
+	assert.NoError(t, err)
+	model, err := db.PutObjectFile(objectID, "model.dff", "application/octet-stream", bytes.NewReader([]byte("model")))
+	assert.NoError(t, err)
+
+	object := types.Object{
+		ID:        objectID,
+		OwnerID:   "00000003-0000-0000-0000-000000000000",
+		OwnerName: "owner3",
+		Name:      "textured",
+		Category:  "category1",
+	}
+	object.PublishVersion(types.ObjectVersion{
+		Images:   []types.File{"image.jpg"},
+		Models:   []types.File{"model.dff"},
+		Textures: []types.File{"texture.txd"},
+		Files:    []types.FileInfo{image, model, texture},
+	})
+	assert.NoError(t, db.CreateObject(object))
+
+	for _, size := range []uint{0, 4} {
+		for i := 0; i < 2; i++ { // the second request is served from the cache
+			var buf bytes.Buffer
+			assert.NoError(t, db.GetTexturePreview(objectID, 0, "texture.txd", "brick", size, 0, &buf))
+			img, err := png.Decode(&buf)
+			assert.NoError(t, err)
+			if size == 0 {
+				assert.Equal(t, 2, img.Bounds().Dx())
+			} else {
+				assert.Equal(t, 4, img.Bounds().Dy())
+			}
+			assert.Equal(t, color.NRGBAModel.Convert(color.NRGBA{255, 0, 0, 128}), color.NRGBAModel.Convert(img.At(0, 0)))
+		}
+	}
+
+	assert.Equal(t, ErrTextureNotFound, db.GetTexturePreview(objectID, 0, "texture.txd", "missing", 0, 0, ioutil.Discard))
+	assert.Equal(t, ErrTextureNotFound, db.GetTexturePreview(objectID, 0, "model.dff", "brick", 0, 0, ioutil.Discard))
+
+	assert.NoError(t, db.DeleteObject(objectID))
+}
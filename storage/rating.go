@@ -1,10 +1,12 @@
 package storage
 
 import (
+	"fmt"
 	"strings"
 	"time"
 
 	"github.com/pkg/errors"
+	"gopkg.in/mgo.v2"
 	"gopkg.in/mgo.v2/bson"
 
 	"github.com/Southclaws/samp-objects-api/types"
@@ -22,6 +24,15 @@ func (db *Database) AddRating(userID types.UserID, objectID types.ObjectID, valu
 		return false, errors.New("invalid rating value")
 	}
 
+	// a rating without its object would never be counted, so the object is looked up first
+	count, err := db.objects.Find(bson.M{"id": objectID}).Count()
+	if err != nil {
+		return
+	}
+	if count == 0 {
+		return false, ErrNotFound
+	}
+
 	err = db.ratings.Insert(types.Rating{
 		UserID:   userID,
 		ObjectID: objectID,
@@ -40,7 +51,14 @@ func (db *Database) AddRating(userID types.UserID, objectID types.ObjectID, valu
 		bson.M{"$inc": bson.M{
 			"ratecount": 1,
 			"ratetotal": value,
+			fmt.Sprintf("ratehistogram.%d", types.RatingStar(value)): 1,
 		}})
+	if err != nil {
+		// the object was removed since it was looked up
+		if errRemove := db.ratings.Remove(bson.M{"userid": userID, "objectid": objectID}); errRemove != nil {
+			return false, errors.Wrap(errRemove, "failed to remove rating of missing object")
+		}
+	}
 	return
 }
 
@@ -69,6 +87,130 @@ func (db *Database) RemoveRating(userID types.UserID, objectID types.ObjectID) (
 		bson.M{"$inc": bson.M{
 			"ratecount": -1,
 			"ratetotal": -rating.Value,
+			fmt.Sprintf("ratehistogram.%d", types.RatingStar(rating.Value)): -1,
+		}})
+	return
+}
+
+// GetRatings returns a page of the ratings on an object
+func (db *Database) GetRatings(objectID types.ObjectID, page PageQuery) (result RatingPage, err error) {
+	if err = objectID.Validate(); err != nil {
+		return
+	}
+
+	filter := bson.M{"objectid": objectID}
+	result.Total, err = db.ratings.Find(filter).Count()
+	if err != nil {
+		return
+	}
+
+	if page.Cursor != "" {
+		var (
+			date   time.Time
+			userID types.UserID
+		)
+		date, userID, err = ratingAfter(page.Cursor)
+		if err != nil {
+			return
+		}
+		filter["$or"] = []bson.M{
+			{"date": bson.M{"$gt": date}},
+			{"date": date, "userid": bson.M{"$gt": userID}},
+		}
+	}
+
+	err = db.ratings.Find(filter).Sort("date", "userid").Limit(page.fetchLimit()).All(&result.Ratings)
+	if err != nil {
+		return
+	}
+	result.Ratings, result.Next = nextRatings(result.Ratings, page)
+	return
+}
+
+// ReconcileRatings recalculates the rating counters of every object from the ratings collection
+// and returns the objects whose stored counters had drifted, for example because the process died
+// between inserting a rating and updating the object. When fix is set the counters are corrected
+// and only the objects that were changed are returned.
+func (db *Database) ReconcileRatings(fix bool) (drift []RatingDrift, err error) {
+	// counters are read before ratings, see readRatingCounters
+	stored := make(map[types.ObjectID]RatingAggregate)
+	iter := db.objects.Find(nil).Select(bson.M{"id": 1, "ratecount": 1, "ratetotal": 1, "ratehistogram": 1}).Iter()
+	for {
+		var object types.Object
+		if !iter.Next(&object) {
+			break
+		}
+		stored[object.ID] = aggregateOf(object)
+	}
+	err = iter.Close()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read object rating counters")
+	}
+
+	var ratings []types.Rating
+	err = db.ratings.Find(nil).Select(bson.M{"objectid": 1, "value": 1}).All(&ratings)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read ratings")
+	}
+
+	drift = ratingDrift(stored, aggregateRatings(ratings))
+	if !fix {
+		return
+	}
+	return fixRatingDrift(db, drift)
+}
+
+func (db *Database) readRatingCounters(objectID types.ObjectID) (stored, actual RatingAggregate, err error) {
+	var object types.Object
+	err = db.objects.Find(bson.M{"id": objectID}).Select(bson.M{"id": 1, "ratecount": 1, "ratetotal": 1, "ratehistogram": 1}).One(&object)
+	if err == mgo.ErrNotFound {
+		return stored, actual, ErrNotFound
+	} else if err != nil {
+		return
+	}
+
+	var ratings []types.Rating
+	err = db.ratings.Find(bson.M{"objectid": objectID}).Select(bson.M{"objectid": 1, "value": 1}).All(&ratings)
+	if err != nil {
+		return
+	}
+	return aggregateOf(object), aggregateRatings(ratings)[objectID], nil
+}
+
+func (db *Database) saveRatingCounters(objectID types.ObjectID, stored, actual RatingAggregate) (err error) {
+	err = db.objects.Update(
+		bson.M{
+			"id":            objectID,
+			"ratecount":     stored.Count,
+			"ratetotal":     stored.Total,
+			"ratehistogram": stored.Histogram,
+		},
+		bson.M{"$set": bson.M{
+			"ratecount":     actual.Count,
+			"ratetotal":     actual.Total,
+			"ratehistogram": actual.Histogram,
 		}})
+	if err == mgo.ErrNotFound {
+		return ErrNotFound
+	}
 	return
 }
+
+// ratingPrior returns the mean of every rating across all objects
+func (db *Database) ratingPrior() (prior float64, err error) {
+	var result struct {
+		Count types.ObjectRateCount `bson:"count"`
+		Total types.ObjectRateTotal `bson:"total"`
+	}
+	err = db.objects.Pipe([]bson.M{
+		{"$group": bson.M{
+			"_id":   nil,
+			"count": bson.M{"$sum": "$ratecount"},
+			"total": bson.M{"$sum": "$ratetotal"},
+		}},
+	}).One(&result)
+	if err == mgo.ErrNotFound {
+		err = nil
+	}
+	return ratingPrior(result.Total, result.Count), err
+}
@@ -3,6 +3,7 @@ package storage
 import (
 	"testing"
 
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 
 	"github.com/Southclaws/samp-objects-api/types"
@@ -40,6 +41,11 @@ func TestDatabase_AddRating(t *testing.T) {
 			"00000000-0000-0000-0000-200000000000",
 			4.6,
 		}, true, false},
+		{"missing object", args{
+			"00000001-0000-0000-0000-000000000000",
+			"00000000-0000-0000-0000-200000000009",
+			4.6,
+		}, false, true},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -52,6 +58,56 @@ func TestDatabase_AddRating(t *testing.T) {
 			assert.Equal(t, tt.wantExists, gotExists)
 		})
 	}
+
+	// the rating on the missing object wasn't kept
+	ratings, err := db.GetRatings("00000000-0000-0000-0000-200000000009", PageQuery{})
+	assert.NoError(t, err)
+	assert.Empty(t, ratings.Ratings)
+}
+
+func TestDatabase_RatingAggregates(t *testing.T) {
+	object, err := db.GetObject("00000000-0000-0000-0000-200000000000")
+	assert.NoError(t, err)
+	assert.Equal(t, types.ObjectRateCount(3), object.RateCount)
+	assert.Equal(t, types.ObjectRateHistogram{0, 0, 0, 1, 1, 1}, object.RateHistogram)
+	assert.InDelta(t, 4.1333, float64(object.RateAverage), 0.001)
+
+	drift, err := db.ReconcileRatings(false)
+	assert.NoError(t, err)
+	assert.Empty(t, drift)
+}
+
+func TestDatabase_UpdateObjectKeepsRatings(t *testing.T) {
+	stale, err := db.GetObject("00000000-0000-0000-0000-200000000000")
+	assert.NoError(t, err)
+
+	// a rating removed between reading the object and saving it must not come back
+	assert.NoError(t, db.RemoveRating("00000001-0000-0000-0000-000000000000", stale.ID))
+	stale.Description = "updated while rated"
+	assert.NoError(t, db.UpdateObject(stale))
+
+	object, err := db.GetObject(stale.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, types.ObjectDescription("updated while rated"), object.Description)
+	assert.Equal(t, types.ObjectRateCount(2), object.RateCount)
+	assert.Equal(t, types.ObjectRateHistogram{0, 0, 0, 1, 1, 0}, object.RateHistogram)
+
+	_, err = db.AddRating("00000001-0000-0000-0000-000000000000", stale.ID, 4.6)
+	assert.NoError(t, err)
+}
+
+func TestDatabase_GetRatings(t *testing.T) {
+	page, err := db.GetRatings("00000000-0000-0000-0000-200000000000", PageQuery{Limit: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, page.Total)
+	assert.Len(t, page.Ratings, 2)
+	assert.Equal(t, types.UserID("00000003-0000-0000-0000-000000000000"), page.Ratings[0].UserID)
+
+	page, err = db.GetRatings("00000000-0000-0000-0000-200000000000", PageQuery{Limit: 2, Cursor: page.Next})
+	assert.NoError(t, err)
+	assert.Len(t, page.Ratings, 1)
+	assert.Equal(t, types.UserID("00000001-0000-0000-0000-000000000000"), page.Ratings[0].UserID)
+	assert.Empty(t, page.Next)
 }
 
 func TestDatabase_RemoveRating(t *testing.T) {
@@ -85,3 +141,34 @@ func TestDatabase_RemoveRating(t *testing.T) {
 		})
 	}
 }
+
+func TestDatabase_FixRatingCountersKeepsConcurrentRatings(t *testing.T) {
+	objectID := types.ObjectID("00000000-0000-0000-0000-200000000000")
+	counters := db.(ratingCounters)
+
+	stored, actual, err := counters.readRatingCounters(objectID)
+	assert.NoError(t, err)
+
+	// a rating added after the counters were read must not be overwritten when they are saved
+	exists, err := db.AddRating("00000009-0000-0000-0000-000000000000", objectID, 1)
+	assert.NoError(t, err)
+	assert.False(t, exists)
+
+	err = counters.saveRatingCounters(objectID, stored, actual)
+	assert.Equal(t, ErrNotFound, errors.Cause(err))
+
+	object, err := db.GetObject(objectID)
+	assert.NoError(t, err)
+	assert.Equal(t, stored.Count+1, object.RateCount)
+
+	drift, err := db.ReconcileRatings(true)
+	assert.NoError(t, err)
+	assert.Empty(t, drift)
+
+	// saving counters that are still current succeeds
+	stored, actual, err = counters.readRatingCounters(objectID)
+	assert.NoError(t, err)
+	assert.NoError(t, counters.saveRatingCounters(objectID, stored, actual))
+
+	assert.NoError(t, db.RemoveRating("00000009-0000-0000-0000-000000000000", objectID))
+}
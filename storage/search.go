@@ -0,0 +1,179 @@
+package storage
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/Southclaws/samp-objects-api/types"
+)
+
+// Object search is done by each database's own full-text index over names, tags and descriptions:
+// a weighted text index ranked by textScore in MongoDB, an FTS5 table ranked by bm25 in SQLite and a
+// weighted tsvector ranked by ts_rank in PostgreSQL. Names count for more than tags, which count
+// for more than descriptions. Every word of a search has to match, the SQL databases also match
+// words by prefix while MongoDB only matches whole words. The memory backend has no index and
+// scores objects with searchScore.
+
+const (
+	searchMinPrefix = 2  // shortest word that matches by prefix
+	searchMaxWords  = 8  // words after this in a query are ignored
+	searchMaxLength = 32 // longer words are truncated
+)
+
+// searchField is a weighted part of an object that is searched
+type searchField struct {
+	weight float64
+	words  []string
+}
+
+// match qualities, multiplied by the weight of the field the word was found in
+const (
+	searchExact  = 1.0
+	searchPrefix = 0.6
+)
+
+// SortRelevance is the sort value that ranks search results by how well they match
+const SortRelevance = "relevance"
+
+// searchWords splits text into lowercase words of letters and digits. Search words never hold
+// anything else so they can be written into a full-text query without escaping.
+func searchWords(text string) (words []string) {
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if r := []rune(word); len(r) > searchMaxLength {
+			word = string(r[:searchMaxLength])
+		}
+		words = append(words, word)
+	}
+	return
+}
+
+// parseSearch turns a search query into the distinct words that must all match
+func parseSearch(query string) (words []string) {
+	seen := make(map[string]bool)
+	for _, word := range searchWords(query) {
+		if seen[word] {
+			continue
+		}
+		seen[word] = true
+		words = append(words, word)
+		if len(words) == searchMaxWords {
+			break
+		}
+	}
+	return
+}
+
+// prefixSearch reports whether a search word also matches longer words that start with it
+func prefixSearch(word string) bool {
+	return len([]rune(word)) >= searchMinPrefix
+}
+
+// searchText returns the text of an object that is indexed for search, tags are joined by spaces
+func searchText(object types.Object) (name, tags, description string) {
+	tagNames := make([]string, len(object.Tags))
+	for i, tag := range object.Tags {
+		tagNames[i] = string(tag)
+	}
+	return string(object.Name), strings.Join(tagNames, " "), string(object.Description)
+}
+
+func searchFields(object types.Object) []searchField {
+	name, tags, description := searchText(object)
+	return []searchField{
+		{3, searchWords(name)},
+		{2, searchWords(tags)},
+		{1, searchWords(description)},
+	}
+}
+
+// matchQuality returns how well a query word matches a word in an object, or 0 if it doesn't
+func matchQuality(query, word string) float64 {
+	if query == word {
+		return searchExact
+	}
+	if prefixSearch(query) && strings.HasPrefix(word, query) {
+		return searchPrefix
+	}
+	return 0
+}
+
+// searchScore returns the relevance of an object to the words of a query, ok is false unless every
+// word matches somewhere in the object
+func searchScore(object types.Object, query []string) (score float64, ok bool) {
+	fields := searchFields(object)
+	for _, q := range query {
+		best := 0.0
+		for _, field := range fields {
+			for _, word := range field.words {
+				if s := field.weight * matchQuality(q, word); s > best {
+					best = s
+				}
+			}
+		}
+		if best == 0 {
+			return 0, false
+		}
+		score += best
+	}
+	return score, true
+}
+
+// byRelevance reports whether results should be ranked by how well they match the search, which is
+// the default order when there is one
+func (query ObjectQuery) byRelevance() bool {
+	field := strings.TrimLeft(query.Sort, "+-")
+	return len(parseSearch(query.Search)) > 0 && (field == SortRelevance || field == "")
+}
+
+// objectSort returns the order of an object listing, the best matches always come first when
+// ranking by relevance
+func (query ObjectQuery) objectSort() objectSort {
+	if query.byRelevance() {
+		return objectSort{SortRelevance, true}
+	}
+	return parseObjectSort(query.Sort)
+}
+
+// storedSort returns the sort the memory backend applies before it ranks search results, newest
+// first is used to break ties between equally relevant objects
+func (query ObjectQuery) storedSort() string {
+	if query.byRelevance() {
+		return "-_id"
+	}
+	return query.Sort
+}
+
+// rankObjects removes objects that do not match a search and, when sorting by relevance, orders the
+// rest by score keeping the existing order between equal scores
+func rankObjects(objects []types.Object, query []string, byRelevance bool) []types.Object {
+	var (
+		matched []types.Object
+		scores  []float64
+	)
+	for _, object := range objects {
+		score, ok := searchScore(object, query)
+		if ok {
+			matched = append(matched, object)
+			scores = append(scores, score)
+		}
+	}
+	if byRelevance {
+		sort.Stable(byScore{matched, scores})
+	}
+	return matched
+}
+
+type byScore struct {
+	objects []types.Object
+	scores  []float64
+}
+
+func (s byScore) Len() int           { return len(s.objects) }
+func (s byScore) Less(i, j int) bool { return s.scores[i] > s.scores[j] }
+func (s byScore) Swap(i, j int) {
+	s.objects[i], s.objects[j] = s.objects[j], s.objects[i]
+	s.scores[i], s.scores[j] = s.scores[j], s.scores[i]
+}
@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Southclaws/samp-objects-api/types"
+)
+
+func Test_searchScore(t *testing.T) {
+	object := types.Object{
+		Name:        "Police Vehicle",
+		Tags:        []types.ObjectTag{"emergency", "car"},
+		Description: "A custom cruiser for roleplay servers",
+	}
+
+	tests := []struct {
+		query   string
+		want    float64
+		matches bool
+	}{
+		{"police", 3, true},
+		{"pol", 3 * searchPrefix, true},
+		{"polcie", 0, false},
+		{"emergency car", 4, true},
+		{"cruiser", 1, true},
+		{"police boat", 0, false},
+		{"p", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			got, ok := searchScore(object, parseSearch(tt.query))
+			assert.Equal(t, tt.matches, ok)
+			assert.InDelta(t, tt.want, got, 0.0001)
+		})
+	}
+}
+
+func Test_textQueries(t *testing.T) {
+	tests := []struct {
+		query string
+		fts   string
+		ts    string
+		mongo string
+	}{
+		{"police", `"police"*`, `police:*`, `"police"`},
+		{"Police  CAR police", `"police"* AND "car"*`, `police:* & car:*`, `"police" "car"`},
+		{"a 4x4", `"a" AND "4x4"*`, `a & 4x4:*`, `"a" "4x4"`},
+		{`"drop"; table--`, `"drop"* AND "table"*`, `drop:* & table:*`, `"drop" "table"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			words := parseSearch(tt.query)
+			assert.Equal(t, tt.fts, ftsQuery(words))
+			assert.Equal(t, tt.ts, tsQuery(words))
+			assert.Equal(t, tt.mongo, mongoTextSearch(words))
+		})
+	}
+}
+
+func Test_rankObjects(t *testing.T) {
+	objects := []types.Object{
+		{ID: "a", Name: "Bridge", Description: "a long road bridge"},
+		{ID: "b", Name: "Road Sign"},
+		{ID: "c", Name: "Road"},
+		{ID: "d", Name: "Tree"},
+	}
+
+	var ids []types.ObjectID
+	for _, object := range rankObjects(objects, parseSearch("road"), true) {
+		ids = append(ids, object.ID)
+	}
+	assert.Equal(t, []types.ObjectID{"b", "c", "a"}, ids)
+
+	ids = nil
+	for _, object := range rankObjects(objects, parseSearch("road"), false) {
+		ids = append(ids, object.ID)
+	}
+	assert.Equal(t, []types.ObjectID{"a", "b", "c"}, ids)
+}
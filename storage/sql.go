@@ -0,0 +1,363 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/Southclaws/samp-objects-api/types"
+)
+
+// SQL is a Storage implementation on top of database/sql, it supports SQLite for single-binary
+// deployments and PostgreSQL for production. Users, ratings and comments are plain tables, objects
+// keep their queryable fields in columns next to a BSON copy of the whole document so new fields
+// on types.Object are persisted without a schema change, the same as they are with MongoDB.
+//
+// Drivers are not linked by default, build with `-tags sqlite` and/or `-tags postgres` to register
+// the "sqlite3" and "postgres" drivers. Search needs SQLite's FTS5 extension, which is only built
+// with `-tags "sqlite sqlite_fts5"`, NewSQL fails without it.
+type SQL struct {
+	db      *sql.DB
+	dialect dialect
+	blobs   BlobStore
+	leases  *blobLeases
+}
+
+// dialect holds the small differences between the supported SQL databases
+type dialect struct {
+	serial   string     // column definition for an auto-incrementing primary key
+	blob     string     // column type for binary data
+	numbered bool       // placeholders are $1, $2... instead of ?
+	search   textSearch // how objects are indexed for full-text search
+}
+
+var dialects = map[string]dialect{
+	"sqlite3": {
+		serial: "INTEGER PRIMARY KEY AUTOINCREMENT",
+		blob:   "BLOB",
+		search: searchFTS5,
+	},
+	"postgres": {
+		serial:   "BIGSERIAL PRIMARY KEY",
+		blob:     "BYTEA",
+		numbered: true,
+		search:   searchTSVector,
+	},
+}
+
+// NewSQL opens a connection with config.SQLDriver and config.SQLSource, applies any outstanding
+// schema migrations and sets up the blob store selected by config.StoreType.
+func NewSQL(config Config) (*SQL, error) {
+	var (
+		err      error
+		database SQL
+		ok       bool
+	)
+
+	database.dialect, ok = dialects[config.SQLDriver]
+	if !ok {
+		return nil, errors.Errorf("unsupported SQL driver '%s'", config.SQLDriver)
+	}
+
+	database.db, err = sql.Open(config.SQLDriver, config.SQLSource)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open database")
+	}
+	if config.SQLDriver == "sqlite3" {
+		// SQLite only allows one writer and each connection to ":memory:" is a new database
+		database.db.SetMaxOpenConns(1)
+	}
+
+	err = database.db.Ping()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to connect to database")
+	}
+
+	err = database.checkTextSearch()
+	if err != nil {
+		return nil, err
+	}
+
+	err = database.migrate()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to migrate database schema")
+	}
+
+	err = database.indexObjectText()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to index objects for search")
+	}
+
+	err = database.normaliseTags()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to normalise object tags")
+	}
+
+	database.blobs, err = NewBlobStore(config)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to set up file store")
+	}
+	database.leases = newBlobLeases()
+
+	return &database, nil
+}
+
+// sqlMigrations is the ordered list of schema changes, each entry is applied in a transaction
+// exactly once and the index+1 is recorded in the schema_migrations table. Never edit or reorder
+// an existing entry, append a new one instead.
+var sqlMigrations = []func(d dialect) []string{
+	func(d dialect) []string {
+		return []string{
+			`CREATE TABLE users (
+				id       TEXT NOT NULL PRIMARY KEY,
+				name     TEXT NOT NULL,
+				email    TEXT NOT NULL,
+				password TEXT NOT NULL,
+				CONSTRAINT users_unique_name UNIQUE (name),
+				CONSTRAINT users_unique_email UNIQUE (email)
+			)`,
+			`CREATE TABLE objects (
+				seq        ` + d.serial + `,
+				id         TEXT NOT NULL,
+				owner_id   TEXT NOT NULL,
+				owner_name TEXT NOT NULL,
+				name       TEXT NOT NULL,
+				category   TEXT NOT NULL,
+				rate_count INTEGER NOT NULL DEFAULT 0,
+				rate_total DOUBLE PRECISION NOT NULL DEFAULT 0,
+				document   ` + d.blob + ` NOT NULL,
+				CONSTRAINT objects_unique_id UNIQUE (id),
+				CONSTRAINT objects_unique_name UNIQUE (owner_id, name)
+			)`,
+			`CREATE INDEX objects_owner_name ON objects (owner_name)`,
+			`CREATE INDEX objects_category ON objects (category)`,
+			`CREATE TABLE object_tags (
+				object_id TEXT NOT NULL,
+				tag       TEXT NOT NULL,
+				PRIMARY KEY (object_id, tag)
+			)`,
+			`CREATE INDEX object_tags_tag ON object_tags (tag)`,
+			`CREATE TABLE ratings (
+				user_id   TEXT NOT NULL,
+				object_id TEXT NOT NULL,
+				value     DOUBLE PRECISION NOT NULL,
+				date      TIMESTAMP NOT NULL,
+				CONSTRAINT ratings_unique_user_object UNIQUE (user_id, object_id)
+			)`,
+			`CREATE TABLE comments (
+				seq       ` + d.serial + `,
+				id        TEXT NOT NULL,
+				user_id   TEXT NOT NULL,
+				object_id TEXT NOT NULL,
+				content   TEXT NOT NULL,
+				date      TIMESTAMP NOT NULL,
+				CONSTRAINT comments_unique_id UNIQUE (id)
+			)`,
+			`CREATE INDEX comments_object_id ON comments (object_id)`,
+		}
+	},
+	func(d dialect) (statements []string) {
+		for star := range (types.ObjectRateHistogram{}) {
+			statements = append(statements, fmt.Sprintf(`ALTER TABLE objects ADD COLUMN rate_star_%d INTEGER NOT NULL DEFAULT 0`, star))
+		}
+		return
+	},
+	func(d dialect) []string {
+		return []string{
+			`CREATE TABLE object_files (
+				object_id TEXT NOT NULL,
+				name      TEXT NOT NULL,
+				size      BIGINT NOT NULL,
+				sha256    TEXT NOT NULL,
+				PRIMARY KEY (object_id, name)
+			)`,
+			`CREATE INDEX object_files_sha256 ON object_files (sha256)`,
+		}
+	},
+	func(d dialect) []string {
+		return []string{
+			`CREATE TABLE object_version_files (
+				object_id TEXT NOT NULL,
+				version   INTEGER NOT NULL,
+				name      TEXT NOT NULL,
+				size      BIGINT NOT NULL,
+				sha256    TEXT NOT NULL,
+				PRIMARY KEY (object_id, version, name)
+			)`,
+			`INSERT INTO object_version_files (object_id, version, name, size, sha256)
+				SELECT object_id, 1, name, size, sha256 FROM object_files`,
+			`DROP TABLE object_files`,
+			`ALTER TABLE object_version_files RENAME TO object_files`,
+			`CREATE INDEX object_files_sha256 ON object_files (sha256)`,
+		}
+	},
+	func(d dialect) []string {
+		return []string{
+			`ALTER TABLE users ADD COLUMN deleted TIMESTAMP`,
+			`ALTER TABLE objects ADD COLUMN deleted TIMESTAMP`,
+			`ALTER TABLE comments ADD COLUMN deleted TIMESTAMP`,
+		}
+	},
+	func(d dialect) []string {
+		return []string{
+			`CREATE TABLE object_search (
+				object_id TEXT NOT NULL,
+				term      TEXT NOT NULL,
+				PRIMARY KEY (object_id, term)
+			)`,
+			`CREATE INDEX object_search_term ON object_search (term)`,
+		}
+	},
+	func(d dialect) []string {
+		return []string{
+			`CREATE TABLE categories (
+				slug   TEXT NOT NULL PRIMARY KEY,
+				name   TEXT NOT NULL,
+				parent TEXT NOT NULL DEFAULT ''
+			)`,
+			`CREATE INDEX categories_parent ON categories (parent)`,
+		}
+	},
+	func(d dialect) []string {
+		return []string{
+			`CREATE TABLE tag_aliases (
+				alias TEXT NOT NULL PRIMARY KEY,
+				tag   TEXT NOT NULL
+			)`,
+			`CREATE INDEX tag_aliases_tag ON tag_aliases (tag)`,
+		}
+	},
+	func(d dialect) []string {
+		return []string{
+			`CREATE TABLE collections (
+				id       TEXT NOT NULL PRIMARY KEY,
+				owner_id TEXT NOT NULL,
+				document ` + d.blob + ` NOT NULL
+			)`,
+			`CREATE INDEX collections_owner_id ON collections (owner_id)`,
+		}
+	},
+	func(d dialect) []string {
+		return []string{
+			`CREATE INDEX comments_object_id_id ON comments (object_id, id)`,
+			`CREATE INDEX ratings_object_id_date ON ratings (object_id, date, user_id)`,
+		}
+	},
+	func(d dialect) []string {
+		return []string{
+			`ALTER TABLE collections ADD COLUMN revision INTEGER NOT NULL DEFAULT 0`,
+		}
+	},
+	func(d dialect) []string {
+		// the search term table is replaced by the database's own full-text search, objects are
+		// indexed again by indexObjectText
+		statements := []string{`DROP TABLE object_search`}
+		switch d.search {
+		case searchFTS5:
+			statements = append(statements, `CREATE VIRTUAL TABLE object_text USING fts5(name, tags, description)`)
+		case searchTSVector:
+			statements = append(statements,
+				`ALTER TABLE objects ADD COLUMN search_text TSVECTOR`,
+				`CREATE INDEX objects_search_text ON objects USING GIN (search_text)`)
+		}
+		return statements
+	},
+}
+
+// migrate brings the schema up to date with sqlMigrations
+func (s *SQL) migrate() (err error) {
+	_, err = s.db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER NOT NULL PRIMARY KEY)`)
+	if err != nil {
+		return errors.Wrap(err, "failed to create schema_migrations table")
+	}
+
+	var current int
+	err = s.db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current)
+	if err != nil {
+		return errors.Wrap(err, "failed to read schema version")
+	}
+
+	for i := current; i < len(sqlMigrations); i++ {
+		version := i + 1
+
+		tx, err := s.db.Begin()
+		if err != nil {
+			return errors.Wrap(err, "failed to begin migration")
+		}
+
+		for _, statement := range sqlMigrations[i](s.dialect) {
+			_, err = tx.Exec(statement)
+			if err != nil {
+				tx.Rollback()
+				return errors.Wrapf(err, "failed to apply migration %d", version)
+			}
+		}
+
+		_, err = tx.Exec(s.rebind(`INSERT INTO schema_migrations (version) VALUES (?)`), version)
+		if err != nil {
+			tx.Rollback()
+			return errors.Wrapf(err, "failed to record migration %d", version)
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			return errors.Wrapf(err, "failed to commit migration %d", version)
+		}
+	}
+
+	return nil
+}
+
+// rebind rewrites ? placeholders to $1, $2... for databases that use numbered placeholders
+func (s *SQL) rebind(query string) string {
+	if !s.dialect.numbered {
+		return query
+	}
+
+	var (
+		out strings.Builder
+		n   int
+	)
+	for _, r := range query {
+		if r == '?' {
+			n++
+			out.WriteString("$" + strconv.Itoa(n))
+		} else {
+			out.WriteRune(r)
+		}
+	}
+	return out.String()
+}
+
+// placeholders returns a comma separated list of n ? placeholders
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// isUniqueViolation reports whether err is a unique constraint failure, PostgreSQL reports the
+// name of the constraint and SQLite reports the constrained columns.
+func isUniqueViolation(err error, constraint string, columns ...string) bool {
+	msg := err.Error()
+	if strings.Contains(msg, constraint) {
+		return true
+	}
+	return len(columns) > 0 && strings.Contains(msg, fmt.Sprintf("UNIQUE constraint failed: %s", strings.Join(columns, ", ")))
+}
+
+// checkAffected turns an update or delete that matched no rows into ErrNotFound
+func checkAffected(result sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
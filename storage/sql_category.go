@@ -0,0 +1,113 @@
+package storage
+
+import (
+	"database/sql"
+
+	"github.com/Southclaws/samp-objects-api/types"
+)
+
+// CreateCategory adds a category to the taxonomy
+func (s *SQL) CreateCategory(category types.Category) (err error) {
+	if err = category.Validate(); err != nil {
+		return
+	}
+
+	categories, err := s.GetCategories()
+	if err != nil {
+		return
+	}
+	if err = checkCategoryParent(categories, category); err != nil {
+		return
+	}
+
+	_, err = s.db.Exec(s.rebind(`INSERT INTO categories (slug, name, parent) VALUES (?, ?, ?)`),
+		category.Slug, category.Name, category.Parent)
+	if err != nil && isUniqueViolation(err, "categories_pkey", "categories.slug") {
+		return ErrCategoryAlreadyExists
+	}
+	return
+}
+
+// UpdateCategory changes the name or parent of a category
+func (s *SQL) UpdateCategory(category types.Category) (err error) {
+	if err = category.Validate(); err != nil {
+		return
+	}
+
+	categories, err := s.GetCategories()
+	if err != nil {
+		return
+	}
+	if err = checkCategoryParent(categories, category); err != nil {
+		return
+	}
+
+	return checkAffected(s.db.Exec(s.rebind(`UPDATE categories SET name = ?, parent = ? WHERE slug = ?`),
+		category.Name, category.Parent, category.Slug))
+}
+
+// DeleteCategory removes a category that no object or other category refers to
+func (s *SQL) DeleteCategory(slug types.ObjectCategory) (err error) {
+	var inUse bool
+	err = s.db.QueryRow(s.rebind(`SELECT
+		EXISTS (SELECT 1 FROM categories WHERE parent = ?) OR EXISTS (SELECT 1 FROM objects WHERE category = ?)`),
+		slug, slug).Scan(&inUse)
+	if err != nil {
+		return
+	}
+	if inUse {
+		return ErrCategoryInUse
+	}
+
+	return checkAffected(s.db.Exec(s.rebind(`DELETE FROM categories WHERE slug = ?`), slug))
+}
+
+// GetCategory returns a category by its slug
+func (s *SQL) GetCategory(slug types.ObjectCategory) (category types.Category, err error) {
+	err = s.db.QueryRow(s.rebind(`SELECT slug, name, parent FROM categories WHERE slug = ?`), slug).
+		Scan(&category.Slug, &category.Name, &category.Parent)
+	if err == sql.ErrNoRows {
+		err = ErrNotFound
+	}
+	return
+}
+
+// GetCategories returns every category sorted by slug
+func (s *SQL) GetCategories() (categories []types.Category, err error) {
+	rows, err := s.db.Query(`SELECT slug, name, parent FROM categories ORDER BY slug`)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var category types.Category
+		err = rows.Scan(&category.Slug, &category.Name, &category.Parent)
+		if err != nil {
+			return
+		}
+		categories = append(categories, category)
+	}
+	err = rows.Err()
+	return
+}
+
+// RemapCategory moves every object in one category to another and returns how many were moved, or
+// with dryRun how many would be. The category is also stored in each object's document so they are
+// updated one at a time.
+func (s *SQL) RemapCategory(from, to types.ObjectCategory, dryRun bool) (n int, err error) {
+	objects, err := s.queryObjects(`SELECT `+sqlObjectColumns+` FROM objects WHERE category = ?`, from)
+	if err != nil || dryRun {
+		return len(objects), err
+	}
+
+	for _, object := range objects {
+		object.Category = to
+		err = s.UpdateObject(object)
+		if err != nil {
+			return
+		}
+		n++
+	}
+	return
+}
@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"database/sql"
+
+	"gopkg.in/mgo.v2/bson"
+
+	"github.com/Southclaws/samp-objects-api/types"
+)
+
+// collections are stored as BSON documents like objects, the owner is a column so a user's
+// collections can be listed
+
+// CreateCollection creates a new collection
+func (s *SQL) CreateCollection(collection types.Collection) (err error) {
+	if err = collection.Validate(); err != nil {
+		return
+	}
+
+	document, err := bson.Marshal(collection)
+	if err != nil {
+		return
+	}
+
+	_, err = s.db.Exec(s.rebind(`INSERT INTO collections (id, owner_id, document) VALUES (?, ?, ?)`),
+		collection.ID, collection.OwnerID, document)
+	if err != nil && isUniqueViolation(err, "collections_pkey", "collections.id") {
+		return ErrCollectionAlreadyExists
+	}
+	return
+}
+
+// UpdateCollection replaces a collection, including its objects and model IDs, if it is still at
+// the revision collection was read at. The stored revision is incremented.
+func (s *SQL) UpdateCollection(collection types.Collection) (err error) {
+	if err = collection.Validate(); err != nil {
+		return
+	}
+
+	revision := collection.Revision
+	collection.Revision++
+	document, err := bson.Marshal(collection)
+	if err != nil {
+		return
+	}
+
+	err = checkAffected(s.db.Exec(s.rebind(`UPDATE collections SET owner_id = ?, document = ?, revision = ? WHERE id = ? AND revision = ?`),
+		collection.OwnerID, document, collection.Revision, collection.ID, revision))
+	if err == ErrNotFound {
+		var exists bool
+		err = s.db.QueryRow(s.rebind(`SELECT EXISTS (SELECT 1 FROM collections WHERE id = ?)`), collection.ID).Scan(&exists)
+		if err != nil {
+			return
+		}
+		if exists {
+			return ErrCollectionChanged
+		}
+		return ErrNotFound
+	}
+	return
+}
+
+// DeleteCollection removes a collection, the objects in it are not affected
+func (s *SQL) DeleteCollection(collectionID types.CollectionID) (err error) {
+	return checkAffected(s.db.Exec(s.rebind(`DELETE FROM collections WHERE id = ?`), collectionID))
+}
+
+// GetCollection returns a collection by its ID
+func (s *SQL) GetCollection(collectionID types.CollectionID) (collection types.Collection, err error) {
+	var document []byte
+	err = s.db.QueryRow(s.rebind(`SELECT document FROM collections WHERE id = ?`), collectionID).Scan(&document)
+	if err == sql.ErrNoRows {
+		return collection, ErrNotFound
+	}
+	if err != nil {
+		return
+	}
+	err = bson.Unmarshal(document, &collection)
+	return
+}
+
+// GetUserCollections returns every collection a user owns sorted by name
+func (s *SQL) GetUserCollections(userID types.UserID) (collections []types.Collection, err error) {
+	rows, err := s.db.Query(s.rebind(`SELECT document FROM collections WHERE owner_id = ?`), userID)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			document   []byte
+			collection types.Collection
+		)
+		err = rows.Scan(&document)
+		if err != nil {
+			return
+		}
+		err = bson.Unmarshal(document, &collection)
+		if err != nil {
+			return
+		}
+		collections = append(collections, collection)
+	}
+	err = rows.Err()
+	sortCollections(collections)
+	return
+}
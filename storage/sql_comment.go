@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/mgo.v2/bson"
+
+	"github.com/Southclaws/samp-objects-api/types"
+)
+
+// GetComments returns a page of comments for a given object ID
+func (s *SQL) GetComments(objectID types.ObjectID, page PageQuery) (result CommentPage, err error) {
+	if err = objectID.Validate(); err != nil {
+		return
+	}
+
+	err = s.db.QueryRow(s.rebind(`SELECT COUNT(*) FROM comments WHERE object_id = ? AND deleted IS NULL`), objectID).Scan(&result.Total)
+	if err != nil {
+		return
+	}
+
+	where := `WHERE object_id = ? AND deleted IS NULL`
+	args := []interface{}{objectID}
+	if page.Cursor != "" {
+		var after string
+		after, err = commentAfter(page.Cursor)
+		if err != nil {
+			return
+		}
+		where += ` AND id > ?`
+		args = append(args, after)
+	}
+	where += ` ORDER BY id`
+	if limit := page.fetchLimit(); limit > 0 {
+		where += ` LIMIT ?`
+		args = append(args, limit)
+	}
+
+	result.Comments, err = s.queryComments(where, args...)
+	if err != nil {
+		return
+	}
+	result.Comments, result.Next = nextComments(result.Comments, page)
+	return
+}
+
+// queryComments selects every comment that matches a WHERE clause, which must never come from user
+// input
+func (s *SQL) queryComments(where string, args ...interface{}) (comments []types.Comment, err error) {
+	rows, err := s.db.Query(s.rebind(`SELECT id, user_id, object_id, content, date, deleted FROM comments `+where), args...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			id      string
+			comment types.Comment
+		)
+		err = rows.Scan(&id, &comment.UserID, &comment.ObjectID, &comment.Content, &comment.Date, &comment.Deleted)
+		if err != nil {
+			return
+		}
+		comment.ID = bson.ObjectIdHex(id)
+		comments = append(comments, comment)
+	}
+	err = rows.Err()
+	return
+}
+
+// AddComment creates a comment from a user on an object
+func (s *SQL) AddComment(userID types.UserID, objectID types.ObjectID, content string) (err error) {
+	if err = userID.Validate(); err != nil {
+		return
+	}
+	if err = objectID.Validate(); err != nil {
+		return
+	}
+	if len(content) > 1024 {
+		return errors.New("content too large")
+	}
+
+	_, err = s.db.Exec(s.rebind(`INSERT INTO comments (id, user_id, object_id, content, date) VALUES (?, ?, ?, ?, ?)`),
+		bson.NewObjectId().Hex(), userID, objectID, content, time.Now())
+	if err != nil {
+		return errors.Wrap(err, "failed to insert new comment")
+	}
+
+	return
+}
+
+// RemoveComment removes a comment by ID, the same ObjectId format as MongoDB is used for IDs
+func (s *SQL) RemoveComment(commentID bson.ObjectId) (err error) {
+	return checkAffected(s.db.Exec(s.rebind(`DELETE FROM comments WHERE id = ?`), commentID.Hex()))
+}
+
+// PurgeComment removes a trashed comment if it was trashed at or before the given time
+func (s *SQL) PurgeComment(commentID bson.ObjectId, before time.Time) (err error) {
+	return checkAffected(s.db.Exec(s.rebind(`DELETE FROM comments WHERE id = ? AND deleted <= ?`), commentID.Hex(), before))
+}
+
+// GetComment returns a single comment by ID, including trashed comments
+func (s *SQL) GetComment(commentID bson.ObjectId) (comment types.Comment, err error) {
+	comments, err := s.queryComments(`WHERE id = ?`, commentID.Hex())
+	if err != nil {
+		return
+	}
+	if len(comments) == 0 {
+		return comment, ErrNotFound
+	}
+	return comments[0], nil
+}
+
+// TrashComment soft deletes a comment
+func (s *SQL) TrashComment(commentID bson.ObjectId) (err error) {
+	return checkAffected(s.db.Exec(s.rebind(`UPDATE comments SET deleted = ? WHERE id = ? AND deleted IS NULL`), time.Now(), commentID.Hex()))
+}
+
+// RestoreComment takes a comment back out of the trash
+func (s *SQL) RestoreComment(commentID bson.ObjectId) (err error) {
+	return checkAffected(s.db.Exec(s.rebind(`UPDATE comments SET deleted = NULL WHERE id = ? AND deleted IS NOT NULL`), commentID.Hex()))
+}
@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// sqlFacetQueries select each value of a field and the number of objects matching the conditions
+// filled in for %s that have it
+var sqlFacetQueries = map[string]string{
+	facetCategory: `SELECT category, COUNT(*) FROM objects WHERE %s GROUP BY category`,
+	facetTag:      `SELECT tag, COUNT(DISTINCT object_id) FROM object_tags WHERE object_id IN (SELECT id FROM objects WHERE %s) GROUP BY tag`,
+	facetOwner:    `SELECT owner_name, COUNT(*) FROM objects WHERE %s GROUP BY owner_name`,
+}
+
+// GetFacets counts the objects matching a query by category, tag and owner
+func (s *SQL) GetFacets(query ObjectQuery) (facets Facets, err error) {
+	facets, err = countFacets(query, s.groupFacet)
+	if err != nil {
+		return
+	}
+
+	where, args, err := s.objectWhere(query)
+	if err != nil {
+		return
+	}
+	err = s.db.QueryRow(s.rebind(`SELECT COUNT(*) FROM objects WHERE `+strings.Join(where, ` AND `)), args...).Scan(&facets.Total)
+	return
+}
+
+// groupFacet counts the objects matching a query by the values of a field
+func (s *SQL) groupFacet(field string, query ObjectQuery) (facet []Facet, err error) {
+	where, args, err := s.objectWhere(query)
+	if err != nil {
+		return
+	}
+
+	statement := fmt.Sprintf(sqlFacetQueries[field], strings.Join(where, ` AND `)) +
+		` ORDER BY 2 DESC, 1 LIMIT ` + strconv.Itoa(facetLimit)
+	rows, err := s.db.Query(s.rebind(statement), args...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	facet = []Facet{}
+	for rows.Next() {
+		var f Facet
+		err = rows.Scan(&f.Value, &f.Count)
+		if err != nil {
+			return
+		}
+		facet = append(facet, f)
+	}
+	err = rows.Err()
+	return
+}
@@ -0,0 +1,720 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/mgo.v2/bson"
+
+	"github.com/Southclaws/samp-objects-api/types"
+)
+
+// sqlSortColumns maps the MongoDB field names accepted by GetObjects to columns
+var sqlSortColumns = map[string]string{
+	"_id":       "seq",
+	"id":        "id",
+	"name":      "name",
+	"ownername": "owner_name",
+	"category":  "category",
+	"ratecount": "rate_count",
+	"ratetotal": "rate_total",
+}
+
+// CreateObject creates a new object in the database
+func (s *SQL) CreateObject(object types.Object) (err error) {
+	object.Tags = types.NormaliseTags(object.Tags)
+	if err = object.Validate(); err != nil {
+		return
+	}
+
+	document, err := bson.Marshal(object)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode object")
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return
+	}
+
+	_, err = tx.Exec(s.rebind(`INSERT INTO objects
+		(id, owner_id, owner_name, name, category, rate_count, rate_total,
+		rate_star_0, rate_star_1, rate_star_2, rate_star_3, rate_star_4, rate_star_5, deleted, document)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`),
+		object.ID, object.OwnerID, object.OwnerName, object.Name, object.Category,
+		object.RateCount, object.RateTotal,
+		object.RateHistogram[0], object.RateHistogram[1], object.RateHistogram[2],
+		object.RateHistogram[3], object.RateHistogram[4], object.RateHistogram[5],
+		object.Deleted, document)
+	if err != nil {
+		tx.Rollback()
+		if isUniqueViolation(err, "objects_unique_name", "objects.owner_id", "objects.name") {
+			return ErrObjectNameAlreadyExists
+		}
+		return
+	}
+
+	err = s.insertTags(tx, object)
+	if err != nil {
+		tx.Rollback()
+		return
+	}
+
+	err = s.insertFiles(tx, object)
+	if err != nil {
+		tx.Rollback()
+		return
+	}
+
+	err = s.indexText(tx, object)
+	if err != nil {
+		tx.Rollback()
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return
+	}
+
+	s.leases.release(object.ID)
+	return
+}
+
+// UpdateObject updates a object's information
+func (s *SQL) UpdateObject(object types.Object) (err error) {
+	object.Tags = types.NormaliseTags(object.Tags)
+	if err = object.Validate(); err != nil {
+		return
+	}
+
+	document, err := bson.Marshal(object)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode object")
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return
+	}
+
+	// the rating columns are left alone, they are only changed by ratings and the caller's copy
+	// may be out of date. Reads take them from the columns rather than the document.
+	err = checkAffected(tx.Exec(s.rebind(`UPDATE objects SET
+		owner_id = ?, owner_name = ?, name = ?, category = ?, deleted = ?, document = ?
+		WHERE id = ?`),
+		object.OwnerID, object.OwnerName, object.Name, object.Category,
+		object.Deleted, document, object.ID))
+	if err != nil {
+		tx.Rollback()
+		return
+	}
+
+	_, err = tx.Exec(s.rebind(`DELETE FROM object_tags WHERE object_id = ?`), object.ID)
+	if err != nil {
+		tx.Rollback()
+		return
+	}
+
+	err = s.insertTags(tx, object)
+	if err != nil {
+		tx.Rollback()
+		return
+	}
+
+	_, err = tx.Exec(s.rebind(`DELETE FROM object_files WHERE object_id = ?`), object.ID)
+	if err != nil {
+		tx.Rollback()
+		return
+	}
+
+	err = s.insertFiles(tx, object)
+	if err != nil {
+		tx.Rollback()
+		return
+	}
+
+	err = s.indexText(tx, object)
+	if err != nil {
+		tx.Rollback()
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return
+	}
+
+	s.leases.release(object.ID)
+	return
+}
+
+func (s *SQL) insertTags(tx *sql.Tx, object types.Object) (err error) {
+	seen := make(map[types.ObjectTag]bool)
+	for _, tag := range object.Tags {
+		if seen[tag] {
+			continue
+		}
+		seen[tag] = true
+
+		_, err = tx.Exec(s.rebind(`INSERT INTO object_tags (object_id, tag) VALUES (?, ?)`), object.ID, tag)
+		if err != nil {
+			return errors.Wrap(err, "failed to insert object tag")
+		}
+	}
+	return
+}
+
+// insertFiles records which blob each file of every version of an object refers to
+func (s *SQL) insertFiles(tx *sql.Tx, object types.Object) (err error) {
+	for _, version := range object.History() {
+		seen := make(map[types.File]bool)
+		for _, info := range version.Files {
+			if seen[info.Name] {
+				continue
+			}
+			seen[info.Name] = true
+
+			_, err = tx.Exec(s.rebind(`INSERT INTO object_files (object_id, version, name, size, sha256) VALUES (?, ?, ?, ?, ?)`),
+				object.ID, version.Version, info.Name, info.Size, info.SHA256)
+			if err != nil {
+				return errors.Wrap(err, "failed to insert object file")
+			}
+		}
+	}
+	return
+}
+
+// PutObjectFile uploads a file to an object's folder in the file store from an io.Reader and
+// returns its size and checksums
+func (s *SQL) PutObjectFile(objectID types.ObjectID, filename, contentType string, reader io.Reader) (info types.FileInfo, err error) {
+	return putObjectFile(s.blobs, s.leases, objectID, filename, contentType, reader)
+}
+
+// GetObjectThumb writes a thumbnail of the first image from an object to the given writer
+func (s *SQL) GetObjectThumb(objectID types.ObjectID, size uint, writer io.Writer) (err error) {
+	if err = objectID.Validate(); err != nil {
+		err = errors.Wrap(err, "invalid object ID format")
+		return
+	}
+
+	object, err := s.GetObject(objectID)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to lookup object %s", string(objectID))
+		return
+	}
+
+	return getObjectThumb(s.blobs, object, size, writer)
+}
+
+// GetObjectFile writes the specified file from a version of an object to the given writer
+func (s *SQL) GetObjectFile(objectID types.ObjectID, version int, fileName types.File, writer io.Writer) (err error) {
+	if err = objectID.Validate(); err != nil {
+		err = errors.Wrap(err, "invalid object ID format")
+		return
+	}
+
+	object, err := s.GetObject(objectID)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to lookup object %s", string(objectID))
+		return
+	}
+
+	return getObjectFile(s.blobs, object, version, fileName, writer)
+}
+
+// OpenObjectFile opens the specified file from a version of an object along with its stat info
+// from the blob store, the caller must close it
+func (s *SQL) OpenObjectFile(objectID types.ObjectID, version int, fileName types.File) (blob Blob, info BlobInfo, err error) {
+	if err = objectID.Validate(); err != nil {
+		err = errors.Wrap(err, "invalid object ID format")
+		return
+	}
+
+	object, err := s.GetObject(objectID)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to lookup object %s", string(objectID))
+		return
+	}
+
+	return openObjectFile(s.blobs, object, version, fileName)
+}
+
+// GetTexturePreview writes a texture from a texture dictionary of an object to the given writer
+// as a PNG
+func (s *SQL) GetTexturePreview(objectID types.ObjectID, version int, fileName types.File, texture string, width, height uint, writer io.Writer) (err error) {
+	if err = objectID.Validate(); err != nil {
+		err = errors.Wrap(err, "invalid object ID format")
+		return
+	}
+
+	object, err := s.GetObject(objectID)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to lookup object %s", string(objectID))
+		return
+	}
+
+	return getTexturePreview(s.blobs, object, version, fileName, texture, width, height, writer)
+}
+
+// GetObjectImage writes the web version of one of the images from a version of an object to the
+// given writer
+func (s *SQL) GetObjectImage(objectID types.ObjectID, version int, fileName types.File, writer io.Writer) (err error) {
+	if err = objectID.Validate(); err != nil {
+		err = errors.Wrap(err, "invalid object ID format")
+		return
+	}
+
+	object, err := s.GetObject(objectID)
+	if err != nil {
+		err = errors.Wrapf(err, "failed to lookup object %s", string(objectID))
+		return
+	}
+
+	return getWebImage(s.blobs, object, version, fileName, writer)
+}
+
+// DeleteObject deletes a object and any of its files that no other object shares
+func (s *SQL) DeleteObject(objectID types.ObjectID) (err error) {
+	return s.deleteObject(objectID, ``)
+}
+
+// PurgeObject deletes a trashed object and its files if it was trashed at or before the given time
+func (s *SQL) PurgeObject(objectID types.ObjectID, before time.Time) (err error) {
+	return s.deleteObject(objectID, ` AND deleted <= ?`, before)
+}
+
+// deleteObject deletes an object if it matches condition, the object row is deleted last in the
+// same transaction as its tags, files and search index so they are kept when it doesn't match
+func (s *SQL) deleteObject(objectID types.ObjectID, condition string, args ...interface{}) (err error) {
+	if err = objectID.Validate(); err != nil {
+		return
+	}
+
+	object, err := s.GetObject(objectID)
+	if err != nil {
+		return
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return
+	}
+
+	_, err = tx.Exec(s.rebind(`DELETE FROM object_tags WHERE object_id = ?`), objectID)
+	if err != nil {
+		tx.Rollback()
+		return
+	}
+
+	_, err = tx.Exec(s.rebind(`DELETE FROM object_files WHERE object_id = ?`), objectID)
+	if err != nil {
+		tx.Rollback()
+		return
+	}
+
+	err = s.removeText(tx, objectID)
+	if err != nil {
+		tx.Rollback()
+		return
+	}
+
+	err = checkAffected(tx.Exec(s.rebind(`DELETE FROM objects WHERE id = ?`+condition), append([]interface{}{objectID}, args...)...))
+	if err != nil {
+		tx.Rollback()
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return
+	}
+
+	return removeObjectFiles(s.blobs, s.leases, object, s.blobReferenced)
+}
+
+// DiscardObjectFiles removes the files stored for an object that was never created, such as an
+// upload that failed part way through, unless another object shares them
+func (s *SQL) DiscardObjectFiles(objectID types.ObjectID, files []types.FileInfo) (err error) {
+	if err = objectID.Validate(); err != nil {
+		return
+	}
+	s.leases.release(objectID)
+	return removeObjectFiles(s.blobs, s.leases, types.Object{ID: objectID, Files: files}, s.blobReferenced)
+}
+
+// blobReferenced reports whether any object has a file with the given contents
+func (s *SQL) blobReferenced(sha256 string) (bool, error) {
+	var count int
+	err := s.db.QueryRow(s.rebind(`SELECT COUNT(*) FROM object_files WHERE sha256 = ?`), sha256).Scan(&count)
+	return count > 0, err
+}
+
+// DedupReport calculates how much space is saved by objects sharing files
+func (s *SQL) DedupReport() (report DedupReport, err error) {
+	rows, err := s.db.Query(`SELECT name, size, sha256 FROM object_files`)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	var files []types.FileInfo
+	for rows.Next() {
+		var info types.FileInfo
+		err = rows.Scan(&info.Name, &info.Size, &info.SHA256)
+		if err != nil {
+			return
+		}
+		files = append(files, info)
+	}
+	err = rows.Err()
+	if err != nil {
+		return
+	}
+
+	return dedupReport(files), nil
+}
+
+// GetObjects returns a list of objects based on query parameters
+func (s *SQL) GetObjects(query ObjectQuery, page PageQuery) (result ObjectPage, err error) {
+	where, args, err := s.objectWhere(query)
+	if err != nil {
+		return
+	}
+
+	err = s.db.QueryRow(s.rebind(`SELECT COUNT(*) FROM objects WHERE `+strings.Join(where, ` AND `)), args...).Scan(&result.Total)
+	if err != nil {
+		return
+	}
+
+	sort := query.objectSort()
+	var prior float64
+	if sort.field == "score" {
+		prior, err = scorePrior(page.Cursor, s.ratingPrior)
+		if err != nil {
+			return
+		}
+	}
+	join, key := s.sortExpression(sort.field, parseSearch(query.Search), prior)
+	if page.Cursor != "" {
+		var (
+			after     string
+			afterArgs []interface{}
+		)
+		after, afterArgs, err = sqlAfter(sort, key, page.Cursor)
+		if err != nil {
+			return
+		}
+		where = append(where, after)
+		args = append(args, afterArgs...)
+	}
+
+	statement := `SELECT ` + sqlObjectColumns + `, seq, ` + key + ` FROM objects` + join + ` WHERE ` + strings.Join(where, ` AND `) +
+		` ORDER BY ` + sqlOrder(sort, key)
+	if limit := page.fetchLimit(); limit > 0 {
+		statement += ` LIMIT ?`
+		args = append(args, limit)
+	}
+
+	objects, keys, err := s.queryObjectKeys(sort.numeric(), statement, args...)
+	if err != nil {
+		return
+	}
+
+	if page.Limit > 0 && len(objects) > page.Limit {
+		objects = objects[:page.Limit]
+		next := keys[page.Limit-1]
+		if sort.field == "_id" {
+			next.Key = nil
+		}
+		if sort.field == "score" {
+			next.Prior = &prior
+		}
+		result.Next = next.encode()
+	}
+	result.Objects = objects
+	return
+}
+
+// objectWhere returns the conditions and arguments that select the objects matching a query
+func (s *SQL) objectWhere(query ObjectQuery) (where []string, args []interface{}, err error) {
+	where = []string{`deleted IS NULL`}
+
+	if query.UserName != "" {
+		where = append(where, `owner_name = ?`)
+		args = append(args, query.UserName)
+	}
+
+	if query.Category != "" {
+		where = append(where, `category = ?`)
+		args = append(args, query.Category)
+	}
+
+	if len(query.Tags) > 0 {
+		var aliases []types.TagAlias
+		aliases, err = s.GetTagAliases()
+		if err != nil {
+			return
+		}
+		if tags := expandTags(aliases, query.Tags); len(tags) > 0 {
+			where = append(where, `id IN (SELECT object_id FROM object_tags WHERE tag IN (`+placeholders(len(tags))+`))`)
+			for _, tag := range tags {
+				args = append(args, tag)
+			}
+		}
+	}
+
+	if words := parseSearch(query.Search); len(words) > 0 {
+		condition, arg := s.textCondition(words)
+		where = append(where, condition)
+		args = append(args, arg)
+	}
+	return
+}
+
+// sortExpression returns the SQL expression for an objectSort field and any join it needs, words
+// are the search that "relevance" ranks by and prior is the rating prior "score" is calculated with
+func (s *SQL) sortExpression(field string, words []string, prior float64) (join, key string) {
+	switch field {
+	case "score":
+		// the prior is a number, never text from the user, so it's safe to format into the query
+		return ``, fmt.Sprintf("CAST(%f + rate_total AS DOUBLE PRECISION) / (%d + rate_count)",
+			ratingConfidence*prior, ratingConfidence)
+	case SortRelevance:
+		return s.relevance(words)
+	}
+	return ``, sqlSortColumns[field]
+}
+
+// sqlOrder returns the ORDER BY clause for a sort on key, rows with equal keys are ordered by seq
+func sqlOrder(sort objectSort, key string) string {
+	direction := func(descending bool) string {
+		if descending {
+			return "DESC"
+		}
+		return "ASC"
+	}
+	if sort.field == "_id" {
+		return "seq " + direction(sort.descending)
+	}
+	return key + " " + direction(sort.descending) + ", seq " + direction(sort.tieDescending())
+}
+
+// sqlAfter returns the condition and arguments for the rows that come after a cursor
+func sqlAfter(sort objectSort, key string, cursor string) (condition string, args []interface{}, err error) {
+	k, err := decodeKeyset(cursor)
+	if err != nil {
+		return
+	}
+	seq, ok := k.ID.(float64)
+	if !ok {
+		return "", nil, ErrInvalidCursor
+	}
+	if err = sort.checkKey(k); err != nil {
+		return
+	}
+
+	op := func(descending bool) string {
+		if descending {
+			return "<"
+		}
+		return ">"
+	}
+	if sort.field == "_id" {
+		return "seq " + op(sort.descending) + " ?", []interface{}{int64(seq)}, nil
+	}
+
+	value := k.Key
+	if key == "rate_count" {
+		value = int64(value.(float64))
+	}
+	return "(" + key + " " + op(sort.descending) + " ? OR (" + key + " = ? AND seq " + op(sort.tieDescending()) + " ?))",
+		[]interface{}{value, value, int64(seq)}, nil
+}
+
+// ratingPrior returns the mean of every rating across all objects
+func (s *SQL) ratingPrior() (float64, error) {
+	var (
+		total types.ObjectRateTotal
+		count types.ObjectRateCount
+	)
+	err := s.db.QueryRow(`SELECT COALESCE(SUM(rate_total), 0), COALESCE(SUM(rate_count), 0) FROM objects`).Scan(&total, &count)
+	return ratingPrior(total, count), err
+}
+
+// GetObject returns a types.Object by their unique ID
+func (s *SQL) GetObject(objectID types.ObjectID) (object types.Object, err error) {
+	return s.queryObject(`SELECT `+sqlObjectColumns+` FROM objects WHERE id = ?`, objectID)
+}
+
+// GetUserObjects returns an array of types.Object from a specific owner
+func (s *SQL) GetUserObjects(userName types.UserName) (objects []types.Object, err error) {
+	if err = userName.Validate(); err != nil {
+		return
+	}
+
+	return s.queryObjects(`SELECT `+sqlObjectColumns+` FROM objects WHERE owner_name = ? AND deleted IS NULL ORDER BY seq`, userName)
+}
+
+// GetUserObject returns a types.Object from a specific owner and an object name
+func (s *SQL) GetUserObject(userName types.UserName, objectName types.ObjectName) (object types.Object, err error) {
+	if err = userName.Validate(); err != nil {
+		return
+	}
+
+	return s.queryObject(`SELECT `+sqlObjectColumns+` FROM objects WHERE owner_name = ? AND name = ? AND deleted IS NULL`, userName, objectName)
+}
+
+// TrashObject soft deletes an object, it keeps its name and files until it is purged
+func (s *SQL) TrashObject(objectID types.ObjectID) (err error) {
+	if err = objectID.Validate(); err != nil {
+		return
+	}
+
+	object, err := s.GetObject(objectID)
+	if err != nil {
+		return
+	}
+	if object.Deleted != nil {
+		return ErrNotFound
+	}
+
+	now := time.Now()
+	object.Deleted = &now
+	return s.UpdateObject(object)
+}
+
+// RestoreObject takes an object back out of the trash
+func (s *SQL) RestoreObject(objectID types.ObjectID) (err error) {
+	if err = objectID.Validate(); err != nil {
+		return
+	}
+
+	object, err := s.GetObject(objectID)
+	if err != nil {
+		return
+	}
+	if object.Deleted == nil {
+		return ErrNotFound
+	}
+
+	object.Deleted = nil
+	return s.UpdateObject(object)
+}
+
+// ObjectExists checks if an object exists by their unique ID
+func (s *SQL) ObjectExists(objectID types.ObjectID) (exists bool, err error) {
+	var count int
+	err = s.db.QueryRow(s.rebind(`SELECT COUNT(*) FROM objects WHERE id = ?`), objectID).Scan(&count)
+	exists = count > 0
+	return
+}
+
+// UserObjectExists checks if an object exists by their name in a user's account
+func (s *SQL) UserObjectExists(object types.Object) (exists bool, err error) {
+	var count int
+	err = s.db.QueryRow(s.rebind(`SELECT COUNT(*) FROM objects WHERE owner_id = ? AND name = ?`), object.OwnerID, object.Name).Scan(&count)
+	exists = count > 0
+	return
+}
+
+// sqlObjectColumns is the column list expected by queryObjects
+const sqlObjectColumns = `document, rate_count, rate_total,
+	rate_star_0, rate_star_1, rate_star_2, rate_star_3, rate_star_4, rate_star_5`
+
+// queryObject runs a query that selects sqlObjectColumns for a single row
+func (s *SQL) queryObject(query string, args ...interface{}) (object types.Object, err error) {
+	objects, err := s.queryObjects(query, args...)
+	if err != nil {
+		return
+	}
+	if len(objects) == 0 {
+		err = ErrNotFound
+		return
+	}
+	return objects[0], nil
+}
+
+// queryObjects runs a query that selects sqlObjectColumns, the rating columns are authoritative as
+// they are updated in place by AddRating and RemoveRating.
+func (s *SQL) queryObjects(query string, args ...interface{}) (objects []types.Object, err error) {
+	rows, err := s.db.Query(s.rebind(query), args...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var object types.Object
+		object, err = scanObject(rows)
+		if err != nil {
+			return
+		}
+		objects = append(objects, object)
+	}
+	err = rows.Err()
+	return
+}
+
+// queryObjectKeys runs a query that selects sqlObjectColumns followed by seq and a sort key, which
+// is a number when numeric is set, and returns the keyset of each object
+func (s *SQL) queryObjectKeys(numeric bool, query string, args ...interface{}) (objects []types.Object, keys []keyset, err error) {
+	rows, err := s.db.Query(s.rebind(query), args...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			object types.Object
+			seq    float64
+			number float64
+			text   string
+		)
+		key := interface{}(&text)
+		if numeric {
+			key = &number
+		}
+		object, err = scanObject(rows, &seq, key)
+		if err != nil {
+			return
+		}
+		objects = append(objects, object)
+		if numeric {
+			keys = append(keys, keyset{Key: number, ID: seq})
+		} else {
+			keys = append(keys, keyset{Key: text, ID: seq})
+		}
+	}
+	err = rows.Err()
+	return
+}
+
+// scanObject reads an object from a row that selects sqlObjectColumns, any columns after them are
+// scanned into extra
+func scanObject(rows *sql.Rows, extra ...interface{}) (object types.Object, err error) {
+	var (
+		document  []byte
+		aggregate RatingAggregate
+	)
+	h := &aggregate.Histogram
+	err = rows.Scan(append([]interface{}{&document, &aggregate.Count, &aggregate.Total, &h[0], &h[1], &h[2], &h[3], &h[4], &h[5]}, extra...)...)
+	if err != nil {
+		return
+	}
+
+	err = bson.Unmarshal(document, &object)
+	if err != nil {
+		return object, errors.Wrap(err, "failed to decode object")
+	}
+	object.RateCount = aggregate.Count
+	object.RateTotal = aggregate.Total
+	object.RateHistogram = aggregate.Histogram
+	object.UpdateRateAverage()
+	return
+}
@@ -0,0 +1,242 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/Southclaws/samp-objects-api/types"
+)
+
+// AddRating adds a rating to an object from a user, the rating and the object's aggregate
+// counters are written in one transaction so they can never disagree.
+func (s *SQL) AddRating(userID types.UserID, objectID types.ObjectID, value float64) (exists bool, err error) {
+	if err = userID.Validate(); err != nil {
+		return
+	}
+	if err = objectID.Validate(); err != nil {
+		return
+	}
+	if value < 0.0 || value > 5.0 {
+		return false, errors.New("invalid rating value")
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return
+	}
+
+	_, err = tx.Exec(s.rebind(`INSERT INTO ratings (user_id, object_id, value, date) VALUES (?, ?, ?, ?)`),
+		userID, objectID, value, time.Now())
+	if err != nil {
+		tx.Rollback()
+		if isUniqueViolation(err, "ratings_unique_user_object", "ratings.user_id", "ratings.object_id") {
+			return true, nil
+		}
+		return false, errors.Wrap(err, "failed to insert new rating")
+	}
+
+	err = checkAffected(tx.Exec(s.rebind(fmt.Sprintf(
+		`UPDATE objects SET rate_count = rate_count + 1, rate_total = rate_total + ?, rate_star_%[1]d = rate_star_%[1]d + 1 WHERE id = ?`,
+		types.RatingStar(value))),
+		value, objectID))
+	if err != nil {
+		tx.Rollback()
+		return
+	}
+
+	err = tx.Commit()
+	return
+}
+
+// RemoveRating removes a user's rating from an object
+func (s *SQL) RemoveRating(userID types.UserID, objectID types.ObjectID) (err error) {
+	if err = userID.Validate(); err != nil {
+		return
+	}
+	if err = objectID.Validate(); err != nil {
+		return
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return
+	}
+
+	var value float64
+	err = tx.QueryRow(s.rebind(`SELECT value FROM ratings WHERE user_id = ? AND object_id = ?`), userID, objectID).Scan(&value)
+	if err != nil {
+		tx.Rollback()
+		if err == sql.ErrNoRows {
+			err = ErrNotFound
+		}
+		return errors.Wrap(err, "failed to find rating")
+	}
+
+	_, err = tx.Exec(s.rebind(`DELETE FROM ratings WHERE user_id = ? AND object_id = ?`), userID, objectID)
+	if err != nil {
+		tx.Rollback()
+		return errors.Wrap(err, "failed to remove rating")
+	}
+
+	err = checkAffected(tx.Exec(s.rebind(fmt.Sprintf(
+		`UPDATE objects SET rate_count = rate_count - 1, rate_total = rate_total - ?, rate_star_%[1]d = rate_star_%[1]d - 1 WHERE id = ?`,
+		types.RatingStar(value))),
+		value, objectID))
+	if err != nil {
+		tx.Rollback()
+		return
+	}
+
+	return tx.Commit()
+}
+
+// GetRatings returns a page of the ratings on an object
+func (s *SQL) GetRatings(objectID types.ObjectID, page PageQuery) (result RatingPage, err error) {
+	if err = objectID.Validate(); err != nil {
+		return
+	}
+
+	err = s.db.QueryRow(s.rebind(`SELECT COUNT(*) FROM ratings WHERE object_id = ?`), objectID).Scan(&result.Total)
+	if err != nil {
+		return
+	}
+
+	query := `SELECT user_id, object_id, value, date FROM ratings WHERE object_id = ?`
+	args := []interface{}{objectID}
+	if page.Cursor != "" {
+		var (
+			date   time.Time
+			userID types.UserID
+		)
+		date, userID, err = ratingAfter(page.Cursor)
+		if err != nil {
+			return
+		}
+		query += ` AND (date > ? OR (date = ? AND user_id > ?))`
+		args = append(args, date, date, userID)
+	}
+	query += ` ORDER BY date, user_id`
+	if limit := page.fetchLimit(); limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, limit)
+	}
+
+	rows, err := s.db.Query(s.rebind(query), args...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	var ratings []types.Rating
+	for rows.Next() {
+		var rating types.Rating
+		err = rows.Scan(&rating.UserID, &rating.ObjectID, &rating.Value, &rating.Date)
+		if err != nil {
+			return
+		}
+		ratings = append(ratings, rating)
+	}
+	err = rows.Err()
+	if err != nil {
+		return
+	}
+	result.Ratings, result.Next = nextRatings(ratings, page)
+	return
+}
+
+// ReconcileRatings recalculates the rating counters of every object from the ratings table, the
+// counters are only ever changed inside the same transaction as a rating so drift should only
+// appear after manual edits or a restore. When fix is set the counters are corrected and only the
+// objects that were changed are returned.
+func (s *SQL) ReconcileRatings(fix bool) (drift []RatingDrift, err error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return
+	}
+	defer tx.Rollback()
+
+	// counters are read before ratings, see readRatingCounters
+	stored := make(map[types.ObjectID]RatingAggregate)
+	rows, err := tx.Query(`SELECT id, rate_count, rate_total,
+		rate_star_0, rate_star_1, rate_star_2, rate_star_3, rate_star_4, rate_star_5 FROM objects`)
+	if err != nil {
+		return
+	}
+	for rows.Next() {
+		var (
+			objectID  types.ObjectID
+			aggregate RatingAggregate
+		)
+		h := &aggregate.Histogram
+		err = rows.Scan(&objectID, &aggregate.Count, &aggregate.Total, &h[0], &h[1], &h[2], &h[3], &h[4], &h[5])
+		if err != nil {
+			rows.Close()
+			return
+		}
+		stored[objectID] = aggregate
+	}
+	rows.Close()
+
+	ratings, err := scanRatingValues(tx.Query(`SELECT object_id, value FROM ratings`))
+	if err != nil {
+		return
+	}
+
+	drift = ratingDrift(stored, aggregateRatings(ratings))
+	if !fix {
+		return
+	}
+	// the transaction only gives the scan a consistent view, each object is fixed on its own
+	tx.Rollback()
+	return fixRatingDrift(s, drift)
+}
+
+func (s *SQL) readRatingCounters(objectID types.ObjectID) (stored, actual RatingAggregate, err error) {
+	h := &stored.Histogram
+	err = s.db.QueryRow(s.rebind(`SELECT rate_count, rate_total,
+		rate_star_0, rate_star_1, rate_star_2, rate_star_3, rate_star_4, rate_star_5 FROM objects WHERE id = ?`), objectID).
+		Scan(&stored.Count, &stored.Total, &h[0], &h[1], &h[2], &h[3], &h[4], &h[5])
+	if err == sql.ErrNoRows {
+		return stored, actual, ErrNotFound
+	} else if err != nil {
+		return
+	}
+
+	ratings, err := scanRatingValues(s.db.Query(s.rebind(`SELECT object_id, value FROM ratings WHERE object_id = ?`), objectID))
+	if err != nil {
+		return
+	}
+	return stored, aggregateRatings(ratings)[objectID], nil
+}
+
+func (s *SQL) saveRatingCounters(objectID types.ObjectID, stored, actual RatingAggregate) error {
+	h, was := actual.Histogram, stored.Histogram
+	return checkAffected(s.db.Exec(s.rebind(`UPDATE objects SET rate_count = ?, rate_total = ?,
+		rate_star_0 = ?, rate_star_1 = ?, rate_star_2 = ?, rate_star_3 = ?, rate_star_4 = ?, rate_star_5 = ?
+		WHERE id = ? AND rate_count = ? AND rate_total = ?
+		AND rate_star_0 = ? AND rate_star_1 = ? AND rate_star_2 = ? AND rate_star_3 = ? AND rate_star_4 = ? AND rate_star_5 = ?`),
+		actual.Count, actual.Total, h[0], h[1], h[2], h[3], h[4], h[5],
+		objectID, stored.Count, stored.Total, was[0], was[1], was[2], was[3], was[4], was[5]))
+}
+
+// scanRatingValues reads the object ID and value of each rating a query returns
+func scanRatingValues(rows *sql.Rows, err error) ([]types.Rating, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ratings []types.Rating
+	for rows.Next() {
+		var rating types.Rating
+		err = rows.Scan(&rating.ObjectID, &rating.Value)
+		if err != nil {
+			return nil, err
+		}
+		ratings = append(ratings, rating)
+	}
+	return ratings, rows.Err()
+}
@@ -0,0 +1,148 @@
+package storage
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/Southclaws/samp-objects-api/types"
+)
+
+// textSearch is the full-text search built into an SQL database that objects are searched with
+type textSearch int
+
+const (
+	// searchFTS5 indexes objects in an FTS5 table whose rowid is the object's seq, results are
+	// ranked by bm25. SQLite has to be built with the sqlite_fts5 tag.
+	searchFTS5 textSearch = iota + 1
+
+	// searchTSVector indexes objects in a weighted tsvector column, results are ranked by ts_rank
+	searchTSVector
+)
+
+// checkTextSearch makes sure the database supports the full-text search objects are indexed with,
+// go-sqlite3 leaves FTS5 out unless it is built with the sqlite_fts5 tag
+func (s *SQL) checkTextSearch() (err error) {
+	if s.dialect.search != searchFTS5 {
+		return
+	}
+	var enabled bool
+	err = s.db.QueryRow(`SELECT sqlite_compileoption_used('ENABLE_FTS5')`).Scan(&enabled)
+	if err != nil {
+		return errors.Wrap(err, "failed to check for SQLite FTS5 support")
+	}
+	if !enabled {
+		return errors.New(`SQLite was built without FTS5 which search needs, build with -tags "sqlite sqlite_fts5"`)
+	}
+	return
+}
+
+// indexText indexes an object for search, replacing whatever was indexed for it before
+func (s *SQL) indexText(tx *sql.Tx, object types.Object) (err error) {
+	name, tags, description := searchText(object)
+	switch s.dialect.search {
+	case searchFTS5:
+		err = s.removeText(tx, object.ID)
+		if err != nil {
+			return
+		}
+		_, err = tx.Exec(s.rebind(`INSERT INTO object_text (rowid, name, tags, description)
+			SELECT seq, ?, ?, ? FROM objects WHERE id = ?`),
+			name, tags, description, object.ID)
+	case searchTSVector:
+		_, err = tx.Exec(s.rebind(`UPDATE objects SET search_text =
+			setweight(to_tsvector('simple', ?), 'A') ||
+			setweight(to_tsvector('simple', ?), 'B') ||
+			setweight(to_tsvector('simple', ?), 'C')
+			WHERE id = ?`),
+			name, tags, description, object.ID)
+	}
+	return errors.Wrap(err, "failed to index object for search")
+}
+
+// removeText removes an object from the search index, a tsvector is removed along with its row
+func (s *SQL) removeText(tx *sql.Tx, objectID types.ObjectID) (err error) {
+	if s.dialect.search == searchFTS5 {
+		_, err = tx.Exec(s.rebind(`DELETE FROM object_text WHERE rowid IN (SELECT seq FROM objects WHERE id = ?)`), objectID)
+	}
+	return
+}
+
+// indexObjectText indexes every object that is missing from the search index, which is every
+// object that was created before full-text search was added
+func (s *SQL) indexObjectText() (err error) {
+	missing := `seq NOT IN (SELECT rowid FROM object_text)`
+	if s.dialect.search == searchTSVector {
+		missing = `search_text IS NULL`
+	}
+	objects, err := s.queryObjects(`SELECT ` + sqlObjectColumns + ` FROM objects WHERE ` + missing)
+	if err != nil {
+		return
+	}
+
+	for _, object := range objects {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return err
+		}
+		err = s.indexText(tx, object)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		err = tx.Commit()
+		if err != nil {
+			return err
+		}
+	}
+	return
+}
+
+// textCondition returns the condition and argument that select the objects matching every word of
+// a search
+func (s *SQL) textCondition(words []string) (string, interface{}) {
+	if s.dialect.search == searchTSVector {
+		return `search_text @@ to_tsquery('simple', ?)`, tsQuery(words)
+	}
+	return `seq IN (SELECT rowid FROM object_text WHERE object_text MATCH ?)`, ftsQuery(words)
+}
+
+// relevance returns the join and expression that rank objects by how well they match a search,
+// names count three times as much as descriptions and tags twice as much. The search is written
+// into the SQL rather than passed as an argument because the expression is repeated in the select
+// list, the order and the cursor condition, search words are only letters and digits so they never
+// need escaping.
+func (s *SQL) relevance(words []string) (join, key string) {
+	if s.dialect.search == searchTSVector {
+		// the weights are for D, C, B and A
+		return ``, `CAST(ts_rank('{0, 0.333, 0.667, 1}', search_text, to_tsquery('simple', '` + tsQuery(words) + `')) AS DOUBLE PRECISION)`
+	}
+	// bm25 is lower for better matches
+	return ` JOIN (SELECT rowid AS text_seq, -bm25(object_text, 3.0, 2.0, 1.0) AS relevance FROM object_text
+		WHERE object_text MATCH '` + ftsQuery(words) + `') AS text_match ON text_seq = seq`, `relevance`
+}
+
+// ftsQuery returns the FTS5 query for the words of a search
+func ftsQuery(words []string) string {
+	terms := make([]string, len(words))
+	for i, word := range words {
+		terms[i] = `"` + word + `"`
+		if prefixSearch(word) {
+			terms[i] += `*`
+		}
+	}
+	return strings.Join(terms, ` AND `)
+}
+
+// tsQuery returns the tsquery for the words of a search
+func tsQuery(words []string) string {
+	terms := make([]string, len(words))
+	for i, word := range words {
+		terms[i] = word
+		if prefixSearch(word) {
+			terms[i] += `:*`
+		}
+	}
+	return strings.Join(terms, ` & `)
+}
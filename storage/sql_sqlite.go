@@ -0,0 +1,9 @@
+//go:build sqlite
+// +build sqlite
+
+package storage
+
+import (
+	// registers the "sqlite3" database/sql driver, this requires cgo and the sqlite_fts5 tag for search
+	_ "github.com/mattn/go-sqlite3"
+)
@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"github.com/Southclaws/samp-objects-api/types"
+)
+
+// CreateTagAlias makes a tag an alias of another
+func (s *SQL) CreateTagAlias(alias types.TagAlias) (err error) {
+	if err = alias.Validate(); err != nil {
+		return
+	}
+
+	aliases, err := s.GetTagAliases()
+	if err != nil {
+		return
+	}
+	if err = checkTagAlias(aliases, alias); err != nil {
+		return
+	}
+
+	_, err = s.db.Exec(s.rebind(`INSERT INTO tag_aliases (alias, tag) VALUES (?, ?)`), alias.Alias, alias.Tag)
+	if err != nil && isUniqueViolation(err, "tag_aliases_pkey", "tag_aliases.alias") {
+		return ErrTagAliasAlreadyExists
+	}
+	return
+}
+
+// DeleteTagAlias removes an alias so the tag stands on its own again
+func (s *SQL) DeleteTagAlias(alias types.ObjectTag) (err error) {
+	return checkAffected(s.db.Exec(s.rebind(`DELETE FROM tag_aliases WHERE alias = ?`), alias))
+}
+
+// GetTagAliases returns every tag alias sorted by alias
+func (s *SQL) GetTagAliases() (aliases []types.TagAlias, err error) {
+	rows, err := s.db.Query(`SELECT alias, tag FROM tag_aliases ORDER BY alias`)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var alias types.TagAlias
+		err = rows.Scan(&alias.Alias, &alias.Tag)
+		if err != nil {
+			return
+		}
+		aliases = append(aliases, alias)
+	}
+	err = rows.Err()
+	return
+}
+
+// GetTagCounts returns how many listed objects use each tag
+func (s *SQL) GetTagCounts() (counts map[types.ObjectTag]int, err error) {
+	rows, err := s.db.Query(`SELECT object_tags.tag, COUNT(*) FROM object_tags
+		JOIN objects ON objects.id = object_tags.object_id
+		WHERE objects.deleted IS NULL
+		GROUP BY object_tags.tag`)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	counts = make(map[types.ObjectTag]int)
+	for rows.Next() {
+		var (
+			tag   types.ObjectTag
+			count int
+		)
+		err = rows.Scan(&tag, &count)
+		if err != nil {
+			return
+		}
+		counts[tag] = count
+	}
+	err = rows.Err()
+	return
+}
+
+// normaliseTags rewrites every object that has a tag which is not normalised, which is every object
+// with such tags that was created before tags were normalised
+func (s *SQL) normaliseTags() (err error) {
+	rows, err := s.db.Query(`SELECT DISTINCT tag FROM object_tags`)
+	if err != nil {
+		return
+	}
+	var stale []interface{}
+	for rows.Next() {
+		var tag types.ObjectTag
+		err = rows.Scan(&tag)
+		if err != nil {
+			rows.Close()
+			return
+		}
+		if tag.Normalise() != tag {
+			stale = append(stale, tag)
+		}
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil || len(stale) == 0 {
+		return
+	}
+
+	objects, err := s.queryObjects(`SELECT `+sqlObjectColumns+` FROM objects
+		WHERE id IN (SELECT object_id FROM object_tags WHERE tag IN (`+placeholders(len(stale))+`))`, stale...)
+	if err != nil {
+		return
+	}
+	for _, object := range objects {
+		err = s.UpdateObject(object)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
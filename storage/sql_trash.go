@@ -0,0 +1,35 @@
+package storage
+
+import (
+	"github.com/Southclaws/samp-objects-api/types"
+)
+
+// GetTrash returns every trashed object, comment and user
+func (s *SQL) GetTrash() (trash Trash, err error) {
+	trash.Objects, err = s.queryObjects(`SELECT ` + sqlObjectColumns + ` FROM objects WHERE deleted IS NOT NULL ORDER BY seq`)
+	if err != nil {
+		return
+	}
+
+	trash.Comments, err = s.queryComments(`WHERE deleted IS NOT NULL ORDER BY seq`)
+	if err != nil {
+		return
+	}
+
+	rows, err := s.db.Query(`SELECT id, name, email, password, deleted FROM users WHERE deleted IS NOT NULL`)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var user types.User
+		err = rows.Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.Deleted)
+		if err != nil {
+			return
+		}
+		trash.Users = append(trash.Users, user)
+	}
+	err = rows.Err()
+	return
+}
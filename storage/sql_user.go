@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/Southclaws/samp-objects-api/types"
+)
+
+// CreateUser creates a new user in the database
+func (s *SQL) CreateUser(user types.User) (err error) {
+	if err = user.Validate(); err != nil {
+		return
+	}
+
+	_, err = s.db.Exec(s.rebind(`INSERT INTO users (id, name, email, password) VALUES (?, ?, ?, ?)`),
+		user.ID, user.Name, user.Email, user.Password)
+	if err != nil {
+		if isUniqueViolation(err, "users_unique_name", "users.name") {
+			return ErrUserNameAlreadyExists
+		} else if isUniqueViolation(err, "users_unique_email", "users.email") {
+			return ErrUserEmailAlreadyExists
+		}
+	}
+
+	return
+}
+
+// UpdateUser updates a user's account information
+func (s *SQL) UpdateUser(user types.User) (err error) {
+	if err = user.Validate(); err != nil {
+		return
+	}
+
+	return checkAffected(s.db.Exec(s.rebind(`UPDATE users SET name = ?, email = ?, password = ? WHERE id = ?`),
+		user.Name, user.Email, user.Password, user.ID))
+}
+
+// DeleteUser deletes a user's account
+func (s *SQL) DeleteUser(userID types.UserID) (err error) {
+	if err = userID.Validate(); err != nil {
+		return
+	}
+
+	return checkAffected(s.db.Exec(s.rebind(`DELETE FROM users WHERE id = ?`), userID))
+}
+
+// PurgeUser deletes a trashed user's account if it was trashed at or before the given time
+func (s *SQL) PurgeUser(userID types.UserID, before time.Time) (err error) {
+	if err = userID.Validate(); err != nil {
+		return
+	}
+
+	return checkAffected(s.db.Exec(s.rebind(`DELETE FROM users WHERE id = ? AND deleted <= ?`), userID, before))
+}
+
+// TrashUser soft deletes a user's account, the name and email stay reserved until it is purged
+func (s *SQL) TrashUser(userID types.UserID) (err error) {
+	if err = userID.Validate(); err != nil {
+		return
+	}
+
+	return checkAffected(s.db.Exec(s.rebind(`UPDATE users SET deleted = ? WHERE id = ? AND deleted IS NULL`), time.Now(), userID))
+}
+
+// RestoreUser takes a user's account back out of the trash
+func (s *SQL) RestoreUser(userID types.UserID) (err error) {
+	if err = userID.Validate(); err != nil {
+		return
+	}
+
+	return checkAffected(s.db.Exec(s.rebind(`UPDATE users SET deleted = NULL WHERE id = ? AND deleted IS NOT NULL`), userID))
+}
+
+// GetUser returns a types.User by their unique ID
+func (s *SQL) GetUser(userID types.UserID) (user types.User, exists bool, err error) {
+	if err = userID.Validate(); err != nil {
+		err = errors.Wrap(err, "invalid user ID")
+		return
+	}
+
+	user, exists, err = s.getUser(`id = ?`, string(userID))
+	if err != nil {
+		err = errors.Wrap(err, "failed to get user by ID")
+	}
+	return
+}
+
+// GetUserByName returns a types.User by their name
+func (s *SQL) GetUserByName(userName types.UserName) (user types.User, exists bool, err error) {
+	if err = userName.Validate(); err != nil {
+		err = errors.Wrap(err, "invalid user name")
+		return
+	}
+
+	user, exists, err = s.getUser(`name = ? AND deleted IS NULL`, string(userName))
+	if err != nil {
+		err = errors.Wrap(err, "failed to get user by name")
+	}
+	return
+}
+
+// UserExists checks if a user exists by their unique ID
+func (s *SQL) UserExists(userID types.UserID) (exists bool, err error) {
+	_, exists, err = s.GetUser(userID)
+	return
+}
+
+// UserExistsByName checks if a user exists by their name
+func (s *SQL) UserExistsByName(userName types.UserName) (exists bool, err error) {
+	_, exists, err = s.GetUserByName(userName)
+	return
+}
+
+// getUser looks up a single user, where must never come from user input
+func (s *SQL) getUser(where string, args ...interface{}) (user types.User, exists bool, err error) {
+	err = s.db.QueryRow(s.rebind(`SELECT id, name, email, password, deleted FROM users WHERE `+where), args...).
+		Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.Deleted)
+	if err == sql.ErrNoRows {
+		return types.User{}, false, nil
+	}
+	return user, err == nil, err
+}
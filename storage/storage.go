@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"io"
+	"time"
+
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+
+	"github.com/Southclaws/samp-objects-api/types"
+)
+
+// Storage describes everything the API needs from a persistent storage backend: user accounts,
+// object metadata, ratings, comments and the files that belong to each object. Database is the
+// MongoDB implementation, SQL is the database/sql implementation and Memory is an in-process
+// implementation for tests and demos.
+//
+// Trashed users, objects and comments are hidden from listings and lookups by name but are still
+// returned by lookups by ID with their Deleted time set so they can be restored. Purging deletes a
+// record only if it is still trashed and was trashed at or before the given time, otherwise it
+// returns ErrNotFound.
+type Storage interface {
+	CreateUser(user types.User) error
+	UpdateUser(user types.User) error
+	DeleteUser(userID types.UserID) error
+	GetUser(userID types.UserID) (types.User, bool, error)
+	GetUserByName(userName types.UserName) (types.User, bool, error)
+	UserExists(userID types.UserID) (bool, error)
+	UserExistsByName(userName types.UserName) (bool, error)
+	TrashUser(userID types.UserID) error
+	RestoreUser(userID types.UserID) error
+	PurgeUser(userID types.UserID, before time.Time) error
+
+	CreateObject(object types.Object) error
+	UpdateObject(object types.Object) error
+	DeleteObject(objectID types.ObjectID) error
+	DiscardObjectFiles(objectID types.ObjectID, files []types.FileInfo) error
+	GetObject(objectID types.ObjectID) (types.Object, error)
+	GetObjects(query ObjectQuery, page PageQuery) (ObjectPage, error)
+	GetFacets(query ObjectQuery) (Facets, error)
+	GetUserObjects(userName types.UserName) ([]types.Object, error)
+	GetUserObject(userName types.UserName, objectName types.ObjectName) (types.Object, error)
+	ObjectExists(objectID types.ObjectID) (bool, error)
+	UserObjectExists(object types.Object) (bool, error)
+	TrashObject(objectID types.ObjectID) error
+	RestoreObject(objectID types.ObjectID) error
+	PurgeObject(objectID types.ObjectID, before time.Time) error
+
+	PutObjectFile(objectID types.ObjectID, filename, contentType string, reader io.Reader) (types.FileInfo, error)
+	GetObjectFile(objectID types.ObjectID, version int, fileName types.File, writer io.Writer) error
+	OpenObjectFile(objectID types.ObjectID, version int, fileName types.File) (Blob, BlobInfo, error)
+	GetObjectThumb(objectID types.ObjectID, size uint, writer io.Writer) error
+	GetObjectImage(objectID types.ObjectID, version int, fileName types.File, writer io.Writer) error
+	GetTexturePreview(objectID types.ObjectID, version int, fileName types.File, texture string, width, height uint, writer io.Writer) error
+	DedupReport() (DedupReport, error)
+
+	AddRating(userID types.UserID, objectID types.ObjectID, value float64) (bool, error)
+	RemoveRating(userID types.UserID, objectID types.ObjectID) error
+	ReconcileRatings(fix bool) ([]RatingDrift, error)
+	GetRatings(objectID types.ObjectID, page PageQuery) (RatingPage, error)
+
+	GetComments(objectID types.ObjectID, page PageQuery) (CommentPage, error)
+	AddComment(userID types.UserID, objectID types.ObjectID, content string) error
+	RemoveComment(commentID bson.ObjectId) error
+	GetComment(commentID bson.ObjectId) (types.Comment, error)
+	TrashComment(commentID bson.ObjectId) error
+	RestoreComment(commentID bson.ObjectId) error
+	PurgeComment(commentID bson.ObjectId, before time.Time) error
+
+	GetTrash() (Trash, error)
+
+	CreateCollection(collection types.Collection) error
+	UpdateCollection(collection types.Collection) error
+	DeleteCollection(collectionID types.CollectionID) error
+	GetCollection(collectionID types.CollectionID) (types.Collection, error)
+	GetUserCollections(userID types.UserID) ([]types.Collection, error)
+
+	CreateCategory(category types.Category) error
+	UpdateCategory(category types.Category) error
+	DeleteCategory(slug types.ObjectCategory) error
+	GetCategory(slug types.ObjectCategory) (types.Category, error)
+	GetCategories() ([]types.Category, error)
+	RemapCategory(from, to types.ObjectCategory, dryRun bool) (int, error)
+
+	CreateTagAlias(alias types.TagAlias) error
+	DeleteTagAlias(alias types.ObjectTag) error
+	GetTagAliases() ([]types.TagAlias, error)
+	GetTagCounts() (map[types.ObjectTag]int, error)
+}
+
+// ObjectQuery filters and orders a list of objects, empty fields match every object
+type ObjectQuery struct {
+	UserName types.UserName
+	Category types.ObjectCategory
+	Tags     []string // matches objects with any of the tags or their aliases
+	Search   string   // free text matched against names, tags and descriptions
+	Sort     string   // field name with an optional "-" prefix, "score" or "relevance"
+}
+
+// ErrNotFound is returned by every backend when a record does not exist, it is the same value
+// that mgo returns so callers only need to check for one error.
+var ErrNotFound = mgo.ErrNotFound
+
+var (
+	_ Storage = &Database{}
+	_ Storage = &SQL{}
+	_ Storage = &Memory{}
+)
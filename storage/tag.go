@@ -0,0 +1,186 @@
+package storage
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+
+	"github.com/Southclaws/samp-objects-api/types"
+)
+
+var (
+	// ErrTagAliasAlreadyExists indicates that the tag is already an alias of another tag
+	ErrTagAliasAlreadyExists = errors.New("tag alias already exists")
+
+	// ErrTagAliasChain indicates that an alias would point at another alias or that a tag which has
+	// aliases would become an alias itself, aliases are only one level deep
+	ErrTagAliasChain = errors.New("tag aliases can not be chained")
+)
+
+// TagCount is an entry in the tag registry, the number of listed objects using a tag or any of its
+// aliases
+type TagCount struct {
+	Tag     types.ObjectTag   `json:"tag"`
+	Count   int               `json:"count"`
+	Aliases []types.ObjectTag `json:"aliases,omitempty"`
+}
+
+// checkTagAlias ensures a new alias does not clash with the existing ones
+func checkTagAlias(aliases []types.TagAlias, alias types.TagAlias) error {
+	for _, existing := range aliases {
+		switch {
+		case existing.Alias == alias.Alias:
+			return ErrTagAliasAlreadyExists
+		case existing.Alias == alias.Tag, existing.Tag == alias.Alias:
+			return ErrTagAliasChain
+		}
+	}
+	return nil
+}
+
+// expandTags normalises the tags of a query and adds every tag that is an alias of, or is aliased
+// by, one of them
+func expandTags(aliases []types.TagAlias, tags []string) (expanded []string) {
+	canonical := make(map[types.ObjectTag]types.ObjectTag)
+	synonyms := make(map[types.ObjectTag][]types.ObjectTag)
+	for _, alias := range aliases {
+		canonical[alias.Alias] = alias.Tag
+		synonyms[alias.Tag] = append(synonyms[alias.Tag], alias.Alias)
+	}
+
+	seen := make(map[types.ObjectTag]bool)
+	add := func(tag types.ObjectTag) {
+		if tag != "" && !seen[tag] {
+			seen[tag] = true
+			expanded = append(expanded, string(tag))
+		}
+	}
+	for _, raw := range tags {
+		tag := types.ObjectTag(raw).Normalise()
+		if target, ok := canonical[tag]; ok {
+			tag = target
+		}
+		add(tag)
+		for _, synonym := range synonyms[tag] {
+			add(synonym)
+		}
+	}
+	return
+}
+
+// SuggestTags returns the most used tags starting with prefix for autocompletion, aliases are
+// counted towards the tag they point at and an alias that matches the prefix suggests its tag. A
+// limit of zero returns every match.
+func SuggestTags(store Storage, prefix string, limit int) (suggestions []TagCount, err error) {
+	aliases, err := store.GetTagAliases()
+	if err != nil {
+		return
+	}
+	counts, err := store.GetTagCounts()
+	if err != nil {
+		return
+	}
+
+	registry := make(map[types.ObjectTag]*TagCount)
+	entry := func(tag types.ObjectTag) *TagCount {
+		if registry[tag] == nil {
+			registry[tag] = &TagCount{Tag: tag}
+		}
+		return registry[tag]
+	}
+	canonical := make(map[types.ObjectTag]types.ObjectTag)
+	for _, alias := range aliases {
+		canonical[alias.Alias] = alias.Tag
+		tag := entry(alias.Tag)
+		tag.Aliases = append(tag.Aliases, alias.Alias)
+	}
+	for tag, count := range counts {
+		if target, ok := canonical[tag]; ok {
+			tag = target
+		}
+		entry(tag).Count += count
+	}
+
+	prefix = string(types.ObjectTag(prefix).Normalise())
+	suggestions = []TagCount{}
+	for _, tag := range registry {
+		if tag.Count == 0 {
+			continue
+		}
+		match := strings.HasPrefix(string(tag.Tag), prefix)
+		for _, alias := range tag.Aliases {
+			match = match || strings.HasPrefix(string(alias), prefix)
+		}
+		if match {
+			sort.Slice(tag.Aliases, func(i, j int) bool { return tag.Aliases[i] < tag.Aliases[j] })
+			suggestions = append(suggestions, *tag)
+		}
+	}
+	sort.Slice(suggestions, func(i, j int) bool {
+		if suggestions[i].Count != suggestions[j].Count {
+			return suggestions[i].Count > suggestions[j].Count
+		}
+		return suggestions[i].Tag < suggestions[j].Tag
+	})
+	if limit > 0 && len(suggestions) > limit {
+		suggestions = suggestions[:limit]
+	}
+	return
+}
+
+// CreateTagAlias makes a tag an alias of another
+func (db Database) CreateTagAlias(alias types.TagAlias) (err error) {
+	if err = alias.Validate(); err != nil {
+		return
+	}
+
+	aliases, err := db.GetTagAliases()
+	if err != nil {
+		return
+	}
+	if err = checkTagAlias(aliases, alias); err != nil {
+		return
+	}
+
+	err = db.tagAliases.Insert(alias)
+	if mgo.IsDup(err) {
+		return ErrTagAliasAlreadyExists
+	}
+	return
+}
+
+// DeleteTagAlias removes an alias so the tag stands on its own again
+func (db Database) DeleteTagAlias(alias types.ObjectTag) (err error) {
+	return db.tagAliases.Remove(bson.M{"alias": alias})
+}
+
+// GetTagAliases returns every tag alias sorted by alias
+func (db Database) GetTagAliases() (aliases []types.TagAlias, err error) {
+	err = db.tagAliases.Find(nil).Sort("alias").All(&aliases)
+	return
+}
+
+// GetTagCounts returns how many listed objects use each tag
+func (db Database) GetTagCounts() (counts map[types.ObjectTag]int, err error) {
+	var results []struct {
+		Tag   types.ObjectTag `bson:"_id"`
+		Count int             `bson:"count"`
+	}
+	err = db.objects.Pipe([]bson.M{
+		{"$match": bson.M{"deleted": notTrashed}},
+		{"$unwind": "$tags"},
+		{"$group": bson.M{"_id": "$tags", "count": bson.M{"$sum": 1}}},
+	}).All(&results)
+	if err != nil {
+		return
+	}
+
+	counts = make(map[types.ObjectTag]int)
+	for _, result := range results {
+		counts[result.Tag] = result.Count
+	}
+	return
+}
@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Southclaws/samp-objects-api/types"
+)
+
+func TestNormaliseTags(t *testing.T) {
+	assert.Equal(t, []types.ObjectTag{"street-props", "vehicle"},
+		types.NormaliseTags([]types.ObjectTag{" Street Props", "street_props", "VEHICLE", "  ", "street--props"}))
+}
+
+func Test_expandTags(t *testing.T) {
+	aliases := []types.TagAlias{{Alias: "car", Tag: "vehicle"}, {Alias: "auto", Tag: "vehicle"}}
+	assert.Equal(t, []string{"vehicle", "car", "auto"}, expandTags(aliases, []string{"Car"}))
+	assert.Equal(t, []string{"vehicle", "car", "auto", "tree"}, expandTags(aliases, []string{"vehicle", "auto", "Tree"}))
+	assert.Nil(t, expandTags(aliases, []string{" "}))
+}
+
+func TestDatabase_Tags(t *testing.T) {
+	objects := []types.Object{
+		{ID: "00000000-0000-0000-0000-a00000000000", OwnerID: "00000003-0000-0000-0000-000000000000", OwnerName: "owner3", Name: "taga", Tags: []types.ObjectTag{"Vehicle", "Tagtest Red"}},
+		{ID: "00000000-0000-0000-0000-a10000000000", OwnerID: "00000003-0000-0000-0000-000000000000", OwnerName: "owner3", Name: "tagb", Tags: []types.ObjectTag{"car", "tagtest_red"}},
+		{ID: "00000000-0000-0000-0000-a20000000000", OwnerID: "00000003-0000-0000-0000-000000000000", OwnerName: "owner3", Name: "tagc", Tags: []types.ObjectTag{"CAR", "tagtest-blue"}},
+	}
+	for _, object := range objects {
+		object.Category = "tagtest"
+		object.Description = "tagged"
+		object.Images = []types.File{"image.jpg"}
+		object.Models = []types.File{"model.dff"}
+		object.Textures = []types.File{"texture.txd"}
+		assert.NoError(t, db.CreateObject(object))
+	}
+
+	object, err := db.GetObject(objects[0].ID)
+	assert.NoError(t, err)
+	assert.Equal(t, []types.ObjectTag{"vehicle", "tagtest-red"}, object.Tags)
+
+	page, err := db.GetObjects(ObjectQuery{Category: "tagtest", Tags: []string{"Vehicle"}}, PageQuery{})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, page.Total)
+
+	assert.NoError(t, db.CreateTagAlias(types.TagAlias{Alias: "car", Tag: "vehicle"}))
+	assert.Equal(t, ErrTagAliasAlreadyExists, db.CreateTagAlias(types.TagAlias{Alias: "car", Tag: "automobile"}))
+	assert.Equal(t, ErrTagAliasChain, db.CreateTagAlias(types.TagAlias{Alias: "vehicle", Tag: "transport"}))
+	assert.Equal(t, ErrTagAliasChain, db.CreateTagAlias(types.TagAlias{Alias: "auto", Tag: "car"}))
+	assert.Error(t, db.CreateTagAlias(types.TagAlias{Alias: "Car", Tag: "vehicle"}))
+
+	page, err = db.GetObjects(ObjectQuery{Category: "tagtest", Tags: []string{"vehicle"}}, PageQuery{})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, page.Total)
+	page, err = db.GetObjects(ObjectQuery{Category: "tagtest", Tags: []string{"car"}}, PageQuery{})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, page.Total)
+
+	suggestions, err := SuggestTags(db, "tagtest", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []TagCount{{"tagtest-red", 2, nil}, {"tagtest-blue", 1, nil}}, suggestions)
+
+	suggestions, err = SuggestTags(db, "ca", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []TagCount{{"vehicle", 3, []types.ObjectTag{"car"}}}, suggestions)
+
+	aliases, err := db.GetTagAliases()
+	assert.NoError(t, err)
+	assert.Equal(t, []types.TagAlias{{Alias: "car", Tag: "vehicle"}}, aliases)
+
+	assert.NoError(t, db.DeleteTagAlias("car"))
+	assert.Equal(t, ErrNotFound, db.DeleteTagAlias("car"))
+
+	page, err = db.GetObjects(ObjectQuery{Category: "tagtest", Tags: []string{"vehicle"}}, PageQuery{})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, page.Total)
+
+	for _, object := range objects {
+		assert.NoError(t, db.DeleteObject(object.ID))
+	}
+}
@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+	"path"
+
+	// decoders for the image types that can be uploaded
+	_ "image/gif"
+	_ "image/png"
+
+	"github.com/nfnt/resize"
+	"github.com/pkg/errors"
+
+	"github.com/Southclaws/samp-objects-api/types"
+)
+
+// ThumbnailSizes are the sizes, in pixels along the longest side, that thumbnails can be requested
+// at. Only these are allowed so the cache can't be filled with every possible size.
+var ThumbnailSizes = []uint{100, 200, 400, 800}
+
+// DefaultThumbnailSize is the size of a thumbnail when none is requested
+const DefaultThumbnailSize = 200
+
+// ErrThumbnailSize is returned when a thumbnail is requested at a size that isn't allowed
+var ErrThumbnailSize = errors.Errorf("thumbnail size must be one of %v", ThumbnailSizes)
+
+// thumbKey returns the blob key a thumbnail is cached under, thumbnails are stored next to the
+// image they came from like texture previews. Images are stored by their contents so replacing an
+// object's first image moves its thumbnails to a new key and the old ones are never served again.
+func thumbKey(key string, size uint) string {
+	return path.Join("thumbs", key, fmt.Sprintf("%d.jpg", size))
+}
+
+// getObjectThumb writes a thumbnail of the first image of object to writer, thumbnails are cached
+// in the blob store the first time they are made
+func getObjectThumb(blobs BlobStore, object types.Object, size uint, writer io.Writer) (err error) {
+	if !ThumbnailSizeAllowed(size) {
+		return ErrThumbnailSize
+	}
+	if len(object.Images) == 0 {
+		return errors.New("object has no images")
+	}
+	key := fileKey(object, object.Images[0])
+
+	if cached, err := blobs.Get(thumbKey(key, size)); err == nil {
+		defer cached.Close()
+		_, err = io.Copy(writer, cached)
+		return err
+	}
+
+	blob, err := blobs.Get(key)
+	if err != nil {
+		err = errors.Wrap(err, "failed to get file from object store")
+		return
+	}
+	defer blob.Close()
+
+	var buf bytes.Buffer
+	err = writeThumbnail(blob, size, &buf)
+	if err != nil {
+		return
+	}
+
+	err = blobs.Put(thumbKey(key, size), bytes.NewReader(buf.Bytes()), "image/jpeg")
+	if err != nil {
+		return errors.Wrap(err, "failed to cache thumbnail")
+	}
+
+	_, err = buf.WriteTo(writer)
+	return
+}
+
+func writeThumbnail(reader io.Reader, size uint, writer io.Writer) (err error) {
+	img, _, err := image.Decode(reader)
+	if err != nil {
+		return errors.Wrap(err, "failed to decode stored image")
+	}
+	err = jpeg.Encode(writer, resize.Thumbnail(size, size, img, resize.Bilinear), &jpeg.Options{Quality: 64})
+
+	return
+}
+
+// ThumbnailSizeAllowed reports whether size is one of ThumbnailSizes
+func ThumbnailSizeAllowed(size uint) bool {
+	for _, allowed := range ThumbnailSizes {
+		if size == allowed {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Southclaws/samp-objects-api/types"
+)
+
+func TestDatabase_Thumbnails(t *testing.T) {
+	objectID := types.ObjectID("00000000-0000-0000-0000-d00000000000")
+
+	var source bytes.Buffer
+	assert.NoError(t, png.Encode(&source, image.NewNRGBA(image.Rect(0, 0, 1000, 500))))
+	img, err := db.PutObjectFile(objectID, "image.png", "image/png", bytes.NewReader(source.Bytes()))
+	assert.NoError(t, err)
+
+	object := types.Object{
+		ID:        objectID,
+		OwnerID:   "00000003-0000-0000-0000-000000000000",
+		OwnerName: "owner3",
+		Name:      "thumbnailed",
+		Category:  "category1",
+		Images:    []types.File{"image.png"},
+		Models:    []types.File{"model.dff"},
+		Textures:  []types.File{"texture.txd"},
+		Files:     []types.FileInfo{img},
+	}
+	assert.NoError(t, db.CreateObject(object))
+
+	for _, size := range []uint{200, 400} {
+		for i := 0; i < 2; i++ { // the second request is served from the cache
+			var buf bytes.Buffer
+			assert.NoError(t, db.GetObjectThumb(objectID, size, &buf))
+			thumb, err := jpeg.Decode(&buf)
+			assert.NoError(t, err)
+			assert.Equal(t, image.Rect(0, 0, int(size), int(size)/2), thumb.Bounds())
+		}
+	}
+	assert.Equal(t, ErrThumbnailSize, db.GetObjectThumb(objectID, 300, ioutil.Discard))
+
+	assert.NoError(t, db.DeleteObject(objectID))
+}
@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/mgo.v2/bson"
+
+	"github.com/Southclaws/samp-objects-api/types"
+)
+
+// Trash holds every soft deleted record, trashed records are hidden from listings and lookups by
+// name but can be restored until they are purged
+type Trash struct {
+	Objects  []types.Object  `json:"objects"`
+	Comments []types.Comment `json:"comments"`
+	Users    []types.User    `json:"users"`
+}
+
+var (
+	// notTrashed matches MongoDB documents that have not been soft deleted
+	notTrashed = bson.M{"$exists": false}
+
+	// trashed matches MongoDB documents that have been soft deleted
+	trashed = bson.M{"$exists": true}
+)
+
+// PurgeTrash permanently deletes everything that was trashed at or before the given time, including
+// the files of purged objects, and returns what was removed. Anything restored after the trash was
+// listed is left alone.
+func PurgeTrash(store Storage, before time.Time) (purged Trash, err error) {
+	trash, err := store.GetTrash()
+	if err != nil {
+		return purged, errors.Wrap(err, "failed to get trash")
+	}
+
+	for _, object := range trash.Objects {
+		if !trashedBefore(object.Deleted, before) {
+			continue
+		}
+		err = store.PurgeObject(object.ID, before)
+		if err == ErrNotFound {
+			continue
+		} else if err != nil {
+			return purged, errors.Wrapf(err, "failed to purge object %s", object.ID)
+		}
+		purged.Objects = append(purged.Objects, object)
+	}
+
+	for _, comment := range trash.Comments {
+		if !trashedBefore(comment.Deleted, before) {
+			continue
+		}
+		err = store.PurgeComment(comment.ID, before)
+		if err == ErrNotFound {
+			continue
+		} else if err != nil {
+			return purged, errors.Wrapf(err, "failed to purge comment %s", comment.ID.Hex())
+		}
+		purged.Comments = append(purged.Comments, comment)
+	}
+
+	for _, user := range trash.Users {
+		if !trashedBefore(user.Deleted, before) {
+			continue
+		}
+		err = store.PurgeUser(user.ID, before)
+		if err == ErrNotFound {
+			continue
+		} else if err != nil {
+			return purged, errors.Wrapf(err, "failed to purge user %s", user.ID)
+		}
+		purged.Users = append(purged.Users, user)
+	}
+
+	return
+}
+
+// trashedBefore reports whether a record was trashed at or before the given time
+func trashedBefore(deleted *time.Time, before time.Time) bool {
+	return deleted != nil && !deleted.After(before)
+}
+
+// GetTrash returns every trashed object, comment and user
+func (db Database) GetTrash() (trash Trash, err error) {
+	err = db.objects.Find(bson.M{"deleted": trashed}).All(&trash.Objects)
+	if err != nil {
+		return
+	}
+	err = db.comments.Find(bson.M{"deleted": trashed}).All(&trash.Comments)
+	if err != nil {
+		return
+	}
+	err = db.users.Find(bson.M{"deleted": trashed}).All(&trash.Users)
+	return
+}
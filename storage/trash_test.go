@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Southclaws/samp-objects-api/types"
+)
+
+func TestDatabase_Trash(t *testing.T) {
+	user := types.User{ID: "00000000-0000-0000-0000-500000000000", Name: "trashed", Email: "trashed", Password: "pass"}
+	object := types.Object{
+		ID:        "00000000-0000-0000-0000-500000000000",
+		OwnerID:   user.ID,
+		OwnerName: user.Name,
+		Name:      "trashed",
+		Category:  "category1",
+		Images:    []types.File{"image.jpg"},
+		Models:    []types.File{"model.dff"},
+		Textures:  []types.File{"texture.txd"},
+	}
+	assert.NoError(t, db.CreateUser(user))
+	assert.NoError(t, db.CreateObject(object))
+	assert.NoError(t, db.AddComment(user.ID, object.ID, "trashed"))
+
+	comments, err := db.GetComments(object.ID, PageQuery{})
+	assert.NoError(t, err)
+	assert.Len(t, comments.Comments, 1)
+	comment := comments.Comments[0]
+
+	trash := func() {
+		assert.NoError(t, db.TrashUser(user.ID))
+		assert.NoError(t, db.TrashObject(object.ID))
+		assert.NoError(t, db.TrashComment(comment.ID))
+	}
+	trash()
+
+	assert.Error(t, db.TrashObject(object.ID), "already trashed")
+	_, exists, err := db.GetUserByName(user.Name)
+	assert.NoError(t, err)
+	assert.False(t, exists)
+	_, err = db.GetUserObject(user.Name, object.Name)
+	assert.Error(t, err)
+	objects, err := db.GetObjects(ObjectQuery{UserName: user.Name}, PageQuery{})
+	assert.NoError(t, err)
+	assert.Empty(t, objects.Objects)
+	comments, err = db.GetComments(object.ID, PageQuery{})
+	assert.NoError(t, err)
+	assert.Empty(t, comments.Comments)
+
+	got, err := db.GetObject(object.ID)
+	assert.NoError(t, err)
+	assert.NotNil(t, got.Deleted)
+	gotComment, err := db.GetComment(comment.ID)
+	assert.NoError(t, err)
+	assert.NotNil(t, gotComment.Deleted)
+
+	contents, err := db.GetTrash()
+	assert.NoError(t, err)
+	assert.Len(t, contents.Users, 1)
+	assert.Len(t, contents.Objects, 1)
+	assert.Len(t, contents.Comments, 1)
+
+	assert.NoError(t, db.RestoreUser(user.ID))
+	assert.NoError(t, db.RestoreObject(object.ID))
+	assert.NoError(t, db.RestoreComment(comment.ID))
+	assert.Error(t, db.RestoreObject(object.ID), "not trashed")
+
+	_, exists, err = db.GetUserByName(user.Name)
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	_, err = db.GetUserObject(user.Name, object.Name)
+	assert.NoError(t, err)
+	comments, err = db.GetComments(object.ID, PageQuery{})
+	assert.NoError(t, err)
+	assert.Len(t, comments.Comments, 1)
+
+	trash()
+
+	purged, err := PurgeTrash(db, time.Now().Add(-time.Minute))
+	assert.NoError(t, err)
+	assert.Empty(t, purged.Objects)
+
+	// records trashed after the cutoff or restored since the trash was listed are left alone
+	before := time.Now().Add(-time.Minute)
+	assert.Equal(t, ErrNotFound, db.PurgeUser(user.ID, before))
+	assert.Equal(t, ErrNotFound, db.PurgeObject(object.ID, before))
+	assert.Equal(t, ErrNotFound, db.PurgeComment(comment.ID, before))
+	assert.NoError(t, db.RestoreObject(object.ID))
+	assert.Equal(t, ErrNotFound, db.PurgeObject(object.ID, time.Now().Add(time.Minute)))
+	exists, err = db.ObjectExists(object.ID)
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	assert.NoError(t, db.TrashObject(object.ID))
+
+	purged, err = PurgeTrash(db, time.Now().Add(time.Minute))
+	assert.NoError(t, err)
+	assert.Len(t, purged.Users, 1)
+	assert.Len(t, purged.Objects, 1)
+	assert.Len(t, purged.Comments, 1)
+
+	exists, err = db.ObjectExists(object.ID)
+	assert.NoError(t, err)
+	assert.False(t, exists)
+	contents, err = db.GetTrash()
+	assert.NoError(t, err)
+	assert.Empty(t, contents.Objects)
+}
@@ -2,6 +2,7 @@ package storage
 
 import (
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	"gopkg.in/mgo.v2/bson"
@@ -59,6 +60,37 @@ func (db Database) DeleteUser(userID types.UserID) (err error) {
 	return
 }
 
+// PurgeUser deletes a trashed user's account if it was trashed at or before the given time
+func (db Database) PurgeUser(userID types.UserID, before time.Time) (err error) {
+	if err = userID.Validate(); err != nil {
+		return
+	}
+
+	return db.users.Remove(bson.M{"id": userID, "deleted": bson.M{"$lte": before}})
+}
+
+// TrashUser soft deletes a user's account, the name and email stay reserved until it is purged
+func (db Database) TrashUser(userID types.UserID) (err error) {
+	if err = userID.Validate(); err != nil {
+		return
+	}
+
+	return db.users.Update(
+		bson.M{"id": userID, "deleted": notTrashed},
+		bson.M{"$set": bson.M{"deleted": time.Now()}})
+}
+
+// RestoreUser takes a user's account back out of the trash
+func (db Database) RestoreUser(userID types.UserID) (err error) {
+	if err = userID.Validate(); err != nil {
+		return
+	}
+
+	return db.users.Update(
+		bson.M{"id": userID, "deleted": trashed},
+		bson.M{"$unset": bson.M{"deleted": ""}})
+}
+
 // GetUser returns a types.User by their unique ID
 func (db Database) GetUser(userID types.UserID) (user types.User, exists bool, err error) {
 	if err = userID.Validate(); err != nil {
@@ -86,7 +118,7 @@ func (db Database) GetUserByName(userName types.UserName) (user types.User, exis
 		return
 	}
 
-	err = db.users.Find(bson.M{"name": userName}).One(&user)
+	err = db.users.Find(bson.M{"name": userName, "deleted": notTrashed}).One(&user)
 	if err != nil {
 		if err.Error() == "not found" {
 			err = nil
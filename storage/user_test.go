@@ -21,20 +21,20 @@ func TestDatabase_CreateUser(t *testing.T) {
 		args    args
 		wantErr bool
 	}{
-		{"v user1", args{types.User{"10000000-0000-0000-0000-000000000000", "user1", "mail1", "pass1"}}, false},
-		{"v user2", args{types.User{"20000000-0000-0000-0000-000000000000", "user2", "mail2", "pass2"}}, false},
-		{"v user3", args{types.User{"30000000-0000-0000-0000-000000000000", "user3", "mail3", "pass3"}}, false},
+		{"v user1", args{types.User{ID: "10000000-0000-0000-0000-000000000000", Name: "user1", Email: "mail1", Password: "pass1"}}, false},
+		{"v user2", args{types.User{ID: "20000000-0000-0000-0000-000000000000", Name: "user2", Email: "mail2", Password: "pass2"}}, false},
+		{"v user3", args{types.User{ID: "30000000-0000-0000-0000-000000000000", Name: "user3", Email: "mail3", Password: "pass3"}}, false},
 
 		// already used name
-		{"i user1 again", args{types.User{"40000000-0000-0000-0000-000000000000", "user1", "mail4", "pass4"}}, true},
+		{"i user1 again", args{types.User{ID: "40000000-0000-0000-0000-000000000000", Name: "user1", Email: "mail4", Password: "pass4"}}, true},
 
 		// already used mail
-		{"i user5", args{types.User{"50000000-0000-0000-0000-000000000000", "user5", "mail3", "pass5"}}, true},
+		{"i user5", args{types.User{ID: "50000000-0000-0000-0000-000000000000", Name: "user5", Email: "mail3", Password: "pass5"}}, true},
 
 		// invalid fielss
-		{"i user6", args{types.User{"60000000-0000-0000-0000-000000000000", "", "mail6", "pass6"}}, true},
-		{"i user7", args{types.User{"70000000-0000-0000-0000-000000000000", "user7", "", "pass7"}}, true},
-		{"i user8", args{types.User{"80000000-0000-0000-0000-000000000000", "user8", "mail8", ""}}, true},
+		{"i user6", args{types.User{ID: "60000000-0000-0000-0000-000000000000", Name: "", Email: "mail6", Password: "pass6"}}, true},
+		{"i user7", args{types.User{ID: "70000000-0000-0000-0000-000000000000", Name: "user7", Email: "", Password: "pass7"}}, true},
+		{"i user8", args{types.User{ID: "80000000-0000-0000-0000-000000000000", Name: "user8", Email: "mail8", Password: ""}}, true},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -59,10 +59,10 @@ func TestDatabase_UpdateUser(t *testing.T) {
 		args    args
 		wantErr bool
 	}{
-		{"v user1", args{types.User{"10000000-0000-0000-0000-000000000000", "user1", "mail1", "pass1new"}}, false},
-		{"v user2", args{types.User{"20000000-0000-0000-0000-000000000000", "user2", "mail2new", "pass2"}}, false},
-		{"v user3", args{types.User{"30000000-0000-0000-0000-000000000000", "user3new", "mail3", "pass3"}}, false},
-		{"i id", args{types.User{"01000000-0000-0000-0000-000000000000", "user4", "mail4", "pass4"}}, true},
+		{"v user1", args{types.User{ID: "10000000-0000-0000-0000-000000000000", Name: "user1", Email: "mail1", Password: "pass1new"}}, false},
+		{"v user2", args{types.User{ID: "20000000-0000-0000-0000-000000000000", Name: "user2", Email: "mail2new", Password: "pass2"}}, false},
+		{"v user3", args{types.User{ID: "30000000-0000-0000-0000-000000000000", Name: "user3new", Email: "mail3", Password: "pass3"}}, false},
+		{"i id", args{types.User{ID: "01000000-0000-0000-0000-000000000000", Name: "user4", Email: "mail4", Password: "pass4"}}, true},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -116,8 +116,8 @@ func TestDatabase_GetUser(t *testing.T) {
 		wantErr    bool
 	}{
 		{"i user1", args{types.UserID("10000000-0000-0000-0000-000000000000")}, types.User{}, false, false},
-		{"v user2", args{types.UserID("20000000-0000-0000-0000-000000000000")}, types.User{"20000000-0000-0000-0000-000000000000", "user2", "mail2new", "pass2"}, true, false},
-		{"v user3", args{types.UserID("30000000-0000-0000-0000-000000000000")}, types.User{"30000000-0000-0000-0000-000000000000", "user3new", "mail3", "pass3"}, true, false},
+		{"v user2", args{types.UserID("20000000-0000-0000-0000-000000000000")}, types.User{ID: "20000000-0000-0000-0000-000000000000", Name: "user2", Email: "mail2new", Password: "pass2"}, true, false},
+		{"v user3", args{types.UserID("30000000-0000-0000-0000-000000000000")}, types.User{ID: "30000000-0000-0000-0000-000000000000", Name: "user3new", Email: "mail3", Password: "pass3"}, true, false},
 		{"i user4", args{types.UserID("40000000-0000-0000-0000-000000000000")}, types.User{}, false, false},
 	}
 	for _, tt := range tests {
@@ -151,8 +151,8 @@ func TestDatabase_GetUserByName(t *testing.T) {
 		wantErr    bool
 	}{
 		{"i user1", args{types.UserName("user1")}, types.User{}, false, false},
-		{"v user2", args{types.UserName("user2")}, types.User{"20000000-0000-0000-0000-000000000000", "user2", "mail2new", "pass2"}, true, false},
-		{"v user3", args{types.UserName("user3new")}, types.User{"30000000-0000-0000-0000-000000000000", "user3new", "mail3", "pass3"}, true, false},
+		{"v user2", args{types.UserName("user2")}, types.User{ID: "20000000-0000-0000-0000-000000000000", Name: "user2", Email: "mail2new", Password: "pass2"}, true, false},
+		{"v user3", args{types.UserName("user3new")}, types.User{ID: "30000000-0000-0000-0000-000000000000", Name: "user3new", Email: "mail3", Password: "pass3"}, true, false},
 		{"i user3", args{types.UserName("user3")}, types.User{}, false, false},
 		{"i user4", args{types.UserName("user4")}, types.User{}, false, false},
 		{"i blank", args{types.UserName("")}, types.User{}, false, true},
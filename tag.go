@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+
+	"github.com/Southclaws/samp-objects-api/storage"
+	"github.com/Southclaws/samp-objects-api/types"
+)
+
+const (
+	defaultTagLimit = 10
+	maxTagLimit     = 100
+)
+
+// TagList handles the /tags endpoint, it returns the most used tags starting with the prefix
+// parameter for autocompletion along with their usage counts and aliases
+func (app *App) TagList(w http.ResponseWriter, r *http.Request) {
+	limit := defaultTagLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		var err error
+		limit, err = strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > maxTagLimit {
+			WriteResponseError(w, http.StatusBadRequest, errors.Errorf("limit must be between 1 and %d", maxTagLimit))
+			return
+		}
+	}
+
+	tags, err := storage.SuggestTags(app.Storage, r.URL.Query().Get("prefix"), limit)
+	if err != nil {
+		WriteResponseError(w, http.StatusInternalServerError, errors.Wrap(err, "failed to get tags"))
+		return
+	}
+
+	payload, err := json.Marshal(tags)
+	if err != nil {
+		WriteResponseError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(payload)
+}
+
+// AdminTagAliasList handles the /admin/tags/aliases endpoint, it lists every tag alias
+func (app *App) AdminTagAliasList(w http.ResponseWriter, r *http.Request) {
+	aliases, err := app.Storage.GetTagAliases()
+	if err != nil {
+		WriteResponseError(w, http.StatusInternalServerError, errors.Wrap(err, "failed to get tag aliases"))
+		return
+	}
+	if aliases == nil {
+		aliases = []types.TagAlias{}
+	}
+
+	payload, err := json.Marshal(aliases)
+	if err != nil {
+		WriteResponseError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(payload)
+}
+
+// AdminTagAliasCreate handles the POST /admin/tags/aliases endpoint, it makes a tag an alias of
+// another so filtering by either finds objects tagged with both
+func (app *App) AdminTagAliasCreate(w http.ResponseWriter, r *http.Request) {
+	var alias types.TagAlias
+	err := readJSON(r, &alias)
+	if err != nil {
+		WriteResponseError(w, http.StatusBadRequest, err)
+		return
+	}
+	alias.Alias = alias.Alias.Normalise()
+	alias.Tag = alias.Tag.Normalise()
+	if err = alias.Validate(); err != nil {
+		WriteResponseError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	err = app.Storage.CreateTagAlias(alias)
+	if err != nil {
+		switch err {
+		case storage.ErrTagAliasAlreadyExists, storage.ErrTagAliasChain:
+			WriteResponseError(w, http.StatusConflict, err)
+		default:
+			WriteResponseError(w, http.StatusInternalServerError, errors.Wrap(err, "failed to create tag alias"))
+		}
+		return
+	}
+
+	payload, err := json.Marshal(alias)
+	if err != nil {
+		WriteResponseError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	w.Write(payload)
+}
+
+// AdminTagAliasDelete handles the DELETE /admin/tags/aliases/{alias} endpoint
+func (app *App) AdminTagAliasDelete(w http.ResponseWriter, r *http.Request) {
+	err := app.Storage.DeleteTagAlias(types.ObjectTag(mux.Vars(r)["alias"]).Normalise())
+	if err != nil {
+		if err == storage.ErrNotFound {
+			WriteResponse(w, http.StatusNotFound, "tag alias not found")
+			return
+		}
+		WriteResponseError(w, http.StatusInternalServerError, errors.Wrap(err, "failed to delete tag alias"))
+		return
+	}
+
+	WriteResponse(w, http.StatusOK, "tag alias deleted")
+}
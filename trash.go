@@ -0,0 +1,96 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"github.com/Southclaws/samp-objects-api/storage"
+	"github.com/Southclaws/samp-objects-api/types"
+)
+
+// ObjectTrash handles the DELETE /object/{objectid} endpoint, it moves an object to the trash if
+// the requesting user owns it
+func (app *App) ObjectTrash(w http.ResponseWriter, r *http.Request) {
+	app.objectTrash(w, r, false)
+}
+
+// ObjectRestore handles the POST /object/{objectid}/restore endpoint, it takes an object back out
+// of the trash
+func (app *App) ObjectRestore(w http.ResponseWriter, r *http.Request) {
+	app.objectTrash(w, r, true)
+}
+
+func (app *App) objectTrash(w http.ResponseWriter, r *http.Request, restore bool) {
+	vars := mux.Vars(r)
+	objectID := types.ObjectID(vars["objectid"])
+	if err := objectID.Validate(); err != nil {
+		WriteResponseError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	user, status, err := app.SessionUser(r)
+	if err != nil {
+		WriteResponseError(w, status, err)
+		return
+	}
+
+	object, err := app.Storage.GetObject(objectID)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			WriteResponse(w, http.StatusNotFound, "object not found")
+			return
+		}
+		WriteResponseError(w, http.StatusInternalServerError, errors.Wrap(err, "failed to get object"))
+		return
+	}
+	if !app.canModerate(user, object.OwnerID) {
+		WriteResponse(w, http.StatusForbidden, "object belongs to another user")
+		return
+	}
+
+	if restore {
+		err = app.Storage.RestoreObject(objectID)
+	} else {
+		err = app.Storage.TrashObject(objectID)
+	}
+	if err != nil {
+		if err == storage.ErrNotFound {
+			WriteResponse(w, http.StatusConflict, "object is already in that state")
+			return
+		}
+		WriteResponseError(w, http.StatusInternalServerError, errors.Wrap(err, "failed to update object"))
+		return
+	}
+
+	WriteResponse(w, http.StatusOK, "object updated")
+}
+
+// TrashSweeper periodically purges everything that has been in the trash for longer than the
+// retention period, including the files of purged objects, until the app context is cancelled.
+func (app *App) TrashSweeper(interval, retention time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-app.ctx.Done():
+			return
+		case <-ticker.C:
+			purged, err := storage.PurgeTrash(app.Storage, time.Now().Add(-retention))
+			if err != nil {
+				logger.Error("failed to purge trash",
+					zap.Error(err))
+			}
+			if len(purged.Objects)+len(purged.Comments)+len(purged.Users) > 0 {
+				logger.Info("purged trash",
+					zap.Int("objects", len(purged.Objects)),
+					zap.Int("comments", len(purged.Comments)),
+					zap.Int("users", len(purged.Users)))
+			}
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package types
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Category is a managed object category, categories form a tree where each one names its parent by
+// slug and top level categories have no parent. Objects refer to categories by slug.
+type Category struct {
+	Slug   ObjectCategory `json:"slug"`
+	Name   string         `json:"name"`
+	Parent ObjectCategory `json:"parent,omitempty" bson:",omitempty"`
+}
+
+var (
+	// CategorySlugMatch is a regular expression used to validate category slugs
+	CategorySlugMatch = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+)
+
+// Slugify turns a category name into a slug, "Race Tracks" becomes "race-tracks"
+func Slugify(name string) ObjectCategory {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return r > unicode.MaxASCII || !(unicode.IsLetter(r) || unicode.IsDigit(r))
+	})
+	return ObjectCategory(strings.Join(words, "-"))
+}
+
+// Validate ensures all necessary fields are correct
+func (category Category) Validate() (err error) {
+	if err = category.Slug.Validate(); err != nil {
+		return
+	}
+	if category.Name == "" {
+		return errors.New("name is empty")
+	}
+	if category.Parent != "" {
+		if err = category.Parent.Validate(); err != nil {
+			return
+		}
+		if category.Parent == category.Slug {
+			return errors.New("category can not be its own parent")
+		}
+	}
+	return
+}
+
+// Validate checks if a category slug is valid
+func (slug ObjectCategory) Validate() (err error) {
+	if !CategorySlugMatch.MatchString(string(slug)) {
+		err = errors.New("category slug must be lowercase letters and digits separated by hyphens")
+	}
+	return
+}
@@ -0,0 +1,183 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// the range of model IDs that SA:MP allows for custom objects, IDs count down from the first
+const (
+	FirstModelID = -1000
+	LastModelID  = -30000
+)
+
+// CollectionID represents a collection's unique ID
+type CollectionID string
+
+// Collection is a set of objects that are installed on a server together. It owns a range of custom
+// model IDs and every model of its objects is assigned an ID from that range when the object is
+// added, which stays the same for as long as the object is in the collection and stays reserved
+// for it after it is removed until the IDs are released.
+type Collection struct {
+	ID      CollectionID      `json:"id"`
+	OwnerID UserID            `json:"owner_id"`
+	Name    string            `json:"name"`
+	FirstID int               `json:"first_model_id"` // the highest ID of the range, such as -1000
+	LastID  int               `json:"last_model_id"`  // the lowest ID of the range, such as -1999
+	Objects []ObjectID        `json:"objects"`
+	Models  []ModelAssignment `json:"models"`
+	Created time.Time         `json:"created"`
+
+	// Revision counts the updates to the collection, an update only succeeds if it was made to
+	// the latest revision so changes made at the same time aren't lost
+	Revision int `json:"revision"`
+}
+
+// ModelAssignment is the model ID given to one model of an object in a collection, Removed is set
+// once the object is removed from the collection and the ID is only reserved for it
+type ModelAssignment struct {
+	ModelID int      `json:"model_id"`
+	Object  ObjectID `json:"object_id"`
+	Model   File     `json:"model"`
+	Removed bool     `json:"removed,omitempty" bson:",omitempty"`
+}
+
+// Validate checks if a collection ID is valid
+func (id CollectionID) Validate() (err error) {
+	if !ObjectIDMatch.MatchString(string(id)) {
+		err = errors.New("id does not match pattern")
+	}
+	return
+}
+
+// Validate ensures all necessary fields are correct and that no model ID is assigned twice
+func (collection Collection) Validate() (err error) {
+	if err = collection.ID.Validate(); err != nil {
+		return
+	}
+	if collection.OwnerID == "" {
+		return errors.New("owner id is empty")
+	}
+	if strings.TrimSpace(collection.Name) == "" {
+		return errors.New("name is empty")
+	}
+	if collection.FirstID > FirstModelID || collection.LastID < LastModelID || collection.FirstID < collection.LastID {
+		return fmt.Errorf("model IDs must be a range from %d down to %d", FirstModelID, LastModelID)
+	}
+
+	objects := make(map[ObjectID]bool)
+	for _, id := range collection.Objects {
+		objects[id] = true
+	}
+	used := make(map[int]ModelAssignment)
+	for _, m := range collection.Models {
+		if m.ModelID > collection.FirstID || m.ModelID < collection.LastID {
+			return fmt.Errorf("model ID %d of %s is outside of the collection's range", m.ModelID, m.Model)
+		}
+		if other, ok := used[m.ModelID]; ok {
+			return fmt.Errorf("model ID %d is assigned to both %s and %s", m.ModelID, other.Model, m.Model)
+		}
+		if objects[m.Object] == m.Removed {
+			if m.Removed {
+				return fmt.Errorf("model %s is reserved for an object that is still in the collection", m.Model)
+			}
+			return fmt.Errorf("model %s belongs to an object that is not in the collection", m.Model)
+		}
+		used[m.ModelID] = m
+	}
+	return
+}
+
+// ModelID returns the model ID assigned to one of an object's models, IDs only reserved for a
+// removed object are not returned
+func (collection Collection) ModelID(objectID ObjectID, model File) (int, bool) {
+	for _, m := range collection.Models {
+		if m.Object == objectID && !m.Removed && strings.EqualFold(string(m.Model), string(model)) {
+			return m.ModelID, true
+		}
+	}
+	return 0, false
+}
+
+// Add puts an object in the collection and assigns the next free model IDs to any of its models
+// that don't have one yet, adding an object again picks up models from newer versions and adding
+// a removed object gives it back the IDs reserved for it. Models an object no longer has keep
+// their IDs so they aren't given to another object while servers may still have them installed.
+func (collection *Collection) Add(object Object) (assigned []ModelAssignment, err error) {
+	found := false
+	for _, id := range collection.Objects {
+		if id == object.ID {
+			found = true
+			break
+		}
+	}
+
+	updated := Collection{Models: make([]ModelAssignment, len(collection.Models))}
+	used := make(map[int]bool)
+	for i, m := range collection.Models {
+		if m.Object == object.ID {
+			m.Removed = false
+		}
+		updated.Models[i] = m
+		used[m.ModelID] = true
+	}
+	next := collection.FirstID
+	for _, model := range object.Models {
+		if _, ok := updated.ModelID(object.ID, model); ok {
+			continue
+		}
+		for used[next] {
+			next--
+		}
+		if next < collection.LastID {
+			return nil, fmt.Errorf("no free model IDs left between %d and %d", collection.FirstID, collection.LastID)
+		}
+		used[next] = true
+		assigned = append(assigned, ModelAssignment{ModelID: next, Object: object.ID, Model: model})
+	}
+
+	if !found {
+		collection.Objects = append(collection.Objects, object.ID)
+	}
+	collection.Models = append(updated.Models, assigned...)
+	return
+}
+
+// Remove takes an object out of the collection, the model IDs of its models stay reserved for it
+// so they aren't given to another object while servers may still have it installed
+func (collection *Collection) Remove(objectID ObjectID) bool {
+	var objects []ObjectID
+	for _, id := range collection.Objects {
+		if id != objectID {
+			objects = append(objects, id)
+		}
+	}
+	if len(objects) == len(collection.Objects) {
+		return false
+	}
+
+	for i := range collection.Models {
+		if collection.Models[i].Object == objectID {
+			collection.Models[i].Removed = true
+		}
+	}
+	collection.Objects = objects
+	return true
+}
+
+// Release frees the model IDs reserved for an object that was removed from the collection so they
+// can be given to objects added later, it returns false if there were none
+func (collection *Collection) Release(objectID ObjectID) bool {
+	var models []ModelAssignment
+	for _, m := range collection.Models {
+		if m.Object != objectID || !m.Removed {
+			models = append(models, m)
+		}
+	}
+
+	found := len(models) != len(collection.Models)
+	collection.Models = models
+	return found
+}
@@ -13,4 +13,5 @@ type Comment struct {
 	ObjectID ObjectID      `json:"object"`
 	Content  string        `json:"content"`
 	Date     time.Time     `json:"date"`
+	Deleted  *time.Time    `json:"deleted,omitempty" bson:",omitempty"` // set while in the trash
 }
@@ -0,0 +1,39 @@
+package types
+
+// ModelInfo is the metadata read from a DFF model when it is uploaded
+type ModelInfo struct {
+	Version    string          `json:"version"` // RenderWare library version, 3.6.0.3 for San Andreas
+	Frames     []ModelFrame    `json:"frames"`
+	Geometries int             `json:"geometries"`
+	Vertices   int             `json:"vertices"`
+	Triangles  int             `json:"triangles"`
+	Materials  []ModelMaterial `json:"materials"`
+	Textures   []string        `json:"textures"` // every texture the materials use, without duplicates
+	Collision  bool            `json:"collision"`
+}
+
+// ModelFrame is a node in a model's hierarchy, Parent is the index of the parent frame or -1
+type ModelFrame struct {
+	Name   string `json:"name"`
+	Parent int    `json:"parent"`
+}
+
+// ModelMaterial is a surface of a model, Texture is empty for untextured materials
+type ModelMaterial struct {
+	Texture string `json:"texture"`
+	Mask    string `json:"mask,omitempty"`
+}
+
+// TextureInfo describes one texture in a TXD file, Format is DXT1, DXT3 or DXT5 for compressed
+// textures, PAL4 or PAL8 for paletted textures or the pixel layout such as 8888. Size is the number
+// of bytes of pixel data in every mipmap level.
+type TextureInfo struct {
+	Name    string `json:"name"`
+	Mask    string `json:"mask,omitempty"`
+	Width   int    `json:"width"`
+	Height  int    `json:"height"`
+	Format  string `json:"format"`
+	MipMaps int    `json:"mipmaps"`
+	Alpha   bool   `json:"alpha"`
+	Size    int    `json:"size"`
+}
@@ -1,7 +1,9 @@
 package types
 
 import "errors"
+import "math"
 import "regexp"
+import "time"
 
 // ObjectID represents an object's unique ID
 type ObjectID string
@@ -24,31 +26,65 @@ type ObjectRateCount int
 // ObjectRateTotal represents the total sum of all ratings on an object
 type ObjectRateTotal float64
 
+// ObjectRateHistogram represents the number of ratings an object has received for each star
+// value, a rating is counted against the nearest whole star from 0 to 5
+type ObjectRateHistogram [6]int
+
 // File represents an object's content filename
 type File string
 
+// FileInfo holds the size and checksums of a stored file, the CRC32 (IEEE) is what the SA:MP client
+// uses to cache downloaded artwork and the SHA-256 is used for ETag and Digest headers.
+type FileInfo struct {
+	Name        File          `json:"name"`
+	Size        int64         `json:"size"`
+	CRC32       string        `json:"crc32"`
+	SHA256      string        `json:"sha256"`
+	ContentType string        `json:"content_type,omitempty" bson:",omitempty"` // unset for files from before it was recorded
+	Model       *ModelInfo    `json:"model,omitempty" bson:",omitempty"`        // only set for DFF files
+	Textures    []TextureInfo `json:"textures,omitempty" bson:",omitempty"`     // only set for TXD files
+}
+
+// ObjectVersion is a single release of an object's files, the newest version's files are also
+// kept on the object itself
+type ObjectVersion struct {
+	Version   int        `json:"version"`
+	Changelog string     `json:"changelog"`
+	Published time.Time  `json:"published"`
+	Images    []File     `json:"images"`
+	Models    []File     `json:"models"`
+	Textures  []File     `json:"textures"`
+	Files     []FileInfo `json:"files"`
+}
+
 // Object represents an object that a object has uploaded, it includes a hash of the file contents
 // and details such as name and owner.
 type Object struct {
-	ID          ObjectID          `json:"id"`
-	OwnerID     UserID            `json:"owner_id"`
-	OwnerName   UserName          `json:"owner_name"`
-	Name        ObjectName        `json:"name"`
-	Description ObjectDescription `json:"description"`
-	Category    ObjectCategory    `json:"category"`
-	Tags        []ObjectTag       `json:"tags"`
-	RateCount   ObjectRateCount   `json:"rate_count"`
-	RateTotal   ObjectRateTotal   `json:"rate_value"`
-	RateAverage float64           `json:"rate_average" bson:"-"` // not stored in db
-	Images      []File            `json:"images"`
-	Models      []File            `json:"models"`
-	Textures    []File            `json:"textures"`
+	ID            ObjectID            `json:"id"`
+	OwnerID       UserID              `json:"owner_id"`
+	OwnerName     UserName            `json:"owner_name"`
+	Name          ObjectName          `json:"name"`
+	Description   ObjectDescription   `json:"description"`
+	Category      ObjectCategory      `json:"category"`
+	Tags          []ObjectTag         `json:"tags"`
+	RateCount     ObjectRateCount     `json:"rate_count"`
+	RateTotal     ObjectRateTotal     `json:"rate_value"`
+	RateAverage   float64             `json:"rate_average" bson:"-"` // not stored in db
+	RateHistogram ObjectRateHistogram `json:"rate_histogram"`
+	Images        []File              `json:"images"`
+	Models        []File              `json:"models"`
+	Textures      []File              `json:"textures"`
+	Files         []FileInfo          `json:"files" bson:",omitempty"`
+	Versions      []ObjectVersion     `json:"versions" bson:",omitempty"`
+	Created       time.Time           `json:"created"`
+	Deleted       *time.Time          `json:"deleted,omitempty" bson:",omitempty"` // set while in the trash
 }
 
 // ObjectFile represents a single file the user uploaded
 type ObjectFile struct {
-	Name string `json:"name"`
-	Type string `json:"type"`
+	Name string   `json:"name"`
+	Type string   `json:"type"`
+	Info FileInfo `json:"info"`
 }
 
 // ObjectDFF represents a model file
@@ -105,6 +141,87 @@ func (object Object) Validate() (err error) {
 	return
 }
 
+// FileInfo returns the stored size and checksums of one of the object's files
+func (object Object) FileInfo(name File) (info FileInfo, ok bool) {
+	for _, info = range object.Files {
+		if info.Name == name {
+			return info, true
+		}
+	}
+	return FileInfo{}, false
+}
+
+// History returns every version of the object, oldest first. Objects uploaded before versioning
+// have no stored versions so their files are returned as an implicit version 1.
+func (object Object) History() []ObjectVersion {
+	if len(object.Versions) > 0 {
+		return object.Versions
+	}
+	return []ObjectVersion{{
+		Version:   1,
+		Published: object.Created,
+		Images:    object.Images,
+		Models:    object.Models,
+		Textures:  object.Textures,
+		Files:     object.Files,
+	}}
+}
+
+// AtVersion returns a copy of the object with the files of a specific version, 0 is the latest
+func (object Object) AtVersion(version int) (Object, bool) {
+	if version == 0 {
+		return object, true
+	}
+	for _, v := range object.History() {
+		if v.Version == version {
+			object.Images = v.Images
+			object.Models = v.Models
+			object.Textures = v.Textures
+			object.Files = v.Files
+			return object, true
+		}
+	}
+	return object, false
+}
+
+// PublishVersion adds a release as the newest version of the object and makes its files the
+// object's current files, the version number is assigned here
+func (object *Object) PublishVersion(version ObjectVersion) {
+	var history []ObjectVersion
+	if len(object.Versions) > 0 || len(object.Models) > 0 {
+		history = object.History()
+	}
+
+	version.Version = len(history) + 1
+	object.Versions = append(history, version)
+	object.Images = version.Images
+	object.Models = version.Models
+	object.Textures = version.Textures
+	object.Files = version.Files
+}
+
+// UpdateRateAverage calculates RateAverage from RateTotal and RateCount, the average is never
+// stored so this is called by storage whenever an object is read.
+func (object *Object) UpdateRateAverage() {
+	if object.RateCount <= 0 {
+		object.RateAverage = 0
+		return
+	}
+	object.RateAverage = float64(object.RateTotal) / float64(object.RateCount)
+}
+
+// RatingStar returns the histogram index for a rating value
+func RatingStar(value float64) int {
+	star := int(math.Floor(value + 0.5))
+	if star < 0 {
+		return 0
+	}
+	if star > 5 {
+		return 5
+	}
+	return star
+}
+
 // Validate checks if an object ID is valid
 func (objectid ObjectID) Validate() (err error) {
 	if !ObjectIDMatch.MatchString(string(objectid)) {
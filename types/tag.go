@@ -0,0 +1,56 @@
+package types
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+)
+
+// TagAlias makes one tag a synonym of another, objects tagged with the alias are found when
+// filtering by the tag and the other way around
+type TagAlias struct {
+	Alias ObjectTag `json:"alias"`
+	Tag   ObjectTag `json:"tag"`
+}
+
+// Normalise returns the form a tag is stored in, it is lowercased and runs of spaces, underscores
+// and hyphens become a single hyphen so "Street Props" and "street_props" are the same tag
+func (tag ObjectTag) Normalise() ObjectTag {
+	words := strings.FieldsFunc(strings.ToLower(string(tag)), func(r rune) bool {
+		return unicode.IsSpace(r) || r == '_' || r == '-'
+	})
+	return ObjectTag(strings.Join(words, "-"))
+}
+
+// NormaliseTags normalises a list of tags and removes empty and duplicate tags, keeping the order
+func NormaliseTags(tags []ObjectTag) (result []ObjectTag) {
+	if tags == nil {
+		return nil
+	}
+
+	result = make([]ObjectTag, 0, len(tags))
+	seen := make(map[ObjectTag]bool)
+	for _, tag := range tags {
+		tag = tag.Normalise()
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		result = append(result, tag)
+	}
+	return
+}
+
+// Validate ensures all necessary fields are correct, both tags must already be normalised
+func (alias TagAlias) Validate() (err error) {
+	if alias.Alias == "" || alias.Tag == "" {
+		return errors.New("alias and tag must not be empty")
+	}
+	if alias.Alias != alias.Alias.Normalise() || alias.Tag != alias.Tag.Normalise() {
+		return errors.New("alias and tag must be normalised")
+	}
+	if alias.Alias == alias.Tag {
+		return errors.New("tag can not be an alias of itself")
+	}
+	return
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"regexp"
 	"strings"
+	"time"
 )
 
 // UserID represents a user's unique ID
@@ -21,10 +22,11 @@ type UserPass string
 
 // User represents a user in the system, it contains their profile details and password hash
 type User struct {
-	ID       UserID    `json:"id,omitempty"`
-	Name     UserName  `json:"name,omitempty"`
-	Email    UserEmail `json:"email,omitempty"`
-	Password UserPass  `json:"password,omitempty"`
+	ID       UserID     `json:"id,omitempty"`
+	Name     UserName   `json:"name,omitempty"`
+	Email    UserEmail  `json:"email,omitempty"`
+	Password UserPass   `json:"password,omitempty"`
+	Deleted  *time.Time `json:"deleted,omitempty" bson:",omitempty"` // set while in the trash
 }
 
 var (
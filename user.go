@@ -6,6 +6,7 @@ import (
 
 	"github.com/gorilla/mux"
 
+	"github.com/Southclaws/samp-objects-api/storage"
 	"github.com/Southclaws/samp-objects-api/types"
 )
 
@@ -46,3 +47,24 @@ func (app *App) UserProfile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 }
+
+// UserObjects handles the /users/{username}/objects endpoint and returns a page of a user's objects,
+// newest first
+func (app *App) UserObjects(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	userName := types.UserName(vars["username"])
+
+	if err := userName.Validate(); err != nil {
+		WriteResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	page, err := pageQuery(r)
+	if err != nil {
+		WriteResponseError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	objects, err := app.Storage.GetObjects(storage.ObjectQuery{UserName: userName, Sort: "-_id"}, page)
+	writePage(w, objects, err)
+}
@@ -2,9 +2,14 @@ package main
 
 import (
 	"encoding/json"
+	"io/ioutil"
 	"net/http"
+	"strconv"
 
+	"github.com/pkg/errors"
 	"go.uber.org/zap"
+
+	"github.com/Southclaws/samp-objects-api/storage"
 )
 
 // just a collection of small utility/helper functions
@@ -75,3 +80,65 @@ func bodyAllowedForStatus(status int) bool {
 	}
 	return true
 }
+
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 200
+)
+
+// pageQuery reads the limit and cursor parameters of a paginated listing
+func pageQuery(r *http.Request) (page storage.PageQuery, err error) {
+	page.Limit = defaultPageLimit
+	page.Cursor = r.URL.Query().Get("cursor")
+
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		page.Limit, err = strconv.Atoi(raw)
+		if err != nil || page.Limit < 1 || page.Limit > maxPageLimit {
+			return page, errors.Errorf("limit must be between 1 and %d", maxPageLimit)
+		}
+	}
+	return
+}
+
+// writePage writes one page of a listing, an invalid cursor is the client's fault
+func writePage(w http.ResponseWriter, page interface{}, err error) {
+	if err != nil {
+		if err == storage.ErrInvalidCursor {
+			WriteResponseError(w, http.StatusBadRequest, err)
+			return
+		}
+		WriteResponseError(w, http.StatusInternalServerError, errors.Wrap(err, "failed to get listing"))
+		return
+	}
+
+	payload, err := json.Marshal(page)
+	if err != nil {
+		WriteResponseError(w, http.StatusInternalServerError, errors.Wrap(err, "failed to encode payload"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(payload)
+}
+
+// writeJSON writes v as a JSON response with the given status
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		WriteResponseError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(payload)
+}
+
+// readJSON decodes a JSON request body into v
+func readJSON(r *http.Request, v interface{}) error {
+	payload, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return errors.Wrap(err, "failed to read request body")
+	}
+	return errors.Wrap(json.Unmarshal(payload, v), "failed to decode request body")
+}